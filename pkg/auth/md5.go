@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"strings"
+)
+
+// md5Prefix starts MD5 hashed passwords as stored by PostgreSQL.
+const md5Prefix = "md5"
+
+// IsMD5Password returns true if the password is an MD5 hash ("md5" followed by 32 hex digits) rather than
+// a plain-text password.
+func IsMD5Password(password string) bool {
+	if len(password) != len(md5Prefix)+2*md5.Size || !strings.HasPrefix(password, md5Prefix) {
+		return false
+	}
+
+	_, err := hex.DecodeString(password[len(md5Prefix):])
+
+	return err == nil
+}
+
+// MD5Password returns MD5 hash of the user's plain-text password as stored by PostgreSQL.
+func MD5Password(user, password string) string {
+	return md5Prefix + md5Hex(password+user)
+}
+
+// MD5Response returns the response to AuthenticationMD5Password request with the given salt.
+// hashedPassword is the result of MD5Password.
+func MD5Response(hashedPassword string, salt [4]byte) string {
+	return md5Prefix + md5Hex(strings.TrimPrefix(hashedPassword, md5Prefix)+string(salt[:]))
+}
+
+// md5Hex returns hex-encoded MD5 digest of the string.
+func md5Hex(s string) string {
+	digest := md5.Sum([]byte(s)) //nolint:gosec
+	return hex.EncodeToString(digest[:])
+}
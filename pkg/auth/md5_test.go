@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMD5Password(t *testing.T) {
+	// SELECT 'md5' || md5('password' || 'postgres')
+	assert.Equal(t, "md532e12f215ba27cb750c9e093ce4b5127", MD5Password("postgres", "password"))
+
+	assert.True(t, IsMD5Password("md532e12f215ba27cb750c9e093ce4b5127"))
+	assert.False(t, IsMD5Password("md5password"))
+	assert.False(t, IsMD5Password("md532e12f215ba27cb750c9e093ce4b512z"))
+}
+
+func TestMD5Response(t *testing.T) {
+	salt := [4]byte{0x8f, 0x01, 0x2d, 0x4c}
+	expected := "md5" + md5Hex("32e12f215ba27cb750c9e093ce4b5127"+string(salt[:]))
+
+	assert.Equal(t, expected, MD5Response("md532e12f215ba27cb750c9e093ce4b5127", salt))
+	assert.Regexp(t, "^md5[0-9a-f]{32}$", expected)
+}
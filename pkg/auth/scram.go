@@ -0,0 +1,417 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SCRAMSHA256 is the name of the SASL mechanism implemented by SCRAMServer and SCRAMClient.
+const SCRAMSHA256 = "SCRAM-SHA-256"
+
+// Parameters of the secrets computed from plain-text passwords (the same as PostgreSQL uses).
+const (
+	scramIterations  = 4096
+	scramSaltLength  = 16
+	scramNonceLength = 18
+)
+
+var (
+	// ErrAuthenticationFailed is returned when the client's proof or the server's signature is wrong.
+	ErrAuthenticationFailed = errors.New("auth: authentication failed")
+
+	// ErrMalformedSCRAMMessage is returned when a SCRAM message cannot be parsed.
+	ErrMalformedSCRAMMessage = errors.New("auth: malformed SCRAM message")
+)
+
+// SCRAMSecret is the SCRAM-SHA-256 verifier of a password as stored by PostgreSQL:
+//
+//	SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>
+type SCRAMSecret struct {
+	Iterations int
+	Salt       []byte
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// IsSCRAMSecret returns true if the password is a SCRAM-SHA-256 secret rather than a plain-text password.
+func IsSCRAMSecret(password string) bool {
+	return strings.HasPrefix(password, SCRAMSHA256+"$")
+}
+
+// ParseSCRAMSecret parses SCRAM-SHA-256 secret in the PostgreSQL format.
+func ParseSCRAMSecret(secret string) (*SCRAMSecret, error) {
+	var iterations, salt, storedKey, serverKey string
+
+	parts := strings.Split(strings.TrimPrefix(secret, SCRAMSHA256+"$"), "$")
+
+	if !IsSCRAMSecret(secret) || len(parts) != 2 || !splitPair(parts[0], &iterations, &salt) ||
+		!splitPair(parts[1], &storedKey, &serverKey) {
+		return nil, fmt.Errorf("auth: invalid SCRAM secret")
+	}
+
+	s := &SCRAMSecret{}
+
+	var err error
+
+	s.Iterations, err = strconv.Atoi(iterations)
+
+	if err != nil || s.Iterations <= 0 {
+		return nil, fmt.Errorf("auth: invalid SCRAM secret iterations count %q", iterations)
+	}
+
+	for _, field := range []struct {
+		value   string
+		decoded *[]byte
+	}{{salt, &s.Salt}, {storedKey, &s.StoredKey}, {serverKey, &s.ServerKey}} {
+		*field.decoded, err = base64.StdEncoding.DecodeString(field.value)
+
+		if err != nil {
+			return nil, fmt.Errorf("auth: invalid SCRAM secret: %w", err)
+		}
+	}
+
+	if len(s.StoredKey) != sha256.Size || len(s.ServerKey) != sha256.Size {
+		return nil, fmt.Errorf("auth: invalid SCRAM secret key length")
+	}
+
+	return s, nil
+}
+
+// NewSCRAMSecret computes SCRAM-SHA-256 secret of the plain-text password with a random salt.
+// NB: the password is used as is (i.e., without SASLprep normalization), which is the same for ASCII passwords.
+func NewSCRAMSecret(password string) (*SCRAMSecret, error) {
+	salt := make([]byte, scramSaltLength)
+
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSCRAMSecretWithSalt(password, salt), nil
+}
+
+// NewSCRAMSecretWithSalt computes SCRAM-SHA-256 secret of the plain-text password with the given salt and
+// the default iterations count.
+func NewSCRAMSecretWithSalt(password string, salt []byte) *SCRAMSecret {
+	saltedPassword := scramSaltedPassword(password, salt, scramIterations)
+
+	return &SCRAMSecret{
+		Iterations: scramIterations,
+		Salt:       salt,
+		StoredKey:  scramHash(scramHMAC(saltedPassword, "Client Key")),
+		ServerKey:  scramHMAC(saltedPassword, "Server Key"),
+	}
+}
+
+// String returns the secret in the PostgreSQL format.
+func (s *SCRAMSecret) String() string {
+	enc := base64.StdEncoding
+
+	return fmt.Sprintf("%s$%d:%s$%s:%s", SCRAMSHA256, s.Iterations, enc.EncodeToString(s.Salt),
+		enc.EncodeToString(s.StoredKey), enc.EncodeToString(s.ServerKey))
+}
+
+// SCRAMServer authenticates a client with SCRAM-SHA-256 (RFC 5802, RFC 7677) without channel binding.
+type SCRAMServer struct {
+	// Secret of the user's password
+	secret *SCRAMSecret
+
+	// client-first-message-bare + "," + server-first-message
+	authMessage string
+
+	// Expected channel binding data (base64 encoded GS2 header of the client-first-message)
+	binding string
+
+	// Client and server nonces
+	nonce string
+}
+
+// NewSCRAMServer initializes a new SCRAMServer verifying the client's proof with the given secret.
+func NewSCRAMServer(secret *SCRAMSecret) *SCRAMServer {
+	return &SCRAMServer{secret: secret}
+}
+
+// First handles client-first-message and returns server-first-message.
+func (s *SCRAMServer) First(clientFirst []byte) ([]byte, error) {
+	// NB: the client doesn't support channel binding ("n") or thinks that the server doesn't ("y")
+	message := string(clientFirst)
+
+	if !strings.HasPrefix(message, "n,,") && !strings.HasPrefix(message, "y,,") {
+		return nil, fmt.Errorf("auth: unsupported SCRAM channel binding")
+	}
+
+	s.binding = base64.StdEncoding.EncodeToString(clientFirst[:3])
+
+	clientFirstBare := message[3:]
+	attrs, err := parseSCRAMAttributes(clientFirstBare)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientNonce, ok := attrs["r"]
+
+	if !ok || clientNonce == "" {
+		return nil, ErrMalformedSCRAMMessage
+	}
+
+	serverNonce, err := scramNonce()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.nonce = clientNonce + serverNonce
+
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d", s.nonce, base64.StdEncoding.EncodeToString(s.secret.Salt), s.secret.Iterations)
+	s.authMessage = clientFirstBare + "," + serverFirst
+
+	return []byte(serverFirst), nil
+}
+
+// Final handles client-final-message and returns server-final-message. It returns ErrAuthenticationFailed
+// if the client's proof is wrong.
+func (s *SCRAMServer) Final(clientFinal []byte) ([]byte, error) {
+	message := string(clientFinal)
+	proofIndex := strings.LastIndex(message, ",p=")
+
+	if s.nonce == "" || proofIndex < 0 {
+		return nil, ErrMalformedSCRAMMessage
+	}
+
+	withoutProof := message[:proofIndex]
+	attrs, err := parseSCRAMAttributes(withoutProof)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// NB: channel binding data must match the GS2 header of the client-first-message
+	if attrs["c"] != s.binding {
+		return nil, ErrAuthenticationFailed
+	}
+
+	if attrs["r"] != s.nonce {
+		return nil, ErrAuthenticationFailed
+	}
+
+	proof, err := base64.StdEncoding.DecodeString(message[proofIndex+3:])
+
+	if err != nil || len(proof) != sha256.Size {
+		return nil, ErrMalformedSCRAMMessage
+	}
+
+	authMessage := s.authMessage + "," + withoutProof
+	clientSignature := scramHMAC(s.secret.StoredKey, authMessage)
+
+	// ClientKey = ClientProof XOR ClientSignature
+	clientKey := make([]byte, len(proof))
+
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+
+	if !hmac.Equal(scramHash(clientKey), s.secret.StoredKey) {
+		return nil, ErrAuthenticationFailed
+	}
+
+	serverSignature := scramHMAC(s.secret.ServerKey, authMessage)
+
+	return []byte("v=" + base64.StdEncoding.EncodeToString(serverSignature)), nil
+}
+
+// SCRAMClient authenticates to a server with SCRAM-SHA-256 without channel binding.
+type SCRAMClient struct {
+	// Plain-text password
+	password string
+
+	// Client nonce
+	nonce string
+
+	// client-first-message-bare
+	clientFirstBare string
+
+	// Signature expected in server-final-message
+	serverSignature []byte
+}
+
+// NewSCRAMClient initializes a new SCRAMClient with the given password.
+func NewSCRAMClient(password string) *SCRAMClient {
+	return &SCRAMClient{password: password}
+}
+
+// First returns client-first-message.
+func (c *SCRAMClient) First() ([]byte, error) {
+	nonce, err := scramNonce()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.nonce = nonce
+
+	// NB: PostgreSQL ignores the user name in SCRAM messages and uses the one from the startup message
+	c.clientFirstBare = "n=,r=" + nonce
+
+	return []byte("n,," + c.clientFirstBare), nil
+}
+
+// Continue handles server-first-message and returns client-final-message.
+func (c *SCRAMClient) Continue(serverFirst []byte) ([]byte, error) {
+	attrs, err := parseSCRAMAttributes(string(serverFirst))
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := attrs["r"]
+
+	if c.nonce == "" || !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return nil, fmt.Errorf("auth: invalid SCRAM server nonce")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+
+	if err != nil {
+		return nil, ErrMalformedSCRAMMessage
+	}
+
+	iterations, err := strconv.Atoi(attrs["i"])
+
+	if err != nil || iterations <= 0 {
+		return nil, ErrMalformedSCRAMMessage
+	}
+
+	saltedPassword := scramSaltedPassword(c.password, salt, iterations)
+	clientKey := scramHMAC(saltedPassword, "Client Key")
+
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := c.clientFirstBare + "," + string(serverFirst) + "," + withoutProof
+	clientSignature := scramHMAC(scramHash(clientKey), authMessage)
+
+	// ClientProof = ClientKey XOR ClientSignature
+	proof := make([]byte, len(clientKey))
+
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	c.serverSignature = scramHMAC(scramHMAC(saltedPassword, "Server Key"), authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// Final verifies server-final-message. It returns ErrAuthenticationFailed if the server doesn't know the password.
+func (c *SCRAMClient) Final(serverFinal []byte) error {
+	attrs, err := parseSCRAMAttributes(string(serverFinal))
+
+	if err != nil {
+		return err
+	}
+
+	if serverError, ok := attrs["e"]; ok {
+		return fmt.Errorf("auth: SCRAM server error: %s", serverError)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+
+	if err != nil || c.serverSignature == nil {
+		return ErrMalformedSCRAMMessage
+	}
+
+	if !hmac.Equal(signature, c.serverSignature) {
+		return ErrAuthenticationFailed
+	}
+
+	return nil
+}
+
+// parseSCRAMAttributes parses comma separated attributes (e.g., "r=nonce,s=salt,i=4096").
+func parseSCRAMAttributes(message string) (map[string]string, error) {
+	attrs := make(map[string]string)
+
+	for _, attr := range strings.Split(message, ",") {
+		if len(attr) < 2 || attr[1] != '=' {
+			return nil, ErrMalformedSCRAMMessage
+		}
+
+		attrs[attr[:1]] = attr[2:]
+	}
+
+	return attrs, nil
+}
+
+// scramNonce generates a random printable nonce.
+func scramNonce() (string, error) {
+	nonce := make([]byte, scramNonceLength)
+
+	_, err := rand.Read(nonce)
+
+	if err != nil {
+		return "", err
+	}
+
+	// NB: base64 alphabet doesn't contain commas
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// scramSaltedPassword computes PBKDF2-HMAC-SHA256 of the password. The derived key has the size of
+// the hash, so a single PBKDF2 block is computed.
+func scramSaltedPassword(password string, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+
+	// U1 = HMAC(password, salt || INT(1))
+	mac.Write(salt)
+
+	_ = binary.Write(mac, binary.BigEndian, uint32(1))
+
+	u := mac.Sum(nil)
+	result := append([]byte{}, u...)
+
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+
+	return result
+}
+
+// scramHMAC computes HMAC-SHA256 of the message.
+func scramHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+
+	return mac.Sum(nil)
+}
+
+// scramHash computes SHA256 of the data.
+func scramHash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// splitPair splits "a:b" into a and b.
+func splitPair(s string, a, b *string) bool {
+	i := strings.IndexByte(s, ':')
+
+	if i < 0 {
+		return false
+	}
+
+	*a, *b = s[:i], s[i+1:]
+
+	return true
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SCRAM-SHA-256 exchange from RFC 7677 (user "user", password "pencil")
+const (
+	rfc7677ClientFirstBare = "n=user,r=rOprNGfwEbeRWgbNEkqO"
+	rfc7677ServerFirst     = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	rfc7677ClientFinal     = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	rfc7677ServerFinal     = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
+func TestSCRAMClient(t *testing.T) {
+	client := NewSCRAMClient("pencil")
+
+	first, err := client.First()
+	require.NoError(t, err)
+	assert.Regexp(t, `^n,,n=,r=[A-Za-z0-9+/=]+$`, string(first))
+
+	// Replay the RFC exchange
+	client.nonce, client.clientFirstBare = "rOprNGfwEbeRWgbNEkqO", rfc7677ClientFirstBare
+
+	final, err := client.Continue([]byte(rfc7677ServerFirst))
+	require.NoError(t, err)
+	assert.Equal(t, rfc7677ClientFinal, string(final))
+
+	assert.NoError(t, client.Final([]byte(rfc7677ServerFinal)))
+	assert.Equal(t, ErrAuthenticationFailed, client.Final([]byte("v=dGVzdA==")))
+	assert.Error(t, client.Final([]byte("e=invalid-proof")))
+
+	// Server nonce must extend the client nonce
+	_, err = client.Continue([]byte("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	assert.Error(t, err)
+}
+
+func TestSCRAMServer(t *testing.T) {
+	secret, err := NewSCRAMSecret("pencil")
+	require.NoError(t, err)
+
+	exchange := func(password string) error {
+		client := NewSCRAMClient(password)
+		server := NewSCRAMServer(secret)
+
+		clientFirst, err := client.First()
+		require.NoError(t, err)
+
+		serverFirst, err := server.First(clientFirst)
+		require.NoError(t, err)
+
+		clientFinal, err := client.Continue(serverFirst)
+		require.NoError(t, err)
+
+		serverFinal, err := server.Final(clientFinal)
+
+		if err != nil {
+			return err
+		}
+
+		return client.Final(serverFinal)
+	}
+
+	assert.NoError(t, exchange("pencil"))
+	assert.Equal(t, ErrAuthenticationFailed, exchange("pen"))
+
+	t.Run("RFC 7677 secret", func(t *testing.T) {
+		secret, err := ParseSCRAMSecret("SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==$" +
+			"WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU=")
+		require.NoError(t, err)
+
+		server := NewSCRAMServer(secret)
+
+		serverFirst, err := server.First([]byte("n,," + rfc7677ClientFirstBare))
+		require.NoError(t, err)
+		assert.Regexp(t, `^r=rOprNGfwEbeRWgbNEkqO[A-Za-z0-9+/=]+,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096$`, string(serverFirst))
+
+		// Proof is bound to the nonce
+		_, err = server.Final([]byte(rfc7677ClientFinal))
+		assert.Equal(t, ErrAuthenticationFailed, err)
+	})
+
+	t.Run("malformed messages", func(t *testing.T) {
+		server := NewSCRAMServer(secret)
+
+		_, err := server.Final([]byte(rfc7677ClientFinal))
+		assert.Error(t, err)
+
+		_, err = server.First([]byte("p=tls-server-end-point,,n=,r=nonce"))
+		assert.Error(t, err)
+
+		_, err = server.First([]byte("n,,n=,x"))
+		assert.Error(t, err)
+
+		_, err = server.First([]byte("n,,n="))
+		assert.Error(t, err)
+	})
+}
+
+func TestSCRAMSecret(t *testing.T) {
+	secret, err := NewSCRAMSecret("pencil")
+	require.NoError(t, err)
+
+	assert.Equal(t, 4096, secret.Iterations)
+	assert.Len(t, secret.Salt, 16)
+
+	// Round trip
+	assert.True(t, IsSCRAMSecret(secret.String()))
+
+	parsed, err := ParseSCRAMSecret(secret.String())
+	require.NoError(t, err)
+	assert.Equal(t, secret, parsed)
+
+	// The same salt gives the same secret
+	assert.Equal(t, secret, NewSCRAMSecretWithSalt("pencil", secret.Salt))
+
+	for _, invalid := range []string{
+		"pencil",
+		"SCRAM-SHA-256$4096:c2FsdA==",
+		"SCRAM-SHA-256$0:c2FsdA==$a2V5:a2V5",
+		"SCRAM-SHA-256$4096:c2FsdA==$a2V5:a2V5",
+		"SCRAM-SHA-256$4096:!!!$WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU=",
+	} {
+		_, err := ParseSCRAMSecret(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
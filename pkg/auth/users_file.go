@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// User is a user allowed to connect to Gevulot.
+type User struct {
+	// User name.
+	Name string
+
+	// Plain-text password, MD5 hash ("md5...") or SCRAM-SHA-256 secret ("SCRAM-SHA-256$...").
+	Password string
+}
+
+// ReadUsersFile reads users from the file in the PgBouncer userlist.txt format: every line contains
+// the quoted user name and password, quotes are escaped by doubling them, and lines starting with ";"
+// are comments:
+//
+//	"alice" "SCRAM-SHA-256$4096:...$...:..."
+//	"bob" "md5..."
+func ReadUsersFile(path string) ([]*User, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseUsers(file)
+}
+
+// ParseUsers reads users in the PgBouncer userlist.txt format from the reader.
+func ParseUsers(r io.Reader) ([]*User, error) {
+	var users []*User
+
+	scanner := bufio.NewScanner(r)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		name, rest, ok := readQuoted(line)
+
+		if !ok {
+			return nil, fmt.Errorf("auth: users file line %d: invalid user name", lineNumber)
+		}
+
+		// NB: the rest of the line is ignored, as PgBouncer does
+		password, _, ok := readQuoted(strings.TrimLeft(rest, " \t"))
+
+		if !ok || name == "" {
+			return nil, fmt.Errorf("auth: users file line %d: invalid password", lineNumber)
+		}
+
+		users = append(users, &User{Name: name, Password: password})
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// readQuoted reads a double quoted string from the beginning of s and returns it with the rest of s.
+func readQuoted(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+
+	var value strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		if s[i] != '"' {
+			value.WriteByte(s[i])
+			continue
+		}
+
+		// Doubled quote is an escaped quote
+		if i+1 < len(s) && s[i+1] == '"' {
+			value.WriteByte('"')
+			i++
+
+			continue
+		}
+
+		return value.String(), s[i+1:], true
+	}
+
+	return "", s, false
+}
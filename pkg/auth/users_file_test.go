@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUsers(t *testing.T) {
+	users, err := ParseUsers(strings.NewReader(`
+; contractors
+"alice" "md532e12f215ba27cb750c9e093ce4b5127"
+  "bob"   "pass""word" trailing comment
+
+"o""brien" ""
+`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []*User{
+		{Name: "alice", Password: "md532e12f215ba27cb750c9e093ce4b5127"},
+		{Name: "bob", Password: `pass"word`},
+		{Name: `o"brien`, Password: ""},
+	}, users)
+
+	for _, invalid := range []string{`alice "password"`, `"alice" password`, `"alice`, `"" "password"`} {
+		_, err := ParseUsers(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestReadUsersFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "users")
+	require.NoError(t, err)
+
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(`"alice" "secret"` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	users, err := ReadUsersFile(file.Name())
+	require.NoError(t, err)
+	assert.Equal(t, []*User{{Name: "alice", Password: "secret"}}, users)
+
+	_, err = ReadUsersFile(file.Name() + ".missing")
+	assert.Error(t, err)
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/hired/gevulot/pkg/server"
 )
 
-// readServerConfig unmarshals server config at the given file path.
+// readServerConfig unmarshals server config at the given file path and loads the files it refers to.
 func readServerConfig(path string) (*server.Config, error) {
 	// Convert to absolute path
 	absPath, err := filepath.Abs(path)
@@ -25,5 +25,24 @@ func readServerConfig(path string) (*server.Config, error) {
 		return nil, err
 	}
 
+	// TLS and users files are resolved relative to the config file
+	err = config.TLS.Load(filepath.Dir(absPath))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Auth.Load(filepath.Dir(absPath))
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
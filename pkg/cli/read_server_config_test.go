@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/hired/gevulot/pkg/masker"
 )
 
 func TestReadServerConfig(t *testing.T) {
@@ -25,6 +28,17 @@ func TestReadServerConfig(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, config.Listen, "0.0.0.0:4242")
 		assert.Equal(t, config.DatabaseURL, "postgres://localhost/hired_dev")
+
+		if assert.Len(t, config.Mask, 2) {
+			assert.Equal(t, "public.users", config.Mask[0].Table)
+			assert.Equal(t, "email", config.Mask[0].Column)
+			assert.Equal(t, "redact", config.Mask[0].Strategy)
+
+			assert.Equal(t, "partial", config.Mask[1].Strategy)
+			assert.Equal(t, masker.Options{"keep_first": int64(1), "mask_char": "#"}, config.Mask[1].Options)
+		}
+
+		assert.Equal(t, []*masker.Key{{Version: 1, Secret: "secret", Active: true}}, config.MaskingKeys)
 	})
 
 	t.Run("it resolves config path relative to cwd", func(t *testing.T) {
@@ -32,6 +46,167 @@ func TestReadServerConfig(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("resolves TLS files relative to the config file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		configPath := filepath.Join(dir, "gevulot.toml")
+		config := "listen = '0.0.0.0:4242'\n[tls]\ncert = 'server.crt'\nkey = 'server.key'\n"
+
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0600))
+
+		// TLS files are missing
+		_, err = readServerConfig(configPath)
+		assert.Error(t, err)
+
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "server.crt"), []byte("cert"), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "server.key"), []byte("key"), 0600))
+
+		loaded, err := readServerConfig(configPath)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")}, loaded.TLS.Files())
+		}
+	})
+
+	t.Run("validates policies", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		configPath := filepath.Join(dir, "gevulot.toml")
+		config := "[[policy]]\nname = 'dba'\nusers = ['postgres']\nmode = 'unmasked'\n" +
+			"[[policy]]\nname = 'oncall'\ncert-cns = ['oncall']\nmode = 'inherit'\ninherits = 'dba'\n" +
+			"[[auth.user]]\nname = 'postgres'\npassword = 'pencil'\n"
+
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0600))
+
+		loaded, err := readServerConfig(configPath)
+
+		if assert.NoError(t, err) && assert.Len(t, loaded.Policies, 2) {
+			assert.Equal(t, []string{"oncall"}, loaded.Policies[1].CertCNs)
+			assert.Equal(t, "dba", loaded.Policies[1].Inherits)
+		}
+
+		// Unknown parent policy
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte("[[policy]]\nname = 'oncall'\nmode = 'inherit'\ninherits = 'dba'\n"), 0600))
+
+		_, err = readServerConfig(configPath)
+		assert.Error(t, err)
+
+		// Startup user names are not verified without auth
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte("[[policy]]\nname = 'dba'\nusers = ['postgres']\nmode = 'unmasked'\n"), 0600))
+
+		_, err = readServerConfig(configPath)
+		assert.Error(t, err)
+	})
+
+	t.Run("validates masking", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		configPath := filepath.Join(dir, "gevulot.toml")
+
+		invalid := map[string]string{
+			"unknown strategy":  "[[mask]]\ntable = 'users'\ncolumn = 'email'\nstrategy = 'redcat'\n",
+			"missing key":       "[[mask]]\ntable = 'users'\ncolumn = 'email'\nstrategy = 'hash'\n",
+			"database rule":     "[databases.analytics]\nurl = 'postgres://localhost/analytics'\n[[databases.analytics.mask]]\ntable = 'events'\nstrategy = 'redact'\n",
+			"error mode":        "[error-masking]\nERROR = 'hide'\n",
+			"notification rule": "[[mask-notification]]\nchannel = 'events'\nstrategy = 'redcat'\n",
+		}
+
+		for name, config := range invalid {
+			assert.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0600))
+
+			_, err = readServerConfig(configPath)
+			assert.Errorf(t, err, name)
+		}
+	})
+
+	t.Run("parses databases", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		configPath := filepath.Join(dir, "gevulot.toml")
+		config := "[databases.analytics]\nurl = 'postgres://localhost/analytics_production'\n" +
+			"[[databases.analytics.mask]]\ntable = 'events'\ncolumn = 'ip'\nstrategy = 'redact'\n"
+
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0600))
+
+		loaded, err := readServerConfig(configPath)
+
+		if assert.NoError(t, err) && assert.Contains(t, loaded.Databases, "analytics") {
+			assert.Equal(t, "postgres://localhost/analytics_production", loaded.Databases["analytics"].URL)
+			assert.Len(t, loaded.Databases["analytics"].Mask, 1)
+		}
+
+		// Invalid URL
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte("[databases.analytics]\nurl = 'mysql://localhost/analytics'\n"), 0600))
+
+		_, err = readServerConfig(configPath)
+		assert.Error(t, err)
+	})
+
+	t.Run("parses pool", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		configPath := filepath.Join(dir, "gevulot.toml")
+		config := "[pool]\nsize = 10\nreset-query = 'RESET ALL'\n[[auth.user]]\nname = 'alice'\npassword = 'pencil'\n"
+
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0600))
+
+		loaded, err := readServerConfig(configPath)
+
+		if assert.NoError(t, err) && assert.NotNil(t, loaded.Pool) {
+			assert.Equal(t, 10, loaded.Pool.Size)
+			assert.Equal(t, "RESET ALL", loaded.Pool.ResetQuery)
+		}
+
+		// Pooling requires authentication by Gevulot
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte("[pool]\nsize = 10\n"), 0600))
+
+		_, err = readServerConfig(configPath)
+		assert.Error(t, err)
+	})
+
+	t.Run("parses replicas", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		configPath := filepath.Join(dir, "gevulot.toml")
+		config := "database-url = 'postgres://localhost/hired_dev'\n" +
+			"replica-urls = ['postgres://replica1/hired_dev', 'postgres://replica2/hired_dev']\n" +
+			"[read-routing]\napplication-names = ['metabase']\nselects = true\n"
+
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0600))
+
+		loaded, err := readServerConfig(configPath)
+
+		if assert.NoError(t, err) && assert.NotNil(t, loaded.ReadRouting) {
+			assert.Equal(t, []string{"postgres://replica1/hired_dev", "postgres://replica2/hired_dev"}, loaded.ReplicaURLs)
+			assert.Equal(t, []string{"metabase"}, loaded.ReadRouting.ApplicationNames)
+			assert.True(t, loaded.ReadRouting.Selects)
+		}
+
+		// Replica URLs are validated
+		assert.NoError(t, ioutil.WriteFile(configPath, []byte("replica-urls = ['mysql://replica']\n"), 0600))
+
+		_, err = readServerConfig(configPath)
+		assert.Error(t, err)
+	})
+
 	t.Run("returns error if file doesn't exist", func(t *testing.T) {
 		_, err := readServerConfig("nonexistent file")
 
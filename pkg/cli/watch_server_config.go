@@ -1,24 +1,100 @@
 package cli
 
 import (
+	"sync"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/hired/gevulot/pkg/server"
 )
 
-// watchServerConfig watches for the config file and sends updated config to the given channel.
+// watchServerConfig watches for the config file and the files it refers to (TLS certificates, users file),
+// and sends updated config to the given channel.
 func watchServerConfig(configPath string, configChan chan *server.Config) error {
-	watcher := newFileWatcher(configPath)
-	watcher.OnWrite = func() {
-		updatedConfig, err := readServerConfig(configPath)
+	w := &configWatcher{path: configPath, configChan: configChan, watched: make(map[string]bool)}
+
+	err := w.watch(configPath)
+
+	if err != nil {
+		return err
+	}
+
+	config, err := readServerConfig(configPath)
+
+	// Errors are reported by the initial config load; referred files are watched after the next successful reload
+	if err != nil {
+		return nil
+	}
+
+	return w.watchFiles(config)
+}
+
+// configWatcher reloads the config when the config file or the files it refers to change.
+type configWatcher struct {
+	// Path to the config file
+	path string
+
+	// Channel to send updated configs to
+	configChan chan *server.Config
+
+	// Guards watched
+	mu sync.Mutex
+
+	// Set of the watched file paths
+	watched map[string]bool
+}
+
+// watch starts watching the given file unless it's watched already.
+func (w *configWatcher) watch(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watched[path] {
+		return nil
+	}
+
+	watcher := newFileWatcher(path)
+	watcher.OnWrite = w.reload
+	watcher.OnCreate = w.reload
+
+	err := watcher.Watch()
+
+	if err != nil {
+		return err
+	}
+
+	w.watched[path] = true
+
+	return nil
+}
+
+// watchFiles starts watching the files referred by the given config.
+func (w *configWatcher) watchFiles(config *server.Config) error {
+	for _, path := range config.Files() {
+		err := w.watch(path)
 
 		if err != nil {
-			log.Errorf("error loading config file %s: %v", configPath, err)
-			return
+			return err
 		}
+	}
+
+	return nil
+}
+
+// reload reads the config and sends it to the config channel.
+func (w *configWatcher) reload() {
+	updatedConfig, err := readServerConfig(w.path)
+
+	if err != nil {
+		log.Errorf("error loading config file %s: %v", w.path, err)
+		return
+	}
+
+	err = w.watchFiles(updatedConfig)
 
-		configChan <- updatedConfig
+	if err != nil {
+		log.Errorf("error watching files referred by the config: %v", err)
 	}
 
-	return watcher.Watch()
+	w.configChan <- updatedConfig
 }
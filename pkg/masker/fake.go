@@ -0,0 +1,197 @@
+package masker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq/oid"
+)
+
+// Range of generated dates of birth.
+const (
+	fakeMinBirthYear = 1940
+	fakeMaxBirthYear = 2005
+)
+
+// fakeGenerator generates a fake value of the given type using the locale dictionaries.
+type fakeGenerator func(l *fakeLocale, r *keystream, typ oid.Oid) string
+
+// fakeGenerators returns all supported fake value generators keyed by their names.
+func fakeGenerators() map[string]fakeGenerator {
+	return map[string]fakeGenerator{
+		"first_name":     fakeFirstName,
+		"last_name":      fakeLastName,
+		"name":           fakeName,
+		"email":          fakeEmail,
+		"phone":          fakePhone,
+		"street_address": fakeStreetAddress,
+		"address":        fakeAddress,
+		"city":           fakeCity,
+		"postcode":       fakePostcode,
+		"company":        fakeCompany,
+		"date_of_birth":  fakeDateOfBirth,
+	}
+}
+
+// NewFake constructs a Masker that replaces values with plausible synthetic data. The kind of data is selected
+// with the strategy parameter: first_name, last_name, name, email, phone, street_address, address, city,
+// postcode, company or date_of_birth (e.g. "fake:first_name"). Dictionaries are selected with the "locale"
+// option (en_US, de_DE or fr_FR; en_US is the default).
+//
+// Fake values are seeded from the original value, so the same value is always replaced with the same fake one.
+// If there is a masking key configured (or set with "key"/"key_version" options) it is mixed into the seed,
+// so fake values cannot be used to guess the original ones.
+func NewFake(spec *Spec) (Masker, error) {
+	generate, ok := fakeGenerators()[spec.Param]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown fake data kind %q", spec.Param)
+	}
+
+	localeName, err := spec.Options.String("locale", defaultFakeLocale)
+
+	if err != nil {
+		return nil, err
+	}
+
+	locale, ok := fakeLocales()[localeName]
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale %q", localeName)
+	}
+
+	key, err := secretFromOptions(spec)
+
+	// Masking key is optional for fake data unless a specific key version is requested
+	if errors.Is(err, ErrNoKeys) && !spec.Options.Has("key_version") {
+		key, err = nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Func(func(value []byte, typ oid.Oid) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+
+		r := newKeystream(key, "fake:"+spec.Param, string(value))
+
+		return []byte(generate(locale, r, typ)), nil
+	}), nil
+}
+
+// fakeFirstName generates a first name.
+func fakeFirstName(l *fakeLocale, r *keystream, _ oid.Oid) string {
+	return pick(r, l.firstNames)
+}
+
+// fakeLastName generates a last name.
+func fakeLastName(l *fakeLocale, r *keystream, _ oid.Oid) string {
+	return pick(r, l.lastNames)
+}
+
+// fakeName generates a full name.
+func fakeName(l *fakeLocale, r *keystream, typ oid.Oid) string {
+	return fakeFirstName(l, r, typ) + " " + fakeLastName(l, r, typ)
+}
+
+// fakeEmail generates an email at one of the reserved example domains.
+func fakeEmail(l *fakeLocale, r *keystream, typ oid.Oid) string {
+	first := asciiFold(fakeFirstName(l, r, typ))
+	last := asciiFold(fakeLastName(l, r, typ))
+	suffix := strconv.Itoa(r.next(100))
+
+	return first + "." + last + suffix + "@" + pick(r, l.emailDomains)
+}
+
+// fakePhone generates a phone number in the locale format.
+func fakePhone(l *fakeLocale, r *keystream, _ oid.Oid) string {
+	return fillDigits(r, l.phoneFormat)
+}
+
+// fakeStreetAddress generates a street address (e.g. "42 Main St").
+func fakeStreetAddress(l *fakeLocale, r *keystream, _ oid.Oid) string {
+	number := strconv.Itoa(1 + r.next(999))
+
+	return strings.NewReplacer("{number}", number, "{street}", pick(r, l.streets)).Replace(l.streetFormat)
+}
+
+// fakeAddress generates a full address with a postcode and a city.
+func fakeAddress(l *fakeLocale, r *keystream, typ oid.Oid) string {
+	return fakeStreetAddress(l, r, typ) + ", " + fakePostcode(l, r, typ) + " " + fakeCity(l, r, typ)
+}
+
+// fakeCity generates a city name.
+func fakeCity(l *fakeLocale, r *keystream, _ oid.Oid) string {
+	return pick(r, l.cities)
+}
+
+// fakePostcode generates a postcode.
+func fakePostcode(l *fakeLocale, r *keystream, _ oid.Oid) string {
+	return fillDigits(r, l.postcodeFormat)
+}
+
+// fakeCompany generates a company name (e.g. "Smith Group").
+func fakeCompany(l *fakeLocale, r *keystream, typ oid.Oid) string {
+	return fakeLastName(l, r, typ) + " " + pick(r, l.companySuffix)
+}
+
+// fakeDateOfBirth generates a date of birth formatted according to the column type.
+func fakeDateOfBirth(_ *fakeLocale, r *keystream, typ oid.Oid) string {
+	from := time.Date(fakeMinBirthYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(fakeMaxBirthYear, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	days := int(to.Sub(from).Hours() / 24)
+
+	date := from.AddDate(0, 0, r.next(days+1))
+
+	switch typ {
+	case oid.T_timestamp:
+		return date.Format("2006-01-02 15:04:05")
+
+	case oid.T_timestamptz:
+		return date.Format("2006-01-02 15:04:05-07")
+
+	default:
+		return date.Format("2006-01-02")
+	}
+}
+
+// pick returns a pseudo-random element of the list.
+func pick(r *keystream, list []string) string {
+	return list[r.next(len(list))]
+}
+
+// fillDigits replaces every '#' in the format with a pseudo-random digit.
+func fillDigits(r *keystream, format string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '#' {
+			return rune('0' + r.next(10))
+		}
+
+		return c
+	}, format)
+}
+
+// asciiFold converts a name into lowercase ASCII suitable for an email (e.g. "Müller" → "mueller").
+func asciiFold(str string) string {
+	replacer := strings.NewReplacer(
+		"ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss",
+		"à", "a", "â", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+		"î", "i", "ï", "i", "ô", "o", "û", "u", "ù", "u",
+	)
+
+	return strings.Map(func(c rune) rune {
+		if c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return c
+		}
+
+		return -1
+	}, replacer.Replace(strings.ToLower(str)))
+}
@@ -0,0 +1,101 @@
+package masker
+
+// Default locale of the fake strategy.
+const defaultFakeLocale = "en_US"
+
+// fakeLocale contains dictionaries and formats used to generate fake data for a single locale.
+type fakeLocale struct {
+	firstNames     []string
+	lastNames      []string
+	streets        []string
+	cities         []string
+	companySuffix  []string
+	emailDomains   []string
+	streetFormat   string // "{number}" and "{street}" placeholders
+	phoneFormat    string // '#' is replaced with a digit
+	postcodeFormat string // '#' is replaced with a digit
+}
+
+// fakeLocales returns all supported locales keyed by their names.
+func fakeLocales() map[string]*fakeLocale {
+	return map[string]*fakeLocale{
+		"en_US": {
+			firstNames: []string{
+				"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+				"William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen",
+				"Daniel", "Nancy", "Matthew", "Lisa", "Anthony", "Betty", "Mark", "Margaret", "Donald", "Sandra",
+			},
+			lastNames: []string{
+				"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+				"Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin",
+				"Lee", "Perez", "Thompson", "White", "Harris", "Sanchez", "Clark", "Ramirez", "Lewis", "Robinson",
+			},
+			streets: []string{
+				"Main St", "Oak Ave", "Pine St", "Maple Ave", "Cedar Ln", "Elm St", "Washington Blvd", "Lake Dr",
+				"Hill Rd", "Park Ave", "Sunset Blvd", "River Rd", "Church St", "Highland Ave", "Mill Rd", "Spring St",
+			},
+			cities: []string{
+				"Springfield", "Riverside", "Franklin", "Greenville", "Bristol", "Clinton", "Fairview", "Salem",
+				"Madison", "Georgetown", "Arlington", "Ashland", "Dover", "Oxford", "Jackson", "Burlington",
+			},
+			companySuffix:  []string{"Inc", "LLC", "Group", "& Sons", "Corp", "Partners", "Holdings"},
+			emailDomains:   []string{"example.com", "example.net", "example.org"},
+			streetFormat:   "{number} {street}",
+			phoneFormat:    "+1 (###) 555-01##",
+			postcodeFormat: "#####",
+		},
+
+		"de_DE": {
+			firstNames: []string{
+				"Maximilian", "Sophie", "Alexander", "Marie", "Paul", "Sophia", "Elias", "Maria", "Ben", "Emilia",
+				"Noah", "Emma", "Leon", "Hannah", "Louis", "Anna", "Jonas", "Mia", "Felix", "Lena",
+				"Lukas", "Lea", "Jürgen", "Ursula", "Stefan", "Sabine", "Andreas", "Petra", "Michael", "Monika",
+			},
+			lastNames: []string{
+				"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann",
+				"Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf", "Schröder", "Neumann", "Schwarz", "Zimmermann",
+				"Braun", "Krüger", "Hofmann", "Hartmann", "Lange", "Schmitt", "Werner", "Schmitz", "Krause", "Meier",
+			},
+			streets: []string{
+				"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße",
+				"Birkenweg", "Lindenstraße", "Kirchstraße", "Waldstraße", "Ringstraße", "Schillerstraße",
+			},
+			cities: []string{
+				"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main", "Stuttgart", "Düsseldorf", "Leipzig",
+				"Dortmund", "Essen", "Bremen", "Dresden", "Hannover", "Nürnberg", "Duisburg", "Bochum",
+			},
+			companySuffix:  []string{"GmbH", "AG", "KG", "GmbH & Co. KG", "OHG"},
+			emailDomains:   []string{"example.de", "example.com", "example.org"},
+			streetFormat:   "{street} {number}",
+			phoneFormat:    "+49 30 ########",
+			postcodeFormat: "#####",
+		},
+
+		"fr_FR": {
+			firstNames: []string{
+				"Gabriel", "Louise", "Léo", "Ambre", "Raphaël", "Alice", "Arthur", "Rose", "Louis", "Anna",
+				"Jules", "Mia", "Adam", "Julia", "Maël", "Chloé", "Lucas", "Léa", "Hugo", "Emma",
+				"Noah", "Inès", "Paul", "Juliette", "Nathan", "Camille", "Théo", "Manon", "Éric", "Hélène",
+			},
+			lastNames: []string{
+				"Martin", "Bernard", "Thomas", "Petit", "Robert", "Richard", "Durand", "Dubois", "Moreau", "Laurent",
+				"Simon", "Michel", "Lefèvre", "Leroy", "Roux", "David", "Bertrand", "Morel", "Fournier", "Girard",
+				"Bonnet", "Dupont", "Lambert", "Fontaine", "Rousseau", "Vincent", "Muller", "Lefebvre", "Faure", "André",
+			},
+			streets: []string{
+				"rue de la Paix", "avenue Victor Hugo", "rue de la République", "boulevard Saint-Michel",
+				"rue du Moulin", "place de l'Église", "rue des Écoles", "chemin des Vignes", "rue Pasteur",
+				"avenue Jean Jaurès", "rue de la Gare", "allée des Tilleuls",
+			},
+			cities: []string{
+				"Paris", "Marseille", "Lyon", "Toulouse", "Nice", "Nantes", "Strasbourg", "Montpellier",
+				"Bordeaux", "Lille", "Rennes", "Reims", "Le Havre", "Saint-Étienne", "Toulon", "Grenoble",
+			},
+			companySuffix:  []string{"SA", "SARL", "SAS", "et Fils", "Groupe"},
+			emailDomains:   []string{"example.fr", "example.com", "example.org"},
+			streetFormat:   "{number} {street}",
+			phoneFormat:    "+33 1 ## ## ## ##",
+			postcodeFormat: "#####",
+		},
+	}
+}
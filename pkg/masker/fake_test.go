@@ -0,0 +1,137 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	testCases := []struct {
+		strategy string
+		options  Options
+		value    string
+		typ      oid.Oid
+		pattern  string
+	}{
+		// users.name
+		{"fake:name", nil, "Denis Diachkov", oid.T_varchar, `^[A-Z][a-z]+ [A-Z][a-z]+$`},
+		{"fake:first_name", nil, "Denis", oid.T_varchar, `^[A-Z][a-z]+$`},
+		{"fake:last_name", nil, "Diachkov", oid.T_text, `^[A-Z][a-z]+$`},
+
+		// users.email
+		{"fake:email", nil, "denis@hired.com", oid.T_varchar, `^[a-z]+\.[a-z]+\d{1,2}@example\.(com|net|org)$`},
+		{"fake:email", Options{"locale": "de_DE"}, "denis@hired.com", oid.T_text, `^[a-z]+\.[a-z]+\d{1,2}@example\.(de|com|org)$`},
+
+		// companies.name
+		{"fake:company", nil, "Hired", oid.T_varchar, `^[A-Z][a-z]+ (Inc|LLC|Group|& Sons|Corp|Partners|Holdings)$`},
+		{"fake:company", Options{"locale": "de_DE"}, "Hired", oid.T_varchar, `^\pL+ (GmbH|AG|KG|GmbH & Co\. KG|OHG)$`},
+
+		{"fake:phone", nil, "+1 (555) 010-0199", oid.T_text, `^\+1 \(\d{3}\) 555-01\d{2}$`},
+		{"fake:phone", Options{"locale": "fr_FR"}, "0612345678", oid.T_text, `^\+33 1 \d{2} \d{2} \d{2} \d{2}$`},
+		{"fake:street_address", nil, "1 Infinite Loop", oid.T_text, `^\d{1,3} [A-Z][a-z]+ [A-Z][a-z]+$`},
+		{"fake:street_address", Options{"locale": "de_DE"}, "Unter den Linden 1", oid.T_text, `^\pL+ \d{1,3}$`},
+		{"fake:address", nil, "1 Infinite Loop, Cupertino", oid.T_text, `^\d{1,3} [A-Za-z ]+, \d{5} [A-Z][a-z]+$`},
+		{"fake:city", nil, "Cupertino", oid.T_text, `^[A-Z][a-z]+$`},
+		{"fake:postcode", nil, "95014", oid.T_text, `^\d{5}$`},
+		{"fake:date_of_birth", nil, "1985-07-13", oid.T_date, `^(19[4-9]\d|200[0-5])-\d{2}-\d{2}$`},
+		{"fake:date_of_birth", nil, "1985-07-13 00:00:00", oid.T_timestamp, `^\d{4}-\d{2}-\d{2} 00:00:00$`},
+		{"fake:date_of_birth", nil, "1985-07-13 00:00:00+00", oid.T_timestamptz, `^\d{4}-\d{2}-\d{2} 00:00:00\+00$`},
+	}
+
+	for _, tc := range testCases {
+		m, err := DefaultRegistry().New(ParseSpec(tc.strategy, tc.options))
+		require.NoError(t, err)
+
+		fake, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+
+		assert.Regexp(t, tc.pattern, string(fake), tc.strategy)
+		assert.True(t, IsValid(fake, tc.typ), tc.strategy)
+
+		// Fake values are deterministic
+		again, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+		assert.Equal(t, fake, again)
+	}
+}
+
+func TestFakeNull(t *testing.T) {
+	m, err := DefaultRegistry().New(ParseSpec("fake:name", nil))
+	require.NoError(t, err)
+
+	fake, err := m.Mask(nil, oid.T_text)
+	require.NoError(t, err)
+	assert.Nil(t, fake)
+}
+
+func TestFakeDistribution(t *testing.T) {
+	m, err := DefaultRegistry().New(ParseSpec("fake:first_name", nil))
+	require.NoError(t, err)
+
+	names := make(map[string]bool)
+
+	for _, value := range []string{"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi"} {
+		fake, err := m.Mask([]byte(value), oid.T_text)
+		require.NoError(t, err)
+
+		names[string(fake)] = true
+	}
+
+	// Different values are very unlikely to produce the same fake value
+	assert.True(t, len(names) > 4)
+}
+
+func TestFakeKeyed(t *testing.T) {
+	unkeyed, err := DefaultRegistry().New(ParseSpec("fake:email", nil))
+	require.NoError(t, err)
+
+	keyed := newTestTokenizer(t, "fake:email", nil, &Key{Version: 1, Secret: "secret"})
+	rotated := newTestTokenizer(t, "fake:email", nil, &Key{Version: 2, Secret: "another secret"})
+
+	value := []byte("denis@hired.com")
+
+	a, err := unkeyed.Mask(value, oid.T_text)
+	require.NoError(t, err)
+
+	b, err := keyed.Mask(value, oid.T_text)
+	require.NoError(t, err)
+
+	c, err := rotated.Mask(value, oid.T_text)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, b, c)
+}
+
+func TestFakeInvalidSpec(t *testing.T) {
+	_, err := DefaultRegistry().New(ParseSpec("fake", nil))
+	assert.Error(t, err)
+
+	_, err = DefaultRegistry().New(ParseSpec("fake:credit_card", nil))
+	assert.Error(t, err)
+
+	_, err = DefaultRegistry().New(ParseSpec("fake:name", Options{"locale": "xx_XX"}))
+	assert.Error(t, err)
+
+	_, err = DefaultRegistry().New(ParseSpec("fake:name", Options{"key_version": 3}))
+	assert.Error(t, err)
+}
+
+func TestFakeInvalidType(t *testing.T) {
+	m, err := DefaultRegistry().New(ParseSpec("fake:name", nil))
+	require.NoError(t, err)
+
+	// Names can't be stored in integer columns
+	fake, err := m.Mask([]byte("42"), oid.T_int4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0"), fake)
+}
+
+func TestASCIIFold(t *testing.T) {
+	assert.Equal(t, "mueller", asciiFold("Müller"))
+	assert.Equal(t, "helene", asciiFold("Hélène"))
+	assert.Equal(t, "jeanpierre", asciiFold("Jean-Pierre"))
+}
@@ -0,0 +1,30 @@
+package masker
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// NewFixed constructs a Masker that replaces every value with a constant (option "value"). When the constant
+// is not set or is not valid for the masked column type, a zero value of the type is used instead
+// (e.g. 0 for integers, 1970-01-01 for dates or an empty string for text).
+func NewFixed(spec *Spec) (Masker, error) {
+	constant, err := spec.Options.String("value", "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	hasConstant := spec.Options.Has("value")
+
+	return Func(func(value []byte, typ oid.Oid) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+
+		if hasConstant && IsValid([]byte(constant), typ) {
+			return []byte(constant), nil
+		}
+
+		return ZeroValue(typ), nil
+	}), nil
+}
@@ -0,0 +1,23 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+)
+
+func TestFixed(t *testing.T) {
+	testMasker(t, "fixed", Options{"value": "42"}, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("42")},
+		{[]byte("31337"), oid.T_int4, []byte("42")},
+		{[]byte("1.5"), oid.T_numeric, []byte("42")},
+		{[]byte("1987-10-29"), oid.T_date, []byte("1970-01-01")},
+		{nil, oid.T_int4, nil},
+	})
+
+	testMasker(t, "fixed", nil, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("")},
+		{[]byte("31337"), oid.T_int4, []byte("0")},
+		{[]byte("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"), oid.T_uuid, []byte("00000000-0000-0000-0000-000000000000")},
+	})
+}
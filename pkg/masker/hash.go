@@ -0,0 +1,76 @@
+package masker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq/oid"
+)
+
+// NewHash constructs a Masker that replaces values with their HMAC-SHA256 computed with the secret key
+// (inline option "key", key from the keyring with version "key_version" or the active key). Text values
+// become hex-encoded digests, optionally truncated to the given length (option "length"); integers and UUIDs
+// are derived from the digest bytes so they remain valid for the column.
+func NewHash(spec *Spec) (Masker, error) {
+	key, err := secretFromOptions(spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := spec.Options.Int("length", 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Func(func(value []byte, typ oid.Oid) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write(value)
+
+		return formatDigest(mac.Sum(nil), typ, length), nil
+	}), nil
+}
+
+// formatDigest converts a digest into a value of the given type.
+// For text types hex-encoded digest is truncated to the given length (if it is positive).
+func formatDigest(digest []byte, typ oid.Oid, length int) []byte {
+	num := binary.BigEndian.Uint64(digest)
+
+	switch typ {
+	case oid.T_int2:
+		return []byte(strconv.FormatUint(num%(1<<15), 10))
+
+	case oid.T_int4:
+		return []byte(strconv.FormatUint(num%(1<<31), 10))
+
+	case oid.T_int8, oid.T_numeric:
+		return []byte(strconv.FormatUint(num%(1<<63), 10))
+
+	case oid.T_uuid:
+		return []byte(fmt.Sprintf("%x-%x-%x-%x-%x", digest[0:4], digest[4:6], digest[6:8], digest[8:10], digest[10:16]))
+
+	case oid.T_bytea:
+		return []byte(`\x` + hex.EncodeToString(digest))
+	}
+
+	if !IsTextType(typ) {
+		return ZeroValue(typ)
+	}
+
+	str := hex.EncodeToString(digest)
+
+	if length > 0 && length < len(str) {
+		str = str[:length]
+	}
+
+	return []byte(str)
+}
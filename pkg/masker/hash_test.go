@@ -0,0 +1,54 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	m, err := DefaultRegistry().New(ParseSpec("hash", Options{"key": "secret"}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		value string
+		typ   oid.Oid
+	}{
+		{"jane@example.com", oid.T_varchar},
+		{"42", oid.T_int2},
+		{"42", oid.T_int4},
+		{"42", oid.T_int8},
+		{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", oid.T_uuid},
+		{`\xdeadbeef`, oid.T_bytea},
+		{"1987-10-29", oid.T_date},
+	}
+
+	for _, tc := range testCases {
+		masked, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+
+		// Hashed value is valid for the type
+		assert.Truef(t, IsValid(masked, tc.typ), "%q of type %s", masked, oid.TypeName[tc.typ])
+
+		// Hash is deterministic
+		again, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+		assert.Equal(t, masked, again)
+	}
+
+	// HMAC-SHA256("secret", "jane@example.com")
+	testMasker(t, "hash", Options{"key": "secret", "length": int64(16)}, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("fb817989d942e7ff")},
+		{nil, oid.T_varchar, nil},
+	})
+
+	// Different keys produce different hashes
+	other, err := DefaultRegistry().New(ParseSpec("hash", Options{"key": "other"}))
+	require.NoError(t, err)
+
+	masked1, _ := m.Mask([]byte("jane@example.com"), oid.T_text)
+	masked2, _ := other.Mask([]byte("jane@example.com"), oid.T_text)
+	assert.NotEqual(t, masked1, masked2)
+}
@@ -0,0 +1,125 @@
+package masker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Key is a versioned secret key used by keyed strategies (e.g. tokenize and hash). Keys are declared in
+// the gevulot.toml:
+//
+//	[[masking-key]]
+//	version = 2
+//	secret = "s3cr3t"
+//	active = true
+type Key struct {
+	// Key version; must be unique.
+	Version int
+
+	// Secret key material.
+	Secret string
+
+	// True when the key must be used by default. If none of the keys is active, the key with
+	// the highest version is used.
+	Active bool
+}
+
+// Keyring is a set of versioned secret keys. It allows to rotate keys: a new key can be added
+// and made active, while rules that must keep producing the same tokens pin the old key version
+// with the "key_version" option.
+type Keyring struct {
+	// Secrets keyed by version
+	secrets map[int][]byte
+
+	// Version of the active key
+	active int
+}
+
+var (
+	// ErrNoKeys is returned by the Keyring when there are no keys configured.
+	ErrNoKeys = errors.New("masker: no masking keys configured")
+)
+
+// NewKeyring initializes a new Keyring with the given keys.
+func NewKeyring(keys []*Key) (*Keyring, error) {
+	k := &Keyring{secrets: make(map[int][]byte, len(keys))}
+	explicitlyActive := false
+
+	for _, key := range keys {
+		if key.Secret == "" {
+			return nil, fmt.Errorf("masker: masking key version %d has empty secret", key.Version)
+		}
+
+		if _, exists := k.secrets[key.Version]; exists {
+			return nil, fmt.Errorf("masker: duplicate masking key version %d", key.Version)
+		}
+
+		k.secrets[key.Version] = []byte(key.Secret)
+
+		switch {
+		case key.Active && explicitlyActive:
+			return nil, fmt.Errorf("masker: more than one active masking key (version %d)", key.Version)
+
+		case key.Active:
+			k.active = key.Version
+			explicitlyActive = true
+
+		case !explicitlyActive && (len(k.secrets) == 1 || key.Version > k.active):
+			k.active = key.Version
+		}
+	}
+
+	return k, nil
+}
+
+// Active returns the active key version and its secret.
+func (k *Keyring) Active() (int, []byte, error) {
+	if k == nil || len(k.secrets) == 0 {
+		return 0, nil, ErrNoKeys
+	}
+
+	return k.active, k.secrets[k.active], nil
+}
+
+// Get returns secret of the key with the given version.
+func (k *Keyring) Get(version int) ([]byte, error) {
+	if k == nil || len(k.secrets) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	secret, ok := k.secrets[version]
+
+	if !ok {
+		return nil, fmt.Errorf("masker: unknown masking key version %d", version)
+	}
+
+	return secret, nil
+}
+
+// secretFromOptions returns the secret key selected by the strategy options: an inline key (option "key"),
+// a specific version from the keyring (option "key_version") or the active key from the keyring.
+func secretFromOptions(spec *Spec) ([]byte, error) {
+	key, err := spec.Options.String("key", "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		return []byte(key), nil
+	}
+
+	if spec.Options.Has("key_version") {
+		version, err := spec.Options.Int("key_version", 0)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return spec.Keys.Get(version)
+	}
+
+	_, secret, err := spec.Keys.Active()
+
+	return secret, err
+}
@@ -0,0 +1,67 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyring(t *testing.T) {
+	testCases := []struct {
+		keys          []*Key
+		activeVersion int
+	}{
+		{[]*Key{{Version: 1, Secret: "a"}}, 1},
+		{[]*Key{{Version: 1, Secret: "a"}, {Version: 3, Secret: "c"}, {Version: 2, Secret: "b"}}, 3},
+		{[]*Key{{Version: 1, Secret: "a", Active: true}, {Version: 2, Secret: "b"}}, 1},
+		{[]*Key{{Version: 2, Secret: "b"}, {Version: 1, Secret: "a", Active: true}}, 1},
+	}
+
+	for _, tc := range testCases {
+		keyring, err := NewKeyring(tc.keys)
+		require.NoError(t, err)
+
+		version, _, err := keyring.Active()
+		assert.NoError(t, err)
+		assert.Equal(t, tc.activeVersion, version)
+	}
+
+	invalidKeys := [][]*Key{
+		{{Version: 1}},
+		{{Version: 1, Secret: "a"}, {Version: 1, Secret: "b"}},
+		{{Version: 1, Secret: "a", Active: true}, {Version: 2, Secret: "b", Active: true}},
+	}
+
+	for _, keys := range invalidKeys {
+		_, err := NewKeyring(keys)
+		assert.Error(t, err)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keyring, err := NewKeyring([]*Key{{Version: 1, Secret: "old"}, {Version: 2, Secret: "new"}})
+	require.NoError(t, err)
+
+	version, secret, err := keyring.Active()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, []byte("new"), secret)
+
+	secret, err = keyring.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("old"), secret)
+
+	_, err = keyring.Get(3)
+	assert.Error(t, err)
+
+	// Empty keyring
+	empty, err := NewKeyring(nil)
+	require.NoError(t, err)
+
+	_, _, err = empty.Active()
+	assert.Equal(t, ErrNoKeys, err)
+
+	_, err = (*Keyring)(nil).Get(1)
+	assert.Equal(t, ErrNoKeys, err)
+}
@@ -0,0 +1,23 @@
+package masker
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// Masker replaces a sensitive value with a masked one.
+type Masker interface {
+	// Mask returns the masked value. The value is in PostgreSQL text format; nil represents NULL.
+	// typ is the OID of the value's data type.
+	Mask(value []byte, typ oid.Oid) ([]byte, error)
+}
+
+// Func is an adapter to allow the use of ordinary functions as Maskers.
+type Func func(value []byte, typ oid.Oid) ([]byte, error)
+
+// Compile time check to make sure that Func implements the Masker interface.
+var _ Masker = Func(nil)
+
+// Mask calls f(value, typ).
+func (f Func) Mask(value []byte, typ oid.Oid) ([]byte, error) {
+	return f(value, typ)
+}
@@ -0,0 +1,42 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maskerTestCase is a single test case for table-driven Masker tests.
+type maskerTestCase struct {
+	value    []byte
+	typ      oid.Oid
+	expected []byte
+}
+
+// testMasker constructs a masker with the default registry and runs the test cases against it.
+func testMasker(t *testing.T, strategy string, options Options, testCases []maskerTestCase) {
+	t.Helper()
+
+	m, err := DefaultRegistry().New(ParseSpec(strategy, options))
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		masked, err := m.Mask(tc.value, tc.typ)
+
+		assert.NoError(t, err)
+		assert.Equalf(t, tc.expected, masked, "value %q of type %s", tc.value, oid.TypeName[tc.typ])
+	}
+}
+
+func TestFunc(t *testing.T) {
+	m := Func(func(value []byte, _ oid.Oid) ([]byte, error) {
+		return append(value, '!'), nil
+	})
+
+	masked, err := m.Mask([]byte("hello"), oid.T_text)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello!"), masked)
+}
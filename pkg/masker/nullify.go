@@ -0,0 +1,12 @@
+package masker
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// NewNullify constructs a Masker that replaces every value with NULL.
+func NewNullify(_ *Spec) (Masker, error) {
+	return Func(func(_ []byte, _ oid.Oid) ([]byte, error) {
+		return nil, nil
+	}), nil
+}
@@ -0,0 +1,15 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+)
+
+func TestNullify(t *testing.T) {
+	testMasker(t, "nullify", nil, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, nil},
+		{[]byte("42"), oid.T_int4, nil},
+		{nil, oid.T_text, nil},
+	})
+}
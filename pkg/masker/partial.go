@@ -0,0 +1,67 @@
+package masker
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq/oid"
+)
+
+// Default character used by the partial strategy.
+const defaultPartialMaskChar = "*"
+
+// NewPartial constructs a Masker that keeps the first N (option "keep_first") and the last M (option "keep_last")
+// characters of a value replacing the rest with the mask character (option "mask_char", default "*").
+// For non-text types only digits are masked and they are replaced with zeros, so "4242424242424242" stored
+// in a bigint column becomes "0000000000004242".
+func NewPartial(spec *Spec) (Masker, error) {
+	keepFirst, err := spec.Options.Int("keep_first", 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keepLast, err := spec.Options.Int("keep_last", 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if keepFirst < 0 || keepLast < 0 {
+		return nil, errors.New("keep_first and keep_last must not be negative")
+	}
+
+	maskChar, err := spec.Options.String("mask_char", defaultPartialMaskChar)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if utf8.RuneCountInString(maskChar) != 1 {
+		return nil, errors.New("mask_char must be a single character")
+	}
+
+	maskRune, _ := utf8.DecodeRuneInString(maskChar)
+
+	return Func(func(value []byte, typ oid.Oid) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+
+		runes := []rune(string(value))
+		isText := IsTextType(typ)
+
+		for i := keepFirst; i < len(runes)-keepLast; i++ {
+			switch {
+			case isText:
+				runes[i] = maskRune
+
+			case unicode.IsDigit(runes[i]):
+				runes[i] = '0'
+			}
+		}
+
+		return []byte(string(runes)), nil
+	}), nil
+}
@@ -0,0 +1,38 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartial(t *testing.T) {
+	testMasker(t, "partial", Options{"keep_first": int64(1), "keep_last": int64(4)}, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("j***********.com")},
+		{[]byte("Jürgen"), oid.T_text, []byte("J*rgen")},
+		{[]byte("abc"), oid.T_text, []byte("abc")},
+		{[]byte("4242424242424242"), oid.T_int8, []byte("4000000000004242")},
+		{nil, oid.T_text, nil},
+	})
+
+	testMasker(t, "partial", Options{"keep_last": int64(2), "mask_char": "#"}, []maskerTestCase{
+		{[]byte("+1 555 0100"), oid.T_text, []byte("#########00")},
+		{[]byte("-123456"), oid.T_int4, []byte("-000056")},
+	})
+
+	testMasker(t, "partial", nil, []maskerTestCase{
+		{[]byte("secret"), oid.T_text, []byte("******")},
+		{[]byte("1987-10-29"), oid.T_date, []byte("1970-01-01")},
+	})
+
+	// Invalid options
+	for _, options := range []Options{
+		{"keep_first": int64(-1)},
+		{"keep_last": "2"},
+		{"mask_char": "**"},
+	} {
+		_, err := DefaultRegistry().New(ParseSpec("partial", options))
+		assert.Errorf(t, err, "options: %v", options)
+	}
+}
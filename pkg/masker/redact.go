@@ -0,0 +1,30 @@
+package masker
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// Default replacement used by the redact strategy.
+const defaultRedactedValue = "***"
+
+// NewRedact constructs a Masker that replaces text values with a constant (option "value", default "***").
+// Values of non-text types are replaced with a zero value of the type (e.g. 0 or 1970-01-01).
+func NewRedact(spec *Spec) (Masker, error) {
+	replacement, err := spec.Options.String("value", defaultRedactedValue)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Func(func(value []byte, typ oid.Oid) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+
+		if IsTextType(typ) {
+			return []byte(replacement), nil
+		}
+
+		return ZeroValue(typ), nil
+	}), nil
+}
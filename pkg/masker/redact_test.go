@@ -0,0 +1,23 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+)
+
+func TestRedact(t *testing.T) {
+	testMasker(t, "redact", nil, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("***")},
+		{[]byte("Jane"), oid.T_text, []byte("***")},
+		{[]byte("42"), oid.T_int4, []byte("0")},
+		{[]byte("1987-10-29"), oid.T_date, []byte("1970-01-01")},
+		{[]byte("t"), oid.T_bool, []byte("f")},
+		{nil, oid.T_text, nil},
+	})
+
+	testMasker(t, "redact", Options{"value": "[REDACTED]"}, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("[REDACTED]")},
+		{[]byte("42"), oid.T_int8, []byte("0")},
+	})
+}
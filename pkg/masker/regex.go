@@ -0,0 +1,43 @@
+package masker
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/lib/pq/oid"
+)
+
+// NewRegex constructs a Masker that replaces all matches of the regular expression (option "pattern") with
+// the replacement (option "replacement", default is an empty string). Inside the replacement, $1 and ${name}
+// are substituted with the submatches as in regexp.Regexp.Expand.
+func NewRegex(spec *Spec) (Masker, error) {
+	pattern, err := spec.Options.String("pattern", "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pattern == "" {
+		return nil, errors.New("pattern is not set")
+	}
+
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := spec.Options.String("replacement", "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Func(func(value []byte, _ oid.Oid) ([]byte, error) {
+		if value == nil {
+			return nil, nil
+		}
+
+		return re.ReplaceAll(value, []byte(replacement)), nil
+	}), nil
+}
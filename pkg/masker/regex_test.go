@@ -0,0 +1,36 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegex(t *testing.T) {
+	testMasker(t, "regex", Options{"pattern": `^[^@]+`, "replacement": "user"}, []maskerTestCase{
+		{[]byte("jane@example.com"), oid.T_varchar, []byte("user@example.com")},
+		{[]byte("no at sign"), oid.T_text, []byte("user")},
+		{nil, oid.T_text, nil},
+	})
+
+	testMasker(t, "regex", Options{"pattern": `(\d{3})\d+`, "replacement": "${1}0000"}, []maskerTestCase{
+		{[]byte("tel: 5550123"), oid.T_text, []byte("tel: 5550000")},
+		{[]byte("5550123"), oid.T_int4, []byte("5550000")},
+	})
+
+	// Result is invalid for the type
+	testMasker(t, "regex", Options{"pattern": `\d`, "replacement": "x"}, []maskerTestCase{
+		{[]byte("42"), oid.T_int4, []byte("0")},
+	})
+
+	// Invalid options
+	for _, options := range []Options{
+		nil,
+		{"pattern": "("},
+		{"pattern": ".", "replacement": int64(1)},
+	} {
+		_, err := DefaultRegistry().New(ParseSpec("regex", options))
+		assert.Errorf(t, err, "options: %v", options)
+	}
+}
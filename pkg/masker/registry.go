@@ -0,0 +1,88 @@
+package masker
+
+import (
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// Factory constructs a Masker from the given spec.
+type Factory func(spec *Spec) (Masker, error)
+
+// Registry is a collection of masking strategies available in the config.
+type Registry struct {
+	factories map[string]Factory
+
+	// Secret keys passed to the strategies
+	keys *Keyring
+}
+
+// NewRegistry initializes an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// DefaultRegistry initializes a Registry with all built-in strategies.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+
+	r.Register("redact", NewRedact)
+	r.Register("nullify", NewNullify)
+	r.Register("hash", NewHash)
+	r.Register("partial", NewPartial)
+	r.Register("regex", NewRegex)
+	r.Register("fixed", NewFixed)
+	r.Register("tokenize", NewTokenize)
+	r.Register("fake", NewFake)
+
+	return r
+}
+
+// Register adds a strategy to the registry replacing existing one with the same name.
+func (r *Registry) Register(name string, factory Factory) {
+	r.factories[name] = factory
+}
+
+// SetKeyring sets secret keys available to keyed strategies.
+func (r *Registry) SetKeyring(keys *Keyring) {
+	r.keys = keys
+}
+
+// New constructs a Masker for the given spec. Returned Masker guarantees that masked values
+// are valid for the data type of the masked column.
+func (r *Registry) New(spec *Spec) (Masker, error) {
+	factory, ok := r.factories[spec.Name]
+
+	if !ok {
+		return nil, fmt.Errorf("masker: unknown strategy %q", spec.Name)
+	}
+
+	// NB: do not modify the caller's spec
+	specWithKeys := *spec
+	specWithKeys.Keys = r.keys
+
+	m, err := factory(&specWithKeys)
+
+	if err != nil {
+		return nil, fmt.Errorf("masker: strategy %q: %w", spec, err)
+	}
+
+	return typeGuard(m), nil
+}
+
+// typeGuard wraps the Masker replacing masked values that are not valid for the data type with zero values.
+func typeGuard(m Masker) Masker {
+	return Func(func(value []byte, typ oid.Oid) ([]byte, error) {
+		masked, err := m.Mask(value, typ)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if masked == nil || IsValid(masked, typ) {
+			return masked, nil
+		}
+
+		return ZeroValue(typ), nil
+	})
+}
@@ -0,0 +1,50 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	_, err := r.New(ParseSpec("constant", nil))
+	assert.Error(t, err, "unknown strategy")
+
+	r.Register("constant", func(_ *Spec) (Masker, error) {
+		return Func(func(_ []byte, _ oid.Oid) ([]byte, error) {
+			return []byte("constant"), nil
+		}), nil
+	})
+
+	m, err := r.New(ParseSpec("constant", nil))
+	require.NoError(t, err)
+
+	masked, err := m.Mask([]byte("secret"), oid.T_text)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("constant"), masked)
+
+	// Invalid values are replaced with zero values of the type
+	masked, err = m.Mask([]byte("42"), oid.T_int4)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("0"), masked)
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	r := DefaultRegistry()
+
+	for _, name := range []string{"redact", "nullify", "partial", "fixed"} {
+		_, err := r.New(ParseSpec(name, nil))
+		assert.NoErrorf(t, err, "strategy %s", name)
+	}
+
+	// Strategies with mandatory options
+	_, err := r.New(ParseSpec("hash", nil))
+	assert.Error(t, err)
+
+	_, err = r.New(ParseSpec("regex", nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package masker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Spec describes a masking strategy selected in the config.
+type Spec struct {
+	// Strategy name, e.g. "partial".
+	Name string
+
+	// Optional strategy parameter following the name after a colon, e.g. "first_name" in "fake:first_name".
+	Param string
+
+	// Strategy options.
+	Options Options
+
+	// Secret keys available to keyed strategies; set by the Registry.
+	Keys *Keyring
+}
+
+// ParseSpec parses strategy definition from the config (e.g. "redact" or "fake:first_name").
+func ParseSpec(strategy string, options Options) *Spec {
+	spec := &Spec{Name: strategy, Options: options}
+
+	if i := strings.IndexByte(strategy, ':'); i >= 0 {
+		spec.Name, spec.Param = strategy[:i], strategy[i+1:]
+	}
+
+	return spec
+}
+
+// String returns strategy definition as it is written in the config.
+func (s *Spec) String() string {
+	if s.Param == "" {
+		return s.Name
+	}
+
+	return s.Name + ":" + s.Param
+}
+
+// Options contains strategy options from the config, e.g. `options = { keep_last = 4 }`.
+type Options map[string]interface{}
+
+// String returns string option value or the default value if the option is not set.
+func (o Options) String(name string, defaultValue string) (string, error) {
+	value, ok := o[name]
+
+	if !ok {
+		return defaultValue, nil
+	}
+
+	str, ok := value.(string)
+
+	if !ok {
+		return "", fmt.Errorf("masker: option %s must be a string, got %T", name, value)
+	}
+
+	return str, nil
+}
+
+// Int returns integer option value or the default value if the option is not set.
+func (o Options) Int(name string, defaultValue int) (int, error) {
+	value, ok := o[name]
+
+	if !ok {
+		return defaultValue, nil
+	}
+
+	// TOML decoder produces int64 for all integers
+	switch v := value.(type) {
+	case int64:
+		return int(v), nil
+
+	case int:
+		return v, nil
+
+	default:
+		return 0, fmt.Errorf("masker: option %s must be an integer, got %T", name, value)
+	}
+}
+
+// Bool returns boolean option value or the default value if the option is not set.
+func (o Options) Bool(name string, defaultValue bool) (bool, error) {
+	value, ok := o[name]
+
+	if !ok {
+		return defaultValue, nil
+	}
+
+	b, ok := value.(bool)
+
+	if !ok {
+		return false, fmt.Errorf("masker: option %s must be a boolean, got %T", name, value)
+	}
+
+	return b, nil
+}
+
+// Has returns true if the option is set.
+func (o Options) Has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
@@ -0,0 +1,52 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpec(t *testing.T) {
+	testCases := map[string]*Spec{
+		"redact":          {Name: "redact"},
+		"fake:first_name": {Name: "fake", Param: "first_name"},
+		"fake:":           {Name: "fake"},
+	}
+
+	for strategy, expected := range testCases {
+		assert.Equal(t, expected, ParseSpec(strategy, nil))
+	}
+
+	assert.Equal(t, "fake:first_name", ParseSpec("fake:first_name", nil).String())
+	assert.Equal(t, "redact", ParseSpec("redact", nil).String())
+}
+
+func TestOptions(t *testing.T) {
+	options := Options{"str": "value", "int": int64(42), "bool": true}
+
+	str, err := options.String("str", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", str)
+
+	str, err = options.String("missing", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "default", str)
+
+	_, err = options.String("int", "")
+	assert.Error(t, err)
+
+	num, err := options.Int("int", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, num)
+
+	num, err = options.Int("missing", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, num)
+
+	_, err = options.Int("str", 0)
+	assert.Error(t, err)
+
+	assert.True(t, options.Has("bool"))
+	assert.False(t, options.Has("missing"))
+	assert.False(t, Options(nil).Has("missing"))
+}
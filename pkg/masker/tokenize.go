@@ -0,0 +1,396 @@
+package masker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq/oid"
+)
+
+// Tokenization formats selected by the strategy parameter, e.g. "tokenize:email".
+const (
+	tokenizeFormatAuto   = ""
+	tokenizeFormatEmail  = "email"
+	tokenizeFormatPhone  = "phone"
+	tokenizeFormatUUID   = "uuid"
+	tokenizeFormatDigits = "digits"
+	tokenizeFormatText   = "text"
+)
+
+// NewTokenize constructs a Masker that replaces values with deterministic format-preserving tokens: the same
+// input always produces the same token as long as the key stays the same, so masked columns can still be
+// joined and grouped. Tokens keep the format of the original value:
+//
+//   - email — local part and domain labels are tokenized, the top-level domain is kept ("jane@example.com"
+//     becomes e.g. "qxbm@hwqnfmr.com"); set option "keep_domain" to keep the whole domain;
+//   - phone — digits are replaced, formatting characters are kept ("+1 (555) 010-0199" → "+7 (382) 946-1203");
+//   - uuid — hex digits are replaced, so the token is still a valid UUID;
+//   - digits — digits are replaced, other characters are kept; integers are permuted within the integers of
+//     the same sign and length that fit into the column type, so distinct integers get distinct tokens;
+//   - text — letters and digits are replaced preserving their case and class.
+//
+// The format is selected with the strategy parameter (e.g. "tokenize:email") or detected from the column
+// type and the value when the parameter is omitted.
+//
+// The secret key is taken from the keyring (active key or version "key_version"); an inline "key" option
+// is also supported.
+func NewTokenize(spec *Spec) (Masker, error) {
+	switch spec.Param {
+	case tokenizeFormatAuto, tokenizeFormatEmail, tokenizeFormatPhone, tokenizeFormatUUID,
+		tokenizeFormatDigits, tokenizeFormatText:
+
+	default:
+		return nil, fmt.Errorf("unknown tokenization format %q", spec.Param)
+	}
+
+	key, err := secretFromOptions(spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keepDomain, err := spec.Options.Bool("keep_domain", false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tokenizer{key: key, format: spec.Param, keepDomain: keepDomain}
+
+	return Func(t.tokenize), nil
+}
+
+// tokenizer implements the tokenize strategy.
+type tokenizer struct {
+	// Secret key
+	key []byte
+
+	// One of the tokenization formats
+	format string
+
+	// Do not tokenize domains of emails
+	keepDomain bool
+}
+
+// tokenize replaces the value with a token.
+func (t *tokenizer) tokenize(value []byte, typ oid.Oid) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	str := string(value)
+
+	switch t.detectFormat(str, typ) {
+	case tokenizeFormatEmail:
+		return []byte(t.tokenizeEmail(str)), nil
+
+	case tokenizeFormatPhone, tokenizeFormatDigits:
+		if bits := integerBits(typ); bits > 0 {
+			if token, ok := t.tokenizeInteger(str, bits); ok {
+				return []byte(token), nil
+			}
+		}
+
+		// Tokens depend only on digits, so differently formatted numbers produce the same digits
+		return []byte(t.substitute(str, t.stream("digits", digitsOnly(str)), digits)), nil
+
+	case tokenizeFormatUUID:
+		normalized := strings.ToLower(strings.ReplaceAll(strings.Trim(str, "{}"), "-", ""))
+		return []byte(t.substitute(str, t.stream("uuid", normalized), hexadecimal(str))), nil
+
+	default:
+		return []byte(t.substitute(str, t.stream("text", str), alphanumeric)), nil
+	}
+}
+
+// detectFormat returns tokenization format for the value.
+func (t *tokenizer) detectFormat(value string, typ oid.Oid) string {
+	if t.format != tokenizeFormatAuto {
+		return t.format
+	}
+
+	switch typ {
+	case oid.T_uuid:
+		return tokenizeFormatUUID
+
+	case oid.T_int2, oid.T_int4, oid.T_int8, oid.T_numeric:
+		return tokenizeFormatDigits
+	}
+
+	switch {
+	case strings.Count(value, "@") == 1 && strings.Contains(value[strings.IndexByte(value, '@'):], "."):
+		return tokenizeFormatEmail
+
+	case isUUID(value):
+		return tokenizeFormatUUID
+
+	case value != "" && strings.Trim(value, "0123456789+-() .") == "":
+		return tokenizeFormatPhone
+
+	default:
+		return tokenizeFormatText
+	}
+}
+
+// tokenizeEmail tokenizes local part and domain of an email.
+func (t *tokenizer) tokenizeEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+
+	// Not an email after all
+	if at < 0 {
+		return t.substitute(email, t.stream("text", email), alphanumeric)
+	}
+
+	local, domain := email[:at], email[at+1:]
+
+	// NB: local part depends on the whole email, while domain depends only on itself, so masked emails
+	// can still be grouped by domain
+	local = t.substitute(local, t.stream("email-local", strings.ToLower(email)), alphanumeric)
+
+	if !t.keepDomain {
+		labels := strings.Split(domain, ".")
+		stream := t.stream("email-domain", strings.ToLower(domain))
+
+		// Keep the top-level domain
+		for i := 0; i < len(labels)-1; i++ {
+			labels[i] = t.substitute(labels[i], stream, alphanumeric)
+		}
+
+		domain = strings.Join(labels, ".")
+	}
+
+	return local + "@" + domain
+}
+
+// Number of rounds of the Feistel network that permutes integers.
+const feistelRounds = 8
+
+// tokenizeInteger replaces the integer with another one of the same sign and number of digits that fits into
+// an integer type of the given size. It returns false if the value is not such an integer.
+func (t *tokenizer) tokenizeInteger(str string, bits int) (string, bool) {
+	num, err := strconv.ParseInt(str, 10, bits)
+
+	if err != nil {
+		return "", false
+	}
+
+	negative := num < 0
+
+	// Absolute value and the largest absolute value of the type with the same sign
+	magnitude, limit := uint64(num), uint64(1)<<(bits-1)-1
+
+	if negative {
+		magnitude, limit = uint64(-(num+1))+1, limit+1
+	}
+
+	// Range of the integers with the same number of digits
+	low, high := uint64(0), uint64(9)
+
+	for high < magnitude {
+		low, high = high+1, high*10+9
+	}
+
+	if negative && low == 0 {
+		low = 1
+	}
+
+	if high > limit {
+		high = limit
+	}
+
+	token := strconv.FormatUint(low+t.permute(magnitude-low, high-low+1), 10)
+
+	if negative {
+		token = "-" + token
+	}
+
+	return token, true
+}
+
+// permute maps x in [0, n) to a number in [0, n). Different numbers are always mapped to different ones:
+// the numbers are encrypted with a balanced Feistel network on the smallest even number of bits that
+// covers n, which is repeated (cycle-walking) until the result falls into [0, n).
+func (t *tokenizer) permute(x, n uint64) uint64 {
+	bits := uint(2)
+
+	for bits < 64 && (n-1)>>bits != 0 {
+		bits += 2
+	}
+
+	half := bits / 2
+	mask := uint64(1)<<half - 1
+
+	mac := hmac.New(sha256.New, t.key)
+	input := make([]byte, 10)
+
+	for {
+		left, right := x>>half, x&mask
+
+		for round := 0; round < feistelRounds; round++ {
+			input[0], input[1] = byte(bits), byte(round)
+			binary.BigEndian.PutUint64(input[2:], right)
+
+			mac.Reset()
+			mac.Write([]byte("integer\x00"))
+			mac.Write(input)
+
+			left, right = right, left^(binary.BigEndian.Uint64(mac.Sum(nil))&mask)
+		}
+
+		x = left<<half | right
+
+		if x < n {
+			return x
+		}
+	}
+}
+
+// integerBits returns the size of the integer type in bits or 0 if the type is not an integer type.
+func integerBits(typ oid.Oid) int {
+	switch typ {
+	case oid.T_int2:
+		return 16
+
+	case oid.T_int4:
+		return 32
+
+	case oid.T_int8:
+		return 64
+
+	default:
+		return 0
+	}
+}
+
+// Alphabets used to substitute characters.
+const (
+	digitAlphabet    = "0123456789"
+	lowerAlphabet    = "abcdefghijklmnopqrstuvwxyz"
+	upperAlphabet    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	hexLowerAlphabet = "0123456789abcdef"
+	hexUpperAlphabet = "0123456789ABCDEF"
+)
+
+// substitute replaces characters of the string with pseudo-random characters taken from the keystream.
+// alphabet returns the set of characters to choose the replacement from; characters with an empty
+// alphabet are kept as is.
+func (t *tokenizer) substitute(str string, stream *keystream, alphabet func(rune) string) string {
+	var sb strings.Builder
+
+	sb.Grow(len(str))
+
+	for _, r := range str {
+		chars := alphabet(r)
+
+		if chars == "" {
+			sb.WriteRune(r)
+			continue
+		}
+
+		sb.WriteByte(chars[stream.next(len(chars))])
+	}
+
+	return sb.String()
+}
+
+// stream initializes a keystream for the given input. Domain separates keystreams of different formats.
+func (t *tokenizer) stream(domain, input string) *keystream {
+	return newKeystream(t.key, domain, input)
+}
+
+// keystream is a deterministic stream of pseudo-random numbers derived from a secret key and an input
+// with HMAC-SHA256 in counter mode.
+type keystream struct {
+	mac     hash.Hash
+	seed    []byte
+	counter uint32
+	buf     []byte
+}
+
+// newKeystream initializes a keystream for the given key and input.
+// Domain separates keystreams used for different purposes.
+func newKeystream(key []byte, domain, input string) *keystream {
+	return &keystream{mac: hmac.New(sha256.New, key), seed: []byte(domain + "\x00" + input)}
+}
+
+// next returns a pseudo-random number in [0, n).
+func (s *keystream) next(n int) int {
+	// Refill the buffer
+	if len(s.buf) < 2 {
+		counterBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(counterBytes, s.counter)
+
+		s.mac.Reset()
+		s.mac.Write(counterBytes)
+		s.mac.Write(s.seed)
+
+		s.buf = s.mac.Sum(nil)
+		s.counter++
+	}
+
+	num := binary.BigEndian.Uint16(s.buf)
+	s.buf = s.buf[2:]
+
+	return int(num) % n
+}
+
+// digitsOnly returns all digits of the string.
+func digitsOnly(str string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+
+		return -1
+	}, str)
+}
+
+// digits is an alphabet function that replaces only digits.
+func digits(r rune) string {
+	if unicode.IsDigit(r) {
+		return digitAlphabet
+	}
+
+	return ""
+}
+
+// alphanumeric is an alphabet function that replaces letters and digits preserving their class and case.
+func alphanumeric(r rune) string {
+	switch {
+	case unicode.IsDigit(r):
+		return digitAlphabet
+
+	case unicode.IsUpper(r):
+		return upperAlphabet
+
+	case unicode.IsLetter(r):
+		return lowerAlphabet
+
+	default:
+		return ""
+	}
+}
+
+// hexadecimal returns an alphabet function that replaces hexadecimal digits. The case of the replacement
+// letters is the same as the case of the letters in the given string.
+func hexadecimal(str string) func(rune) string {
+	alphabet := hexLowerAlphabet
+
+	if strings.ToUpper(str) == str {
+		alphabet = hexUpperAlphabet
+	}
+
+	return func(r rune) string {
+		if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F') {
+			return alphabet
+		}
+
+		return ""
+	}
+}
@@ -0,0 +1,187 @@
+package masker
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTokenizer constructs tokenize masker with the given keys.
+func newTestTokenizer(t *testing.T, strategy string, options Options, keys ...*Key) Masker {
+	t.Helper()
+
+	keyring, err := NewKeyring(keys)
+	require.NoError(t, err)
+
+	registry := DefaultRegistry()
+	registry.SetKeyring(keyring)
+
+	m, err := registry.New(ParseSpec(strategy, options))
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestTokenize(t *testing.T) {
+	m := newTestTokenizer(t, "tokenize", nil, &Key{Version: 1, Secret: "secret"})
+
+	testCases := []struct {
+		value   string
+		typ     oid.Oid
+		pattern string
+	}{
+		{"jane.doe@example.com", oid.T_varchar, `^[a-z]{4}\.[a-z]{3}@[a-z]{7}\.com$`},
+		{"Jane.Doe+1@mail.example.co.uk", oid.T_text, `^[A-Z][a-z]{3}\.[A-Z][a-z]{2}\+\d@[a-z]{4}\.[a-z]{7}\.[a-z]{2}\.uk$`},
+		{"+1 (555) 010-0199", oid.T_text, `^\+\d \(\d{3}\) \d{3}-\d{4}$`},
+		{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", oid.T_uuid, `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`},
+		{"A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11", oid.T_text, `^[0-9A-F]{8}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{12}$`},
+		{"-1452268", oid.T_int4, `^-\d{7}$`},
+		{"Denis Diachkov", oid.T_varchar, `^[A-Z][a-z]{4} [A-Z][a-z]{7}$`},
+	}
+
+	for _, tc := range testCases {
+		token, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+
+		assert.Regexp(t, tc.pattern, string(token))
+		assert.NotEqual(t, tc.value, string(token))
+
+		// Tokenization is deterministic
+		again, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+		assert.Equal(t, token, again)
+	}
+
+	// NULL stays NULL
+	token, err := m.Mask(nil, oid.T_text)
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+}
+
+func TestTokenizeFormats(t *testing.T) {
+	key := &Key{Version: 1, Secret: "secret"}
+
+	// Emails with the same domain get the same masked domain
+	m := newTestTokenizer(t, "tokenize:email", nil, key)
+
+	token1, _ := m.Mask([]byte("jane@example.com"), oid.T_text)
+	token2, _ := m.Mask([]byte("john@example.com"), oid.T_text)
+
+	assert.Equal(t, strings.SplitN(string(token1), "@", 2)[1], strings.SplitN(string(token2), "@", 2)[1])
+	assert.NotEqual(t, token1, token2)
+
+	// Domain is preserved if asked
+	m = newTestTokenizer(t, "tokenize:email", Options{"keep_domain": true}, key)
+
+	token, _ := m.Mask([]byte("jane@example.com"), oid.T_text)
+	assert.Regexp(t, `^[a-z]{4}@example\.com$`, string(token))
+
+	// Phone numbers depend only on digits
+	m = newTestTokenizer(t, "tokenize:phone", nil, key)
+
+	token1, _ = m.Mask([]byte("+1 (555) 010-0199"), oid.T_text)
+	token2, _ = m.Mask([]byte("15550100199"), oid.T_text)
+	assert.Equal(t, digitsOnly(string(token1)), string(token2))
+
+	// Text format doesn't treat emails specially
+	m = newTestTokenizer(t, "tokenize:text", nil, key)
+
+	token, _ = m.Mask([]byte("jane@example.com"), oid.T_text)
+	assert.Regexp(t, `^[a-z]{4}@[a-z]{7}\.[a-z]{3}$`, string(token))
+
+	// Unknown format
+	_, err := DefaultRegistry().New(ParseSpec("tokenize:ssn", Options{"key": "secret"}))
+	assert.Error(t, err)
+}
+
+func TestTokenizeIntegers(t *testing.T) {
+	m := newTestTokenizer(t, "tokenize:digits", nil, &Key{Version: 1, Secret: "secret"})
+
+	// Every int2 value gets a distinct token of the same sign and length
+	tokens := make(map[string]int64)
+
+	for num := int64(math.MinInt16); num <= math.MaxInt16; num++ {
+		value := strconv.FormatInt(num, 10)
+
+		token, err := m.Mask([]byte(value), oid.T_int2)
+		require.NoError(t, err)
+
+		parsed, err := strconv.ParseInt(string(token), 10, 16)
+		require.NoError(t, err, value)
+
+		assert.Equal(t, len(value), len(token), value)
+		assert.Equal(t, num < 0, parsed < 0, value)
+
+		if other, ok := tokens[string(token)]; ok {
+			t.Fatalf("%d and %d have the same token %s", other, num, token)
+		}
+
+		tokens[string(token)] = num
+	}
+
+	// Values close to the type limits stay within the range
+	for _, tc := range []struct {
+		value string
+		typ   oid.Oid
+		bits  int
+	}{
+		{"2147483647", oid.T_int4, 32},
+		{"-2147483648", oid.T_int4, 32},
+		{"9223372036854775807", oid.T_int8, 64},
+		{"-9223372036854775808", oid.T_int8, 64},
+		{"0", oid.T_int8, 64},
+	} {
+		token, err := m.Mask([]byte(tc.value), tc.typ)
+		require.NoError(t, err)
+
+		_, err = strconv.ParseInt(string(token), 10, tc.bits)
+		assert.NoError(t, err, tc.value)
+		assert.Equal(t, len(tc.value), len(token), tc.value)
+	}
+
+	// Integers of the same value are joinable across integer types
+	token4, _ := m.Mask([]byte("1452268"), oid.T_int4)
+	token8, _ := m.Mask([]byte("1452268"), oid.T_int8)
+	assert.Equal(t, token4, token8)
+}
+
+func TestTokenizeKeyRotation(t *testing.T) {
+	oldKey := &Key{Version: 1, Secret: "old"}
+	newKey := &Key{Version: 2, Secret: "new"}
+
+	beforeRotation := newTestTokenizer(t, "tokenize", nil, oldKey)
+	afterRotation := newTestTokenizer(t, "tokenize", nil, oldKey, newKey)
+	pinned := newTestTokenizer(t, "tokenize", Options{"key_version": int64(1)}, oldKey, newKey)
+
+	value := []byte("jane@example.com")
+
+	tokenBefore, _ := beforeRotation.Mask(value, oid.T_text)
+	tokenAfter, _ := afterRotation.Mask(value, oid.T_text)
+	tokenPinned, _ := pinned.Mask(value, oid.T_text)
+
+	// New key produces new tokens
+	assert.NotEqual(t, tokenBefore, tokenAfter)
+
+	// Pinned key version keeps producing old tokens
+	assert.Equal(t, tokenBefore, tokenPinned)
+
+	// Tokenization requires a key
+	_, err := DefaultRegistry().New(ParseSpec("tokenize", nil))
+	assert.True(t, errors.Is(err, ErrNoKeys))
+
+	// Unknown key version
+	keyring, err := NewKeyring([]*Key{oldKey})
+	require.NoError(t, err)
+
+	registry := DefaultRegistry()
+	registry.SetKeyring(keyring)
+
+	_, err = registry.New(ParseSpec("tokenize", Options{"key_version": int64(2)}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package masker
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq/oid"
+)
+
+// Date/time layouts accepted by validation (PostgreSQL ISO output style).
+const (
+	dateLayout        = "2006-01-02"
+	timestampLayout   = "2006-01-02 15:04:05.999999"
+	timestamptzLayout = "2006-01-02 15:04:05.999999-07"
+
+	// Used for time zones with non-zero minutes offset, e.g. +05:30
+	timestamptzLongLayout = "2006-01-02 15:04:05.999999-07:00"
+)
+
+// IsTextType returns true if values of the given type can hold arbitrary text.
+func IsTextType(typ oid.Oid) bool {
+	switch typ {
+	case oid.T_text, oid.T_varchar, oid.T_bpchar, oid.T_name, oid.T_unknown:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// IsArrayType returns true if the given type is a known array type.
+func IsArrayType(typ oid.Oid) bool {
+	return strings.HasPrefix(oid.TypeName[typ], "_")
+}
+
+// IsValid returns true if the value in PostgreSQL text format is valid for the given data type.
+// Values of non-text types that are unknown to Gevulot (e.g. inet, interval, enums or domains) are considered
+// to be invalid, since it cannot be checked that the client is able to decode them.
+func IsValid(value []byte, typ oid.Oid) bool {
+	str := string(value)
+
+	switch typ {
+	case oid.T_int2:
+		_, err := strconv.ParseInt(str, 10, 16)
+		return err == nil
+
+	case oid.T_int4, oid.T_oid:
+		_, err := strconv.ParseInt(str, 10, 32)
+		return err == nil
+
+	case oid.T_int8:
+		_, err := strconv.ParseInt(str, 10, 64)
+		return err == nil
+
+	case oid.T_float4, oid.T_float8:
+		_, err := strconv.ParseFloat(str, 64)
+		return err == nil || str == "NaN" || str == "Infinity" || str == "-Infinity"
+
+	case oid.T_numeric:
+		return str == "NaN" || isNumeric(str)
+
+	case oid.T_bool:
+		_, err := parseBool(str)
+		return err == nil
+
+	case oid.T_uuid:
+		return isUUID(str)
+
+	case oid.T_date:
+		_, err := time.Parse(dateLayout, str)
+		return err == nil
+
+	case oid.T_timestamp:
+		_, err := time.Parse(timestampLayout, str)
+		return err == nil
+
+	case oid.T_timestamptz:
+		_, err := time.Parse(timestamptzLayout, str)
+
+		if err != nil {
+			_, err = time.Parse(timestamptzLongLayout, str)
+		}
+
+		return err == nil
+
+	case oid.T_json, oid.T_jsonb:
+		return json.Valid(value)
+
+	case oid.T_bytea:
+		return strings.HasPrefix(str, `\x`) && len(str)%2 == 0
+	}
+
+	if IsArrayType(typ) {
+		return strings.HasPrefix(str, "{") && strings.HasSuffix(str, "}")
+	}
+
+	return IsTextType(typ)
+}
+
+// IsValidLength returns true if the value in PostgreSQL text format fits the length limit of the given type
+// modifier: varchar(n) and char(n) values cannot be longer than n characters. Values of other types and of
+// types without the limit (typmod is -1) always fit.
+func IsValidLength(value []byte, typ oid.Oid, typmod int32) bool {
+	// NB: the type modifier of character types is the maximum length plus the size of the varlena header
+	const varHeaderSize = 4
+
+	if (typ != oid.T_varchar && typ != oid.T_bpchar) || typmod < varHeaderSize {
+		return true
+	}
+
+	return utf8.RuneCount(value) <= int(typmod-varHeaderSize)
+}
+
+// ZeroValue returns a constant valid for the given data type (e.g. 0 for integers or 1970-01-01 for dates).
+// It returns nil (i.e., NULL) if there is no such constant for the type.
+func ZeroValue(typ oid.Oid) []byte {
+	switch typ {
+	case oid.T_int2, oid.T_int4, oid.T_int8, oid.T_oid, oid.T_float4, oid.T_float8, oid.T_numeric:
+		return []byte("0")
+
+	case oid.T_bool:
+		return []byte("f")
+
+	case oid.T_uuid:
+		return []byte("00000000-0000-0000-0000-000000000000")
+
+	case oid.T_date:
+		return []byte("1970-01-01")
+
+	case oid.T_timestamp:
+		return []byte("1970-01-01 00:00:00")
+
+	case oid.T_timestamptz:
+		return []byte("1970-01-01 00:00:00+00")
+
+	case oid.T_json, oid.T_jsonb:
+		return []byte("null")
+
+	case oid.T_bytea:
+		return []byte(`\x`)
+	}
+
+	if IsTextType(typ) {
+		return []byte{}
+	}
+
+	if IsArrayType(typ) {
+		return []byte("{}")
+	}
+
+	return nil
+}
+
+// parseBool parses boolean in any of the formats accepted by PostgreSQL.
+func parseBool(str string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, nil
+
+	case "f", "false", "n", "no", "off", "0":
+		return false, nil
+
+	default:
+		return false, strconv.ErrSyntax
+	}
+}
+
+// isNumeric returns true if the string is a decimal number (e.g. "-12.50" or "1e10").
+func isNumeric(str string) bool {
+	str = strings.TrimSpace(str)
+
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		str = str[1:]
+	}
+
+	// Split off the exponent
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		exp := str[i+1:]
+
+		if exp != "" && (exp[0] == '+' || exp[0] == '-') {
+			exp = exp[1:]
+		}
+
+		if exp == "" || strings.Trim(exp, "0123456789") != "" {
+			return false
+		}
+
+		str = str[:i]
+	}
+
+	digits := strings.Replace(str, ".", "", 1)
+
+	return digits != "" && strings.Trim(digits, "0123456789") == ""
+}
+
+// isUUID returns true if the string is a UUID in any of the formats accepted by PostgreSQL
+// (with or without hyphens, optionally surrounded by braces).
+func isUUID(str string) bool {
+	if strings.HasPrefix(str, "{") && strings.HasSuffix(str, "}") {
+		str = str[1 : len(str)-1]
+	}
+
+	hex := strings.ReplaceAll(str, "-", "")
+
+	return len(hex) == 32 && strings.Trim(hex, "0123456789abcdefABCDEF") == ""
+}
@@ -0,0 +1,106 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValid(t *testing.T) {
+	testCases := []struct {
+		value string
+		typ   oid.Oid
+		valid bool
+	}{
+		{"anything", oid.T_text, true},
+		{"***", oid.T_varchar, true},
+		{"42", oid.T_int2, true},
+		{"40000", oid.T_int2, false},
+		{"-2147483648", oid.T_int4, true},
+		{"***", oid.T_int4, false},
+		{"9223372036854775807", oid.T_int8, true},
+		{"1.5", oid.T_int8, false},
+		{"1.5e10", oid.T_float8, true},
+		{"NaN", oid.T_float4, true},
+		{"-12.50", oid.T_numeric, true},
+		{"1e-5", oid.T_numeric, true},
+		{"12.5.0", oid.T_numeric, false},
+		{"+-1", oid.T_numeric, false},
+		{"t", oid.T_bool, true},
+		{"maybe", oid.T_bool, false},
+		{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", oid.T_uuid, true},
+		{"{a0eebc999c0b4ef8bb6d6bb9bd380a11}", oid.T_uuid, true},
+		{"a0eebc99-9c0b", oid.T_uuid, false},
+		{"1987-10-29", oid.T_date, true},
+		{"1987-13-29", oid.T_date, false},
+		{"1987-10-29 12:34:56.789", oid.T_timestamp, true},
+		{"1987-10-29 12:34:56+03", oid.T_timestamptz, true},
+		{"1987-10-29 12:34:56+05:30", oid.T_timestamptz, true},
+		{"1987-10-29", oid.T_timestamptz, false},
+		{`{"a": 1}`, oid.T_jsonb, true},
+		{`{"a": }`, oid.T_json, false},
+		{`\xdeadbeef`, oid.T_bytea, true},
+		{`deadbeef`, oid.T_bytea, false},
+		{"{1,2,3}", oid.T__int4, true},
+		{"1,2,3", oid.T__int4, false},
+		{"whatever", oid.T_inet, false},
+		{"127.0.0.1", oid.T_inet, false},
+		{"unknown", oid.T_unknown, true},
+	}
+
+	for _, tc := range testCases {
+		assert.Equalf(t, tc.valid, IsValid([]byte(tc.value), tc.typ), "%q of type %s", tc.value, oid.TypeName[tc.typ])
+	}
+}
+
+func TestIsValidLength(t *testing.T) {
+	testCases := []struct {
+		value  string
+		typ    oid.Oid
+		typmod int32
+		valid  bool
+	}{
+		{"abcde", oid.T_varchar, 5 + 4, true},
+		{"abcdef", oid.T_varchar, 5 + 4, false},
+		{"ёжик", oid.T_bpchar, 4 + 4, true},
+		{"anything", oid.T_varchar, -1, true},
+		{"anything", oid.T_text, 5 + 4, true},
+		{"123456", oid.T_numeric, 5 + 4, true},
+	}
+
+	for _, tc := range testCases {
+		assert.Equalf(t, tc.valid, IsValidLength([]byte(tc.value), tc.typ, tc.typmod), "%q of type %s(%d)", tc.value, oid.TypeName[tc.typ], tc.typmod)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	types := []oid.Oid{
+		oid.T_text, oid.T_varchar, oid.T_int2, oid.T_int4, oid.T_int8, oid.T_float8, oid.T_numeric, oid.T_bool,
+		oid.T_uuid, oid.T_date, oid.T_timestamp, oid.T_timestamptz, oid.T_json, oid.T_jsonb, oid.T_bytea, oid.T__text,
+	}
+
+	for _, typ := range types {
+		value := ZeroValue(typ)
+
+		assert.NotNilf(t, value, "type %s", oid.TypeName[typ])
+		assert.Truef(t, IsValid(value, typ), "type %s", oid.TypeName[typ])
+	}
+
+	// There is no zero value for unknown types
+	assert.Nil(t, ZeroValue(oid.T_inet))
+}
+
+func TestIsTextType(t *testing.T) {
+	assert.True(t, IsTextType(oid.T_text))
+	assert.True(t, IsTextType(oid.T_varchar))
+	assert.True(t, IsTextType(oid.T_bpchar))
+	assert.False(t, IsTextType(oid.T_int4))
+	assert.False(t, IsTextType(oid.T__text))
+}
+
+func TestIsArrayType(t *testing.T) {
+	assert.True(t, IsArrayType(oid.T__text))
+	assert.True(t, IsArrayType(oid.T__int4))
+	assert.False(t, IsArrayType(oid.T_text))
+}
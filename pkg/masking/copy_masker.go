@@ -0,0 +1,664 @@
+package masking
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgmeta"
+	"github.com/hired/gevulot/pkg/pgsql"
+)
+
+// Signature of the binary COPY format.
+const copyBinarySignature = "PGCOPY\n\xff\r\n\x00"
+
+// CopyMasker masks the data stream of a single COPY ... TO STDOUT command. Rows may be split across
+// CopyData messages: incomplete rows are kept until the rest of the row arrives.
+type CopyMasker struct {
+	// Data format and its options
+	stmt *pgsql.CopyStatement
+
+	// Maskers for every copied column; nil means that a column is not masked
+	fields []*fieldMasker
+
+	// Copied columns are unknown (e.g. COPY (query) TO): every value is replaced with NULL
+	nullify bool
+
+	// Data received but not processed yet
+	pending []byte
+
+	// The header (the column names row or the binary file header) is expected
+	header bool
+}
+
+// CopyMasker returns CopyMasker for the data of the given COPY ... TO STDOUT statement announced by
+// the CopyOutResponse message. It returns nil if none of the columns has to be masked. The statement
+// may be nil if it is unknown (e.g. COPY executed by a function); such data cannot be masked.
+func (e *Engine) CopyMasker(statement *pgsql.Statement, resp *pg.CopyOutResponseMessage) (*CopyMasker, error) {
+	if e == nil || len(e.maskers) == 0 {
+		return nil, nil
+	}
+
+	if statement == nil {
+		return nil, fmt.Errorf("masking: COPY data of an unknown statement")
+	}
+
+	stmt, err := pgsql.ParseCopyStatement(statement)
+
+	if err != nil {
+		return nil, fmt.Errorf("masking: cannot mask COPY data: %w", err)
+	}
+
+	m := &CopyMasker{
+		stmt:   stmt,
+		fields: make([]*fieldMasker, len(resp.ColumnFormats)),
+		header: stmt.Header || stmt.Format == pgsql.CopyFormatBinary,
+	}
+
+	// Fail closed: we can't tell which values to mask
+	if stmt.Query != "" {
+		log.Warn("masking: columns of COPY (query) TO STDOUT cannot be resolved; sending NULLs")
+
+		m.nullify = true
+
+		return m, nil
+	}
+
+	schema := stmt.Schema
+
+	if schema == "" {
+		// NB: the table is resolved through search_path of the session, which isn't known. Assuming the default
+		// schema is safe unless a table with the same name is masked in another schema.
+		if schemas := e.maskedTableSchemas(stmt.Table); len(schemas) > 0 {
+			log.Warnf("masking: COPY %s TO STDOUT may copy a masked table in schema %s; sending NULLs",
+				stmt.Table, strings.Join(schemas, ", "))
+
+			m.nullify = true
+
+			return m, nil
+		}
+
+		schema = DefaultSchema
+	}
+
+	columns, err := e.copyColumns(pgmeta.Table{Schema: schema, Name: stmt.Table}, stmt.Columns)
+
+	if err != nil || len(columns) != len(resp.ColumnFormats) {
+		// Table that has no masked columns is copied as is
+		if len(e.tableMaskedColumns(schema, stmt.Table)) == 0 {
+			return nil, nil
+		}
+
+		log.Warnf("masking: columns of COPY %s.%s TO STDOUT cannot be resolved (%v); sending NULLs", schema, stmt.Table, err)
+
+		m.nullify = true
+
+		return m, nil
+	}
+
+	hasMaskedFields := false
+
+	for i, column := range columns {
+		key := columnKey(schema, stmt.Table, column.Name)
+
+		if fm, ok := e.maskers[key]; ok {
+			m.fields[i] = &fieldMasker{
+				column: key,
+				typ:    column.TypeOID,
+				typmod: column.TypeModifier,
+				format: resp.ColumnFormats[i],
+				masker: fm,
+			}
+			hasMaskedFields = true
+		}
+	}
+
+	if !hasMaskedFields {
+		return nil, nil
+	}
+
+	return m, nil
+}
+
+// copyColumns returns the copied columns of the table: either the listed ones or all of them.
+func (e *Engine) copyColumns(table pgmeta.Table, names []string) ([]*pgmeta.Column, error) {
+	columns, ok := e.resolver.ResolveTable(table)
+
+	if !ok {
+		return nil, fmt.Errorf("unknown table")
+	}
+
+	if len(names) == 0 {
+		return columns, nil
+	}
+
+	listed := make([]*pgmeta.Column, len(names))
+
+	for i, name := range names {
+		for _, column := range columns {
+			if column.Name == name {
+				listed[i] = column
+			}
+		}
+
+		if listed[i] == nil {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+
+	return listed, nil
+}
+
+// Mask masks a chunk of COPY data (the contents of a CopyData message). It returns masked complete rows;
+// the returned data is empty if the chunk doesn't complete any row.
+func (m *CopyMasker) Mask(data []byte) ([]byte, error) {
+	// NB: do not keep a reference to the message data
+	m.pending = append(m.pending, data...)
+
+	var masked []byte
+
+	for len(m.pending) > 0 {
+		var (
+			n   int
+			row []byte
+			err error
+		)
+
+		switch m.stmt.Format {
+		case pgsql.CopyFormatBinary:
+			n, row, err = m.binaryRow()
+
+		case pgsql.CopyFormatCSV:
+			n, row, err = m.csvRow()
+
+		default:
+			n, row, err = m.textRow()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Incomplete row
+		if n == 0 {
+			break
+		}
+
+		masked = append(masked, row...)
+		m.pending = m.pending[n:]
+	}
+
+	// Release the processed data
+	m.pending = append([]byte(nil), m.pending...)
+
+	return masked, nil
+}
+
+// Close checks that the data stream has ended with a complete row.
+func (m *CopyMasker) Close() error {
+	if len(m.pending) > 0 {
+		m.pending = nil
+		return fmt.Errorf("masking: COPY data ends with an incomplete row")
+	}
+
+	return nil
+}
+
+// textRow masks the first row of the pending data in text format. It returns the number of consumed bytes
+// (zero if the row is incomplete) and the masked row.
+func (m *CopyMasker) textRow() (int, []byte, error) {
+	// Newlines in values are always escaped
+	end := bytes.IndexByte(m.pending, '\n')
+
+	if end < 0 {
+		return 0, nil, nil
+	}
+
+	line := m.pending[:end+1]
+
+	// Header and the end-of-data marker are sent as is
+	if m.header || bytes.Equal(line, []byte("\\.\n")) {
+		m.header = false
+		return len(line), line, nil
+	}
+
+	values := splitCopyTextRow(line[:end], m.stmt.Delimiter)
+
+	err := m.checkValuesCount(len(values))
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for i, value := range values {
+		if string(value) == m.stmt.Null {
+			continue
+		}
+
+		switch {
+		case m.nullify:
+			values[i] = []byte(m.stmt.Null)
+
+		case m.fields[i] != nil:
+			masked := m.fields[i].mask(unescapeCopyText(value))
+
+			if masked == nil {
+				values[i] = []byte(m.stmt.Null)
+			} else {
+				values[i] = escapeCopyText(masked, m.stmt.Delimiter)
+			}
+		}
+	}
+
+	return len(line), append(bytes.Join(values, []byte{m.stmt.Delimiter}), '\n'), nil
+}
+
+// csvRow masks the first row of the pending data in CSV format. It returns the number of consumed bytes
+// (zero if the row is incomplete) and the masked row.
+func (m *CopyMasker) csvRow() (int, []byte, error) {
+	end, ok := m.csvRowEnd()
+
+	if !ok {
+		return 0, nil, nil
+	}
+
+	line := m.pending[:end+1]
+
+	if m.header {
+		m.header = false
+		return len(line), line, nil
+	}
+
+	// Line ending is kept as is (\n or \r\n)
+	content, eol := line[:end], line[end:]
+
+	if bytes.HasSuffix(content, []byte{'\r'}) {
+		content, eol = content[:len(content)-1], line[end-1:]
+	}
+
+	values := m.splitCSVRow(content)
+
+	err := m.checkValuesCount(len(values))
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	nullValue := []byte(m.stmt.Null)
+	masked := make([][]byte, len(values))
+
+	for i, value := range values {
+		masked[i] = value.raw
+
+		// Unquoted NULL string means NULL
+		if !value.quoted && string(value.raw) == m.stmt.Null {
+			continue
+		}
+
+		switch {
+		case m.nullify:
+			masked[i] = nullValue
+
+		case m.fields[i] != nil:
+			if v := m.fields[i].mask(value.decoded); v == nil {
+				masked[i] = nullValue
+			} else {
+				masked[i] = m.quoteCSV(v)
+			}
+		}
+	}
+
+	row := bytes.Join(masked, []byte{m.stmt.Delimiter})
+
+	return len(line), append(row, eol...), nil
+}
+
+// binaryRow masks the first tuple of the pending data in binary format. It returns the number of consumed
+// bytes (zero if the tuple is incomplete) and the masked tuple.
+func (m *CopyMasker) binaryRow() (int, []byte, error) {
+	data := m.pending
+
+	// File header: signature, int32 flags and int32 length of the header extension area
+	if m.header {
+		headerLen := len(copyBinarySignature) + 8
+
+		if len(data) < headerLen {
+			return 0, nil, nil
+		}
+
+		if string(data[:len(copyBinarySignature)]) != copyBinarySignature {
+			return 0, nil, fmt.Errorf("masking: invalid binary COPY signature")
+		}
+
+		extensionLen := int(binary.BigEndian.Uint32(data[headerLen-4:]))
+
+		if len(data) < headerLen+extensionLen {
+			return 0, nil, nil
+		}
+
+		m.header = false
+
+		return headerLen + extensionLen, data[:headerLen+extensionLen], nil
+	}
+
+	if len(data) < 2 {
+		return 0, nil, nil
+	}
+
+	count := int16(binary.BigEndian.Uint16(data))
+
+	// File trailer
+	if count == -1 {
+		return 2, data[:2], nil
+	}
+
+	err := m.checkValuesCount(int(count))
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var tuple pg.WriteBuffer
+
+	tuple.WriteInt16(count)
+
+	pos := 2
+
+	for i := 0; i < int(count); i++ {
+		if len(data) < pos+4 {
+			return 0, nil, nil
+		}
+
+		size := int(int32(binary.BigEndian.Uint32(data[pos:])))
+		pos += 4
+
+		// NULL
+		if size < 0 {
+			tuple.WriteInt32(-1)
+			continue
+		}
+
+		if len(data) < pos+size {
+			return 0, nil, nil
+		}
+
+		value := data[pos : pos+size]
+		pos += size
+
+		switch {
+		case m.nullify:
+			value = nil
+
+		case m.fields[i] != nil:
+			value = m.fields[i].mask(value)
+		}
+
+		if value == nil {
+			tuple.WriteInt32(-1)
+		} else {
+			tuple.WriteInt32(int32(len(value)))
+			tuple.WriteBytes(value)
+		}
+	}
+
+	return pos, tuple, nil
+}
+
+// checkValuesCount checks that a row has a value for every copied column.
+func (m *CopyMasker) checkValuesCount(count int) error {
+	if count != len(m.fields) {
+		return fmt.Errorf("masking: COPY row has %d values, but %d columns are copied", count, len(m.fields))
+	}
+
+	return nil
+}
+
+// csvRowEnd returns the position of the newline that ends the first CSV row of the pending data.
+// Newlines inside quoted values don't end the row.
+func (m *CopyMasker) csvRowEnd() (int, bool) {
+	quote, escape := m.stmt.Quote, m.stmt.Escape
+	quoted := false
+
+	for i := 0; i < len(m.pending); i++ {
+		c := m.pending[i]
+
+		switch {
+		case !quoted && c == '\n':
+			return i, true
+
+		case !quoted && c == quote:
+			quoted = true
+
+		case quoted && (c == escape || c == quote):
+			// Need the next character to tell an escape sequence from the closing quote
+			if i+1 == len(m.pending) {
+				return 0, false
+			}
+
+			next := m.pending[i+1]
+
+			if c == escape && (next == quote || next == escape) {
+				i++
+			} else if c == quote {
+				quoted = false
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// csvValue is a value of a CSV row.
+type csvValue struct {
+	// Value as it appears in the row
+	raw []byte
+
+	// Unquoted and unescaped value
+	decoded []byte
+
+	// The value is quoted
+	quoted bool
+}
+
+// splitCSVRow splits a CSV row (without the line ending) into values.
+func (m *CopyMasker) splitCSVRow(row []byte) []*csvValue {
+	quote, escape, delimiter := m.stmt.Quote, m.stmt.Escape, m.stmt.Delimiter
+
+	var values []*csvValue
+
+	value := &csvValue{decoded: []byte{}}
+	start, quoted := 0, false
+
+	for i := 0; i < len(row); i++ {
+		c := row[i]
+
+		switch {
+		case quoted && c == escape && i+1 < len(row) && (row[i+1] == quote || row[i+1] == escape):
+			value.decoded = append(value.decoded, row[i+1])
+			i++
+
+		case quoted && c == quote:
+			quoted = false
+
+		case quoted:
+			value.decoded = append(value.decoded, c)
+
+		case c == quote:
+			quoted, value.quoted = true, true
+
+		case c == delimiter:
+			value.raw = row[start:i]
+			values = append(values, value)
+
+			value = &csvValue{decoded: []byte{}}
+			start = i + 1
+
+		default:
+			value.decoded = append(value.decoded, c)
+		}
+	}
+
+	value.raw = row[start:]
+
+	return append(values, value)
+}
+
+// quoteCSV encodes a value for CSV format quoting it if necessary.
+func (m *CopyMasker) quoteCSV(value []byte) []byte {
+	quote, escape := m.stmt.Quote, m.stmt.Escape
+
+	needsQuotes := string(value) == m.stmt.Null || string(value) == "\\." ||
+		bytes.IndexByte(value, m.stmt.Delimiter) >= 0 || bytes.IndexByte(value, quote) >= 0 ||
+		bytes.IndexAny(value, "\r\n") >= 0
+
+	if !needsQuotes {
+		return value
+	}
+
+	quoted := make([]byte, 0, len(value)+2)
+	quoted = append(quoted, quote)
+
+	for _, c := range value {
+		if c == quote || c == escape {
+			quoted = append(quoted, escape)
+		}
+
+		quoted = append(quoted, c)
+	}
+
+	return append(quoted, quote)
+}
+
+// splitCopyTextRow splits a row in text format (without the newline) into values. Escaped delimiters
+// don't split values.
+func splitCopyTextRow(row []byte, delimiter byte) [][]byte {
+	var values [][]byte
+
+	start := 0
+
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\\':
+			i++
+
+		case delimiter:
+			values = append(values, row[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(values, row[start:])
+}
+
+// unescapeCopyText decodes backslash escape sequences of the text format: \b, \f, \n, \r, \t, \v,
+// octal (\123) and hex (\x5F) character codes; a backslash followed by any other character stands for it.
+func unescapeCopyText(value []byte) []byte {
+	if bytes.IndexByte(value, '\\') < 0 {
+		return value
+	}
+
+	decoded := make([]byte, 0, len(value))
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			decoded = append(decoded, value[i])
+			continue
+		}
+
+		i++
+
+		switch c := value[i]; {
+		case c >= '0' && c <= '7':
+			code := 0
+
+			for n := 0; n < 3 && i < len(value) && value[i] >= '0' && value[i] <= '7'; n++ {
+				code = code*8 + int(value[i]-'0')
+				i++
+			}
+
+			decoded = append(decoded, byte(code))
+			i--
+
+		case c == 'x' && i+1 < len(value) && isHexDigit(value[i+1]):
+			code := 0
+			i++
+
+			for n := 0; n < 2 && i < len(value) && isHexDigit(value[i]); n++ {
+				code = code*16 + hexDigitValue(value[i])
+				i++
+			}
+
+			decoded = append(decoded, byte(code))
+			i--
+
+		default:
+			decoded = append(decoded, copyTextEscapes()[c])
+		}
+	}
+
+	return decoded
+}
+
+// escapeCopyText encodes a value for text format escaping backslashes, control characters and the delimiter.
+func escapeCopyText(value []byte, delimiter byte) []byte {
+	escaped := make([]byte, 0, len(value))
+
+	for _, c := range value {
+		switch c {
+		case '\\':
+			escaped = append(escaped, '\\', '\\')
+		case '\b':
+			escaped = append(escaped, '\\', 'b')
+		case '\f':
+			escaped = append(escaped, '\\', 'f')
+		case '\n':
+			escaped = append(escaped, '\\', 'n')
+		case '\r':
+			escaped = append(escaped, '\\', 'r')
+		case '\t':
+			escaped = append(escaped, '\\', 't')
+		case '\v':
+			escaped = append(escaped, '\\', 'v')
+		case delimiter:
+			escaped = append(escaped, '\\', c)
+		default:
+			escaped = append(escaped, c)
+		}
+	}
+
+	return escaped
+}
+
+// copyTextEscapes maps characters following a backslash in text format to the characters they stand for.
+func copyTextEscapes() *[256]byte {
+	var escapes [256]byte
+
+	for i := range escapes {
+		escapes[i] = byte(i)
+	}
+
+	escapes['b'], escapes['f'], escapes['n'], escapes['r'], escapes['t'], escapes['v'] = '\b', '\f', '\n', '\r', '\t', '\v'
+
+	return &escapes
+}
+
+// isHexDigit returns true for hexadecimal digits.
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// hexDigitValue returns the value of a hexadecimal digit.
+func hexDigitValue(c byte) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	default:
+		return int(c - '0')
+	}
+}
@@ -0,0 +1,262 @@
+package masking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgsql"
+)
+
+func testCopyStatement(sql string) *pgsql.Statement {
+	return pgsql.SplitStatements(sql)[0]
+}
+
+func testCopyOutResponse(format pg.DataFormat, columns int) *pg.CopyOutResponseMessage {
+	resp := &pg.CopyOutResponseMessage{Format: format, ColumnFormats: make([]pg.DataFormat, columns)}
+
+	for i := range resp.ColumnFormats {
+		resp.ColumnFormats[i] = format
+	}
+
+	return resp
+}
+
+func testCopyMasker(t *testing.T, sql string, columns int) *CopyMasker {
+	t.Helper()
+
+	engine, err := NewEngine([]*Rule{
+		{Table: "users", Column: "email", Strategy: "redact"},
+		{Table: "users", Column: "name", Strategy: "partial", Options: masker.Options{"keep_first": int64(1)}},
+	}, testResolver())
+	require.NoError(t, err)
+
+	format := pg.DataFormatText
+
+	if strings.Contains(sql, "binary") {
+		format = pg.DataFormatBinary
+	}
+
+	m, err := engine.CopyMasker(testCopyStatement(sql), testCopyOutResponse(format, columns))
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	return m
+}
+
+// maskCopyChunks masks the data split into chunks of the given size.
+func maskCopyChunks(t *testing.T, m *CopyMasker, data string, size int) string {
+	t.Helper()
+
+	var masked []byte
+
+	for len(data) > 0 {
+		n := size
+
+		if n > len(data) {
+			n = len(data)
+		}
+
+		chunk, err := m.Mask([]byte(data[:n]))
+		require.NoError(t, err)
+
+		masked = append(masked, chunk...)
+		data = data[n:]
+	}
+
+	require.NoError(t, m.Close())
+
+	return string(masked)
+}
+
+func TestEngineCopyMasker(t *testing.T) {
+	engine, err := NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "redact"}}, testResolver())
+	require.NoError(t, err)
+
+	copyMasker := func(sql string, columns int) *CopyMasker {
+		m, err := engine.CopyMasker(testCopyStatement(sql), testCopyOutResponse(pg.DataFormatText, columns))
+		require.NoError(t, err)
+
+		return m
+	}
+
+	// Table with masked columns
+	m := copyMasker("COPY users TO STDOUT", 5)
+	require.NotNil(t, m)
+	assert.False(t, m.nullify)
+	assert.NotNil(t, m.fields[3])
+
+	// Masked column is not copied
+	assert.Nil(t, copyMasker("COPY users (id, name) TO STDOUT", 2))
+
+	// Table without masked columns
+	assert.Nil(t, copyMasker("COPY companies TO STDOUT", 2))
+
+	// Query source cannot be resolved
+	m = copyMasker("COPY (SELECT 1) TO STDOUT", 1)
+	require.NotNil(t, m)
+	assert.True(t, m.nullify)
+
+	// Unknown column of a table with masked columns
+	m = copyMasker("COPY users (id, phone) TO STDOUT", 2)
+	require.NotNil(t, m)
+	assert.True(t, m.nullify)
+
+	// Unqualified name of a table masked in a non-default schema may be resolved to it through search_path
+	engine, err = NewEngine([]*Rule{{Table: "billing.cards", Column: "number", Strategy: "redact"}}, testResolver())
+	require.NoError(t, err)
+
+	m = copyMasker("COPY cards TO STDOUT", 3)
+	require.NotNil(t, m)
+	assert.True(t, m.nullify)
+
+	m = copyMasker("COPY users TO STDOUT", 5)
+	assert.Nil(t, m)
+
+	m = copyMasker("COPY billing.cards (id) TO STDOUT", 1)
+	require.NotNil(t, m)
+	assert.True(t, m.nullify)
+
+	// Unknown or unparsable statement
+	_, err = engine.CopyMasker(nil, testCopyOutResponse(pg.DataFormatText, 1))
+	assert.Error(t, err)
+
+	_, err = engine.CopyMasker(testCopyStatement("SELECT copy_users()"), testCopyOutResponse(pg.DataFormatText, 1))
+	assert.Error(t, err)
+
+	// Engine without rules
+	engine, err = NewEngine(nil, testResolver())
+	require.NoError(t, err)
+
+	assert.Nil(t, copyMasker("COPY (SELECT 1) TO STDOUT", 1))
+
+	m, err = engine.CopyMasker(nil, testCopyOutResponse(pg.DataFormatText, 1))
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestCopyMaskerText(t *testing.T) {
+	data := "1\t7\tJane Doe\tjane@example.com\t\\N\n" +
+		"2\t7\tJohn\\tSmith\tjohn@example.com\t{\"a\": 1}\n" +
+		"3\t7\t\\N\t\\N\t\\N\n"
+
+	expected := "1\t7\tJ*******\t***\t\\N\n" +
+		"2\t7\tJ*********\t***\t{\"a\": 1}\n" +
+		"3\t7\t\\N\t\\N\t\\N\n"
+
+	for _, size := range []int{1, 5, len(data)} {
+		m := testCopyMasker(t, "COPY users TO STDOUT", 5)
+		assert.Equal(t, expected, maskCopyChunks(t, m, data, size), "chunk size %d", size)
+	}
+
+	t.Run("custom delimiter and NULL", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY public.users (email, id) TO STDOUT WITH (DELIMITER '|', NULL 'null')", 2)
+		assert.Equal(t, "***|1\nnull|2\n", maskCopyChunks(t, m, "a\\|b@example.com|1\nnull|2\n", 3))
+	})
+
+	t.Run("header", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY users (name) TO STDOUT WITH (HEADER)", 1)
+		assert.Equal(t, "name\nJ*******\n", maskCopyChunks(t, m, "name\nJane Doe\n", 4))
+	})
+
+	t.Run("masked values are escaped", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY users (name) TO STDOUT", 1)
+		assert.Equal(t, "\\\\*\n", maskCopyChunks(t, m, "\\\\\\t\n", 100))
+	})
+
+	t.Run("incomplete row", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY users (name) TO STDOUT", 1)
+
+		masked, err := m.Mask([]byte("Jane"))
+		require.NoError(t, err)
+		assert.Empty(t, masked)
+
+		assert.Error(t, m.Close())
+	})
+
+	t.Run("wrong number of values", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY users (name) TO STDOUT", 1)
+
+		_, err := m.Mask([]byte("Jane\tDoe\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("query source", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY (SELECT id, email FROM users) TO STDOUT", 2)
+		assert.Equal(t, "\\N\t\\N\n", maskCopyChunks(t, m, "1\tjane@example.com\n", 100))
+	})
+}
+
+func TestCopyMaskerCSV(t *testing.T) {
+	data := "id,name,email\n" +
+		"1,Jane Doe,jane@example.com\n" +
+		"2,\"Smith, John\",\"john\"\"s@example.com\"\n" +
+		"3,\"multi\r\nline\",\n" +
+		"4,\"\",\"\"\r\n"
+
+	expected := "id,name,email\n" +
+		"1,J*******,***\n" +
+		"2,S**********,***\n" +
+		"3,m**********,\n" +
+		"4,\"\",***\r\n"
+
+	for _, size := range []int{1, 7, len(data)} {
+		m := testCopyMasker(t, "COPY users (id, name, email) TO STDOUT WITH CSV HEADER", 3)
+		assert.Equal(t, expected, maskCopyChunks(t, m, data, size), "chunk size %d", size)
+	}
+
+	t.Run("custom quote and escape", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY users (name) TO STDOUT WITH (FORMAT csv, QUOTE '''', ESCAPE '\\')", 1)
+		assert.Equal(t, "'\\'******'\n", maskCopyChunks(t, m, "'\\'Jane,\\\\'\n", 100))
+	})
+
+	t.Run("masked value equal to NULL string is quoted", func(t *testing.T) {
+		engine, err := NewEngine([]*Rule{
+			{Table: "users", Column: "name", Strategy: "redact", Options: masker.Options{"value": "NA"}},
+		}, testResolver())
+		require.NoError(t, err)
+
+		m, err := engine.CopyMasker(testCopyStatement("COPY users (name) TO STDOUT WITH (FORMAT csv, NULL 'NA')"),
+			testCopyOutResponse(pg.DataFormatText, 1))
+		require.NoError(t, err)
+		require.NotNil(t, m)
+
+		assert.Equal(t, "\"NA\"\nNA\n", maskCopyChunks(t, m, "Jane\nNA\n", 100))
+	})
+}
+
+func TestCopyMaskerBinary(t *testing.T) {
+	header := copyBinarySignature + "\x00\x00\x00\x00" + "\x00\x00\x00\x02" + "\xab\xcd"
+
+	data := header +
+		"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x01" + "\x00\x00\x00\x08Jane Doe" + "\x00\x00\x00\x10jane@example.com" +
+		"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x02" + "\xff\xff\xff\xff" + "\xff\xff\xff\xff" +
+		"\xff\xff"
+
+	expected := header +
+		"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x01" + "\x00\x00\x00\x08J*******" + "\x00\x00\x00\x03***" +
+		"\x00\x03" + "\x00\x00\x00\x04\x00\x00\x00\x02" + "\xff\xff\xff\xff" + "\xff\xff\xff\xff" +
+		"\xff\xff"
+
+	for _, size := range []int{1, 6, len(data)} {
+		m := testCopyMasker(t, "COPY users (id, name, email) TO STDOUT (FORMAT binary)", 3)
+		assert.Equal(t, expected, maskCopyChunks(t, m, data, size), "chunk size %d", size)
+	}
+
+	t.Run("invalid signature", func(t *testing.T) {
+		m := testCopyMasker(t, "COPY users (name) TO STDOUT (FORMAT binary)", 1)
+
+		_, err := m.Mask([]byte("PGCOPY\n\xff\r\n\x01\x00\x00\x00\x00\x00\x00\x00\x00"))
+		assert.Error(t, err)
+	})
+}
+
+func TestCopyTextEscapes(t *testing.T) {
+	assert.Equal(t, []byte("a\tb\nc\\d\x01S|"), unescapeCopyText([]byte(`a\tb\nc\\d\1\x53\|`)))
+	assert.Equal(t, []byte(`a\tb\nc\\d|`), escapeCopyText([]byte("a\tb\nc\\d|"), ','))
+	assert.Equal(t, []byte(`a\|b`), escapeCopyText([]byte("a|b"), '|'))
+}
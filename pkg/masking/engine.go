@@ -0,0 +1,319 @@
+package masking
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq/oid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgmeta"
+)
+
+// Objects with lower OIDs are created by initdb (system catalogs and information_schema views), so they never
+// hold user data.
+const firstNormalObjectID = 16384
+
+// ColumnResolver resolves RowDescription fields to table columns.
+type ColumnResolver interface {
+	// ResolveColumn returns the column identified by the table OID and the column attribute number.
+	// The second returned value is false when the column is unknown.
+	ResolveColumn(tableOID oid.Oid, index int16) (*pgmeta.Column, bool)
+
+	// ResolveTable returns live columns of the table in the attribute number order (i.e., the order of
+	// COPY table TO output). The second returned value is false when the table is unknown.
+	ResolveTable(table pgmeta.Table) ([]*pgmeta.Column, bool)
+}
+
+// FallibleColumnResolver is a ColumnResolver that loads the metadata from the database and may fail to do that
+// (e.g. when the database is unreachable). Columns cannot be resolved then, so RowMasker sends NULLs instead of
+// the values of all table columns.
+type FallibleColumnResolver interface {
+	ColumnResolver
+
+	// LoadFailed returns true if the last attempt to load the metadata has failed.
+	LoadFailed() bool
+}
+
+// Engine masks query results according to the configured rules.
+type Engine struct {
+	// Used to map RowDescription fields to table columns
+	resolver ColumnResolver
+
+	// Maskers keyed by fully qualified column name
+	maskers map[string]masker.Masker
+}
+
+// NewEngine initializes a new Engine with the given rules. Strategies are looked up in the default registry.
+func NewEngine(rules []*Rule, resolver ColumnResolver) (*Engine, error) {
+	return NewEngineWithRegistry(rules, resolver, masker.DefaultRegistry())
+}
+
+// NewEngineWithRegistry initializes a new Engine with the given rules and masking strategies registry.
+func NewEngineWithRegistry(rules []*Rule, resolver ColumnResolver, registry *masker.Registry) (*Engine, error) {
+	maskers := make(map[string]masker.Masker, len(rules))
+	jsonMaskers := make(map[string]*jsonMasker)
+
+	for _, rule := range rules {
+		err := rule.Validate()
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := registry.New(rule.Spec())
+
+		if err != nil {
+			return nil, fmt.Errorf("masking: rule for %s: %w", rule.Key(), err)
+		}
+
+		if rule.Path == "" {
+			maskers[rule.Key()] = m
+			continue
+		}
+
+		// NB: the path is validated above
+		path, _ := parseJSONPath(rule.Path)
+
+		if jsonMaskers[rule.Key()] == nil {
+			jsonMaskers[rule.Key()] = &jsonMasker{}
+		}
+
+		jsonMaskers[rule.Key()].add(path, m)
+	}
+
+	for key, m := range jsonMaskers {
+		if _, ok := maskers[key]; ok {
+			return nil, fmt.Errorf("masking: rules for %s: column cannot be masked both as a whole and by JSON paths", key)
+		}
+
+		maskers[key] = m
+	}
+
+	return &Engine{resolver: resolver, maskers: maskers}, nil
+}
+
+// RowMasker returns RowMasker for the rows described by the given RowDescriptionMessage.
+// It returns nil if none of the fields has to be masked.
+func (e *Engine) RowMasker(desc *pg.RowDescriptionMessage) *RowMasker {
+	if e == nil || len(e.maskers) == 0 || desc == nil {
+		return nil
+	}
+
+	fields := make([]*fieldMasker, len(desc.Fields))
+	hasMaskedFields := false
+
+	for i, field := range desc.Fields {
+		// Field is not a table column (e.g., an expression)
+		if field.TableOID == 0 || field.ColumnIndex <= 0 {
+			continue
+		}
+
+		column, ok := e.resolver.ResolveColumn(field.TableOID, field.ColumnIndex)
+
+		// Fail closed: the column may be masked (e.g. the table has been recreated after the columns were loaded)
+		if !ok && (field.TableOID >= firstNormalObjectID || e.loadFailed()) {
+			log.Warnf("masking: column %d of table %d cannot be resolved; sending NULLs", field.ColumnIndex, field.TableOID)
+
+			fields[i] = &fieldMasker{column: fmt.Sprintf("%d.%d", field.TableOID, field.ColumnIndex), nullify: true}
+			hasMaskedFields = true
+
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		key := columnKey(column.Table.Schema, column.Table.Name, column.Name)
+
+		if m, ok := e.maskers[key]; ok {
+			fields[i] = &fieldMasker{
+				column: key,
+				typ:    field.DataTypeOID,
+				typmod: field.DataTypeModifier,
+				format: field.Format,
+				masker: m,
+			}
+			hasMaskedFields = true
+		}
+	}
+
+	if !hasMaskedFields {
+		return nil
+	}
+
+	return &RowMasker{fields: fields}
+}
+
+// loadFailed returns true if the resolver has failed to load the column metadata.
+func (e *Engine) loadFailed() bool {
+	resolver, ok := e.resolver.(FallibleColumnResolver)
+	return ok && resolver.LoadFailed()
+}
+
+// columnMasker returns the masker of the given table column.
+func (e *Engine) columnMasker(schema, table, column string) (masker.Masker, bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	m, ok := e.maskers[columnKey(schema, table, column)]
+
+	return m, ok
+}
+
+// tableMaskedColumns returns names of the masked columns of the given table in alphabetical order.
+func (e *Engine) tableMaskedColumns(schema, table string) []string {
+	if e == nil {
+		return nil
+	}
+
+	prefix := columnKey(schema, table, "")
+
+	var columns []string
+
+	for key := range e.maskers {
+		if strings.HasPrefix(key, prefix) {
+			columns = append(columns, key[len(prefix):])
+		}
+	}
+
+	sort.Strings(columns)
+
+	return columns
+}
+
+// maskedTableSchemas returns schemas other than the default one that have a table with masked columns with
+// the given name, in alphabetical order.
+func (e *Engine) maskedTableSchemas(table string) []string {
+	if e == nil {
+		return nil
+	}
+
+	found := make(map[string]bool)
+
+	for key := range e.maskers {
+		parts := strings.SplitN(key, ".", 3)
+
+		if len(parts) == 3 && parts[1] == table && parts[0] != DefaultSchema {
+			found[parts[0]] = true
+		}
+	}
+
+	schemas := make([]string, 0, len(found))
+
+	for schema := range found {
+		schemas = append(schemas, schema)
+	}
+
+	sort.Strings(schemas)
+
+	return schemas
+}
+
+// columnMaskerByName returns the masker of a masked column with the given name in any table.
+// If several tables have such masked columns, the first one in the alphabetical order is returned.
+func (e *Engine) columnMaskerByName(column string) (masker.Masker, bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	found := ""
+
+	for key := range e.maskers {
+		if strings.HasSuffix(key, "."+column) && (found == "" || key < found) {
+			found = key
+		}
+	}
+
+	if found == "" {
+		return nil, false
+	}
+
+	return e.maskers[found], true
+}
+
+// RowMasker masks DataRows of a single result set.
+type RowMasker struct {
+	// Maskers for every field of the result set; nil means that a field is not masked
+	fields []*fieldMasker
+}
+
+// fieldMasker masks a single field of a result set.
+type fieldMasker struct {
+	// Fully qualified column name (for logging)
+	column string
+
+	// Data type of the field values
+	typ oid.Oid
+
+	// Type modifier of the field (e.g. maximum length of varchar values plus 4); -1 if there is none
+	typmod int32
+
+	// Wire format of the field values
+	format pg.DataFormat
+
+	// Masking strategy
+	masker masker.Masker
+
+	// Every value is replaced with NULL (the column is unknown)
+	nullify bool
+}
+
+// MaskRow masks values of the given row in place.
+func (m *RowMasker) MaskRow(row *pg.DataRowMessage) error {
+	if len(row.Values) != len(m.fields) {
+		return fmt.Errorf("masking: row has %d values, but %d fields are described", len(row.Values), len(m.fields))
+	}
+
+	for i, field := range m.fields {
+		// Nothing to mask
+		if field == nil || row.Values[i] == nil {
+			continue
+		}
+
+		row.Values[i] = field.mask(row.Values[i])
+	}
+
+	return nil
+}
+
+// mask masks a single non-NULL value. Binary values are converted to text for masking and back.
+// It returns nil (i.e., NULL) if the value cannot be masked: the original value is never sent.
+func (f *fieldMasker) mask(value []byte) []byte {
+	if f.nullify {
+		return nil
+	}
+
+	text, err := pg.Transcode(f.typ, f.format, pg.DataFormatText, value)
+
+	if err != nil {
+		log.Warnf("masking: cannot decode %s: %v; sending NULL", f.column, err)
+		return nil
+	}
+
+	masked, err := f.masker.Mask(text, f.typ)
+
+	if err != nil {
+		log.Errorf("masking: error masking %s: %v; sending NULL", f.column, err)
+		return nil
+	}
+
+	// NB: maskers don't know the type modifier, so masked values may be longer than the column allows
+	if masked != nil && !masker.IsValidLength(masked, f.typ, f.typmod) {
+		masked = masker.ZeroValue(f.typ)
+	}
+
+	masked, err = pg.Transcode(f.typ, pg.DataFormatText, f.format, masked)
+
+	if err != nil {
+		log.Errorf("masking: cannot encode masked %s: %v; sending NULL", f.column, err)
+		return nil
+	}
+
+	return masked
+}
@@ -0,0 +1,301 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgmeta"
+)
+
+// Fake users table OID
+const usersTableOID = 787969
+
+// staticResolver is a ColumnResolver backed by a ColumnCatalog.
+type staticResolver struct {
+	*pgmeta.ColumnCatalog
+}
+
+func (r staticResolver) ResolveColumn(tableOID oid.Oid, index int16) (*pgmeta.Column, bool) {
+	return r.Lookup(tableOID, index)
+}
+
+func (r staticResolver) ResolveTable(table pgmeta.Table) ([]*pgmeta.Column, bool) {
+	tableOID, ok := r.LookupTable(table)
+
+	if !ok {
+		return nil, false
+	}
+
+	return r.TableColumns(tableOID), true
+}
+
+func testResolver() staticResolver {
+	users := pgmeta.Table{Schema: "public", Name: "users"}
+
+	return staticResolver{pgmeta.NewColumnCatalog(map[oid.Oid][]*pgmeta.Column{
+		usersTableOID: {
+			{Table: users, Name: "id", Index: 1, TypeOID: oid.T_int4, TypeName: "int4", NotNull: true},
+			{Table: users, Name: "company_id", Index: 2, TypeOID: oid.T_int4, TypeName: "int4", NotNull: true},
+			{Table: users, Name: "name", Index: 3, TypeOID: oid.T_varchar, TypeName: "varchar", TypeModifier: 259, NotNull: true},
+			{Table: users, Name: "email", Index: 4, TypeOID: oid.T_varchar, TypeName: "varchar", TypeModifier: 259, NotNull: true},
+			{Table: users, Name: "profile", Index: 5, TypeOID: oid.T_jsonb, TypeName: "jsonb"},
+		},
+	})}
+}
+
+// failedResolver is a FallibleColumnResolver that has failed to load the columns.
+type failedResolver struct{}
+
+func (failedResolver) ResolveColumn(tableOID oid.Oid, index int16) (*pgmeta.Column, bool) {
+	return nil, false
+}
+
+func (failedResolver) ResolveTable(table pgmeta.Table) ([]*pgmeta.Column, bool) {
+	return nil, false
+}
+
+func (failedResolver) LoadFailed() bool {
+	return true
+}
+
+func testRowDescription(format pg.DataFormat) *pg.RowDescriptionMessage {
+	return &pg.RowDescriptionMessage{
+		Fields: []*pg.FieldDescriptor{
+			{Name: "id", TableOID: usersTableOID, ColumnIndex: 1, DataTypeOID: oid.T_int4, Format: format},
+			{Name: "name", TableOID: usersTableOID, ColumnIndex: 3, DataTypeOID: oid.T_varchar, Format: format},
+			{Name: "email", TableOID: usersTableOID, ColumnIndex: 4, DataTypeOID: oid.T_varchar, Format: format},
+			{Name: "?column?", DataTypeOID: oid.T_text, Format: format},
+		},
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	_, err := NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "redact"}}, testResolver())
+	assert.NoError(t, err)
+
+	_, err = NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "unknown"}}, testResolver())
+	assert.Error(t, err)
+
+	_, err = NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "hash"}}, testResolver())
+	assert.Error(t, err, "hash requires a key")
+
+	_, err = NewEngine([]*Rule{{Table: "users", Strategy: "redact"}}, testResolver())
+	assert.Error(t, err)
+
+	_, err = NewEngine([]*Rule{{Table: "users", Column: "profile", Path: "contact.email", Strategy: "redact"}}, testResolver())
+	assert.Error(t, err, "invalid JSON path")
+
+	_, err = NewEngine([]*Rule{
+		{Table: "users", Column: "profile", Strategy: "redact"},
+		{Table: "users", Column: "profile", Path: "$.contact.email", Strategy: "redact"},
+	}, testResolver())
+	assert.Error(t, err, "whole column and JSON path rules")
+}
+
+func TestEngineRowMasker(t *testing.T) {
+	engine, err := NewEngine([]*Rule{{Table: "public.users", Column: "email", Strategy: "redact"}}, testResolver())
+	require.NoError(t, err)
+
+	// Result set contains masked column
+	assert.NotNil(t, engine.RowMasker(testRowDescription(pg.DataFormatText)))
+
+	// Result set doesn't contain masked column
+	assert.Nil(t, engine.RowMasker(&pg.RowDescriptionMessage{
+		Fields: []*pg.FieldDescriptor{
+			{Name: "id", TableOID: usersTableOID, ColumnIndex: 1, DataTypeOID: oid.T_int4},
+		},
+	}))
+
+	// Engine without rules
+	engine, err = NewEngine(nil, testResolver())
+	require.NoError(t, err)
+
+	assert.Nil(t, engine.RowMasker(testRowDescription(pg.DataFormatText)))
+}
+
+func TestEngineRowMaskerLoadFailed(t *testing.T) {
+	engine, err := NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "redact"}}, failedResolver{})
+	require.NoError(t, err)
+
+	// Values of all table columns are replaced with NULLs; expressions are sent as is
+	row := &pg.DataRowMessage{
+		Values: [][]byte{[]byte("1"), []byte("Jane Doe"), []byte("jane@example.com"), []byte("42")},
+	}
+
+	require.NoError(t, engine.RowMasker(testRowDescription(pg.DataFormatText)).MaskRow(row))
+	assert.Equal(t, [][]byte{nil, nil, nil, []byte("42")}, row.Values)
+}
+
+func TestEngineRowMaskerUnknownTable(t *testing.T) {
+	engine, err := NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "redact"}}, testResolver())
+	require.NoError(t, err)
+
+	// Table unknown to the loaded catalog (e.g. recreated since the load) may be masked
+	desc := &pg.RowDescriptionMessage{
+		Fields: []*pg.FieldDescriptor{
+			{Name: "email", TableOID: usersTableOID + 100, ColumnIndex: 4, DataTypeOID: oid.T_varchar},
+			{Name: "?column?", DataTypeOID: oid.T_text},
+		},
+	}
+
+	row := &pg.DataRowMessage{Values: [][]byte{[]byte("jane@example.com"), []byte("42")}}
+
+	require.NoError(t, engine.RowMasker(desc).MaskRow(row))
+	assert.Equal(t, [][]byte{nil, []byte("42")}, row.Values)
+
+	// System catalogs are sent as is
+	assert.Nil(t, engine.RowMasker(&pg.RowDescriptionMessage{
+		Fields: []*pg.FieldDescriptor{{Name: "relname", TableOID: 1259, ColumnIndex: 2, DataTypeOID: oid.T_name}},
+	}))
+}
+
+func TestRowMaskerMaskRow(t *testing.T) {
+	engine, err := NewEngine([]*Rule{{Table: "users", Column: "email", Strategy: "redact"}}, testResolver())
+	require.NoError(t, err)
+
+	t.Run("text format", func(t *testing.T) {
+		row := &pg.DataRowMessage{
+			Values: [][]byte{[]byte("1"), []byte("Jane Doe"), []byte("jane@example.com"), []byte("jane@example.com")},
+		}
+
+		err := engine.RowMasker(testRowDescription(pg.DataFormatText)).MaskRow(row)
+		require.NoError(t, err)
+
+		assert.Equal(t, [][]byte{[]byte("1"), []byte("Jane Doe"), []byte("***"), []byte("jane@example.com")}, row.Values)
+	})
+
+	t.Run("NULL is preserved", func(t *testing.T) {
+		row := &pg.DataRowMessage{Values: [][]byte{[]byte("1"), []byte("Jane Doe"), nil, nil}}
+
+		err := engine.RowMasker(testRowDescription(pg.DataFormatText)).MaskRow(row)
+		require.NoError(t, err)
+
+		assert.Nil(t, row.Values[2])
+	})
+
+	t.Run("binary format", func(t *testing.T) {
+		engine, err := NewEngine([]*Rule{
+			{Table: "users", Column: "id", Strategy: "partial", Options: masker.Options{"keep_last": int64(1)}},
+			{Table: "users", Column: "email", Strategy: "redact"},
+		}, testResolver())
+		require.NoError(t, err)
+
+		row := &pg.DataRowMessage{
+			Values: [][]byte{{0x00, 0x16, 0x28, 0xec}, []byte("Jane Doe"), []byte("jane@example.com"), nil},
+		}
+
+		err = engine.RowMasker(testRowDescription(pg.DataFormatBinary)).MaskRow(row)
+		require.NoError(t, err)
+
+		// 1452268 → 0000008
+		assert.Equal(t, []byte{0, 0, 0, 8}, row.Values[0])
+		assert.Equal(t, []byte("***"), row.Values[2])
+	})
+
+	t.Run("binary format of unsupported type", func(t *testing.T) {
+		desc := &pg.RowDescriptionMessage{
+			Fields: []*pg.FieldDescriptor{
+				{Name: "email", TableOID: usersTableOID, ColumnIndex: 4, DataTypeOID: oid.T_point, Format: pg.DataFormatBinary},
+			},
+		}
+
+		row := &pg.DataRowMessage{Values: [][]byte{{1, 2, 3}}}
+
+		err := engine.RowMasker(desc).MaskRow(row)
+		require.NoError(t, err)
+
+		assert.Nil(t, row.Values[0])
+	})
+
+	t.Run("varchar length", func(t *testing.T) {
+		engine, err := NewEngine([]*Rule{
+			{Table: "users", Column: "email", Strategy: "fixed", Options: masker.Options{"value": "redacted@example.com"}},
+		}, testResolver())
+		require.NoError(t, err)
+
+		desc := func(typmod int32) *pg.RowDescriptionMessage {
+			return &pg.RowDescriptionMessage{
+				Fields: []*pg.FieldDescriptor{
+					{Name: "email", TableOID: usersTableOID, ColumnIndex: 4, DataTypeOID: oid.T_varchar, DataTypeModifier: typmod},
+				},
+			}
+		}
+
+		// varchar(255)
+		row := &pg.DataRowMessage{Values: [][]byte{[]byte("jane@example.com")}}
+		require.NoError(t, engine.RowMasker(desc(255+4)).MaskRow(row))
+		assert.Equal(t, "redacted@example.com", string(row.Values[0]))
+
+		// varchar(16) cannot hold the masked value
+		row = &pg.DataRowMessage{Values: [][]byte{[]byte("jane@example.com")}}
+		require.NoError(t, engine.RowMasker(desc(16+4)).MaskRow(row))
+		assert.Equal(t, []byte{}, row.Values[0])
+	})
+
+	t.Run("JSON paths", func(t *testing.T) {
+		engine, err := NewEngine([]*Rule{
+			{Table: "users", Column: "profile", Path: "$.contact.email", Strategy: "redact"},
+			{Table: "users", Column: "profile", Path: "$.addresses[*].street", Strategy: "partial",
+				Options: masker.Options{"keep_first": int64(2)}},
+		}, testResolver())
+		require.NoError(t, err)
+
+		desc := func(format pg.DataFormat) *pg.RowDescriptionMessage {
+			return &pg.RowDescriptionMessage{
+				Fields: []*pg.FieldDescriptor{
+					{Name: "profile", TableOID: usersTableOID, ColumnIndex: 5, DataTypeOID: oid.T_jsonb, Format: format},
+				},
+			}
+		}
+
+		profile := `{"name": "Jane", "contact": {"email": "jane@example.com", "phone": "555-0100"}, ` +
+			`"addresses": [{"street": "Main St", "city": "Springfield"}, {"city": "Shelbyville", "street": "Oak Ave"}]}`
+
+		expected := `{"name": "Jane", "contact": {"email": "***", "phone": "555-0100"}, ` +
+			`"addresses": [{"street": "Ma*****", "city": "Springfield"}, {"city": "Shelbyville", "street": "Oa*****"}]}`
+
+		row := &pg.DataRowMessage{Values: [][]byte{[]byte(profile)}}
+
+		err = engine.RowMasker(desc(pg.DataFormatText)).MaskRow(row)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected, string(row.Values[0]))
+
+		// jsonb binary format is the text prefixed with the format version
+		row = &pg.DataRowMessage{Values: [][]byte{append([]byte{1}, profile...)}}
+
+		err = engine.RowMasker(desc(pg.DataFormatBinary)).MaskRow(row)
+		require.NoError(t, err)
+
+		assert.Equal(t, append([]byte{1}, expected...), row.Values[0])
+	})
+
+	t.Run("masked values are valid for the column type", func(t *testing.T) {
+		engine, err := NewEngine([]*Rule{
+			{Table: "users", Column: "id", Strategy: "redact"},
+			{Table: "users", Column: "name", Strategy: "partial", Options: masker.Options{"keep_first": int64(1)}},
+		}, testResolver())
+		require.NoError(t, err)
+
+		row := &pg.DataRowMessage{
+			Values: [][]byte{[]byte("42"), []byte("Jane"), []byte("jane@example.com"), nil},
+		}
+
+		err = engine.RowMasker(testRowDescription(pg.DataFormatText)).MaskRow(row)
+		require.NoError(t, err)
+
+		assert.Equal(t, [][]byte{[]byte("0"), []byte("J***"), []byte("jane@example.com"), nil}, row.Values)
+	})
+
+	t.Run("values count mismatch", func(t *testing.T) {
+		row := &pg.DataRowMessage{Values: [][]byte{[]byte("1")}}
+
+		err := engine.RowMasker(testRowDescription(pg.DataFormatText)).MaskRow(row)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,424 @@
+package masking
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq/oid"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgsql"
+)
+
+// ErrorMode defines how ErrorResponse and NoticeResponse messages of a severity are scrubbed.
+type ErrorMode string
+
+const (
+	// ErrorModeMask replaces values of the masked columns with masked ones. This is the default.
+	ErrorModeMask ErrorMode = "mask"
+
+	// ErrorModeRedact removes the fields that may contain data (detail, hint, context and internal query)
+	// and masks values of the masked columns in the message.
+	ErrorModeRedact ErrorMode = "redact"
+
+	// ErrorModeOff forwards messages as is.
+	ErrorModeOff ErrorMode = "off"
+)
+
+// Replacement of values that cannot be masked with the column's strategy.
+const scrubbedValue = "***"
+
+// ErrorScrubber masks values of the masked columns that PostgreSQL echoes in error and notice fields,
+// e.g. "Key (email)=(jane@example.com) already exists." in the detail of a unique violation.
+type ErrorScrubber struct {
+	// Provides maskers of the masked columns
+	engine *Engine
+
+	// Scrubbing modes keyed by upper case severity
+	modes map[string]ErrorMode
+
+	// Detail messages containing column values; see detailPatterns
+	patterns []*regexp.Regexp
+
+	// Messages echoing an input value; see messagePatterns
+	messagePatterns []*regexp.Regexp
+}
+
+// NewErrorScrubber initializes a new ErrorScrubber. modes map severities (e.g. "ERROR" or "NOTICE") to
+// scrubbing modes; messages of other severities are scrubbed in the ErrorModeMask mode.
+func NewErrorScrubber(engine *Engine, modes map[string]string) (*ErrorScrubber, error) {
+	s := &ErrorScrubber{
+		engine:          engine,
+		modes:           make(map[string]ErrorMode, len(modes)),
+		patterns:        detailPatterns(),
+		messagePatterns: messagePatterns(),
+	}
+
+	for severity, mode := range modes {
+		switch m := ErrorMode(strings.ToLower(mode)); m {
+		case ErrorModeMask, ErrorModeRedact, ErrorModeOff:
+			s.modes[strings.ToUpper(severity)] = m
+
+		default:
+			return nil, fmt.Errorf("masking: unknown error masking mode %q for severity %s", mode, severity)
+		}
+	}
+
+	return s, nil
+}
+
+// detailPatterns returns regular expressions matching detail messages that contain column values.
+// Patterns capture pairs of a column list and a value list, e.g. "email" and "jane@example.com" from
+// "Key (email)=(jane@example.com) already exists.".
+func detailPatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		// exclusion_violation
+		regexp.MustCompile(`(?s)^Key \((.+?)\)=\((.*)\) conflicts with existing key \((.+?)\)=\((.*)\)\.$`),
+
+		// unique_violation and foreign_key_violation
+		regexp.MustCompile(`(?s)^Key \((.+?)\)=\((.*)\)(?: already exists\.| is not present in table .*| is still referenced from table .*)$`),
+
+		// Partition routing failures
+		regexp.MustCompile(`(?s)^Partition key of the failing row contains \((.+?)\)=\((.*)\)\.$`),
+	}
+}
+
+// messagePatterns returns regular expressions matching messages that echo an input value, e.g.
+// `invalid input syntax for type integer: "jane@example.com"`. Patterns capture the value.
+func messagePatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		// numeric_value_out_of_range
+		regexp.MustCompile(`(?s)^value "(.*)" is out of range for type [^"]*$`),
+
+		// invalid_text_representation, invalid_datetime_format and other input function errors
+		regexp.MustCompile(`(?s)^[^"]*: "(.*)"$`),
+	}
+}
+
+// Scrub masks values of the masked columns in the error or notice fields. query is the SQL of the failed
+// request ("" if unknown): values echoed in the message are masked if it refers to the masked columns or their
+// tables. It returns the scrubbed fields; fields that may contain data but cannot be scrubbed are removed.
+func (s *ErrorScrubber) Scrub(fields []*pg.MessageField, query string) []*pg.MessageField {
+	if s == nil || s.engine == nil || len(s.engine.maskers) == 0 {
+		return fields
+	}
+
+	mode := s.mode(fields)
+
+	if mode == ErrorModeOff {
+		return fields
+	}
+
+	scrub := &errorScrub{scrubber: s, schema: DefaultSchema, replacements: make(map[string]string)}
+
+	for _, field := range fields {
+		switch field.Type {
+		case pg.MessageFieldSchema:
+			scrub.schema = field.Value
+
+		case pg.MessageFieldTable:
+			scrub.table = field.Value
+
+		case pg.MessageFieldColumn:
+			scrub.column = field.Value
+
+		case pg.MessageFieldConstraint:
+			scrub.constraint = field.Value
+		}
+	}
+
+	scrubbed := make([]*pg.MessageField, 0, len(fields))
+
+	for _, field := range fields {
+		switch field.Type {
+		case pg.MessageFieldDetail:
+			if mode == ErrorModeRedact {
+				continue
+			}
+
+			detail, ok := scrub.detail(field.Value)
+
+			// Unknown detail message about a masked column (e.g. a localized one)
+			if !ok && scrub.relatesToMaskedColumn() {
+				continue
+			}
+
+			field = &pg.MessageField{Type: field.Type, Value: detail}
+
+		case pg.MessageFieldMessage:
+			if scrub.relatesToMaskedColumn() || s.refersToMaskedColumns(query) {
+				field = &pg.MessageField{Type: field.Type, Value: scrub.message(field.Value)}
+			}
+
+		case pg.MessageFieldHint, pg.MessageFieldWhere, pg.MessageFieldInternalQuery, pg.MessageFieldInternalPosition:
+			if mode == ErrorModeRedact {
+				continue
+			}
+		}
+
+		scrubbed = append(scrubbed, field)
+	}
+
+	// Values found in the detail may be repeated in other fields
+	for i, field := range scrubbed {
+		switch field.Type {
+		case pg.MessageFieldMessage, pg.MessageFieldHint, pg.MessageFieldWhere, pg.MessageFieldInternalQuery:
+			scrubbed[i] = &pg.MessageField{Type: field.Type, Value: scrub.replace(field.Value)}
+		}
+	}
+
+	return scrubbed
+}
+
+// refersToMaskedColumns returns true if the query mentions a masked column or a table with masked columns.
+// NB: names are compared without the schema, so the check may err on the side of masking.
+func (s *ErrorScrubber) refersToMaskedColumns(query string) bool {
+	if query == "" {
+		return false
+	}
+
+	names := make(map[string]bool)
+
+	for key := range s.engine.maskers {
+		parts := strings.SplitN(key, ".", 3)
+
+		if len(parts) == 3 {
+			names[parts[1]] = true
+			names[parts[2]] = true
+		}
+	}
+
+	for _, token := range pgsql.Tokenize(query) {
+		if name, ok := token.Name(); ok && names[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mode returns the scrubbing mode for the message severity.
+func (s *ErrorScrubber) mode(fields []*pg.MessageField) ErrorMode {
+	severity := ""
+
+	for _, field := range fields {
+		// Prefer the non-localized severity
+		if field.Type == pg.MessageFieldSeverity || (field.Type == pg.MessageFieldSeverityLocalized && severity == "") {
+			severity = field.Value
+		}
+	}
+
+	if mode, ok := s.modes[strings.ToUpper(severity)]; ok {
+		return mode
+	}
+
+	return ErrorModeMask
+}
+
+// errorScrub holds the state of scrubbing a single message.
+type errorScrub struct {
+	scrubber *ErrorScrubber
+
+	// Context of the error reported in the schema, table, column and constraint fields
+	schema     string
+	table      string
+	column     string
+	constraint string
+
+	// Masked values found in the detail keyed by the original values
+	replacements map[string]string
+}
+
+// detail scrubs the detail message. The second returned value is false if the message is not recognized.
+func (e *errorScrub) detail(detail string) (string, bool) {
+	for _, pattern := range e.scrubber.patterns {
+		match := pattern.FindStringSubmatchIndex(detail)
+
+		if match == nil {
+			continue
+		}
+
+		scrubbed := detail
+
+		// Replace value lists starting from the last one to keep the indices valid
+		for group := len(match)/2 - 2; group > 0; group -= 2 {
+			columns := detail[match[2*group]:match[2*group+1]]
+			start, end := match[2*group+2], match[2*group+3]
+
+			scrubbed = scrubbed[:start] + e.values(columns, detail[start:end]) + scrubbed[end:]
+		}
+
+		return scrubbed, true
+	}
+
+	// Check constraint and not null violations report the whole row
+	if strings.HasPrefix(detail, "Failing row contains (") {
+		if e.table != "" && len(e.scrubber.engine.tableMaskedColumns(e.schema, e.table)) == 0 {
+			return detail, true
+		}
+
+		return "Failing row contains (" + scrubbedValue + ").", true
+	}
+
+	return detail, false
+}
+
+// message scrubs the input value echoed in the message. The value is also replaced in other fields where
+// it is quoted the same way (e.g. `COPY users, line 1, column id: "jane@example.com"`).
+func (e *errorScrub) message(message string) string {
+	for _, pattern := range e.scrubber.messagePatterns {
+		match := pattern.FindStringSubmatchIndex(message)
+
+		if match == nil {
+			continue
+		}
+
+		start, end := match[2], match[3]
+
+		if start < end {
+			e.replacements[`"`+message[start:end]+`"`] = `"` + scrubbedValue + `"`
+		}
+
+		return message[:start] + scrubbedValue + message[end:]
+	}
+
+	return message
+}
+
+// values masks values of the masked columns in a comma separated list of values.
+func (e *errorScrub) values(columns, values string) string {
+	columnList, valueList := strings.Split(columns, ", "), strings.Split(values, ", ")
+
+	for i, column := range columnList {
+		m, ok := e.masker(column)
+
+		if !ok {
+			continue
+		}
+
+		// Values contain commas: cannot tell which one belongs to the masked column
+		if len(columnList) != len(valueList) {
+			return scrubbedValue
+		}
+
+		masked := maskErrorValue(m, valueList[i])
+
+		if valueList[i] != "" {
+			e.replacements[valueList[i]] = masked
+		}
+
+		valueList[i] = masked
+	}
+
+	return strings.Join(valueList, ", ")
+}
+
+// masker returns the masker of a column from a key column list. Columns of expression indexes
+// (e.g. "lower(email::text)") are masked if the expression refers to a masked column.
+func (e *errorScrub) masker(column string) (masker.Masker, bool) {
+	for _, name := range columnIdentifiers(column) {
+		// NB: key columns of "is still referenced" violations belong to the referenced table, not to the reported one
+		if m, ok := e.scrubber.engine.columnMasker(e.schema, e.table, name); ok {
+			return m, true
+		}
+
+		if m, ok := e.scrubber.engine.columnMaskerByName(name); ok {
+			return m, true
+		}
+	}
+
+	return nil, false
+}
+
+// relatesToMaskedColumn returns true if the error is reported for a masked column or for a constraint
+// on a masked column. Constraint columns are guessed from the default constraint names like users_email_key.
+func (e *errorScrub) relatesToMaskedColumn() bool {
+	if e.table == "" {
+		return false
+	}
+
+	for _, column := range e.scrubber.engine.tableMaskedColumns(e.schema, e.table) {
+		if column == e.column || strings.Contains("_"+e.constraint+"_", "_"+column+"_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replace replaces all known original values in the string with the masked ones.
+func (e *errorScrub) replace(str string) string {
+	originals := make([]string, 0, len(e.replacements))
+
+	for original := range e.replacements {
+		originals = append(originals, original)
+	}
+
+	// Longer values first: one value may contain another
+	sort.Slice(originals, func(i, j int) bool { return len(originals[i]) > len(originals[j]) })
+
+	for _, original := range originals {
+		str = strings.ReplaceAll(str, original, e.replacements[original])
+	}
+
+	return str
+}
+
+// maskErrorValue masks a value reported in an error message.
+func maskErrorValue(m masker.Masker, value string) string {
+	masked, err := m.Mask([]byte(value), oid.T_text)
+
+	if err != nil {
+		return scrubbedValue
+	}
+
+	if masked == nil {
+		return "NULL"
+	}
+
+	return string(masked)
+}
+
+// columnIdentifiers returns identifiers used in a column name or expression, e.g. "email" from
+// "lower((email)::text)". Quoted identifiers are unquoted.
+func columnIdentifiers(column string) []string {
+	if strings.HasPrefix(column, `"`) && strings.HasSuffix(column, `"`) && len(column) > 1 {
+		return []string{strings.ReplaceAll(column[1:len(column)-1], `""`, `"`)}
+	}
+
+	var identifiers []string
+
+	for i := 0; i < len(column); {
+		c := column[i]
+
+		switch {
+		case c == '"':
+			end := strings.IndexByte(column[i+1:], '"')
+
+			if end < 0 {
+				return identifiers
+			}
+
+			identifiers = append(identifiers, column[i+1:i+1+end])
+			i += end + 2
+
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+
+			for i < len(column) && (column[i] == '_' || column[i] == '$' || (column[i] >= 'a' && column[i] <= 'z') ||
+				(column[i] >= 'A' && column[i] <= 'Z') || (column[i] >= '0' && column[i] <= '9')) {
+				i++
+			}
+
+			identifiers = append(identifiers, column[start:i])
+
+		default:
+			i++
+		}
+	}
+
+	return identifiers
+}
@@ -0,0 +1,257 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+func newTestErrorScrubber(t *testing.T, modes map[string]string) *ErrorScrubber {
+	engine, err := NewEngine([]*Rule{
+		{Table: "users", Column: "email", Strategy: "redact"},
+		{Table: "users", Column: "name", Strategy: "partial", Options: masker.Options{"keep_first": int64(1)}},
+	}, testResolver())
+	require.NoError(t, err)
+
+	scrubber, err := NewErrorScrubber(engine, modes)
+	require.NoError(t, err)
+
+	return scrubber
+}
+
+// errorFields builds error fields from field type/value pairs.
+func errorFields(pairs ...interface{}) []*pg.MessageField {
+	var fields []*pg.MessageField
+
+	for i := 0; i < len(pairs); i += 2 {
+		fields = append(fields, &pg.MessageField{Type: pairs[i].(pg.MessageFieldType), Value: pairs[i+1].(string)})
+	}
+
+	return fields
+}
+
+func TestNewErrorScrubber(t *testing.T) {
+	_, err := NewErrorScrubber(nil, map[string]string{"error": "Redact", "NOTICE": "off"})
+	assert.NoError(t, err)
+
+	_, err = NewErrorScrubber(nil, map[string]string{"ERROR": "hide"})
+	assert.Error(t, err)
+}
+
+func TestErrorScrubberScrub(t *testing.T) {
+	scrubber := newTestErrorScrubber(t, nil)
+
+	testCases := []struct {
+		name     string
+		query    string
+		fields   []*pg.MessageField
+		expected []*pg.MessageField
+	}{
+		{
+			name: "unique violation",
+			fields: errorFields(
+				pg.MessageFieldSeverity, "ERROR",
+				pg.MessageFieldMessage, `duplicate key value violates unique constraint "users_email_key"`,
+				pg.MessageFieldDetail, "Key (email)=(jane@example.com) already exists.",
+				pg.MessageFieldSchema, "public",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldConstraint, "users_email_key",
+			),
+			expected: errorFields(
+				pg.MessageFieldSeverity, "ERROR",
+				pg.MessageFieldMessage, `duplicate key value violates unique constraint "users_email_key"`,
+				pg.MessageFieldDetail, "Key (email)=(***) already exists.",
+				pg.MessageFieldSchema, "public",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldConstraint, "users_email_key",
+			),
+		},
+		{
+			name: "composite key",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Key (company_id, name)=(42, Jane Doe) already exists.",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldWhere, "SQL statement \"INSERT INTO users (company_id, name) VALUES (42, 'Jane Doe')\"",
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, "Key (company_id, name)=(42, J*******) already exists.",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldWhere, "SQL statement \"INSERT INTO users (company_id, name) VALUES (42, 'J*******')\"",
+			),
+		},
+		{
+			name: "values with commas",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Key (company_id, name)=(42, Doe, Jane) already exists.",
+				pg.MessageFieldTable, "users",
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, "Key (company_id, name)=(***) already exists.",
+				pg.MessageFieldTable, "users",
+			),
+		},
+		{
+			name: "expression index",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Key (lower(email::text))=(jane@example.com) already exists.",
+				pg.MessageFieldTable, "users",
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, "Key (lower(email::text))=(***) already exists.",
+				pg.MessageFieldTable, "users",
+			),
+		},
+		{
+			name: "foreign key violation without table",
+			fields: errorFields(
+				pg.MessageFieldDetail, `Key (email)=(jane@example.com) is still referenced from table "invitations".`,
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, `Key (email)=(***) is still referenced from table "invitations".`,
+			),
+		},
+		{
+			name: "exclusion violation",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Key (email)=(jane@example.com) conflicts with existing key (email)=(jane@example.org).",
+				pg.MessageFieldTable, "users",
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, "Key (email)=(***) conflicts with existing key (email)=(***).",
+				pg.MessageFieldTable, "users",
+			),
+		},
+		{
+			name: "failing row",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Failing row contains (1, 42, Jane Doe, null).",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldColumn, "email",
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, "Failing row contains (***).",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldColumn, "email",
+			),
+		},
+		{
+			name: "unknown detail of a masked column is removed",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Schlüssel (email)=(jane@example.com) existiert bereits.",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldConstraint, "users_email_key",
+			),
+			expected: errorFields(
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldConstraint, "users_email_key",
+			),
+		},
+		{
+			name:  "input value of a masked column",
+			query: "SELECT * FROM users WHERE email::int = 1",
+			fields: errorFields(
+				pg.MessageFieldSeverity, "ERROR",
+				pg.MessageFieldMessage, `invalid input syntax for type integer: "jane@example.com"`,
+			),
+			expected: errorFields(
+				pg.MessageFieldSeverity, "ERROR",
+				pg.MessageFieldMessage, `invalid input syntax for type integer: "***"`,
+			),
+		},
+		{
+			name:  "out of range value of a masked table",
+			query: "INSERT INTO users VALUES (99999999999)",
+			fields: errorFields(
+				pg.MessageFieldMessage, `value "99999999999" is out of range for type integer`,
+			),
+			expected: errorFields(
+				pg.MessageFieldMessage, `value "***" is out of range for type integer`,
+			),
+		},
+		{
+			name:  "input value of COPY FROM",
+			query: "COPY users FROM STDIN",
+			fields: errorFields(
+				pg.MessageFieldMessage, `invalid input syntax for type integer: "jane@example.com"`,
+				pg.MessageFieldWhere, `COPY users, line 1, column id: "jane@example.com"`,
+			),
+			expected: errorFields(
+				pg.MessageFieldMessage, `invalid input syntax for type integer: "***"`,
+				pg.MessageFieldWhere, `COPY users, line 1, column id: "***"`,
+			),
+		},
+		{
+			name:  "input value of other tables",
+			query: "SELECT 'abc'::int FROM companies",
+			fields: errorFields(
+				pg.MessageFieldMessage, `invalid input syntax for type integer: "abc"`,
+			),
+			expected: errorFields(
+				pg.MessageFieldMessage, `invalid input syntax for type integer: "abc"`,
+			),
+		},
+		{
+			name: "unmasked columns",
+			fields: errorFields(
+				pg.MessageFieldDetail, "Key (id)=(1) already exists.",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldConstraint, "users_pkey",
+			),
+			expected: errorFields(
+				pg.MessageFieldDetail, "Key (id)=(1) already exists.",
+				pg.MessageFieldTable, "users",
+				pg.MessageFieldConstraint, "users_pkey",
+			),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, scrubber.Scrub(tc.fields, tc.query))
+		})
+	}
+}
+
+func TestErrorScrubberModes(t *testing.T) {
+	scrubber := newTestErrorScrubber(t, map[string]string{"error": "redact", "NOTICE": "off"})
+
+	fields := func(severity string) []*pg.MessageField {
+		return errorFields(
+			pg.MessageFieldSeverityLocalized, severity,
+			pg.MessageFieldMessage, "duplicate key value",
+			pg.MessageFieldDetail, "Key (email)=(jane@example.com) already exists.",
+			pg.MessageFieldHint, "jane@example.com",
+			pg.MessageFieldTable, "users",
+		)
+	}
+
+	// Redact
+	assert.Equal(t, errorFields(
+		pg.MessageFieldSeverityLocalized, "ERROR",
+		pg.MessageFieldMessage, "duplicate key value",
+		pg.MessageFieldTable, "users",
+	), scrubber.Scrub(fields("ERROR"), ""))
+
+	// Off
+	assert.Equal(t, fields("NOTICE"), scrubber.Scrub(fields("NOTICE"), ""))
+
+	// Mask by default
+	assert.Equal(t, errorFields(
+		pg.MessageFieldSeverityLocalized, "WARNING",
+		pg.MessageFieldMessage, "duplicate key value",
+		pg.MessageFieldDetail, "Key (email)=(***) already exists.",
+		pg.MessageFieldHint, "***",
+		pg.MessageFieldTable, "users",
+	), scrubber.Scrub(fields("WARNING"), ""))
+}
+
+func TestColumnIdentifiers(t *testing.T) {
+	assert.Equal(t, []string{"email"}, columnIdentifiers("email"))
+	assert.Equal(t, []string{"E-mail"}, columnIdentifiers(`"E-mail"`))
+	assert.Equal(t, []string{"lower", "email", "text"}, columnIdentifiers("lower((email)::text)"))
+	assert.Equal(t, []string{"lower", "E-mail"}, columnIdentifiers(`lower("E-mail")`))
+}
@@ -0,0 +1,376 @@
+package masking
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq/oid"
+
+	"github.com/hired/gevulot/pkg/masker"
+)
+
+// jsonMasker masks nodes selected by JSON paths inside json and jsonb values. The rest of the document
+// (including key order and whitespace) is kept intact.
+type jsonMasker struct {
+	paths []*jsonPathMasker
+}
+
+// jsonPathMasker is a masking strategy applied to the nodes selected by a JSON path.
+type jsonPathMasker struct {
+	path   jsonPath
+	masker masker.Masker
+}
+
+var _ masker.Masker = &jsonMasker{}
+
+// add adds a masking strategy for the given path. Strategies added first take precedence.
+func (m *jsonMasker) add(path jsonPath, strategy masker.Masker) {
+	m.paths = append(m.paths, &jsonPathMasker{path: path, masker: strategy})
+}
+
+// Mask masks a json or jsonb value in text format.
+func (m *jsonMasker) Mask(value []byte, typ oid.Oid) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if typ != oid.T_json && typ != oid.T_jsonb {
+		return nil, fmt.Errorf("masking: JSON paths cannot be applied to values of type %s", oid.TypeName[typ])
+	}
+
+	if !json.Valid(value) {
+		return nil, fmt.Errorf("masking: invalid JSON value")
+	}
+
+	r := &jsonRewriter{src: value, out: make([]byte, 0, len(value))}
+
+	err := r.value(m.paths, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.copyWhitespace()
+
+	return r.out, nil
+}
+
+// jsonRewriter copies a valid JSON document replacing masked nodes.
+type jsonRewriter struct {
+	src []byte
+	out []byte
+	pos int
+}
+
+// value copies a single JSON value. active are paths that matched the value's ancestors up to depth.
+func (r *jsonRewriter) value(active []*jsonPathMasker, depth int) error {
+	r.copyWhitespace()
+
+	// The value itself is selected by a path
+	for _, p := range active {
+		if len(p.path) == depth {
+			start := r.pos
+			r.skipValue()
+
+			masked, err := maskJSONNode(r.src[start:r.pos], p.masker)
+
+			if err != nil {
+				return err
+			}
+
+			r.out = append(r.out, masked...)
+
+			return nil
+		}
+	}
+
+	switch {
+	case len(active) == 0:
+		start := r.pos
+		r.skipValue()
+		r.out = append(r.out, r.src[start:r.pos]...)
+
+		return nil
+
+	case r.src[r.pos] == '{':
+		return r.object(active, depth)
+
+	case r.src[r.pos] == '[':
+		return r.array(active, depth)
+
+	default:
+		// Scalars have no children to mask
+		return r.value(nil, depth)
+	}
+}
+
+// object copies an object descending into members selected by the active paths.
+func (r *jsonRewriter) object(active []*jsonPathMasker, depth int) error {
+	r.copyByte() // {
+
+	for {
+		r.copyWhitespace()
+
+		if r.src[r.pos] == '}' {
+			r.copyByte()
+			return nil
+		}
+
+		if r.src[r.pos] == ',' {
+			r.copyByte()
+			r.copyWhitespace()
+		}
+
+		start := r.pos
+		r.skipValue()
+
+		var name string
+
+		err := json.Unmarshal(r.src[start:r.pos], &name)
+
+		if err != nil {
+			return fmt.Errorf("masking: invalid JSON member name: %w", err)
+		}
+
+		r.out = append(r.out, r.src[start:r.pos]...)
+
+		r.copyWhitespace()
+		r.copyByte() // :
+
+		children := selectJSONPaths(active, depth, func(step jsonPathStep) bool { return step.matchesMember(name) })
+
+		err = r.value(children, depth+1)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// array copies an array descending into elements selected by the active paths.
+func (r *jsonRewriter) array(active []*jsonPathMasker, depth int) error {
+	r.copyByte() // [
+
+	for index := 0; ; index++ {
+		r.copyWhitespace()
+
+		if r.src[r.pos] == ']' {
+			r.copyByte()
+			return nil
+		}
+
+		if r.src[r.pos] == ',' {
+			r.copyByte()
+		}
+
+		i := index
+		children := selectJSONPaths(active, depth, func(step jsonPathStep) bool { return step.matchesElement(i) })
+
+		err := r.value(children, depth+1)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// copyByte copies the current byte to the output.
+func (r *jsonRewriter) copyByte() {
+	r.out = append(r.out, r.src[r.pos])
+	r.pos++
+}
+
+// copyWhitespace copies insignificant whitespace to the output.
+func (r *jsonRewriter) copyWhitespace() {
+	for r.pos < len(r.src) && isJSONWhitespace(r.src[r.pos]) {
+		r.copyByte()
+	}
+}
+
+// skipValue moves past the current value. The document is known to be valid JSON.
+func (r *jsonRewriter) skipValue() {
+	nesting := 0
+
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+
+		switch {
+		case c == '"':
+			r.skipString()
+
+		case c == '{' || c == '[':
+			nesting++
+			r.pos++
+
+		case c == '}' || c == ']':
+			if nesting == 0 {
+				return
+			}
+
+			nesting--
+			r.pos++
+
+		case nesting == 0 && (c == ',' || isJSONWhitespace(c)):
+			// End of a scalar
+			return
+
+		default:
+			r.pos++
+		}
+
+		// End of a string, an object or an array
+		if nesting == 0 && (c == '"' || c == '}' || c == ']') {
+			return
+		}
+	}
+}
+
+// skipString moves past the current string.
+func (r *jsonRewriter) skipString() {
+	for r.pos++; r.pos < len(r.src); r.pos++ {
+		switch r.src[r.pos] {
+		case '\\':
+			r.pos++
+
+		case '"':
+			r.pos++
+			return
+		}
+	}
+}
+
+// selectJSONPaths returns the active paths whose step at depth matches a child node.
+func selectJSONPaths(active []*jsonPathMasker, depth int, match func(step jsonPathStep) bool) []*jsonPathMasker {
+	var selected []*jsonPathMasker
+
+	for _, p := range active {
+		if len(p.path) > depth && match(p.path[depth]) {
+			selected = append(selected, p)
+		}
+	}
+
+	return selected
+}
+
+// maskJSONNode masks a single JSON node. Strings are masked as text, numbers as numeric, booleans as bool
+// and objects or arrays as jsonb. Nulls are left as is.
+func maskJSONNode(node []byte, m masker.Masker) ([]byte, error) {
+	switch node[0] {
+	case 'n':
+		return node, nil
+
+	case '"':
+		var str string
+
+		err := json.Unmarshal(node, &str)
+
+		if err != nil {
+			return nil, fmt.Errorf("masking: invalid JSON string: %w", err)
+		}
+
+		masked, err := m.Mask([]byte(str), oid.T_text)
+
+		if err != nil || masked == nil {
+			return jsonNull(), err
+		}
+
+		return jsonString(masked)
+
+	case 't', 'f':
+		masked, err := m.Mask(node, oid.T_bool)
+
+		if err != nil || masked == nil {
+			return jsonNull(), err
+		}
+
+		switch strings.ToLower(string(masked)) {
+		case "t", "true", "y", "yes", "on", "1":
+			return []byte("true"), nil
+
+		default:
+			return []byte("false"), nil
+		}
+
+	case '{', '[':
+		masked, err := m.Mask(node, oid.T_jsonb)
+
+		if err != nil || masked == nil {
+			return jsonNull(), err
+		}
+
+		if !json.Valid(masked) {
+			return nil, fmt.Errorf("masking: masked JSON node is not valid JSON")
+		}
+
+		return masked, nil
+
+	default:
+		masked, err := m.Mask(node, oid.T_numeric)
+
+		if err != nil || masked == nil {
+			return jsonNull(), err
+		}
+
+		if number, ok := jsonNumber(masked); ok {
+			return number, nil
+		}
+
+		// NaN and infinities are not valid JSON numbers
+		return jsonString(masked)
+	}
+}
+
+// jsonString encodes a JSON string without escaping HTML characters.
+func jsonString(str []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(string(str))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonNumber converts a numeric value to a JSON number stripping leading zeros (e.g. "0042" → "42").
+// The second returned value is false if the value is not a number.
+func jsonNumber(value []byte) ([]byte, bool) {
+	str := strings.TrimSpace(string(value))
+	sign := ""
+
+	if strings.HasPrefix(str, "-") {
+		sign, str = "-", str[1:]
+	}
+
+	if trimmed := strings.TrimLeft(str, "0"); len(trimmed) < len(str) {
+		if trimmed == "" || trimmed[0] < '0' || trimmed[0] > '9' {
+			trimmed = "0" + trimmed
+		}
+
+		str = trimmed
+	}
+
+	number := []byte(sign + str)
+
+	if str == "" || str[0] < '0' || str[0] > '9' || !json.Valid(number) {
+		return nil, false
+	}
+
+	return number, true
+}
+
+// jsonNull returns JSON null literal.
+func jsonNull() []byte {
+	return []byte("null")
+}
+
+// isJSONWhitespace returns true for insignificant whitespace characters.
+func isJSONWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,164 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masker"
+)
+
+// newTestJSONMasker builds jsonMasker applying the strategy to the given paths.
+func newTestJSONMasker(t *testing.T, strategy string, options masker.Options, paths ...string) *jsonMasker {
+	m, err := masker.DefaultRegistry().New(masker.ParseSpec(strategy, options))
+	require.NoError(t, err)
+
+	jm := &jsonMasker{}
+
+	for _, str := range paths {
+		path, err := parseJSONPath(str)
+		require.NoError(t, err)
+
+		jm.add(path, m)
+	}
+
+	return jm
+}
+
+func TestJSONMaskerMask(t *testing.T) {
+	testCases := []struct {
+		name     string
+		paths    []string
+		value    string
+		expected string
+	}{
+		{
+			name:     "object member",
+			paths:    []string{"$.contact.email"},
+			value:    `{"contact": {"email": "jane@example.com", "phone": "555-0100"}, "email": "jane@example.com"}`,
+			expected: `{"contact": {"email": "***", "phone": "555-0100"}, "email": "jane@example.com"}`,
+		},
+		{
+			name:     "key order and whitespace are preserved",
+			paths:    []string{"$.b"},
+			value:    "{ \"z\" : 1,\n  \"b\" : \"secret\" ,\"a\":[1, 2] }",
+			expected: "{ \"z\" : 1,\n  \"b\" : \"***\" ,\"a\":[1, 2] }",
+		},
+		{
+			name:     "array wildcard",
+			paths:    []string{"$.addresses[*].street"},
+			value:    `{"addresses": [{"street": "Main St"}, {"city": "Springfield"}, {"street": "Oak Ave"}]}`,
+			expected: `{"addresses": [{"street": "***"}, {"city": "Springfield"}, {"street": "***"}]}`,
+		},
+		{
+			name:     "array index",
+			paths:    []string{"$[1]"},
+			value:    `["a", "b", "c"]`,
+			expected: `["a", "***", "c"]`,
+		},
+		{
+			name:     "member wildcard",
+			paths:    []string{"$.*"},
+			value:    `{"a": "x", "b": "y"}`,
+			expected: `{"a": "***", "b": "***"}`,
+		},
+		{
+			name:     "multiple paths",
+			paths:    []string{"$.a", "$.c"},
+			value:    `{"a": "x", "b": "y", "c": "z"}`,
+			expected: `{"a": "***", "b": "y", "c": "***"}`,
+		},
+		{
+			name:     "non-string nodes",
+			paths:    []string{"$.n", "$.b", "$.o", "$.a", "$.null"},
+			value:    `{"n": 42.5, "b": true, "o": {"x": 1}, "a": [1], "null": null}`,
+			expected: `{"n": 0, "b": false, "o": null, "a": null, "null": null}`,
+		},
+		{
+			name:     "whole document",
+			paths:    []string{"$"},
+			value:    `{"a": "x"}`,
+			expected: `null`,
+		},
+		{
+			name:     "missing path",
+			paths:    []string{"$.contact.email"},
+			value:    `{"contact": "jane@example.com", "list": [{"contact": {}}]}`,
+			expected: `{"contact": "jane@example.com", "list": [{"contact": {}}]}`,
+		},
+		{
+			name:     "escaped strings",
+			paths:    []string{"$[\"a\\\"b\"]"},
+			value:    `{"a\"b": "x\"}", "c": "[\\"}`,
+			expected: `{"a\"b": "***", "c": "[\\"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestJSONMasker(t, "redact", nil, tc.paths...)
+
+			masked, err := m.Mask([]byte(tc.value), oid.T_jsonb)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, string(masked))
+		})
+	}
+}
+
+func TestJSONMaskerMaskStrategies(t *testing.T) {
+	m := newTestJSONMasker(t, "partial", masker.Options{"keep_last": int64(2)}, "$.email", "$.pin")
+
+	masked, err := m.Mask([]byte(`{"email": "<jane>@example.com", "pin": 1234}`), oid.T_json)
+	require.NoError(t, err)
+
+	// HTML characters are not escaped
+	assert.Equal(t, `{"email": "****************om", "pin": 34}`, string(masked))
+
+	m = newTestJSONMasker(t, "nullify", nil, "$.email")
+
+	masked, err = m.Mask([]byte(`{"email": "jane@example.com"}`), oid.T_json)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"email": null}`, string(masked))
+}
+
+func TestJSONNumber(t *testing.T) {
+	testCases := map[string]string{
+		"42":     "42",
+		"0042":   "42",
+		"-007.5": "-7.5",
+		"0000":   "0",
+		"0.25":   "0.25",
+		"000.25": "0.25",
+		"1e3":    "1e3",
+	}
+
+	for value, expected := range testCases {
+		number, ok := jsonNumber([]byte(value))
+		require.True(t, ok, value)
+
+		assert.Equal(t, expected, string(number), value)
+	}
+
+	for _, value := range []string{"", "NaN", "Infinity", "-", "1.", ".5"} {
+		_, ok := jsonNumber([]byte(value))
+		assert.False(t, ok, value)
+	}
+}
+
+func TestJSONMaskerMaskErrors(t *testing.T) {
+	m := newTestJSONMasker(t, "redact", nil, "$.email")
+
+	masked, err := m.Mask(nil, oid.T_jsonb)
+	assert.NoError(t, err)
+	assert.Nil(t, masked)
+
+	_, err = m.Mask([]byte(`{"email": `), oid.T_jsonb)
+	assert.Error(t, err)
+
+	_, err = m.Mask([]byte(`{"email": "jane@example.com"}`), oid.T_text)
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package masking
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSON path selecting nodes inside a JSON document. Supported syntax is a subset
+// of SQL/JSON paths:
+//
+//	$                     the whole document
+//	$.contact.email       object member
+//	$["first name"]       object member with a quoted name
+//	$.addresses[0]        array element
+//	$.addresses[*].street any array element (or any object member with .*)
+type jsonPath []jsonPathStep
+
+// jsonPathStep is a single step of a JSON path.
+type jsonPathStep struct {
+	// Object member name (if index is jsonPathMember)
+	member string
+
+	// Array element index, jsonPathMember or jsonPathWildcard
+	index int
+}
+
+// Special values of jsonPathStep.index.
+const (
+	jsonPathMember   = -1
+	jsonPathWildcard = -2
+)
+
+// parseJSONPath parses a JSON path (e.g. "$.addresses[*].street").
+func parseJSONPath(str string) (jsonPath, error) {
+	if !strings.HasPrefix(str, "$") {
+		return nil, fmt.Errorf("masking: JSON path %q must start with $", str)
+	}
+
+	var path jsonPath
+
+	for rest := str[1:]; rest != ""; {
+		var (
+			step jsonPathStep
+			err  error
+		)
+
+		switch rest[0] {
+		case '.':
+			step, rest, err = parseJSONPathMember(rest[1:])
+
+		case '[':
+			step, rest, err = parseJSONPathSubscript(rest[1:])
+
+		default:
+			err = fmt.Errorf("unexpected %q", rest[0])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("masking: invalid JSON path %q: %v", str, err)
+		}
+
+		path = append(path, step)
+	}
+
+	return path, nil
+}
+
+// parseJSONPathMember parses an unquoted member name or a wildcard following a dot.
+func parseJSONPathMember(str string) (jsonPathStep, string, error) {
+	if strings.HasPrefix(str, "*") {
+		return jsonPathStep{index: jsonPathWildcard}, str[1:], nil
+	}
+
+	if strings.HasPrefix(str, `"`) {
+		return parseJSONPathQuoted(str)
+	}
+
+	end := strings.IndexAny(str, ".[")
+
+	if end < 0 {
+		end = len(str)
+	}
+
+	if end == 0 {
+		return jsonPathStep{}, "", fmt.Errorf("member name expected")
+	}
+
+	return jsonPathStep{member: str[:end], index: jsonPathMember}, str[end:], nil
+}
+
+// parseJSONPathSubscript parses a subscript following an opening bracket: [*], [n], ["name"] or ['name'].
+func parseJSONPathSubscript(str string) (jsonPathStep, string, error) {
+	var (
+		step jsonPathStep
+		err  error
+	)
+
+	switch {
+	case strings.HasPrefix(str, "*"):
+		step, str = jsonPathStep{index: jsonPathWildcard}, str[1:]
+
+	case strings.HasPrefix(str, `"`), strings.HasPrefix(str, "'"):
+		step, str, err = parseJSONPathQuoted(str)
+
+		if err != nil {
+			return step, "", err
+		}
+
+	default:
+		end := strings.IndexByte(str, ']')
+
+		if end < 0 {
+			return step, "", fmt.Errorf("unterminated subscript")
+		}
+
+		index, err := strconv.Atoi(str[:end])
+
+		if err != nil || index < 0 {
+			return step, "", fmt.Errorf("invalid array index %q", str[:end])
+		}
+
+		step, str = jsonPathStep{index: index}, str[end:]
+	}
+
+	if !strings.HasPrefix(str, "]") {
+		return step, "", fmt.Errorf("unterminated subscript")
+	}
+
+	return step, str[1:], nil
+}
+
+// parseJSONPathQuoted parses a member name in single or double quotes. Backslash escapes the next character.
+func parseJSONPathQuoted(str string) (jsonPathStep, string, error) {
+	quote := str[0]
+
+	var sb strings.Builder
+
+	for i := 1; i < len(str); i++ {
+		switch str[i] {
+		case quote:
+			return jsonPathStep{member: sb.String(), index: jsonPathMember}, str[i+1:], nil
+
+		case '\\':
+			i++
+
+			if i == len(str) {
+				return jsonPathStep{}, "", fmt.Errorf("unterminated member name")
+			}
+		}
+
+		sb.WriteByte(str[i])
+	}
+
+	return jsonPathStep{}, "", fmt.Errorf("unterminated member name")
+}
+
+// matchesMember returns true if the step selects the object member with the given name.
+func (s jsonPathStep) matchesMember(name string) bool {
+	return s.index == jsonPathWildcard || (s.index == jsonPathMember && s.member == name)
+}
+
+// matchesElement returns true if the step selects the array element with the given index.
+func (s jsonPathStep) matchesElement(index int) bool {
+	return s.index == jsonPathWildcard || s.index == index
+}
@@ -0,0 +1,54 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	testCases := map[string]jsonPath{
+		"$":               nil,
+		"$.contact.email": {{member: "contact", index: jsonPathMember}, {member: "email", index: jsonPathMember}},
+		"$.addresses[*].street": {
+			{member: "addresses", index: jsonPathMember},
+			{index: jsonPathWildcard},
+			{member: "street", index: jsonPathMember},
+		},
+		"$.phones[1]":     {{member: "phones", index: jsonPathMember}, {index: 1}},
+		"$.*":             {{index: jsonPathWildcard}},
+		`$["first name"]`: {{member: "first name", index: jsonPathMember}},
+		`$['it\'s']`:      {{member: "it's", index: jsonPathMember}},
+		`$."a.b"`:         {{member: "a.b", index: jsonPathMember}},
+	}
+
+	for str, expected := range testCases {
+		path, err := parseJSONPath(str)
+		require.NoError(t, err, str)
+
+		assert.Equal(t, expected, path, str)
+	}
+
+	for _, str := range []string{"", "contact.email", "$.", "$..email", "$[", "$[-1]", "$[x]", `$["name`, "$email"} {
+		_, err := parseJSONPath(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestJSONPathStepMatches(t *testing.T) {
+	member := jsonPathStep{member: "email", index: jsonPathMember}
+	element := jsonPathStep{index: 2}
+	wildcard := jsonPathStep{index: jsonPathWildcard}
+
+	assert.True(t, member.matchesMember("email"))
+	assert.False(t, member.matchesMember("name"))
+	assert.False(t, member.matchesElement(0))
+
+	assert.True(t, element.matchesElement(2))
+	assert.False(t, element.matchesElement(1))
+	assert.False(t, element.matchesMember("2"))
+
+	assert.True(t, wildcard.matchesMember("email"))
+	assert.True(t, wildcard.matchesElement(0))
+}
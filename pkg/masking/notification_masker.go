@@ -0,0 +1,189 @@
+package masking
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/lib/pq/oid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// NotificationMasker masks payloads of NotificationResponse messages according to the channel rules.
+// Notifications arrive asynchronously, so they would bypass masking of the query results otherwise.
+type NotificationMasker struct {
+	// Maskers keyed by channel name
+	channels map[string]*channelMasker
+}
+
+// channelMasker masks payloads of the notifications raised on a single channel.
+type channelMasker struct {
+	// Masks the whole payload
+	payload masker.Masker
+
+	// Masks nodes of JSON payloads selected by JSON paths
+	json *jsonMasker
+
+	// Mask parts of the payload matched by regular expressions
+	patterns []*patternMasker
+}
+
+// patternMasker is a masking strategy applied to the parts of a payload matched by a regular expression.
+type patternMasker struct {
+	pattern *regexp.Regexp
+	masker  masker.Masker
+}
+
+// NewNotificationMasker initializes a new NotificationMasker with the given rules and masking strategies registry.
+func NewNotificationMasker(rules []*NotificationRule, registry *masker.Registry) (*NotificationMasker, error) {
+	channels := make(map[string]*channelMasker)
+
+	for _, rule := range rules {
+		err := rule.Validate()
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := registry.New(rule.Spec())
+
+		if err != nil {
+			return nil, fmt.Errorf("masking: notification rule for channel %s: %w", rule.Channel, err)
+		}
+
+		channel, ok := channels[rule.Channel]
+
+		if !ok {
+			channel = &channelMasker{}
+			channels[rule.Channel] = channel
+		}
+
+		switch {
+		case rule.Path != "":
+			// NB: the path is validated above
+			path, _ := parseJSONPath(rule.Path)
+
+			if channel.json == nil {
+				channel.json = &jsonMasker{}
+			}
+
+			channel.json.add(path, m)
+
+		case rule.Pattern != "":
+			channel.patterns = append(channel.patterns, &patternMasker{pattern: regexp.MustCompile(rule.Pattern), masker: m})
+
+		default:
+			if channel.payload != nil {
+				return nil, fmt.Errorf("masking: notification rules for channel %s: payload is masked twice", rule.Channel)
+			}
+
+			channel.payload = m
+		}
+
+		if channel.payload != nil && (channel.json != nil || len(channel.patterns) > 0) {
+			return nil, fmt.Errorf("masking: notification rules for channel %s: payload cannot be masked both as a whole "+
+				"and by paths or patterns", rule.Channel)
+		}
+	}
+
+	return &NotificationMasker{channels: channels}, nil
+}
+
+// Mask masks the payload of the notification in place. Payloads that cannot be masked are replaced
+// with an empty string: the original payload is never sent.
+func (m *NotificationMasker) Mask(msg *pg.NotificationResponseMessage) {
+	if m == nil {
+		return
+	}
+
+	channel, ok := m.channels[msg.Channel]
+
+	if !ok {
+		return
+	}
+
+	payload, err := channel.mask([]byte(msg.Payload))
+
+	if err != nil {
+		log.Errorf("masking: error masking notification on channel %s: %v; sending empty payload", msg.Channel, err)
+		payload = nil
+	}
+
+	msg.Payload = string(payload)
+}
+
+// mask masks a notification payload.
+func (c *channelMasker) mask(payload []byte) ([]byte, error) {
+	if c.payload != nil {
+		return c.payload.Mask(payload, oid.T_text)
+	}
+
+	var err error
+
+	if c.json != nil {
+		// NB: payload is JSON text, so it is masked as json preserving the formatting
+		payload, err = c.json.Mask(payload, oid.T_json)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range c.patterns {
+		payload, err = p.mask(payload)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
+
+// mask masks parts of the payload matched by the pattern: capturing groups if the pattern has any,
+// or whole matches otherwise.
+func (p *patternMasker) mask(payload []byte) ([]byte, error) {
+	var masked []byte
+
+	last := 0
+
+	for _, match := range p.pattern.FindAllSubmatchIndex(payload, -1) {
+		groups := [][]int{match[:2]}
+
+		if len(match) > 2 {
+			groups = nil
+
+			for i := 2; i < len(match); i += 2 {
+				// Group that didn't participate in the match
+				if match[i] < 0 {
+					continue
+				}
+
+				groups = append(groups, match[i:i+2])
+			}
+		}
+
+		for _, group := range groups {
+			start, end := group[0], group[1]
+
+			// Nested groups are masked as a part of the outer group
+			if start < last {
+				continue
+			}
+
+			value, err := p.masker.Mask(payload[start:end], oid.T_text)
+
+			if err != nil {
+				return nil, err
+			}
+
+			masked = append(masked, payload[last:start]...)
+			masked = append(masked, value...)
+			last = end
+		}
+	}
+
+	return append(masked, payload[last:]...), nil
+}
@@ -0,0 +1,79 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+func TestNewNotificationMasker(t *testing.T) {
+	registry := masker.DefaultRegistry()
+
+	_, err := NewNotificationMasker([]*NotificationRule{
+		{Channel: "user_events", Path: "$.email", Strategy: "redact"},
+		{Channel: "user_events", Pattern: `\d+`, Strategy: "redact"},
+	}, registry)
+	assert.NoError(t, err)
+
+	_, err = NewNotificationMasker([]*NotificationRule{{Channel: "user_events", Strategy: "unknown"}}, registry)
+	assert.Error(t, err)
+
+	_, err = NewNotificationMasker([]*NotificationRule{{Channel: "user_events"}}, registry)
+	assert.Error(t, err)
+
+	_, err = NewNotificationMasker([]*NotificationRule{
+		{Channel: "user_events", Strategy: "redact"},
+		{Channel: "user_events", Path: "$.email", Strategy: "redact"},
+	}, registry)
+	assert.Error(t, err, "payload is masked both as a whole and by paths")
+
+	_, err = NewNotificationMasker([]*NotificationRule{
+		{Channel: "user_events", Strategy: "redact"},
+		{Channel: "user_events", Strategy: "nullify"},
+	}, registry)
+	assert.Error(t, err, "payload is masked twice")
+}
+
+func TestNotificationMaskerMask(t *testing.T) {
+	m, err := NewNotificationMasker([]*NotificationRule{
+		{Channel: "user_events", Path: "$.user.email", Strategy: "redact"},
+		{Channel: "user_events", Path: "$.user.phone", Strategy: "partial", Options: masker.Options{"keep_last": int64(2)}},
+		{Channel: "audit", Pattern: `email=(\S+)|phone=(\d+)`, Strategy: "redact"},
+		{Channel: "secrets", Strategy: "redact"},
+		{Channel: "tokens", Pattern: `[a-f0-9]{8}`, Strategy: "redact", Options: masker.Options{"value": "<token>"}},
+	}, masker.DefaultRegistry())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		channel  string
+		payload  string
+		expected string
+	}{
+		{"user_events", `{"user": {"id": 1, "email": "jane@example.com", "phone": "5550100"}}`,
+			`{"user": {"id": 1, "email": "***", "phone": "*****00"}}`},
+		{"audit", "login email=jane@example.com phone=5550100 ok", "login email=*** phone=*** ok"},
+		{"secrets", "s3cr3t", "***"},
+		{"tokens", "a1b2c3d4 and deadbeef", "<token> and <token>"},
+		{"other", "jane@example.com", "jane@example.com"},
+
+		// Invalid JSON is never sent
+		{"user_events", "jane@example.com", ""},
+	}
+
+	for _, tc := range testCases {
+		msg := &pg.NotificationResponseMessage{ProcessID: 28822, Channel: tc.channel, Payload: tc.payload}
+		m.Mask(msg)
+
+		assert.Equal(t, tc.expected, msg.Payload, "channel %s", tc.channel)
+	}
+
+	// Nil masker does nothing
+	msg := &pg.NotificationResponseMessage{Channel: "secrets", Payload: "s3cr3t"}
+	(*NotificationMasker)(nil).Mask(msg)
+
+	assert.Equal(t, "s3cr3t", msg.Payload)
+}
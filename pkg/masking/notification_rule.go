@@ -0,0 +1,73 @@
+package masking
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hired/gevulot/pkg/masker"
+)
+
+// NotificationRule describes how to mask payloads of LISTEN/NOTIFY notifications raised on a channel.
+// Rules are declared in the gevulot.toml:
+//
+//	[[mask-notification]]
+//	channel = "user_events"
+//	path = "$.user.email"
+//	strategy = "redact"
+//
+// The whole payload is masked as text unless a JSON path or a regular expression selects the masked parts.
+type NotificationRule struct {
+	// Channel name.
+	Channel string
+
+	// JSON path of the masked nodes of JSON payloads (e.g. "$.user.email").
+	Path string
+
+	// Regular expression matching the masked parts of the payload. If the expression has capturing
+	// groups, only the groups are masked.
+	Pattern string
+
+	// Name of the masking strategy to apply to the payload.
+	Strategy string
+
+	// Masking strategy options.
+	Options masker.Options
+}
+
+// Validate checks that all mandatory rule fields are set and the path or the pattern is valid.
+func (r *NotificationRule) Validate() error {
+	if r.Channel == "" {
+		return fmt.Errorf("masking: notification rule: channel is not set")
+	}
+
+	if r.Strategy == "" {
+		return fmt.Errorf("masking: notification rule for channel %s: strategy is not set", r.Channel)
+	}
+
+	if r.Path != "" && r.Pattern != "" {
+		return fmt.Errorf("masking: notification rule for channel %s: path and pattern cannot be used together", r.Channel)
+	}
+
+	if r.Path != "" {
+		_, err := parseJSONPath(r.Path)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Pattern != "" {
+		_, err := regexp.Compile(r.Pattern)
+
+		if err != nil {
+			return fmt.Errorf("masking: notification rule for channel %s: invalid pattern: %w", r.Channel, err)
+		}
+	}
+
+	return nil
+}
+
+// Spec returns specification of the rule's masking strategy.
+func (r *NotificationRule) Spec() *masker.Spec {
+	return masker.ParseSpec(r.Strategy, r.Options)
+}
@@ -0,0 +1,19 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationRuleValidate(t *testing.T) {
+	assert.NoError(t, (&NotificationRule{Channel: "user_events", Strategy: "redact"}).Validate())
+	assert.NoError(t, (&NotificationRule{Channel: "user_events", Path: "$.email", Strategy: "redact"}).Validate())
+	assert.NoError(t, (&NotificationRule{Channel: "user_events", Pattern: `email=(\S+)`, Strategy: "redact"}).Validate())
+
+	assert.Error(t, (&NotificationRule{Strategy: "redact"}).Validate())
+	assert.Error(t, (&NotificationRule{Channel: "user_events"}).Validate())
+	assert.Error(t, (&NotificationRule{Channel: "user_events", Path: "email", Strategy: "redact"}).Validate())
+	assert.Error(t, (&NotificationRule{Channel: "user_events", Pattern: `email=(\S+`, Strategy: "redact"}).Validate())
+	assert.Error(t, (&NotificationRule{Channel: "user_events", Path: "$.email", Pattern: "@", Strategy: "redact"}).Validate())
+}
@@ -0,0 +1,90 @@
+package masking
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hired/gevulot/pkg/masker"
+)
+
+// DefaultSchema is used when a Rule's table name is not schema-qualified.
+const DefaultSchema = "public"
+
+// Rule describes how to mask a single table column. Rules are declared in the gevulot.toml:
+//
+//	[[mask]]
+//	table = "public.users"
+//	column = "email"
+//	strategy = "partial"
+//	options = { keep_first = 1, keep_last = 4 }
+//
+// Rules for json and jsonb columns may mask only the nodes selected by a JSON path:
+//
+//	[[mask]]
+//	table = "public.users"
+//	column = "profile"
+//	path = "$.addresses[*].street"
+//	strategy = "redact"
+type Rule struct {
+	// Table name, optionally qualified with a schema (e.g. "public.users").
+	Table string
+
+	// Column name.
+	Column string
+
+	// JSON path of the masked nodes inside json and jsonb values (e.g. "$.contact.email").
+	// The whole value is masked if the path is not set.
+	Path string
+
+	// Name of the masking strategy to apply to the column values.
+	Strategy string
+
+	// Masking strategy options.
+	Options masker.Options
+}
+
+// Validate checks that all mandatory rule fields are set.
+func (r *Rule) Validate() error {
+	if r.Table == "" {
+		return fmt.Errorf("masking: rule for column %q: table is not set", r.Column)
+	}
+
+	if r.Column == "" {
+		return fmt.Errorf("masking: rule for table %q: column is not set", r.Table)
+	}
+
+	if r.Strategy == "" {
+		return fmt.Errorf("masking: rule for %s.%s: strategy is not set", r.Table, r.Column)
+	}
+
+	if r.Path != "" {
+		_, err := parseJSONPath(r.Path)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Key returns fully qualified column name (i.e. "public.users.email") the rule is applied to.
+func (r *Rule) Key() string {
+	schema, table := DefaultSchema, r.Table
+
+	if i := strings.IndexByte(r.Table, '.'); i >= 0 {
+		schema, table = r.Table[:i], r.Table[i+1:]
+	}
+
+	return columnKey(schema, table, r.Column)
+}
+
+// Spec returns specification of the rule's masking strategy.
+func (r *Rule) Spec() *masker.Spec {
+	return masker.ParseSpec(r.Strategy, r.Options)
+}
+
+// columnKey builds fully qualified column name.
+func columnKey(schema, table, column string) string {
+	return schema + "." + table + "." + column
+}
@@ -0,0 +1,30 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleKey(t *testing.T) {
+	testCases := map[string]*Rule{
+		"public.users.email":   {Table: "users", Column: "email"},
+		"public.users.name":    {Table: "public.users", Column: "name"},
+		"billing.cards.number": {Table: "billing.cards", Column: "number"},
+	}
+
+	for expected, rule := range testCases {
+		assert.Equal(t, expected, rule.Key())
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	assert.NoError(t, (&Rule{Table: "users", Column: "email", Strategy: "redact"}).Validate())
+
+	assert.Error(t, (&Rule{Column: "email", Strategy: "redact"}).Validate())
+	assert.Error(t, (&Rule{Table: "users", Strategy: "redact"}).Validate())
+	assert.Error(t, (&Rule{Table: "users", Column: "email"}).Validate())
+
+	assert.NoError(t, (&Rule{Table: "users", Column: "profile", Path: "$.email", Strategy: "redact"}).Validate())
+	assert.Error(t, (&Rule{Table: "users", Column: "profile", Path: "email", Strategy: "redact"}).Validate())
+}
@@ -44,6 +44,24 @@ type AuthenticationGSSContinueMessage struct {
 // AuthenticationSSPIMessage is sent by a backend when SSPI authentication is required.
 type AuthenticationSSPIMessage struct{}
 
+// AuthenticationSASLMessage is sent by a backend when SASL authentication (e.g., SCRAM-SHA-256) is required.
+type AuthenticationSASLMessage struct {
+	// Authentication mechanisms supported by the backend in the order of preference.
+	Mechanisms []string
+}
+
+// AuthenticationSASLContinueMessage is sent by a backend with a SASL challenge.
+type AuthenticationSASLContinueMessage struct {
+	// SASL data, specific to the SASL mechanism being used.
+	Data []byte
+}
+
+// AuthenticationSASLFinalMessage is sent by a backend when SASL authentication has completed.
+type AuthenticationSASLFinalMessage struct {
+	// SASL outcome "additional data", specific to the SASL mechanism being used.
+	Data []byte
+}
+
 var (
 	// ErrunsupportedAuthenticationRequest is returned when ParseAuthenticationRequestMessage
 	// cannot handle unknown auth status.
@@ -60,6 +78,9 @@ const (
 	authStatusCodeGSS               = 7
 	authStatusCodeGSSContinue       = 8
 	authStatusCodeSSPI              = 9
+	authStatusCodeSASL              = 10
+	authStatusCodeSASLContinue      = 11
+	authStatusCodeSASLFinal         = 12
 )
 
 // Mapping between status code and implementation of AuthenticationRequestMessage.
@@ -72,6 +93,9 @@ var authStatusMap = map[int32]reflect.Type{ //nolint:gochecknoglobals
 	authStatusCodeGSS:               reflect.TypeOf(AuthenticationGSSMessage{}),
 	authStatusCodeGSSContinue:       reflect.TypeOf(AuthenticationGSSContinueMessage{}),
 	authStatusCodeSSPI:              reflect.TypeOf(AuthenticationSSPIMessage{}),
+	authStatusCodeSASL:              reflect.TypeOf(AuthenticationSASLMessage{}),
+	authStatusCodeSASLContinue:      reflect.TypeOf(AuthenticationSASLContinueMessage{}),
+	authStatusCodeSASLFinal:         reflect.TypeOf(AuthenticationSASLFinalMessage{}),
 }
 
 // ParseAuthenticationRequestMessage parses authentication request from a network frame.
@@ -120,6 +144,36 @@ func ParseAuthenticationRequestMessage(frame Frame) (AuthenticationRequestMessag
 	case *AuthenticationGSSContinueMessage:
 		v.Data, err = messageData.ReadBytes(messageData.Len())
 
+		if err != nil {
+			return nil, err
+		}
+
+	case *AuthenticationSASLMessage:
+		// List of mechanism names is terminated by an empty string
+		for {
+			mechanism, err := messageData.ReadString()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if mechanism == "" {
+				break
+			}
+
+			v.Mechanisms = append(v.Mechanisms, mechanism)
+		}
+
+	case *AuthenticationSASLContinueMessage:
+		v.Data, err = messageData.ReadBytes(messageData.Len())
+
+		if err != nil {
+			return nil, err
+		}
+
+	case *AuthenticationSASLFinalMessage:
+		v.Data, err = messageData.ReadBytes(messageData.Len())
+
 		if err != nil {
 			return nil, err
 		}
@@ -195,3 +249,38 @@ func (m *AuthenticationSSPIMessage) Frame() Frame {
 
 	return NewStandardFrame(AuthenticationRequestMessageType, messageBuffer)
 }
+
+// Frame serializes the message into a network frame.
+func (m *AuthenticationSASLMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteInt32(authStatusCodeSASL)
+
+	for _, mechanism := range m.Mechanisms {
+		messageBuffer.WriteString(mechanism)
+	}
+
+	messageBuffer.WriteByte(0)
+
+	return NewStandardFrame(AuthenticationRequestMessageType, messageBuffer)
+}
+
+// Frame serializes the message into a network frame.
+func (m *AuthenticationSASLContinueMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteInt32(authStatusCodeSASLContinue)
+	messageBuffer.WriteBytes(m.Data)
+
+	return NewStandardFrame(AuthenticationRequestMessageType, messageBuffer)
+}
+
+// Frame serializes the message into a network frame.
+func (m *AuthenticationSASLFinalMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteInt32(authStatusCodeSASLFinal)
+	messageBuffer.WriteBytes(m.Data)
+
+	return NewStandardFrame(AuthenticationRequestMessageType, messageBuffer)
+}
@@ -17,6 +17,17 @@ const GoldenAuthenticationMD5PasswordMessagePacket = "\x52\x00\x00\x00\x0c\x00\x
 // Real auth ok message packet from pg captured with Wireshark
 const GoldenAuthenticationOkMessagePacket = "\x52\x00\x00\x00\x08\x00\x00\x00\x00"
 
+// SCRAM-SHA-256 exchange from RFC 7677 with the PostgreSQL framing
+const (
+	GoldenAuthenticationSASLMessagePacket = "\x52\x00\x00\x00\x17\x00\x00\x00\x0a" + "SCRAM-SHA-256\x00\x00"
+
+	GoldenAuthenticationSASLContinueMessagePacket = "\x52\x00\x00\x00\x5e\x00\x00\x00\x0b" +
+		"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+
+	GoldenAuthenticationSASLFinalMessagePacket = "\x52\x00\x00\x00\x36\x00\x00\x00\x0c" +
+		"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
 func TestParseAuthenticationRequestMessage(t *testing.T) {
 	{
 		msg, err := ParseAuthenticationRequestMessage(StandardFrame(GoldenAuthenticationCleartextPasswordMessagePacket))
@@ -42,6 +53,50 @@ func TestParseAuthenticationRequestMessage(t *testing.T) {
 		assert.IsType(t, &AuthenticationOkMessage{}, msg)
 	}
 
+	{
+		msg, err := ParseAuthenticationRequestMessage(StandardFrame(GoldenAuthenticationSASLMessagePacket))
+
+		assert.NoError(t, err)
+
+		if assert.IsType(t, &AuthenticationSASLMessage{}, msg) {
+			assert.Equal(t, []string{"SCRAM-SHA-256"}, msg.(*AuthenticationSASLMessage).Mechanisms)
+		}
+	}
+
+	{
+		msg, err := ParseAuthenticationRequestMessage(StandardFrame(GoldenAuthenticationSASLContinueMessagePacket))
+
+		assert.NoError(t, err)
+
+		if assert.IsType(t, &AuthenticationSASLContinueMessage{}, msg) {
+			assert.Equal(t, []byte(GoldenAuthenticationSASLContinueMessagePacket[9:]), msg.(*AuthenticationSASLContinueMessage).Data)
+		}
+	}
+
+	{
+		msg, err := ParseAuthenticationRequestMessage(StandardFrame(GoldenAuthenticationSASLFinalMessagePacket))
+
+		assert.NoError(t, err)
+
+		if assert.IsType(t, &AuthenticationSASLFinalMessage{}, msg) {
+			assert.Equal(t, []byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="), msg.(*AuthenticationSASLFinalMessage).Data)
+		}
+	}
+
+	// Mechanisms list is not terminated
+	{
+		_, err := ParseAuthenticationRequestMessage(StandardFrame("\x52\x00\x00\x00\x16\x00\x00\x00\x0a" + "SCRAM-SHA-256\x00"))
+
+		assert.Error(t, err)
+	}
+
+	// Unknown status code
+	{
+		_, err := ParseAuthenticationRequestMessage(StandardFrame("\x52\x00\x00\x00\x08\x00\x00\x00\x0d"))
+
+		assert.Equal(t, ErrunsupportedAuthenticationRequest, err)
+	}
+
 	// Test invalid type
 	{
 		_, err := ParseAuthenticationRequestMessage(append(StandardFrame{'X'}, GoldenAuthenticationOkMessagePacket[1:]...))
@@ -65,4 +120,19 @@ func TestAuthenticationRequestMessageFrame(t *testing.T) {
 		msg := &AuthenticationOkMessage{}
 		assert.Equal(t, []byte(GoldenAuthenticationOkMessagePacket), msg.Frame().Bytes())
 	}
+
+	{
+		msg := &AuthenticationSASLMessage{[]string{"SCRAM-SHA-256"}}
+		assert.Equal(t, []byte(GoldenAuthenticationSASLMessagePacket), msg.Frame().Bytes())
+	}
+
+	{
+		msg := &AuthenticationSASLContinueMessage{[]byte(GoldenAuthenticationSASLContinueMessagePacket[9:])}
+		assert.Equal(t, []byte(GoldenAuthenticationSASLContinueMessagePacket), msg.Frame().Bytes())
+	}
+
+	{
+		msg := &AuthenticationSASLFinalMessage{[]byte(GoldenAuthenticationSASLFinalMessagePacket[9:])}
+		assert.Equal(t, []byte(GoldenAuthenticationSASLFinalMessagePacket), msg.Frame().Bytes())
+	}
 }
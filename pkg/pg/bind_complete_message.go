@@ -0,0 +1,30 @@
+package pg
+
+// BindCompleteMessageType identifies BindCompleteMessage message.
+const BindCompleteMessageType = '2'
+
+// BindCompleteMessage is sent by a backend when Bind has been completed.
+type BindCompleteMessage struct{}
+
+// Compile time check to make sure that BindCompleteMessage implements the Message interface.
+var _ Message = &BindCompleteMessage{}
+
+// ParseBindCompleteMessage parses BindCompleteMessage from a network frame.
+func ParseBindCompleteMessage(frame Frame) (*BindCompleteMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != BindCompleteMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &BindCompleteMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *BindCompleteMessage) Frame() Frame {
+	return NewStandardFrame(BindCompleteMessageType, nil)
+}
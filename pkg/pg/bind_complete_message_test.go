@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden bind complete message packet (the message has no body)
+const GoldenBindCompleteMessagePacket = "\x32\x00\x00\x00\x04"
+
+func TestParseBindCompleteMessage(t *testing.T) {
+	{
+		_, err := ParseBindCompleteMessage(StandardFrame(GoldenBindCompleteMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseBindCompleteMessage(append(StandardFrame{'!'}, GoldenBindCompleteMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseBindCompleteMessage(NewStandardFrame(BindCompleteMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestBindCompleteMessageFrame(t *testing.T) {
+	msg := &BindCompleteMessage{}
+	assert.Equal(t, []byte(GoldenBindCompleteMessagePacket), msg.Frame().Bytes())
+}
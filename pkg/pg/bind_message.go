@@ -0,0 +1,194 @@
+package pg
+
+// BindMessageType identifies BindMessage message.
+const BindMessageType = 'B'
+
+// BindMessage is sent by a frontend to create a portal from a prepared statement (extended query protocol).
+type BindMessage struct {
+	// The name of the destination portal (an empty string selects the unnamed portal).
+	Portal string
+
+	// The name of the source prepared statement (an empty string selects the unnamed prepared statement).
+	Statement string
+
+	// The parameter format codes. There can be zero to indicate that there are no parameters or that the parameters
+	// all use the default format (text); or one, in which case the specified format code is applied to all
+	// parameters; or it can equal the actual number of parameters.
+	ParameterFormats []DataFormat
+
+	// The parameter values in the format indicated by the associated format code; nil represents NULL.
+	Parameters [][]byte
+
+	// The result-column format codes. The same rules as for ParameterFormats apply.
+	ResultFormats []DataFormat
+}
+
+// Compile time check to make sure that BindMessage implements the Message interface.
+var _ Message = &BindMessage{}
+
+// ParseBindMessage parses BindMessage from a network frame.
+func ParseBindMessage(frame Frame) (*BindMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != BindMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	portal, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	parameterFormats, err := readDataFormats(&messageData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parametersCount, err := messageData.ReadInt16()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parametersCount < 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	parameters := make([][]byte, parametersCount)
+
+	for i := range parameters {
+		size, err := messageData.ReadInt32()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// -1 represents NULL
+		if size == -1 {
+			continue
+		}
+
+		if size < 0 {
+			return nil, ErrMalformedMessage
+		}
+
+		parameters[i], err = messageData.ReadBytes(int(size))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resultFormats, err := readDataFormats(&messageData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &BindMessage{
+		Portal:           portal,
+		Statement:        statement,
+		ParameterFormats: parameterFormats,
+		Parameters:       parameters,
+		ResultFormats:    resultFormats,
+	}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *BindMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteString(m.Portal)
+	messageBuffer.WriteString(m.Statement)
+
+	writeDataFormats(&messageBuffer, m.ParameterFormats)
+
+	messageBuffer.WriteInt16(int16(len(m.Parameters)))
+
+	for _, value := range m.Parameters {
+		if value == nil {
+			// -1 represents NULL
+			messageBuffer.WriteInt32(-1)
+			continue
+		}
+
+		messageBuffer.WriteInt32(int32(len(value)))
+		messageBuffer.WriteBytes(value)
+	}
+
+	writeDataFormats(&messageBuffer, m.ResultFormats)
+
+	return NewStandardFrame(BindMessageType, messageBuffer)
+}
+
+// ParameterFormat returns format of the i-th parameter according to the format codes rules.
+func (m *BindMessage) ParameterFormat(i int) DataFormat {
+	return formatAt(m.ParameterFormats, i)
+}
+
+// ResultFormat returns format of the i-th result column according to the format codes rules.
+func (m *BindMessage) ResultFormat(i int) DataFormat {
+	return formatAt(m.ResultFormats, i)
+}
+
+// formatAt returns the i-th format: no formats mean text, a single format applies to all values.
+func formatAt(formats []DataFormat, i int) DataFormat {
+	switch {
+	case len(formats) == 0:
+		return DataFormatText
+
+	case len(formats) == 1:
+		return formats[0]
+
+	case i < len(formats):
+		return formats[i]
+
+	default:
+		return DataFormatText
+	}
+}
+
+// readDataFormats reads a list of format codes prefixed with its length.
+func readDataFormats(messageData *ReadBuffer) ([]DataFormat, error) {
+	count, err := messageData.ReadInt16()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count < 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	codes, err := messageData.ReadInt16Array(int(count))
+
+	if err != nil {
+		return nil, err
+	}
+
+	formats := make([]DataFormat, count)
+
+	for i, code := range codes {
+		formats[i] = DataFormat(code)
+	}
+
+	return formats, nil
+}
+
+// writeDataFormats writes a list of format codes prefixed with its length.
+func writeDataFormats(messageBuffer *WriteBuffer, formats []DataFormat) {
+	messageBuffer.WriteInt16(int16(len(formats)))
+
+	for _, format := range formats {
+		messageBuffer.WriteInt16(int16(format))
+	}
+}
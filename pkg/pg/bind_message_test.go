@@ -0,0 +1,59 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Bind message packet of the unnamed portal and statement with parameters (1452268, NULL) in text format;
+// the first result column is requested in text and the second one in binary format
+const GoldenBindMessagePacket = "\x42\x00\x00\x00\x21\x00\x00\x00\x01\x00\x00\x00\x02\x00\x00\x00" +
+	"\x07\x31\x34\x35\x32\x32\x36\x38\xff\xff\xff\xff\x00\x02\x00\x00" +
+	"\x00\x01"
+
+func TestParseBindMessage(t *testing.T) {
+	msg, err := ParseBindMessage(StandardFrame(GoldenBindMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", msg.Portal)
+	assert.Equal(t, "", msg.Statement)
+	assert.Equal(t, []DataFormat{DataFormatText}, msg.ParameterFormats)
+	assert.Equal(t, [][]byte{[]byte("1452268"), nil}, msg.Parameters)
+	assert.Equal(t, []DataFormat{DataFormatText, DataFormatBinary}, msg.ResultFormats)
+
+	// Test invalid type
+	_, err = ParseBindMessage(append(StandardFrame{'X'}, GoldenBindMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test truncated message
+	_, err = ParseBindMessage(StandardFrame(GoldenBindMessagePacket[:len(GoldenBindMessagePacket)-2]))
+	assert.Error(t, err)
+}
+
+func TestBindMessageFrame(t *testing.T) {
+	msg := &BindMessage{
+		ParameterFormats: []DataFormat{DataFormatText},
+		Parameters:       [][]byte{[]byte("1452268"), nil},
+		ResultFormats:    []DataFormat{DataFormatText, DataFormatBinary},
+	}
+
+	assert.Equal(t, []byte(GoldenBindMessagePacket), msg.Frame().Bytes())
+}
+
+func TestBindMessageFormats(t *testing.T) {
+	// No format codes — everything is text
+	msg := &BindMessage{}
+	assert.Equal(t, DataFormatText, msg.ParameterFormat(0))
+	assert.Equal(t, DataFormatText, msg.ResultFormat(3))
+
+	// Single format code applies to all values
+	msg = &BindMessage{ParameterFormats: []DataFormat{DataFormatBinary}, ResultFormats: []DataFormat{DataFormatBinary}}
+	assert.Equal(t, DataFormatBinary, msg.ParameterFormat(1))
+	assert.Equal(t, DataFormatBinary, msg.ResultFormat(5))
+
+	// Format code per value
+	msg = &BindMessage{ResultFormats: []DataFormat{DataFormatText, DataFormatBinary}}
+	assert.Equal(t, DataFormatText, msg.ResultFormat(0))
+	assert.Equal(t, DataFormatBinary, msg.ResultFormat(1))
+}
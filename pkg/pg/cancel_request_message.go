@@ -0,0 +1,54 @@
+package pg
+
+// CancelRequestMagic is magic protocol version that client is using to cancel a query in progress.
+const CancelRequestMagic = 80877102
+
+// CancelRequestMessage is sent by a frontend instead of StartupMessage over a new connection to cancel the query
+// running in another session. The session is identified by the secret-key data from its BackendKeyDataMessage.
+type CancelRequestMessage struct {
+	// The process ID of the target backend.
+	ProcessID int32
+
+	// The secret key for the target backend.
+	Key int32
+}
+
+// Compile time check to make sure that CancelRequestMessage implements the Message interface.
+var _ Message = &CancelRequestMessage{}
+
+// ParseCancelRequestMessage parses CancelRequestMessage from a network frame.
+func ParseCancelRequestMessage(frame Frame) (*CancelRequestMessage, error) {
+	messageData := ReadBuffer(frame.MessageBody())
+
+	// Assert the request code
+	code, err := messageData.ReadInt32()
+
+	if err != nil || code != CancelRequestMagic {
+		return nil, ErrMalformedMessage
+	}
+
+	processID, err := messageData.ReadInt32()
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := messageData.ReadInt32()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CancelRequestMessage{ProcessID: processID, Key: key}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CancelRequestMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteInt32(CancelRequestMagic)
+	messageBuffer.WriteInt32(m.ProcessID)
+	messageBuffer.WriteInt32(m.Key)
+
+	return NewStartupFrame(messageBuffer)
+}
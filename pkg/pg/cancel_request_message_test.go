@@ -0,0 +1,33 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Cancel request packet
+//   PID: 28822
+//   Key: -636998870
+const GoldenCancelRequestMessagePacket = "\x00\x00\x00\x10\x04\xd2\x16\x2e\x00\x00\x70\x96\xda\x08\x2b\x2a"
+
+func TestParseCancelRequestMessage(t *testing.T) {
+	{
+		msg, err := ParseCancelRequestMessage(StartupFrame(GoldenCancelRequestMessagePacket))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(28822), msg.ProcessID)
+		assert.Equal(t, int32(-636998870), msg.Key)
+	}
+
+	// Test invalid request code
+	{
+		_, err := ParseCancelRequestMessage(StartupFrame(GoldenSSLRequestMessagePacket))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestCancelRequestMessageFrame(t *testing.T) {
+	msg := &CancelRequestMessage{ProcessID: 28822, Key: -636998870}
+	assert.Equal(t, []byte(GoldenCancelRequestMessagePacket), msg.Frame().Bytes())
+}
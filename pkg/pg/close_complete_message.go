@@ -0,0 +1,30 @@
+package pg
+
+// CloseCompleteMessageType identifies CloseCompleteMessage message.
+const CloseCompleteMessageType = '3'
+
+// CloseCompleteMessage is sent by a backend when Close has been completed.
+type CloseCompleteMessage struct{}
+
+// Compile time check to make sure that CloseCompleteMessage implements the Message interface.
+var _ Message = &CloseCompleteMessage{}
+
+// ParseCloseCompleteMessage parses CloseCompleteMessage from a network frame.
+func ParseCloseCompleteMessage(frame Frame) (*CloseCompleteMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CloseCompleteMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &CloseCompleteMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CloseCompleteMessage) Frame() Frame {
+	return NewStandardFrame(CloseCompleteMessageType, nil)
+}
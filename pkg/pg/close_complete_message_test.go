@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden close complete message packet (the message has no body)
+const GoldenCloseCompleteMessagePacket = "\x33\x00\x00\x00\x04"
+
+func TestParseCloseCompleteMessage(t *testing.T) {
+	{
+		_, err := ParseCloseCompleteMessage(StandardFrame(GoldenCloseCompleteMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseCloseCompleteMessage(append(StandardFrame{'!'}, GoldenCloseCompleteMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseCloseCompleteMessage(NewStandardFrame(CloseCompleteMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestCloseCompleteMessageFrame(t *testing.T) {
+	msg := &CloseCompleteMessage{}
+	assert.Equal(t, []byte(GoldenCloseCompleteMessagePacket), msg.Frame().Bytes())
+}
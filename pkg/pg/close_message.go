@@ -0,0 +1,44 @@
+package pg
+
+// CloseMessageType identifies CloseMessage message.
+const CloseMessageType = 'C'
+
+// CloseMessage is sent by a frontend to close a prepared statement or a portal.
+type CloseMessage struct {
+	// Close a prepared statement or a portal.
+	ObjectType ObjectType
+
+	// The name of the prepared statement or portal to close (an empty string selects the unnamed one).
+	Name string
+}
+
+// Compile time check to make sure that CloseMessage implements the Message interface.
+var _ Message = &CloseMessage{}
+
+// ParseCloseMessage parses CloseMessage from a network frame.
+func ParseCloseMessage(frame Frame) (*CloseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CloseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	objectType, name, err := readObjectReference(&messageData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CloseMessage{ObjectType: objectType, Name: name}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CloseMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteByte(byte(m.ObjectType))
+	messageBuffer.WriteString(m.Name)
+
+	return NewStandardFrame(CloseMessageType, messageBuffer)
+}
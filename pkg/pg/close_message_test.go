@@ -0,0 +1,31 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Close message packet of the prepared statement "stmt1"
+const GoldenCloseMessagePacket = "\x43\x00\x00\x00\x0b\x53\x73\x74\x6d\x74\x31\x00"
+
+func TestParseCloseMessage(t *testing.T) {
+	msg, err := ParseCloseMessage(StandardFrame(GoldenCloseMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectTypeStatement, msg.ObjectType)
+	assert.Equal(t, "stmt1", msg.Name)
+
+	// Test invalid type
+	_, err = ParseCloseMessage(append(StandardFrame{'X'}, GoldenCloseMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test missing string terminator
+	_, err = ParseCloseMessage(NewStandardFrame(CloseMessageType, []byte("Sstmt1")))
+	assert.Error(t, err)
+}
+
+func TestCloseMessageFrame(t *testing.T) {
+	msg := &CloseMessage{ObjectType: ObjectTypeStatement, Name: "stmt1"}
+	assert.Equal(t, []byte(GoldenCloseMessagePacket), msg.Frame().Bytes())
+}
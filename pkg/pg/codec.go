@@ -0,0 +1,209 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// Codec decodes and encodes values of a PostgreSQL data type in text and binary formats.
+//
+// Decoded values have Go types that naturally represent the data type: int16/int32/int64 for integers,
+// float32/float64 for floats, Numeric, bool, string for text types, []byte for bytea, time.Time (or Infinity)
+// for dates and timestamps, UUID, json.RawMessage for json/jsonb and Array for arrays.
+//
+// Codecs never see NULLs: NULL is represented by a nil value on the wire and is handled by DecodeValue
+// and EncodeValue.
+type Codec interface {
+	// DecodeText decodes a value in text format.
+	DecodeText(src []byte) (interface{}, error)
+
+	// DecodeBinary decodes a value in binary format.
+	DecodeBinary(src []byte) (interface{}, error)
+
+	// EncodeText encodes a value into text format.
+	EncodeText(value interface{}) ([]byte, error)
+
+	// EncodeBinary encodes a value into binary format.
+	EncodeBinary(value interface{}) ([]byte, error)
+}
+
+var (
+	// ErrUnsupportedType is returned when there is no codec for a data type.
+	ErrUnsupportedType = errors.New("pg: unsupported data type")
+)
+
+// LookupCodec returns a codec for the given data type. The second returned value is false if the data type
+// is not supported.
+func LookupCodec(typ oid.Oid) (Codec, bool) {
+	switch typ {
+	case oid.T_int2:
+		return intCodec{size: 2}, true
+
+	case oid.T_int4:
+		return intCodec{size: 4}, true
+
+	case oid.T_int8:
+		return intCodec{size: 8}, true
+
+	case oid.T_float4:
+		return floatCodec{size: 4}, true
+
+	case oid.T_float8:
+		return floatCodec{size: 8}, true
+
+	case oid.T_numeric:
+		return numericCodec{}, true
+
+	case oid.T_bool:
+		return boolCodec{}, true
+
+	case oid.T_text, oid.T_varchar, oid.T_bpchar, oid.T_name, oid.T_unknown:
+		return textCodec{}, true
+
+	case oid.T_bytea:
+		return byteaCodec{}, true
+
+	case oid.T_date:
+		return dateCodec{}, true
+
+	case oid.T_timestamp:
+		return timestampCodec{withTimeZone: false}, true
+
+	case oid.T_timestamptz:
+		return timestampCodec{withTimeZone: true}, true
+
+	case oid.T_uuid:
+		return uuidCodec{}, true
+
+	case oid.T_json:
+		return jsonCodec{binary: false}, true
+
+	case oid.T_jsonb:
+		return jsonCodec{binary: true}, true
+	}
+
+	if elemType, ok := arrayElementType(typ); ok {
+		elem, _ := LookupCodec(elemType)
+		return arrayCodec{elemType: elemType, elem: elem}, true
+	}
+
+	return nil, false
+}
+
+// arrayElementType returns element type of a supported array type.
+func arrayElementType(typ oid.Oid) (oid.Oid, bool) {
+	switch typ {
+	case oid.T__int2:
+		return oid.T_int2, true
+	case oid.T__int4:
+		return oid.T_int4, true
+	case oid.T__int8:
+		return oid.T_int8, true
+	case oid.T__float4:
+		return oid.T_float4, true
+	case oid.T__float8:
+		return oid.T_float8, true
+	case oid.T__numeric:
+		return oid.T_numeric, true
+	case oid.T__bool:
+		return oid.T_bool, true
+	case oid.T__text:
+		return oid.T_text, true
+	case oid.T__varchar:
+		return oid.T_varchar, true
+	case oid.T__bpchar:
+		return oid.T_bpchar, true
+	case oid.T__name:
+		return oid.T_name, true
+	case oid.T__bytea:
+		return oid.T_bytea, true
+	case oid.T__date:
+		return oid.T_date, true
+	case oid.T__timestamp:
+		return oid.T_timestamp, true
+	case oid.T__timestamptz:
+		return oid.T_timestamptz, true
+	case oid.T__uuid:
+		return oid.T_uuid, true
+	case oid.T__json:
+		return oid.T_json, true
+	case oid.T__jsonb:
+		return oid.T_jsonb, true
+	default:
+		return 0, false
+	}
+}
+
+// DecodeValue decodes a value of the given data type in the given format. NULL (nil) is decoded into nil.
+func DecodeValue(typ oid.Oid, format DataFormat, src []byte) (interface{}, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	codec, ok := LookupCodec(typ)
+
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	switch format {
+	case DataFormatText:
+		return codec.DecodeText(src)
+
+	case DataFormatBinary:
+		return codec.DecodeBinary(src)
+
+	default:
+		return nil, fmt.Errorf("pg: unknown data format %d", format)
+	}
+}
+
+// EncodeValue encodes a value of the given data type into the given format. nil is encoded into NULL (nil).
+func EncodeValue(typ oid.Oid, format DataFormat, value interface{}) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	codec, ok := LookupCodec(typ)
+
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	switch format {
+	case DataFormatText:
+		return codec.EncodeText(value)
+
+	case DataFormatBinary:
+		return codec.EncodeBinary(value)
+
+	default:
+		return nil, fmt.Errorf("pg: unknown data format %d", format)
+	}
+}
+
+// Transcode converts a value of the given data type from one format to another (e.g. binary to text).
+func Transcode(typ oid.Oid, from, to DataFormat, src []byte) ([]byte, error) {
+	if src == nil || from == to {
+		return src, nil
+	}
+
+	value, err := DecodeValue(typ, from, src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeValue(typ, to, value)
+}
+
+// errInvalidValue returns an error for a value that cannot be decoded or encoded as the given type.
+func errInvalidValue(typeName string, value interface{}) error {
+	if src, ok := value.([]byte); ok {
+		return fmt.Errorf("pg: invalid %s value %q", typeName, src)
+	}
+
+	return fmt.Errorf("pg: cannot encode %T as %s", value, typeName)
+}
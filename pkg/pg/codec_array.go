@@ -0,0 +1,580 @@
+package pg
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq/oid"
+)
+
+// Array represents a (possibly multidimensional) array value. Elements are stored in row-major order;
+// nil elements are NULLs.
+type Array struct {
+	// Array dimensions; empty for an empty array
+	Dimensions []ArrayDimension
+
+	// Array elements decoded by the element type codec
+	Elements []interface{}
+}
+
+// ArrayDimension describes a single dimension of an array.
+type ArrayDimension struct {
+	// Number of elements in the dimension
+	Length int32
+
+	// Index of the first element (1 by default)
+	LowerBound int32
+}
+
+// arrayCodec encodes arrays of the supported element types (Array).
+type arrayCodec struct {
+	// Element data type
+	elemType oid.Oid
+
+	// Element codec
+	elem Codec
+}
+
+// DecodeText decodes a value in text format, e.g. {{1,2},{3,NULL}} or [0:1]={"a b",c}.
+func (c arrayCodec) DecodeText(src []byte) (interface{}, error) {
+	p := &arrayParser{src: src}
+
+	arr, err := p.parse()
+
+	if err != nil {
+		return nil, fmt.Errorf("pg: invalid array value %q: %v", src, err)
+	}
+
+	for i, elem := range arr.Elements {
+		if elem == nil {
+			continue
+		}
+
+		arr.Elements[i], err = c.elem.DecodeText(elem.([]byte))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return arr, nil
+}
+
+// DecodeBinary decodes a value in binary format:
+//
+//	int32 ndim, int32 hasnull, int32 elemtype, {int32 length, int32 lbound}[ndim], {int32 size, bytes}[...]
+func (c arrayCodec) DecodeBinary(src []byte) (interface{}, error) {
+	buf := ReadBuffer(src)
+
+	header, err := buf.ReadInt32Array(3)
+
+	if err != nil || header[0] < 0 {
+		return nil, errInvalidValue("array", src)
+	}
+
+	arr := &Array{}
+	count := 1
+
+	if header[0] > 0 {
+		arr.Dimensions = make([]ArrayDimension, header[0])
+	}
+
+	for i := range arr.Dimensions {
+		dim, err := buf.ReadInt32Array(2)
+
+		if err != nil || dim[0] < 0 {
+			return nil, errInvalidValue("array", src)
+		}
+
+		arr.Dimensions[i] = ArrayDimension{Length: dim[0], LowerBound: dim[1]}
+		count *= int(dim[0])
+	}
+
+	if len(arr.Dimensions) == 0 {
+		count = 0
+	}
+
+	arr.Elements = make([]interface{}, count)
+
+	for i := range arr.Elements {
+		size, err := buf.ReadInt32()
+
+		if err != nil {
+			return nil, errInvalidValue("array", src)
+		}
+
+		// -1 represents NULL
+		if size == -1 {
+			continue
+		}
+
+		elem, err := buf.ReadBytes(int(size))
+
+		if err != nil {
+			return nil, errInvalidValue("array", src)
+		}
+
+		arr.Elements[i], err = c.elem.DecodeBinary(elem)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if buf.Len() != 0 {
+		return nil, errInvalidValue("array", src)
+	}
+
+	return arr, nil
+}
+
+// EncodeText encodes a value into text format.
+func (c arrayCodec) EncodeText(value interface{}) ([]byte, error) {
+	arr, err := c.array(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(arr.Elements) == 0 {
+		return []byte("{}"), nil
+	}
+
+	var buf bytes.Buffer
+
+	// Non-default lower bounds are written as a dimensions decoration: [0:1][1:3]=
+	if hasCustomLowerBounds(arr.Dimensions) {
+		for _, dim := range arr.Dimensions {
+			fmt.Fprintf(&buf, "[%d:%d]", dim.LowerBound, dim.LowerBound+dim.Length-1)
+		}
+
+		buf.WriteByte('=')
+	}
+
+	elements := arr.Elements
+
+	var encode func(dim int) error
+
+	encode = func(dim int) error {
+		buf.WriteByte('{')
+
+		for i := 0; i < int(arr.Dimensions[dim].Length); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if dim < len(arr.Dimensions)-1 {
+				err := encode(dim + 1)
+
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			elem := elements[0]
+			elements = elements[1:]
+
+			if elem == nil {
+				buf.WriteString("NULL")
+				continue
+			}
+
+			text, err := c.elem.EncodeText(elem)
+
+			if err != nil {
+				return err
+			}
+
+			writeArrayElement(&buf, text)
+		}
+
+		buf.WriteByte('}')
+
+		return nil
+	}
+
+	err = encode(0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (c arrayCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	arr, err := c.array(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dims := arr.Dimensions
+
+	if len(arr.Elements) == 0 {
+		dims = nil
+	}
+
+	hasNull := int32(0)
+
+	for _, elem := range arr.Elements {
+		if elem == nil {
+			hasNull = 1
+			break
+		}
+	}
+
+	var buf WriteBuffer
+
+	buf.WriteInt32(int32(len(dims)))
+	buf.WriteInt32(hasNull)
+	buf.WriteInt32(int32(c.elemType))
+
+	for _, dim := range dims {
+		buf.WriteInt32(dim.Length)
+		buf.WriteInt32(dim.LowerBound)
+	}
+
+	for _, elem := range arr.Elements {
+		if elem == nil {
+			// -1 represents NULL
+			buf.WriteInt32(-1)
+			continue
+		}
+
+		data, err := c.elem.EncodeBinary(elem)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteInt32(int32(len(data)))
+		buf.WriteBytes(data)
+	}
+
+	return buf, nil
+}
+
+// array converts the value to Array and checks that dimensions match the number of elements.
+func (c arrayCodec) array(value interface{}) (*Array, error) {
+	var arr *Array
+
+	switch v := value.(type) {
+	case *Array:
+		arr = v
+	case Array:
+		arr = &v
+	default:
+		return nil, errInvalidValue("array", value)
+	}
+
+	count := 0
+
+	if len(arr.Dimensions) > 0 {
+		count = 1
+
+		for _, dim := range arr.Dimensions {
+			count *= int(dim.Length)
+		}
+	}
+
+	if count != len(arr.Elements) {
+		return nil, fmt.Errorf("pg: array has %d elements, but its dimensions require %d", len(arr.Elements), count)
+	}
+
+	return arr, nil
+}
+
+// hasCustomLowerBounds returns true if any of the dimensions doesn't start with 1.
+func hasCustomLowerBounds(dims []ArrayDimension) bool {
+	for _, dim := range dims {
+		if dim.LowerBound != 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeArrayElement writes an array element in text format quoting it if necessary.
+func writeArrayElement(buf *bytes.Buffer, text []byte) {
+	needsQuotes := len(text) == 0 || strings.EqualFold(string(text), "NULL") ||
+		bytes.ContainsAny(text, "{},\"\\ \t\n\r\v\f")
+
+	if !needsQuotes {
+		buf.Write(text)
+		return
+	}
+
+	buf.WriteByte('"')
+
+	for _, c := range text {
+		if c == '"' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+
+		buf.WriteByte(c)
+	}
+
+	buf.WriteByte('"')
+}
+
+// arrayParser parses arrays in text format. Elements are returned as raw bytes (nil for NULL).
+type arrayParser struct {
+	src []byte
+	pos int
+
+	// Lengths of the dimensions seen so far
+	lengths []int32
+
+	// Depth of the elements (all elements must be at the same depth)
+	elemDepth int
+}
+
+// parse parses the whole array.
+func (p *arrayParser) parse() (*Array, error) {
+	p.elemDepth = -1
+
+	lowerBounds, err := p.parseDecoration()
+
+	if err != nil {
+		return nil, err
+	}
+
+	arr := &Array{}
+
+	err = p.parseLevel(0, arr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+
+	if p.pos != len(p.src) {
+		return nil, fmt.Errorf("unexpected %q after the array", p.src[p.pos:])
+	}
+
+	// Empty array
+	if len(arr.Elements) == 0 {
+		return &Array{Elements: []interface{}{}}, nil
+	}
+
+	if lowerBounds != nil && len(lowerBounds) != len(p.lengths) {
+		return nil, fmt.Errorf("dimensions decoration doesn't match the array")
+	}
+
+	for i, length := range p.lengths {
+		dim := ArrayDimension{Length: length, LowerBound: 1}
+
+		if lowerBounds != nil {
+			dim.LowerBound = lowerBounds[i]
+		}
+
+		arr.Dimensions = append(arr.Dimensions, dim)
+	}
+
+	return arr, nil
+}
+
+// parseDecoration parses optional dimensions decoration (e.g. [0:2]=) and returns lower bounds.
+func (p *arrayParser) parseDecoration() ([]int32, error) {
+	p.skipSpaces()
+
+	if p.pos >= len(p.src) || p.src[p.pos] != '[' {
+		return nil, nil
+	}
+
+	end := bytes.IndexByte(p.src[p.pos:], '=')
+
+	if end < 0 {
+		return nil, fmt.Errorf("invalid dimensions decoration")
+	}
+
+	decoration := string(p.src[p.pos : p.pos+end])
+	p.pos += end + 1
+
+	var lowerBounds []int32
+
+	for _, dim := range strings.SplitAfter(decoration, "]") {
+		dim = strings.TrimSpace(dim)
+
+		if dim == "" {
+			continue
+		}
+
+		bounds := strings.Split(strings.Trim(dim, "[]"), ":")
+
+		if len(bounds) != 2 || !strings.HasPrefix(dim, "[") || !strings.HasSuffix(dim, "]") {
+			return nil, fmt.Errorf("invalid dimensions decoration")
+		}
+
+		lower, err := strconv.ParseInt(bounds[0], 10, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid dimensions decoration")
+		}
+
+		lowerBounds = append(lowerBounds, int32(lower))
+	}
+
+	return lowerBounds, nil
+}
+
+// parseLevel parses a single level of the array starting with '{'.
+func (p *arrayParser) parseLevel(depth int, arr *Array) error {
+	p.skipSpaces()
+
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return fmt.Errorf("expected '{'")
+	}
+
+	p.pos++
+	p.skipSpaces()
+
+	// First sub-array at this depth; its length is not known yet
+	if depth == len(p.lengths) {
+		p.lengths = append(p.lengths, -1)
+	}
+
+	// Empty array is allowed only at the top level
+	if p.pos < len(p.src) && p.src[p.pos] == '}' && depth == 0 {
+		p.pos++
+		return nil
+	}
+
+	count := int32(0)
+
+	for {
+		p.skipSpaces()
+
+		if p.pos >= len(p.src) {
+			return fmt.Errorf("unexpected end of the array")
+		}
+
+		if p.src[p.pos] == '{' {
+			err := p.parseLevel(depth+1, arr)
+
+			if err != nil {
+				return err
+			}
+		} else {
+			if p.elemDepth != -1 && p.elemDepth != depth {
+				return fmt.Errorf("multidimensional arrays must have sub-arrays with matching dimensions")
+			}
+
+			p.elemDepth = depth
+
+			elem, err := p.parseElement()
+
+			if err != nil {
+				return err
+			}
+
+			// NB: keep NULL as untyped nil
+			if elem == nil {
+				arr.Elements = append(arr.Elements, nil)
+			} else {
+				arr.Elements = append(arr.Elements, elem)
+			}
+		}
+
+		count++
+
+		p.skipSpaces()
+
+		if p.pos >= len(p.src) {
+			return fmt.Errorf("unexpected end of the array")
+		}
+
+		if p.src[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+
+		if p.src[p.pos] == '}' {
+			p.pos++
+			break
+		}
+
+		return fmt.Errorf("unexpected %q", p.src[p.pos])
+	}
+
+	// Check that sub-arrays have matching dimensions
+	if p.lengths[depth] == -1 {
+		p.lengths[depth] = count
+	} else if p.lengths[depth] != count {
+		return fmt.Errorf("multidimensional arrays must have sub-arrays with matching dimensions")
+	}
+
+	return nil
+}
+
+// parseElement parses a quoted or unquoted element.
+func (p *arrayParser) parseElement() ([]byte, error) {
+	quoted := p.src[p.pos] == '"'
+
+	if quoted {
+		p.pos++
+	}
+
+	elem := []byte{}
+	escaped := false
+
+	// Escaped characters are never trimmed
+	keep := 0
+
+	for ; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+
+		if c == '\\' {
+			p.pos++
+
+			if p.pos >= len(p.src) {
+				break
+			}
+
+			elem = append(elem, p.src[p.pos])
+			escaped = true
+			keep = len(elem)
+
+			continue
+		}
+
+		if quoted && c == '"' {
+			p.pos++
+			return elem, nil
+		}
+
+		if !quoted && (c == ',' || c == '}') {
+			// Trailing spaces of unquoted elements are ignored
+			elem = append(elem[:keep], bytes.TrimRight(elem[keep:], " \t\n\r\v\f")...)
+
+			if !escaped && strings.EqualFold(string(elem), "NULL") {
+				return nil, nil
+			}
+
+			return elem, nil
+		}
+
+		if !quoted && (c == '{' || c == '"') {
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+
+		elem = append(elem, c)
+	}
+
+	return nil, fmt.Errorf("unexpected end of the array")
+}
+
+// skipSpaces advances the parser over whitespace.
+func (p *arrayParser) skipSpaces() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r\v\f", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
@@ -0,0 +1,95 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrayCodec(t *testing.T) {
+	testCodec(t, []codecTestCase{
+		{
+			oid.T__int4,
+			"{1,NULL,3}",
+			[]byte{
+				0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 23, // ndim, hasnull, elemtype
+				0, 0, 0, 3, 0, 0, 0, 1, // length, lbound
+				0, 0, 0, 4, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 4, 0, 0, 0, 3,
+			},
+			&Array{
+				Dimensions: []ArrayDimension{{Length: 3, LowerBound: 1}},
+				Elements:   []interface{}{int32(1), nil, int32(3)},
+			},
+		},
+		{
+			oid.T__text,
+			`{{a,"b c"},{"",NULL}}`,
+			[]byte{
+				0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 25,
+				0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 1,
+				0, 0, 0, 1, 'a', 0, 0, 0, 3, 'b', ' ', 'c', 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff,
+			},
+			&Array{
+				Dimensions: []ArrayDimension{{Length: 2, LowerBound: 1}, {Length: 2, LowerBound: 1}},
+				Elements:   []interface{}{"a", "b c", "", nil},
+			},
+		},
+		{
+			oid.T__varchar,
+			`[0:1]={"\"quoted\"","NULL"}`,
+			[]byte{
+				0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0x04, 0x13,
+				0, 0, 0, 2, 0, 0, 0, 0,
+				0, 0, 0, 8, '"', 'q', 'u', 'o', 't', 'e', 'd', '"', 0, 0, 0, 4, 'N', 'U', 'L', 'L',
+			},
+			&Array{
+				Dimensions: []ArrayDimension{{Length: 2, LowerBound: 0}},
+				Elements:   []interface{}{`"quoted"`, "NULL"},
+			},
+		},
+		{
+			oid.T__int8,
+			"{}",
+			[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 20},
+			&Array{Elements: []interface{}{}},
+		},
+	})
+}
+
+func TestArrayCodecTextVariants(t *testing.T) {
+	value, err := DecodeValue(oid.T__text, DataFormatText, []byte(` { a b , c\,d ,"e" } `))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a b", "c,d", "e"}, value.(*Array).Elements)
+
+	value, err = DecodeValue(oid.T__uuid, DataFormatText, []byte(`{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11}`))
+	require.NoError(t, err)
+	assert.IsType(t, UUID{}, value.(*Array).Elements[0])
+}
+
+func TestArrayCodecInvalidValues(t *testing.T) {
+	for _, src := range []string{
+		"{1,2",
+		"{{1,2},{3}}",
+		"{{1},2}",
+		"{1,2}x",
+		"[1:2={1,2}",
+		"[1:3][1:2]={1,2}",
+		"{1,abc}",
+	} {
+		_, err := DecodeValue(oid.T__int4, DataFormatText, []byte(src))
+		assert.Error(t, err, src)
+	}
+
+	// Dimensions don't match elements
+	_, err := EncodeValue(oid.T__int4, DataFormatText, &Array{
+		Dimensions: []ArrayDimension{{Length: 3, LowerBound: 1}},
+		Elements:   []interface{}{int32(1)},
+	})
+	assert.Error(t, err)
+
+	// Truncated binary value
+	_, err = DecodeValue(oid.T__int4, DataFormatBinary, []byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 23, 0, 0, 0, 1, 0, 0, 0, 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package pg
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"time"
+)
+
+// Infinity represents infinite dates and timestamps ('infinity' and '-infinity').
+type Infinity int8
+
+const (
+	NegativeInfinity Infinity = -1 // -infinity
+	PositiveInfinity Infinity = 1  // infinity
+)
+
+// String returns PostgreSQL representation of the infinity.
+func (i Infinity) String() string {
+	if i < 0 {
+		return "-infinity"
+	}
+
+	return "infinity"
+}
+
+// Date/time layouts used by PostgreSQL in the ISO output style.
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// Time zone offset layouts used in timestamptz values, e.g. +03, +05:30 or -09:30:15.
+func timestamptzLayouts() []string {
+	return []string{timestampLayout + "-07", timestampLayout + "-07:00", timestampLayout + "-07:00:00"}
+}
+
+// Binary format of dates and timestamps counts from 2000-01-01.
+const (
+	postgresEpochUnix = 946684800 // 2000-01-01 00:00:00 UTC
+	secondsPerDay     = 24 * 60 * 60
+	microsPerSecond   = 1000000
+)
+
+// dateCodec encodes date values (time.Time or Infinity).
+type dateCodec struct{}
+
+// DecodeText decodes a value in text format.
+func (dateCodec) DecodeText(src []byte) (interface{}, error) {
+	if inf, ok := parseInfinity(src); ok {
+		return inf, nil
+	}
+
+	t, err := time.Parse(dateLayout, string(src))
+
+	if err != nil {
+		return nil, errInvalidValue("date", src)
+	}
+
+	return t, nil
+}
+
+// DecodeBinary decodes a value in binary format (int32 days since 2000-01-01).
+func (dateCodec) DecodeBinary(src []byte) (interface{}, error) {
+	if len(src) != 4 {
+		return nil, errInvalidValue("date", src)
+	}
+
+	days := int32(binary.BigEndian.Uint32(src))
+
+	switch days {
+	case math.MaxInt32:
+		return PositiveInfinity, nil
+
+	case math.MinInt32:
+		return NegativeInfinity, nil
+	}
+
+	return time.Unix(postgresEpochUnix+int64(days)*secondsPerDay, 0).UTC(), nil
+}
+
+// EncodeText encodes a value into text format.
+func (dateCodec) EncodeText(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case Infinity:
+		return []byte(v.String()), nil
+
+	case time.Time:
+		return []byte(v.Format(dateLayout)), nil
+
+	default:
+		return nil, errInvalidValue("date", value)
+	}
+}
+
+// EncodeBinary encodes a value into binary format.
+func (dateCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	var days int32
+
+	switch v := value.(type) {
+	case Infinity:
+		days = math.MaxInt32
+
+		if v < 0 {
+			days = math.MinInt32
+		}
+
+	case time.Time:
+		// Date in the value's own time zone
+		date := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)
+		days = int32((date.Unix() - postgresEpochUnix) / secondsPerDay)
+
+	default:
+		return nil, errInvalidValue("date", value)
+	}
+
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(days))
+
+	return buf, nil
+}
+
+// timestampCodec encodes timestamp and timestamptz values (time.Time or Infinity).
+// timestamptz values are always encoded in UTC.
+type timestampCodec struct {
+	withTimeZone bool
+}
+
+// DecodeText decodes a value in text format.
+func (c timestampCodec) DecodeText(src []byte) (interface{}, error) {
+	if inf, ok := parseInfinity(src); ok {
+		return inf, nil
+	}
+
+	if !c.withTimeZone {
+		t, err := time.Parse(timestampLayout, string(src))
+
+		if err != nil {
+			return nil, errInvalidValue(c.name(), src)
+		}
+
+		return t, nil
+	}
+
+	for _, layout := range timestamptzLayouts() {
+		t, err := time.Parse(layout, string(src))
+
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return nil, errInvalidValue(c.name(), src)
+}
+
+// DecodeBinary decodes a value in binary format (int64 microseconds since 2000-01-01 00:00:00 UTC).
+func (c timestampCodec) DecodeBinary(src []byte) (interface{}, error) {
+	if len(src) != 8 {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	micros := int64(binary.BigEndian.Uint64(src))
+
+	switch micros {
+	case math.MaxInt64:
+		return PositiveInfinity, nil
+
+	case math.MinInt64:
+		return NegativeInfinity, nil
+	}
+
+	seconds, remainder := micros/microsPerSecond, micros%microsPerSecond
+
+	// Round towards negative infinity
+	if remainder < 0 {
+		seconds--
+		remainder += microsPerSecond
+	}
+
+	return time.Unix(postgresEpochUnix+seconds, remainder*1000).UTC(), nil
+}
+
+// EncodeText encodes a value into text format.
+func (c timestampCodec) EncodeText(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case Infinity:
+		return []byte(v.String()), nil
+
+	case time.Time:
+		if c.withTimeZone {
+			return []byte(v.UTC().Format(timestampLayout + "-07")), nil
+		}
+
+		return []byte(v.Format(timestampLayout)), nil
+
+	default:
+		return nil, errInvalidValue(c.name(), value)
+	}
+}
+
+// EncodeBinary encodes a value into binary format.
+func (c timestampCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	var micros int64
+
+	switch v := value.(type) {
+	case Infinity:
+		micros = math.MaxInt64
+
+		if v < 0 {
+			micros = math.MinInt64
+		}
+
+	case time.Time:
+		// Timestamp without time zone keeps the wall clock
+		if !c.withTimeZone {
+			v = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC)
+		}
+
+		micros = (v.Unix()-postgresEpochUnix)*microsPerSecond + int64(v.Nanosecond()/1000)
+
+	default:
+		return nil, errInvalidValue(c.name(), value)
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(micros))
+
+	return buf, nil
+}
+
+// name returns the PostgreSQL type name.
+func (c timestampCodec) name() string {
+	if c.withTimeZone {
+		return "timestamptz"
+	}
+
+	return "timestamp"
+}
+
+// parseInfinity parses 'infinity' and '-infinity' values.
+func parseInfinity(src []byte) (Infinity, bool) {
+	switch strings.ToLower(string(src)) {
+	case "infinity", "+infinity":
+		return PositiveInfinity, true
+
+	case "-infinity":
+		return NegativeInfinity, true
+
+	default:
+		return 0, false
+	}
+}
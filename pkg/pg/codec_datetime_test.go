@@ -0,0 +1,67 @@
+package pg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateTimeCodecs(t *testing.T) {
+	testCodec(t, []codecTestCase{
+		{oid.T_date, "2000-01-02", []byte{0, 0, 0, 1}, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{oid.T_date, "1999-12-31", []byte{0xff, 0xff, 0xff, 0xff}, time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{oid.T_date, "infinity", []byte{0x7f, 0xff, 0xff, 0xff}, PositiveInfinity},
+		{
+			oid.T_timestamp,
+			"2000-01-01 00:00:01.5",
+			[]byte{0, 0, 0, 0, 0, 0x16, 0xe3, 0x60},
+			time.Date(2000, 1, 1, 0, 0, 1, 500000000, time.UTC),
+		},
+		{
+			oid.T_timestamp,
+			"1999-12-31 23:59:59.999999",
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			time.Date(1999, 12, 31, 23, 59, 59, 999999000, time.UTC),
+		},
+		{oid.T_timestamp, "-infinity", []byte{0x80, 0, 0, 0, 0, 0, 0, 0}, NegativeInfinity},
+	})
+}
+
+func TestTimestamptzCodec(t *testing.T) {
+	expected := time.Date(2019, 10, 1, 9, 30, 0, 0, time.UTC)
+
+	for _, text := range []string{"2019-10-01 12:30:00+03", "2019-10-01 15:00:00+05:30", "2019-10-01 09:30:00+00"} {
+		value, err := DecodeValue(oid.T_timestamptz, DataFormatText, []byte(text))
+		assert.NoError(t, err, text)
+		assert.True(t, expected.Equal(value.(time.Time)), text)
+
+		// Always encoded in UTC
+		encoded, err := EncodeValue(oid.T_timestamptz, DataFormatText, value)
+		assert.NoError(t, err)
+		assert.Equal(t, "2019-10-01 09:30:00+00", string(encoded))
+
+		// Binary round trip
+		binary, err := Transcode(oid.T_timestamptz, DataFormatText, DataFormatBinary, []byte(text))
+		assert.NoError(t, err)
+
+		encoded, err = Transcode(oid.T_timestamptz, DataFormatBinary, DataFormatText, binary)
+		assert.NoError(t, err)
+		assert.Equal(t, "2019-10-01 09:30:00+00", string(encoded))
+	}
+}
+
+func TestDateTimeCodecsInvalidValues(t *testing.T) {
+	_, err := DecodeValue(oid.T_date, DataFormatText, []byte("2019-13-01"))
+	assert.Error(t, err)
+
+	_, err = DecodeValue(oid.T_timestamp, DataFormatBinary, []byte{0, 0, 0, 0})
+	assert.Error(t, err)
+
+	_, err = DecodeValue(oid.T_timestamptz, DataFormatText, []byte("2019-10-01 09:30:00 MSK"))
+	assert.Error(t, err)
+
+	_, err = EncodeValue(oid.T_date, DataFormatBinary, "2019-10-01")
+	assert.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package pg
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// Numeric represents a numeric value as a decimal string (e.g. "-12.50", "NaN" or "Infinity").
+// Decimal strings keep the exact value and the scale.
+type Numeric string
+
+// Sign field values of the numeric binary format.
+const (
+	numericPositive    uint16 = 0x0000
+	numericNegative    uint16 = 0x4000
+	numericNaN         uint16 = 0xC000
+	numericPositiveInf uint16 = 0xD000
+	numericNegativeInf uint16 = 0xF000
+)
+
+// Numeric binary format stores digits in base 10000.
+const numericDigitsPerWord = 4
+
+// numericCodec encodes numeric values (Numeric).
+type numericCodec struct{}
+
+// DecodeText decodes a value in text format.
+func (numericCodec) DecodeText(src []byte) (interface{}, error) {
+	str := string(src)
+
+	if _, _, _, ok := parseDecimal(str); !ok && !isNumericSpecial(str) {
+		return nil, errInvalidValue("numeric", src)
+	}
+
+	return Numeric(str), nil
+}
+
+// DecodeBinary decodes a value in binary format:
+//
+//	int16 ndigits, int16 weight, uint16 sign, int16 dscale, int16 digits[ndigits]
+//
+// The value is sum(digits[i] * 10000^(weight-i)); dscale is the number of decimal digits after the point.
+func (numericCodec) DecodeBinary(src []byte) (interface{}, error) {
+	buf := ReadBuffer(src)
+
+	header, err := buf.ReadInt16Array(4)
+
+	if err != nil {
+		return nil, errInvalidValue("numeric", src)
+	}
+
+	ndigits, weight, sign, dscale := int(header[0]), int(header[1]), uint16(header[2]), int(header[3])
+
+	switch sign {
+	case numericNaN:
+		return Numeric("NaN"), nil
+
+	case numericPositiveInf:
+		return Numeric("Infinity"), nil
+
+	case numericNegativeInf:
+		return Numeric("-Infinity"), nil
+
+	case numericPositive, numericNegative:
+
+	default:
+		return nil, errInvalidValue("numeric", src)
+	}
+
+	if ndigits < 0 || dscale < 0 {
+		return nil, errInvalidValue("numeric", src)
+	}
+
+	digits, err := buf.ReadInt16Array(ndigits)
+
+	if err != nil || buf.Len() != 0 {
+		return nil, errInvalidValue("numeric", src)
+	}
+
+	// digit returns the base 10000 digit multiplied by 10000^exp
+	digit := func(exp int) int {
+		i := weight - exp
+
+		if i < 0 || i >= ndigits {
+			return 0
+		}
+
+		return int(digits[i])
+	}
+
+	var sb strings.Builder
+
+	if sign == numericNegative {
+		sb.WriteByte('-')
+	}
+
+	// Integer part
+	if weight < 0 {
+		sb.WriteByte('0')
+	} else {
+		sb.WriteString(strconv.Itoa(digit(weight)))
+
+		for exp := weight - 1; exp >= 0; exp-- {
+			sb.WriteString(padDigits(digit(exp)))
+		}
+	}
+
+	// Fractional part
+	if dscale > 0 {
+		var fraction strings.Builder
+
+		for exp := -1; fraction.Len() < dscale; exp-- {
+			fraction.WriteString(padDigits(digit(exp)))
+		}
+
+		sb.WriteByte('.')
+		sb.WriteString(fraction.String()[:dscale])
+	}
+
+	return Numeric(sb.String()), nil
+}
+
+// EncodeText encodes a value into text format.
+func (c numericCodec) EncodeText(value interface{}) ([]byte, error) {
+	v, ok := value.(Numeric)
+
+	if !ok {
+		return nil, errInvalidValue("numeric", value)
+	}
+
+	if _, _, _, ok := parseDecimal(string(v)); !ok && !isNumericSpecial(string(v)) {
+		return nil, errInvalidValue("numeric", value)
+	}
+
+	return []byte(v), nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (numericCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	v, ok := value.(Numeric)
+
+	if !ok {
+		return nil, errInvalidValue("numeric", value)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(v))) {
+	case "nan":
+		return numericHeader(0, 0, numericNaN, 0), nil
+
+	case "infinity", "+infinity":
+		return numericHeader(0, 0, numericPositiveInf, 0), nil
+
+	case "-infinity":
+		return numericHeader(0, 0, numericNegativeInf, 0), nil
+	}
+
+	negative, intPart, fracPart, ok := parseDecimal(string(v))
+
+	if !ok {
+		return nil, errInvalidValue("numeric", value)
+	}
+
+	dscale := len(fracPart)
+
+	// Align both parts to base 10000 digits: "12.5" → "0012" + "5000"
+	intPart = strings.TrimLeft(intPart, "0")
+
+	if pad := len(intPart) % numericDigitsPerWord; pad != 0 {
+		intPart = strings.Repeat("0", numericDigitsPerWord-pad) + intPart
+	}
+
+	if pad := len(fracPart) % numericDigitsPerWord; pad != 0 {
+		fracPart += strings.Repeat("0", numericDigitsPerWord-pad)
+	}
+
+	all := intPart + fracPart
+	digits := make([]int16, 0, len(all)/numericDigitsPerWord)
+
+	for i := 0; i < len(all); i += numericDigitsPerWord {
+		d, _ := strconv.Atoi(all[i : i+numericDigitsPerWord])
+		digits = append(digits, int16(d))
+	}
+
+	weight := len(intPart)/numericDigitsPerWord - 1
+
+	// Strip leading and trailing zero digits
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+		weight--
+	}
+
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+
+	sign := numericPositive
+
+	if len(digits) == 0 {
+		weight = 0
+	} else if negative {
+		sign = numericNegative
+	}
+
+	buf := WriteBuffer(numericHeader(len(digits), weight, sign, dscale))
+	buf.WriteInt16Array(digits)
+
+	return buf, nil
+}
+
+// numericHeader encodes the header of the numeric binary format.
+func numericHeader(ndigits, weight int, sign uint16, dscale int) []byte {
+	buf := make([]byte, 8)
+
+	binary.BigEndian.PutUint16(buf[0:], uint16(ndigits))
+	binary.BigEndian.PutUint16(buf[2:], uint16(weight))
+	binary.BigEndian.PutUint16(buf[4:], sign)
+	binary.BigEndian.PutUint16(buf[6:], uint16(dscale))
+
+	return buf
+}
+
+// parseDecimal splits a decimal number (e.g. "-12.50" or "1.5e3") into the sign, integer and fractional
+// digits, applying the exponent. The last returned value is false if the string is not a decimal number.
+func parseDecimal(str string) (negative bool, intPart, fracPart string, ok bool) {
+	str = strings.TrimSpace(str)
+
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
+
+	exp := 0
+
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		e, err := strconv.Atoi(str[i+1:])
+
+		if err != nil {
+			return false, "", "", false
+		}
+
+		exp = e
+		str = str[:i]
+	}
+
+	intPart, fracPart = str, ""
+
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+
+	if intPart+fracPart == "" || strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return false, "", "", false
+	}
+
+	// Move the decimal point
+	switch {
+	case exp > 0:
+		if exp > len(fracPart) {
+			fracPart += strings.Repeat("0", exp-len(fracPart))
+		}
+
+		intPart, fracPart = intPart+fracPart[:exp], fracPart[exp:]
+
+	case exp < 0:
+		if -exp > len(intPart) {
+			intPart = strings.Repeat("0", -exp-len(intPart)) + intPart
+		}
+
+		split := len(intPart) + exp
+		intPart, fracPart = intPart[:split], intPart[split:]+fracPart
+	}
+
+	return negative, intPart, fracPart, true
+}
+
+// isNumericSpecial returns true for special numeric values: NaN and infinities.
+func isNumericSpecial(str string) bool {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "nan", "infinity", "+infinity", "-infinity":
+		return true
+
+	default:
+		return false
+	}
+}
+
+// padDigits formats a base 10000 digit as four decimal digits.
+func padDigits(d int) string {
+	s := strconv.Itoa(d)
+	return strings.Repeat("0", numericDigitsPerWord-len(s)) + s
+}
@@ -0,0 +1,53 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumericCodec(t *testing.T) {
+	testCodec(t, []codecTestCase{
+		// ndigits, weight, sign, dscale, digits...
+		{oid.T_numeric, "0", []byte{0, 0, 0, 0, 0, 0, 0, 0}, Numeric("0")},
+		{oid.T_numeric, "12.5", []byte{0, 2, 0, 0, 0, 0, 0, 1, 0, 12, 0x13, 0x88}, Numeric("12.5")},
+		{oid.T_numeric, "-12.50", []byte{0, 2, 0, 0, 0x40, 0, 0, 2, 0, 12, 0x13, 0x88}, Numeric("-12.50")},
+		{oid.T_numeric, "10000", []byte{0, 1, 0, 1, 0, 0, 0, 0, 0, 1}, Numeric("10000")},
+		{oid.T_numeric, "0.0001", []byte{0, 1, 0xff, 0xff, 0, 0, 0, 4, 0, 1}, Numeric("0.0001")},
+		{oid.T_numeric, "0.00000001", []byte{0, 1, 0xff, 0xfe, 0, 0, 0, 8, 0, 1}, Numeric("0.00000001")},
+		{oid.T_numeric, "123456789.123", []byte{0, 4, 0, 2, 0, 0, 0, 3, 0, 1, 0x09, 0x29, 0x1a, 0x85, 0x04, 0xce}, Numeric("123456789.123")},
+		{oid.T_numeric, "NaN", []byte{0, 0, 0, 0, 0xc0, 0, 0, 0}, Numeric("NaN")},
+		{oid.T_numeric, "-Infinity", []byte{0, 0, 0, 0, 0xf0, 0, 0, 0}, Numeric("-Infinity")},
+	})
+}
+
+func TestNumericCodecExponent(t *testing.T) {
+	binary, err := EncodeValue(oid.T_numeric, DataFormatBinary, Numeric("1.5e3"))
+	assert.NoError(t, err)
+
+	value, err := DecodeValue(oid.T_numeric, DataFormatBinary, binary)
+	assert.NoError(t, err)
+	assert.Equal(t, Numeric("1500"), value)
+
+	binary, err = EncodeValue(oid.T_numeric, DataFormatBinary, Numeric("15E-3"))
+	assert.NoError(t, err)
+
+	value, err = DecodeValue(oid.T_numeric, DataFormatBinary, binary)
+	assert.NoError(t, err)
+	assert.Equal(t, Numeric("0.015"), value)
+}
+
+func TestNumericCodecInvalidValues(t *testing.T) {
+	_, err := DecodeValue(oid.T_numeric, DataFormatText, []byte("12,5"))
+	assert.Error(t, err)
+
+	_, err = DecodeValue(oid.T_numeric, DataFormatBinary, []byte{0, 2, 0, 0, 0, 0, 0, 1, 0, 12})
+	assert.Error(t, err, "truncated digits")
+
+	_, err = DecodeValue(oid.T_numeric, DataFormatBinary, []byte{0, 0, 0, 0, 0x12, 0x34, 0, 0})
+	assert.Error(t, err, "invalid sign")
+
+	_, err = EncodeValue(oid.T_numeric, DataFormatBinary, Numeric("1e"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,548 @@
+package pg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// intCodec encodes int2, int4 and int8 values (int16, int32 and int64).
+type intCodec struct {
+	// Size in bytes: 2, 4 or 8
+	size int
+}
+
+// DecodeText decodes a value in text format.
+func (c intCodec) DecodeText(src []byte) (interface{}, error) {
+	num, err := strconv.ParseInt(string(src), 10, c.size*8)
+
+	if err != nil {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	return c.typed(num), nil
+}
+
+// DecodeBinary decodes a value in binary format.
+func (c intCodec) DecodeBinary(src []byte) (interface{}, error) {
+	if len(src) != c.size {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	switch c.size {
+	case 2:
+		return int16(binary.BigEndian.Uint16(src)), nil
+
+	case 4:
+		return int32(binary.BigEndian.Uint32(src)), nil
+
+	default:
+		return int64(binary.BigEndian.Uint64(src)), nil
+	}
+}
+
+// EncodeText encodes a value into text format.
+func (c intCodec) EncodeText(value interface{}) ([]byte, error) {
+	num, err := c.int64(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strconv.FormatInt(num, 10)), nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (c intCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	num, err := c.int64(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, c.size)
+
+	switch c.size {
+	case 2:
+		binary.BigEndian.PutUint16(buf, uint16(num))
+
+	case 4:
+		binary.BigEndian.PutUint32(buf, uint32(num))
+
+	default:
+		binary.BigEndian.PutUint64(buf, uint64(num))
+	}
+
+	return buf, nil
+}
+
+// int64 converts the value to int64 checking that it fits the type.
+func (c intCodec) int64(value interface{}) (int64, error) {
+	var num int64
+
+	switch v := value.(type) {
+	case int16:
+		num = int64(v)
+	case int32:
+		num = int64(v)
+	case int64:
+		num = v
+	case int:
+		num = int64(v)
+	default:
+		return 0, errInvalidValue(c.name(), value)
+	}
+
+	bits := uint(c.size*8 - 1)
+
+	if num < -(1<<bits) || num > 1<<bits-1 {
+		return 0, errInvalidValue(c.name(), value)
+	}
+
+	return num, nil
+}
+
+// typed converts int64 into the Go type of the codec.
+func (c intCodec) typed(num int64) interface{} {
+	switch c.size {
+	case 2:
+		return int16(num)
+
+	case 4:
+		return int32(num)
+
+	default:
+		return num
+	}
+}
+
+// name returns the PostgreSQL type name.
+func (c intCodec) name() string {
+	return "int" + strconv.Itoa(c.size)
+}
+
+// floatCodec encodes float4 and float8 values (float32 and float64).
+type floatCodec struct {
+	// Size in bytes: 4 or 8
+	size int
+}
+
+// DecodeText decodes a value in text format.
+func (c floatCodec) DecodeText(src []byte) (interface{}, error) {
+	num, err := strconv.ParseFloat(string(src), c.size*8)
+
+	if err != nil {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	if c.size == 4 {
+		return float32(num), nil
+	}
+
+	return num, nil
+}
+
+// DecodeBinary decodes a value in binary format.
+func (c floatCodec) DecodeBinary(src []byte) (interface{}, error) {
+	if len(src) != c.size {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	if c.size == 4 {
+		return math.Float32frombits(binary.BigEndian.Uint32(src)), nil
+	}
+
+	return math.Float64frombits(binary.BigEndian.Uint64(src)), nil
+}
+
+// EncodeText encodes a value into text format.
+func (c floatCodec) EncodeText(value interface{}) ([]byte, error) {
+	num, err := c.float64(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case math.IsNaN(num):
+		return []byte("NaN"), nil
+
+	case math.IsInf(num, 1):
+		return []byte("Infinity"), nil
+
+	case math.IsInf(num, -1):
+		return []byte("-Infinity"), nil
+
+	default:
+		return []byte(strconv.FormatFloat(num, 'g', -1, c.size*8)), nil
+	}
+}
+
+// EncodeBinary encodes a value into binary format.
+func (c floatCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	num, err := c.float64(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, c.size)
+
+	if c.size == 4 {
+		binary.BigEndian.PutUint32(buf, math.Float32bits(float32(num)))
+	} else {
+		binary.BigEndian.PutUint64(buf, math.Float64bits(num))
+	}
+
+	return buf, nil
+}
+
+// float64 converts the value to float64.
+func (c floatCodec) float64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, errInvalidValue(c.name(), value)
+	}
+}
+
+// name returns the PostgreSQL type name.
+func (c floatCodec) name() string {
+	return "float" + strconv.Itoa(c.size)
+}
+
+// boolCodec encodes bool values.
+type boolCodec struct{}
+
+// DecodeText decodes a value in text format.
+func (boolCodec) DecodeText(src []byte) (interface{}, error) {
+	switch strings.ToLower(strings.TrimSpace(string(src))) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, nil
+
+	case "f", "false", "n", "no", "off", "0":
+		return false, nil
+
+	default:
+		return nil, errInvalidValue("bool", src)
+	}
+}
+
+// DecodeBinary decodes a value in binary format.
+func (boolCodec) DecodeBinary(src []byte) (interface{}, error) {
+	if len(src) != 1 {
+		return nil, errInvalidValue("bool", src)
+	}
+
+	return src[0] != 0, nil
+}
+
+// EncodeText encodes a value into text format.
+func (boolCodec) EncodeText(value interface{}) ([]byte, error) {
+	v, ok := value.(bool)
+
+	if !ok {
+		return nil, errInvalidValue("bool", value)
+	}
+
+	if v {
+		return []byte("t"), nil
+	}
+
+	return []byte("f"), nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (boolCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	v, ok := value.(bool)
+
+	if !ok {
+		return nil, errInvalidValue("bool", value)
+	}
+
+	if v {
+		return []byte{1}, nil
+	}
+
+	return []byte{0}, nil
+}
+
+// textCodec encodes values of text types (string). Text and binary representations are the same.
+type textCodec struct{}
+
+// DecodeText decodes a value in text format.
+func (textCodec) DecodeText(src []byte) (interface{}, error) {
+	return string(src), nil
+}
+
+// DecodeBinary decodes a value in binary format.
+func (textCodec) DecodeBinary(src []byte) (interface{}, error) {
+	return string(src), nil
+}
+
+// EncodeText encodes a value into text format.
+func (textCodec) EncodeText(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, errInvalidValue("text", value)
+	}
+}
+
+// EncodeBinary encodes a value into binary format.
+func (c textCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	return c.EncodeText(value)
+}
+
+// byteaCodec encodes bytea values ([]byte). Text format is hex (\x0102...); the legacy escape format
+// is accepted when decoding.
+type byteaCodec struct{}
+
+// DecodeText decodes a value in text format.
+func (byteaCodec) DecodeText(src []byte) (interface{}, error) {
+	if bytes.HasPrefix(src, []byte(`\x`)) {
+		buf := make([]byte, hex.DecodedLen(len(src)-2))
+		_, err := hex.Decode(buf, src[2:])
+
+		if err != nil {
+			return nil, errInvalidValue("bytea", src)
+		}
+
+		return buf, nil
+	}
+
+	// Escape format: printable characters as is, \\ for backslash and \nnn for other bytes
+	buf := make([]byte, 0, len(src))
+
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\\' {
+			buf = append(buf, src[i])
+			continue
+		}
+
+		if i+1 < len(src) && src[i+1] == '\\' {
+			buf = append(buf, '\\')
+			i++
+
+			continue
+		}
+
+		if i+4 > len(src) {
+			return nil, errInvalidValue("bytea", src)
+		}
+
+		c, err := strconv.ParseUint(string(src[i+1:i+4]), 8, 8)
+
+		if err != nil {
+			return nil, errInvalidValue("bytea", src)
+		}
+
+		buf = append(buf, byte(c))
+		i += 3
+	}
+
+	return buf, nil
+}
+
+// DecodeBinary decodes a value in binary format.
+func (byteaCodec) DecodeBinary(src []byte) (interface{}, error) {
+	return append([]byte{}, src...), nil
+}
+
+// EncodeText encodes a value into text format.
+func (byteaCodec) EncodeText(value interface{}) ([]byte, error) {
+	v, ok := value.([]byte)
+
+	if !ok {
+		return nil, errInvalidValue("bytea", value)
+	}
+
+	buf := make([]byte, 2+hex.EncodedLen(len(v)))
+	copy(buf, `\x`)
+	hex.Encode(buf[2:], v)
+
+	return buf, nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (byteaCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	v, ok := value.([]byte)
+
+	if !ok {
+		return nil, errInvalidValue("bytea", value)
+	}
+
+	return v, nil
+}
+
+// UUID represents a uuid value.
+type UUID [16]byte
+
+// String returns the UUID in the canonical form (e.g. a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11).
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf)
+}
+
+// uuidCodec encodes uuid values (UUID).
+type uuidCodec struct{}
+
+// DecodeText decodes a value in text format. All formats accepted by PostgreSQL are supported
+// (with or without hyphens, optionally surrounded by braces).
+func (uuidCodec) DecodeText(src []byte) (interface{}, error) {
+	str := string(src)
+
+	if strings.HasPrefix(str, "{") && strings.HasSuffix(str, "}") {
+		str = str[1 : len(str)-1]
+	}
+
+	var u UUID
+
+	digits := strings.ReplaceAll(str, "-", "")
+
+	if len(digits) != 32 {
+		return nil, errInvalidValue("uuid", src)
+	}
+
+	_, err := hex.Decode(u[:], []byte(digits))
+
+	if err != nil {
+		return nil, errInvalidValue("uuid", src)
+	}
+
+	return u, nil
+}
+
+// DecodeBinary decodes a value in binary format.
+func (uuidCodec) DecodeBinary(src []byte) (interface{}, error) {
+	var u UUID
+
+	if len(src) != len(u) {
+		return nil, errInvalidValue("uuid", src)
+	}
+
+	copy(u[:], src)
+
+	return u, nil
+}
+
+// EncodeText encodes a value into text format.
+func (uuidCodec) EncodeText(value interface{}) ([]byte, error) {
+	u, ok := value.(UUID)
+
+	if !ok {
+		return nil, errInvalidValue("uuid", value)
+	}
+
+	return []byte(u.String()), nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (uuidCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	u, ok := value.(UUID)
+
+	if !ok {
+		return nil, errInvalidValue("uuid", value)
+	}
+
+	return u[:], nil
+}
+
+// Version of the jsonb binary format.
+const jsonbVersion = 1
+
+// jsonCodec encodes json and jsonb values (json.RawMessage). Binary format of json is the same as text;
+// binary format of jsonb is text prefixed with the format version.
+type jsonCodec struct {
+	// jsonb type
+	binary bool
+}
+
+// DecodeText decodes a value in text format.
+func (c jsonCodec) DecodeText(src []byte) (interface{}, error) {
+	if !json.Valid(src) {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	return json.RawMessage(append([]byte{}, src...)), nil
+}
+
+// DecodeBinary decodes a value in binary format.
+func (c jsonCodec) DecodeBinary(src []byte) (interface{}, error) {
+	if c.binary {
+		if len(src) == 0 || src[0] != jsonbVersion {
+			return nil, errInvalidValue(c.name(), src)
+		}
+
+		src = src[1:]
+	}
+
+	return c.DecodeText(src)
+}
+
+// EncodeText encodes a value into text format.
+func (c jsonCodec) EncodeText(value interface{}) ([]byte, error) {
+	var src []byte
+
+	switch v := value.(type) {
+	case json.RawMessage:
+		src = v
+	case []byte:
+		src = v
+	case string:
+		src = []byte(v)
+	default:
+		return nil, errInvalidValue(c.name(), value)
+	}
+
+	if !json.Valid(src) {
+		return nil, errInvalidValue(c.name(), src)
+	}
+
+	return src, nil
+}
+
+// EncodeBinary encodes a value into binary format.
+func (c jsonCodec) EncodeBinary(value interface{}) ([]byte, error) {
+	src, err := c.EncodeText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.binary {
+		return append([]byte{jsonbVersion}, src...), nil
+	}
+
+	return src, nil
+}
+
+// name returns the PostgreSQL type name.
+func (c jsonCodec) name() string {
+	if c.binary {
+		return "jsonb"
+	}
+
+	return "json"
+}
@@ -0,0 +1,89 @@
+package pg
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScalarCodecs(t *testing.T) {
+	testCodec(t, []codecTestCase{
+		{oid.T_int2, "-2", []byte{0xff, 0xfe}, int16(-2)},
+		{oid.T_int4, "1452268", []byte{0x00, 0x16, 0x28, 0xec}, int32(1452268)},
+		{oid.T_int8, "4294967296", []byte{0, 0, 0, 1, 0, 0, 0, 0}, int64(4294967296)},
+		{oid.T_float4, "1.5", []byte{0x3f, 0xc0, 0x00, 0x00}, float32(1.5)},
+		{oid.T_float8, "-0.25", []byte{0xbf, 0xd0, 0, 0, 0, 0, 0, 0}, -0.25},
+		{oid.T_float8, "Infinity", []byte{0x7f, 0xf0, 0, 0, 0, 0, 0, 0}, math.Inf(1)},
+		{oid.T_bool, "t", []byte{1}, true},
+		{oid.T_bool, "f", []byte{0}, false},
+		{oid.T_varchar, "Denis Diachkov", []byte("Denis Diachkov"), "Denis Diachkov"},
+		{oid.T_bytea, `\xdeadbeef`, []byte{0xde, 0xad, 0xbe, 0xef}, []byte{0xde, 0xad, 0xbe, 0xef}},
+		{
+			oid.T_uuid,
+			"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11",
+			[]byte{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11},
+			UUID{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11},
+		},
+		{oid.T_json, `{"a": 1}`, []byte(`{"a": 1}`), json.RawMessage(`{"a": 1}`)},
+		{oid.T_jsonb, `{"a": 1}`, append([]byte{1}, `{"a": 1}`...), json.RawMessage(`{"a": 1}`)},
+	})
+}
+
+func TestScalarCodecsInvalidValues(t *testing.T) {
+	testCases := []struct {
+		typ    oid.Oid
+		format DataFormat
+		src    string
+	}{
+		{oid.T_int2, DataFormatText, "32768"},
+		{oid.T_int4, DataFormatText, "abc"},
+		{oid.T_int4, DataFormatBinary, "\x00\x01"},
+		{oid.T_float8, DataFormatText, "1,5"},
+		{oid.T_bool, DataFormatText, "maybe"},
+		{oid.T_bytea, DataFormatText, `\xzz`},
+		{oid.T_bytea, DataFormatText, `\1`},
+		{oid.T_uuid, DataFormatText, "a0eebc99"},
+		{oid.T_json, DataFormatText, "{"},
+		{oid.T_jsonb, DataFormatBinary, "\x02{}"},
+	}
+
+	for _, tc := range testCases {
+		_, err := DecodeValue(tc.typ, tc.format, []byte(tc.src))
+		assert.Error(t, err, tc.src)
+	}
+
+	// Values of wrong Go types
+	_, err := EncodeValue(oid.T_int2, DataFormatBinary, int32(100000))
+	assert.Error(t, err)
+
+	_, err = EncodeValue(oid.T_bool, DataFormatText, "t")
+	assert.Error(t, err)
+
+	_, err = EncodeValue(oid.T_jsonb, DataFormatBinary, "{")
+	assert.Error(t, err)
+}
+
+func TestScalarCodecsTextVariants(t *testing.T) {
+	// Legacy bytea escape format
+	value, err := DecodeValue(oid.T_bytea, DataFormatText, []byte(`a\\b\001`))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'a', '\\', 'b', 1}, value)
+
+	// UUID without hyphens in braces
+	value, err = DecodeValue(oid.T_uuid, DataFormatText, []byte("{A0EEBC999C0B4EF8BB6D6BB9BD380A11}"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", value.(UUID).String())
+
+	// Booleans
+	value, err = DecodeValue(oid.T_bool, DataFormatText, []byte("yes"))
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+
+	// NaN
+	text, err := EncodeValue(oid.T_float4, DataFormatText, float32(math.NaN()))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("NaN"), text)
+}
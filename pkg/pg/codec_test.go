@@ -0,0 +1,106 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupCodec(t *testing.T) {
+	for _, typ := range []oid.Oid{
+		oid.T_int2, oid.T_int4, oid.T_int8, oid.T_float4, oid.T_float8, oid.T_numeric, oid.T_bool,
+		oid.T_text, oid.T_varchar, oid.T_bpchar, oid.T_bytea, oid.T_date, oid.T_timestamp, oid.T_timestamptz,
+		oid.T_uuid, oid.T_json, oid.T_jsonb, oid.T__int4, oid.T__text, oid.T__uuid, oid.T__jsonb,
+	} {
+		_, ok := LookupCodec(typ)
+		assert.True(t, ok, oid.TypeName[typ])
+	}
+
+	_, ok := LookupCodec(oid.T_point)
+	assert.False(t, ok)
+}
+
+func TestDecodeEncodeValue(t *testing.T) {
+	value, err := DecodeValue(oid.T_int4, DataFormatBinary, []byte{0x00, 0x16, 0x28, 0xec})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1452268), value)
+
+	text, err := EncodeValue(oid.T_int4, DataFormatText, value)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1452268"), text)
+
+	// NULL
+	value, err = DecodeValue(oid.T_int4, DataFormatBinary, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	text, err = EncodeValue(oid.T_int4, DataFormatText, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, text)
+
+	// Unsupported type
+	_, err = DecodeValue(oid.T_point, DataFormatText, []byte("(1,2)"))
+	assert.Equal(t, ErrUnsupportedType, err)
+
+	_, err = EncodeValue(oid.T_point, DataFormatText, "(1,2)")
+	assert.Equal(t, ErrUnsupportedType, err)
+
+	// Unknown format
+	_, err = DecodeValue(oid.T_int4, DataFormat(2), []byte("1"))
+	assert.Error(t, err)
+}
+
+func TestTranscode(t *testing.T) {
+	binary, err := Transcode(oid.T_int8, DataFormatText, DataFormatBinary, []byte("-1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, binary)
+
+	text, err := Transcode(oid.T_int8, DataFormatBinary, DataFormatText, binary)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("-1"), text)
+
+	// Same format
+	text, err = Transcode(oid.T_point, DataFormatText, DataFormatText, []byte("(1,2)"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("(1,2)"), text)
+
+	// Invalid value
+	_, err = Transcode(oid.T_int2, DataFormatText, DataFormatBinary, []byte("100000"))
+	assert.Error(t, err)
+}
+
+// codecTestCase is a value in both formats.
+type codecTestCase struct {
+	typ    oid.Oid
+	text   string
+	binary []byte
+	value  interface{}
+}
+
+// testCodec checks decoding and encoding of the values in both formats.
+func testCodec(t *testing.T, testCases []codecTestCase) {
+	t.Helper()
+
+	for _, tc := range testCases {
+		codec, ok := LookupCodec(tc.typ)
+		require.True(t, ok)
+
+		value, err := codec.DecodeText([]byte(tc.text))
+		require.NoError(t, err, tc.text)
+		assert.Equal(t, tc.value, value, tc.text)
+
+		value, err = codec.DecodeBinary(tc.binary)
+		require.NoError(t, err, tc.text)
+		assert.Equal(t, tc.value, value, tc.text)
+
+		text, err := codec.EncodeText(tc.value)
+		require.NoError(t, err, tc.text)
+		assert.Equal(t, tc.text, string(text))
+
+		binary, err := codec.EncodeBinary(tc.value)
+		require.NoError(t, err, tc.text)
+		assert.Equal(t, tc.binary, binary, tc.text)
+	}
+}
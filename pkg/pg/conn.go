@@ -1,6 +1,8 @@
 package pg
 
 import (
+	"fmt"
+	"io"
 	"net"
 )
 
@@ -25,7 +27,22 @@ func (h *Conn) RecvStartupMessage() (*StartupMessage, error) {
 	return ParseStartupMessage(frame)
 }
 
+// RecvInitialMessage receives the first message of a frontend from the underlying network connection:
+// StartupMessage (including SSLRequest) or CancelRequestMessage.
+func (h *Conn) RecvInitialMessage() (Message, error) {
+	frame, err := ReadStartupFrame(h.conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseInitialMessage(frame)
+}
+
 // RecvMessage receives PostgreSQL message from the underlying network connection.
+//
+// Deprecated: some frontend and backend messages share the same type byte (e.g. Describe and DataRow), so
+// the message cannot be parsed without knowing its direction. Use RecvFrontendMessage or RecvBackendMessage.
 func (h *Conn) RecvMessage() (Message, error) {
 	frame, err := ReadStandardFrame(h.conn)
 
@@ -38,7 +55,7 @@ func (h *Conn) RecvMessage() (Message, error) {
 		return ParseAuthenticationRequestMessage(frame)
 
 	case NegotiateProtocolVersionMessageType:
-		return ParseNoticeResponseMessage(frame)
+		return ParseNegotiateProtocolVersionMessage(frame)
 
 	case BackendKeyDataMessageType:
 		return ParseBackendKeyDataMessage(frame)
@@ -81,6 +98,30 @@ func (h *Conn) RecvMessage() (Message, error) {
 	}
 }
 
+// RecvFrontendMessage receives PostgreSQL message sent by a frontend (i.e., a client) from the underlying
+// network connection. Unknown messages are returned as GenericMessage.
+func (h *Conn) RecvFrontendMessage() (Message, error) {
+	frame, err := ReadStandardFrame(h.conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFrontendMessage(frame)
+}
+
+// RecvBackendMessage receives PostgreSQL message sent by a backend (i.e., a database) from the underlying
+// network connection. Unknown messages are returned as GenericMessage.
+func (h *Conn) RecvBackendMessage() (Message, error) {
+	frame, err := ReadStandardFrame(h.conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBackendMessage(frame)
+}
+
 // SendMessage sends given message over the network.
 func (h *Conn) SendMessage(msg Message) error {
 	_, err := h.conn.Write(msg.Frame().Bytes())
@@ -93,6 +134,35 @@ func (h *Conn) SendByte(c byte) error {
 	return err
 }
 
+// RequestSSL sends SSLRequest over the network and returns true if the server is willing to perform
+// TLS handshake. NB: the response is read without buffering, so the handshake can follow on the same connection.
+func (h *Conn) RequestSSL() (bool, error) {
+	err := h.SendMessage(&StartupMessage{ProtocolVersion: SSLRequestMagic})
+
+	if err != nil {
+		return false, err
+	}
+
+	response := make([]byte, 1)
+
+	_, err = io.ReadFull(h.conn, response)
+
+	if err != nil {
+		return false, err
+	}
+
+	switch response[0] {
+	case 'S':
+		return true, nil
+
+	case 'N':
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("pg: unexpected response to SSLRequest: %q", response[0])
+	}
+}
+
 // Unwrap returns the underlying net.Conn.
 func (h *Conn) Unwrap() net.Conn {
 	return h.conn
@@ -0,0 +1,216 @@
+package pg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Environment variables that provide default values of the connection parameters (as in libpq)
+var envParams = map[string]string{
+	"PGHOST":               "host",
+	"PGHOSTADDR":           "hostaddr",
+	"PGPORT":               "port",
+	"PGDATABASE":           "database",
+	"PGUSER":               "user",
+	"PGPASSWORD":           "password",
+	"PGPASSFILE":           "passfile",
+	"PGOPTIONS":            "options",
+	"PGAPPNAME":            "application_name",
+	"PGCLIENTENCODING":     "client_encoding",
+	"PGCONNECT_TIMEOUT":    "connect_timeout",
+	"PGSSLMODE":            "sslmode",
+	"PGSSLCERT":            "sslcert",
+	"PGSSLKEY":             "sslkey",
+	"PGSSLROOTCERT":        "sslrootcert",
+	"PGSSLCRL":             "sslcrl",
+	"PGTARGETSESSIONATTRS": "target_session_attrs",
+}
+
+// Keywords of the connection string recognized by libpq
+var connOptions = map[string]bool{
+	"host":                      true,
+	"hostaddr":                  true,
+	"port":                      true,
+	"dbname":                    true,
+	"user":                      true,
+	"password":                  true,
+	"passfile":                  true,
+	"channel_binding":           true,
+	"connect_timeout":           true,
+	"client_encoding":           true,
+	"options":                   true,
+	"application_name":          true,
+	"fallback_application_name": true,
+	"keepalives":                true,
+	"keepalives_idle":           true,
+	"keepalives_interval":       true,
+	"keepalives_count":          true,
+	"tcp_user_timeout":          true,
+	"replication":               true,
+	"gssencmode":                true,
+	"sslmode":                   true,
+	"requiressl":                true,
+	"sslcompression":            true,
+	"sslcert":                   true,
+	"sslkey":                    true,
+	"sslpassword":               true,
+	"sslrootcert":               true,
+	"sslcrl":                    true,
+	"sslcrldir":                 true,
+	"sslsni":                    true,
+	"requirepeer":               true,
+	"ssl_min_protocol_version":  true,
+	"ssl_max_protocol_version":  true,
+	"krbsrvname":                true,
+	"gsslib":                    true,
+	"service":                   true,
+	"target_session_attrs":      true,
+}
+
+// ParseConnString parses given PostgreSQL connection string in URI or keyword/value format and resolves
+// the connection params the way libpq does: params missing from the connection string are taken from the service
+// in the connection service file, then from PG* environment variables, then from the implicit defaults.
+// Unless the password is set, it is looked up in the password file for every host (see ConnectionParams.Hosts).
+// See the documentation: https://www.postgresql.org/docs/current/libpq-envars.html
+func ParseConnString(connString string) (ConnectionParams, error) {
+	var (
+		params ConnectionParams
+		err    error
+	)
+
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		params, err = parseURI(connString)
+	} else {
+		params, err = parseKeywordValue(connString)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	service := params["service"]
+
+	if service == "" {
+		service = os.Getenv("PGSERVICE")
+	}
+
+	if service != "" {
+		serviceParams, err := lookupService(service)
+
+		if err != nil {
+			return nil, err
+		}
+
+		params.setMissing(serviceParams)
+	}
+
+	for env, name := range envParams {
+		if value := os.Getenv(env); value != "" {
+			params.setMissing(ConnectionParams{name: value})
+		}
+	}
+
+	params = withDefaults(params)
+	err = params.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// setMissing copies the params that are not set yet.
+func (p ConnectionParams) setMissing(params ConnectionParams) {
+	for k, v := range params {
+		if _, ok := p[k]; !ok {
+			p[k] = v
+		}
+	}
+}
+
+// parseKeywordValue parses the connection string in keyword/value format (e.g. "host=db dbname='my app'").
+// Values may be single-quoted; a backslash escapes the next character both in quoted and unquoted values.
+func parseKeywordValue(connString string) (ConnectionParams, error) {
+	params := make(ConnectionParams)
+	input := []rune(connString)
+
+	skipSpaces := func(i int) int {
+		for i < len(input) && unicode.IsSpace(input[i]) {
+			i++
+		}
+
+		return i
+	}
+
+	for i := skipSpaces(0); i < len(input); i = skipSpaces(i) {
+		// Keyword
+		start := i
+
+		for i < len(input) && input[i] != '=' && !unicode.IsSpace(input[i]) {
+			i++
+		}
+
+		keyword := string(input[start:i])
+
+		if keyword == "" {
+			return nil, fmt.Errorf("missing keyword before \"=\" in connection string")
+		}
+
+		if !connOptions[keyword] {
+			return nil, fmt.Errorf("invalid connection option %q", keyword)
+		}
+
+		i = skipSpaces(i)
+
+		if i == len(input) || input[i] != '=' {
+			return nil, fmt.Errorf("missing \"=\" after %q in connection string", keyword)
+		}
+
+		i = skipSpaces(i + 1)
+
+		// Value
+		var value strings.Builder
+
+		quoted := i < len(input) && input[i] == '\''
+
+		if quoted {
+			i++
+		}
+
+		for {
+			if i == len(input) {
+				if quoted {
+					return nil, fmt.Errorf("unterminated quoted string in connection string")
+				}
+
+				break
+			}
+
+			c := input[i]
+
+			if quoted && c == '\'' {
+				i++
+				break
+			}
+
+			if !quoted && unicode.IsSpace(c) {
+				break
+			}
+
+			if c == '\\' && i+1 < len(input) {
+				i++
+				c = input[i]
+			}
+
+			value.WriteRune(c)
+			i++
+		}
+
+		params[paramName(keyword)] = value.String()
+	}
+
+	return params, nil
+}
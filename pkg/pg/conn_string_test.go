@@ -0,0 +1,178 @@
+package pg
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withEnv replaces the libpq environment variables and the home directory (with an empty one) for the test.
+// It returns a function restoring them.
+func withEnv(t *testing.T, vars map[string]string) func() {
+	t.Helper()
+
+	home, err := ioutil.TempDir("", "home")
+	require.NoError(t, err)
+
+	names := []string{"HOME", "PGSERVICE", "PGSERVICEFILE", "PGSYSCONFDIR"}
+
+	for name := range envParams {
+		names = append(names, name)
+	}
+
+	saved := make(map[string]*string)
+
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			saved[name] = &value
+		} else {
+			saved[name] = nil
+		}
+
+		require.NoError(t, os.Unsetenv(name))
+	}
+
+	require.NoError(t, os.Setenv("HOME", home))
+	require.NoError(t, os.Setenv("PGSYSCONFDIR", home))
+
+	for name, value := range vars {
+		require.NoError(t, os.Setenv(name, value))
+	}
+
+	return func() {
+		for name, value := range saved {
+			if value != nil {
+				os.Setenv(name, *value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+
+		os.RemoveAll(home)
+	}
+}
+
+func TestParseConnString(t *testing.T) {
+	defer withEnv(t, nil)()
+
+	user, err := user.Current()
+	require.NoError(t, err)
+
+	defaultUser := user.Username
+
+	//nolint:lll
+	validTestCases := map[string]ConnectionParams{
+		"":                           {"host": "localhost", "port": "5432", "user": defaultUser, "database": defaultUser},
+		"host=db port=5433":          {"host": "db", "port": "5433", "user": defaultUser, "database": defaultUser},
+		"  host = db\tdbname=app   ": {"host": "db", "port": "5432", "user": defaultUser, "database": "app"},
+		`user=alice password='it\'s a \\secret' application_name='my app' options=''`: {"host": "localhost", "port": "5432", "user": "alice", "password": `it's a \secret`, "database": "alice", "application_name": "my app", "options": ""},
+		`password=semi\ colon sslmode=require`:                                        {"host": "localhost", "port": "5432", "user": defaultUser, "password": "semi colon", "database": defaultUser, "sslmode": "require"},
+		"host=db1,db2 port=5432,5433 target_session_attrs=primary":                    {"host": "db1,db2", "port": "5432,5433", "user": defaultUser, "database": defaultUser, "target_session_attrs": "primary"},
+		"postgresql://alice@db/app?dbname=other":                                      {"host": "db", "port": "5432", "user": "alice", "database": "other"},
+		"host=db1,db2 hostaddr=10.0.0.1,10.0.0.2":                                     {"host": "db1,db2", "hostaddr": "10.0.0.1,10.0.0.2", "port": "5432", "user": defaultUser, "database": defaultUser},
+	}
+
+	for connString, expected := range validTestCases {
+		params, err := ParseConnString(connString)
+
+		if assert.NoErrorf(t, err, "connection string: %s", connString) {
+			assert.Equalf(t, expected, params, "connection string: %s", connString)
+		}
+	}
+
+	invalidTestCases := []string{
+		"host",
+		"host db",
+		"=db",
+		"password='unterminated",
+		"target_session_attrs=master",
+		"mysql://localhost",
+		"service=unknown",
+		"hostname=db",
+		"host=db1,db2 hostaddr=10.0.0.1",
+	}
+
+	for _, connString := range invalidTestCases {
+		_, err := ParseConnString(connString)
+		assert.Errorf(t, err, "connection string: %s", connString)
+	}
+}
+
+func TestParseConnStringEnv(t *testing.T) {
+	defer withEnv(t, map[string]string{
+		"PGHOST":     "env-db",
+		"PGPORT":     "5433",
+		"PGUSER":     "env-user",
+		"PGDATABASE": "env-app",
+		"PGSSLMODE":  "verify-full",
+		"PGAPPNAME":  "env-app-name",
+	})()
+
+	// Connection string takes precedence
+	params, err := ParseConnString("postgres://alice@db/app")
+	require.NoError(t, err)
+
+	assert.Equal(t, ConnectionParams{
+		"host":             "db",
+		"port":             "5433",
+		"user":             "alice",
+		"database":         "app",
+		"sslmode":          "verify-full",
+		"application_name": "env-app-name",
+	}, params)
+
+	params, err = ParseConnString("")
+	require.NoError(t, err)
+
+	assert.Equal(t, "env-db", params["host"])
+	assert.Equal(t, "env-user", params["user"])
+	assert.Equal(t, "env-app", params["database"])
+}
+
+func TestParseConnStringServiceAndPassword(t *testing.T) {
+	defer withEnv(t, nil)()
+
+	home := os.Getenv("HOME")
+
+	serviceFile := "# Services\n[other]\nhost=other-db\n\n[app]\nhost = db1,db2\ndbname=app\nuser=alice\nport=5433\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(home, ".pg_service.conf"), []byte(serviceFile), 0600))
+
+	passFile := "# hostname:port:database:username:password\ndb1:5432:app:alice:wrong-port\ndb2:5433:*:alice:s3cr3t\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(home, ".pgpass"), []byte(passFile), 0600))
+
+	// Connection string takes precedence over the service; the password is looked up for each host
+	params, err := ParseConnString("service=app port=5433 sslmode=disable")
+	require.NoError(t, err)
+
+	assert.Equal(t, ConnectionParams{
+		"service":  "app",
+		"host":     "db1,db2",
+		"port":     "5433",
+		"user":     "alice",
+		"database": "app",
+		"sslmode":  "disable",
+	}, params)
+
+	hosts := params.Hosts()
+	require.Len(t, hosts, 2)
+
+	assert.Equal(t, "", hosts[0]["password"])
+	assert.Equal(t, "s3cr3t", hosts[1]["password"])
+
+	// PGSERVICE
+	require.NoError(t, os.Setenv("PGSERVICE", "other"))
+
+	params, err = ParseConnString("dbname=app")
+	require.NoError(t, err)
+	assert.Equal(t, "other-db", params["host"])
+
+	// Password set explicitly
+	params, err = ParseConnString("host=db2 port=5433 user=alice password=explicit")
+	require.NoError(t, err)
+	assert.Equal(t, "explicit", params["password"])
+}
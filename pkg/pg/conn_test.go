@@ -29,6 +29,31 @@ func TestConnRecvStartupMessage(t *testing.T) {
 	assert.Equal(t, int32(DefaultProtocolVersion), msg.ProtocolVersion)
 }
 
+func TestConnRecvInitialMessage(t *testing.T) {
+	client, server := net.Pipe()
+
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, err := server.Write([]byte(GoldenStartupMessagePacket + GoldenCancelRequestMessagePacket))
+
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	pgConn := NewConn(client)
+
+	msg, err := pgConn.RecvInitialMessage()
+	assert.NoError(t, err)
+	assert.IsType(t, &StartupMessage{}, msg)
+
+	msg, err = pgConn.RecvInitialMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, &CancelRequestMessage{ProcessID: 28822, Key: -636998870}, msg)
+}
+
 func TestConnRecvMessage(t *testing.T) {
 	client, server := net.Pipe()
 
@@ -61,6 +86,48 @@ func TestConnRecvMessage(t *testing.T) {
 	}
 }
 
+func TestConnRecvFrontendMessage(t *testing.T) {
+	client, server := net.Pipe()
+
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, err := server.Write([]byte(GoldenDescribeMessagePacket))
+
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	pgConn := NewConn(client)
+	msg, err := pgConn.RecvFrontendMessage()
+
+	assert.NoError(t, err)
+	assert.Equal(t, &DescribeMessage{ObjectType: ObjectTypePortal}, msg)
+}
+
+func TestConnRecvBackendMessage(t *testing.T) {
+	client, server := net.Pipe()
+
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, err := server.Write([]byte(GoldenDataRowMesagePacket))
+
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	pgConn := NewConn(client)
+	msg, err := pgConn.RecvBackendMessage()
+
+	assert.NoError(t, err)
+	assert.IsType(t, &DataRowMessage{}, msg)
+}
+
 func TestConnSendMessage(t *testing.T) {
 	client, server := net.Pipe()
 
@@ -110,6 +177,50 @@ func TestConnSendByte(t *testing.T) {
 	assert.Equal(t, []byte{'$'}, buf)
 }
 
+func TestConnRequestSSL(t *testing.T) {
+	for response, expected := range map[byte]bool{'S': true, 'N': false} {
+		client, server := net.Pipe()
+
+		go func(response byte) {
+			serverConn := NewConn(server)
+			msg, err := serverConn.RecvStartupMessage()
+
+			if err != nil || msg.ProtocolVersion != SSLRequestMagic {
+				panic("SSLRequest expected")
+			}
+
+			err = serverConn.SendByte(response)
+
+			if err != nil {
+				panic(err)
+			}
+		}(response)
+
+		accepted, err := NewConn(client).RequestSSL()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, accepted)
+
+		client.Close()
+		server.Close()
+	}
+
+	t.Run("unexpected response", func(t *testing.T) {
+		client, server := net.Pipe()
+
+		defer client.Close()
+		defer server.Close()
+
+		go func() {
+			_, _ = NewConn(server).RecvStartupMessage()
+			_, _ = server.Write([]byte{'E'})
+		}()
+
+		_, err := NewConn(client).RequestSSL()
+		assert.Error(t, err)
+	})
+}
+
 func TestConnUnwrap(t *testing.T) {
 	client, server := net.Pipe()
 
@@ -164,7 +275,7 @@ func BenchmarkConnThroughput(b *testing.B) {
 	pgConn := NewConn(client)
 
 	for n := 0; n < b.N; n++ {
-		_, err := pgConn.RecvMessage()
+		_, err := pgConn.RecvFrontendMessage()
 
 		if err != nil {
 			b.Fatal(err)
@@ -0,0 +1,38 @@
+//nolint:dupl
+package pg
+
+// CopyBothResponseMessageType identifies CopyBothResponseMessage message.
+const CopyBothResponseMessageType = 'W'
+
+// CopyBothResponseMessage is sent by a backend to start copying data in both directions (used only by streaming replication).
+type CopyBothResponseMessage struct {
+	// Overall COPY format: text (also used for CSV) or binary.
+	Format DataFormat
+
+	// Formats of every column; all of them are text if the overall format is text.
+	ColumnFormats []DataFormat
+}
+
+// Compile time check to make sure that CopyBothResponseMessage implements the Message interface.
+var _ Message = &CopyBothResponseMessage{}
+
+// ParseCopyBothResponseMessage parses CopyBothResponseMessage from a network frame.
+func ParseCopyBothResponseMessage(frame Frame) (*CopyBothResponseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CopyBothResponseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	format, columnFormats, err := readCopyResponse(frame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CopyBothResponseMessage{Format: format, ColumnFormats: columnFormats}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CopyBothResponseMessage) Frame() Frame {
+	return NewStandardFrame(CopyBothResponseMessageType, writeCopyResponse(m.Format, m.ColumnFormats))
+}
@@ -0,0 +1,31 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden COPY both response message packet (START_REPLICATION)
+const GoldenCopyBothResponseMessagePacket = "\x57\x00\x00\x00\x07\x00\x00\x00"
+
+func TestParseCopyBothResponseMessage(t *testing.T) {
+	msg, err := ParseCopyBothResponseMessage(StandardFrame(GoldenCopyBothResponseMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, DataFormatText, msg.Format)
+	assert.Equal(t, []DataFormat{}, msg.ColumnFormats)
+
+	// Test invalid type
+	_, err = ParseCopyBothResponseMessage(append(StandardFrame{'X'}, GoldenCopyBothResponseMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test truncated message
+	_, err = ParseCopyBothResponseMessage(NewStandardFrame(CopyBothResponseMessageType, []byte{0, 0}))
+	assert.Error(t, err)
+}
+
+func TestCopyBothResponseMessageFrame(t *testing.T) {
+	msg := &CopyBothResponseMessage{Format: DataFormatText, ColumnFormats: []DataFormat{}}
+	assert.Equal(t, []byte(GoldenCopyBothResponseMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,30 @@
+package pg
+
+// CopyDataMessageType identifies CopyDataMessage message.
+const CopyDataMessageType = 'd'
+
+// CopyDataMessage carries a chunk of COPY data. It is sent by a backend during COPY TO STDOUT and by
+// a frontend during COPY FROM STDIN. Messages are not aligned with rows: a row may be split across
+// several messages.
+type CopyDataMessage struct {
+	// Data stream chunk
+	Data []byte
+}
+
+// Compile time check to make sure that CopyDataMessage implements the Message interface.
+var _ Message = &CopyDataMessage{}
+
+// ParseCopyDataMessage parses CopyDataMessage from a network frame.
+func ParseCopyDataMessage(frame Frame) (*CopyDataMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CopyDataMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	return &CopyDataMessage{Data: frame.MessageBody()}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CopyDataMessage) Frame() Frame {
+	return NewStandardFrame(CopyDataMessageType, m.Data)
+}
@@ -0,0 +1,26 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden COPY data message packet (a row of COPY users (id, email) TO STDOUT)
+const GoldenCopyDataMessagePacket = "\x64\x00\x00\x00\x17" + "1\tjane@example.com\n"
+
+func TestParseCopyDataMessage(t *testing.T) {
+	msg, err := ParseCopyDataMessage(StandardFrame(GoldenCopyDataMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1\tjane@example.com\n"), msg.Data)
+
+	// Test invalid type
+	_, err = ParseCopyDataMessage(append(StandardFrame{'X'}, GoldenCopyDataMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+}
+
+func TestCopyDataMessageFrame(t *testing.T) {
+	msg := &CopyDataMessage{Data: []byte("1\tjane@example.com\n")}
+	assert.Equal(t, []byte(GoldenCopyDataMessagePacket), msg.Frame().Bytes())
+}
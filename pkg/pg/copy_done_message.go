@@ -0,0 +1,30 @@
+package pg
+
+// CopyDoneMessageType identifies CopyDoneMessage message.
+const CopyDoneMessageType = 'c'
+
+// CopyDoneMessage is sent by a backend or a frontend at the end of COPY data.
+type CopyDoneMessage struct{}
+
+// Compile time check to make sure that CopyDoneMessage implements the Message interface.
+var _ Message = &CopyDoneMessage{}
+
+// ParseCopyDoneMessage parses CopyDoneMessage from a network frame.
+func ParseCopyDoneMessage(frame Frame) (*CopyDoneMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CopyDoneMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &CopyDoneMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CopyDoneMessage) Frame() Frame {
+	return NewStandardFrame(CopyDoneMessageType, nil)
+}
@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden COPY done message packet (the message has no body)
+const GoldenCopyDoneMessagePacket = "\x63\x00\x00\x00\x04"
+
+func TestParseCopyDoneMessage(t *testing.T) {
+	{
+		_, err := ParseCopyDoneMessage(StandardFrame(GoldenCopyDoneMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseCopyDoneMessage(append(StandardFrame{'!'}, GoldenCopyDoneMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseCopyDoneMessage(NewStandardFrame(CopyDoneMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestCopyDoneMessageFrame(t *testing.T) {
+	msg := &CopyDoneMessage{}
+	assert.Equal(t, []byte(GoldenCopyDoneMessagePacket), msg.Frame().Bytes())
+}
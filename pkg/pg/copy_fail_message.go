@@ -0,0 +1,39 @@
+package pg
+
+// CopyFailMessageType identifies CopyFailMessage message.
+const CopyFailMessageType = 'f'
+
+// CopyFailMessage is sent by a frontend to abort COPY FROM STDIN.
+type CopyFailMessage struct {
+	// The cause of the failure
+	Message string
+}
+
+// Compile time check to make sure that CopyFailMessage implements the Message interface.
+var _ Message = &CopyFailMessage{}
+
+// ParseCopyFailMessage parses CopyFailMessage from a network frame.
+func ParseCopyFailMessage(frame Frame) (*CopyFailMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CopyFailMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	message, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CopyFailMessage{Message: message}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CopyFailMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+	messageBuffer.WriteString(m.Message)
+
+	return NewStandardFrame(CopyFailMessageType, messageBuffer)
+}
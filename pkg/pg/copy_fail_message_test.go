@@ -0,0 +1,26 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden COPY fail message packet
+const GoldenCopyFailMessagePacket = "\x66\x00\x00\x00\x0d" + "canceled\x00"
+
+func TestParseCopyFailMessage(t *testing.T) {
+	msg, err := ParseCopyFailMessage(StandardFrame(GoldenCopyFailMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "canceled", msg.Message)
+
+	// Test invalid type
+	_, err = ParseCopyFailMessage(append(StandardFrame{'X'}, GoldenCopyFailMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+}
+
+func TestCopyFailMessageFrame(t *testing.T) {
+	msg := &CopyFailMessage{Message: "canceled"}
+	assert.Equal(t, []byte(GoldenCopyFailMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,38 @@
+//nolint:dupl
+package pg
+
+// CopyInResponseMessageType identifies CopyInResponseMessage message.
+const CopyInResponseMessageType = 'G'
+
+// CopyInResponseMessage is sent by a backend when it is ready to receive COPY FROM STDIN data.
+type CopyInResponseMessage struct {
+	// Overall COPY format: text (also used for CSV) or binary.
+	Format DataFormat
+
+	// Formats of every column; all of them are text if the overall format is text.
+	ColumnFormats []DataFormat
+}
+
+// Compile time check to make sure that CopyInResponseMessage implements the Message interface.
+var _ Message = &CopyInResponseMessage{}
+
+// ParseCopyInResponseMessage parses CopyInResponseMessage from a network frame.
+func ParseCopyInResponseMessage(frame Frame) (*CopyInResponseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CopyInResponseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	format, columnFormats, err := readCopyResponse(frame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CopyInResponseMessage{Format: format, ColumnFormats: columnFormats}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CopyInResponseMessage) Frame() Frame {
+	return NewStandardFrame(CopyInResponseMessageType, writeCopyResponse(m.Format, m.ColumnFormats))
+}
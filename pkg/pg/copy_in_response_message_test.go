@@ -0,0 +1,31 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden COPY in response message packet (COPY users (email) FROM STDIN BINARY)
+const GoldenCopyInResponseMessagePacket = "\x47\x00\x00\x00\x09\x01\x00\x01\x00\x01"
+
+func TestParseCopyInResponseMessage(t *testing.T) {
+	msg, err := ParseCopyInResponseMessage(StandardFrame(GoldenCopyInResponseMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, DataFormatBinary, msg.Format)
+	assert.Equal(t, []DataFormat{DataFormatBinary}, msg.ColumnFormats)
+
+	// Test invalid type
+	_, err = ParseCopyInResponseMessage(append(StandardFrame{'X'}, GoldenCopyInResponseMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test truncated message
+	_, err = ParseCopyInResponseMessage(NewStandardFrame(CopyInResponseMessageType, []byte{0, 0}))
+	assert.Error(t, err)
+}
+
+func TestCopyInResponseMessageFrame(t *testing.T) {
+	msg := &CopyInResponseMessage{Format: DataFormatBinary, ColumnFormats: []DataFormat{DataFormatBinary}}
+	assert.Equal(t, []byte(GoldenCopyInResponseMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,68 @@
+//nolint:dupl
+package pg
+
+// CopyOutResponseMessageType identifies CopyOutResponseMessage message.
+const CopyOutResponseMessageType = 'H'
+
+// CopyOutResponseMessage is sent by a backend before COPY TO STDOUT data.
+type CopyOutResponseMessage struct {
+	// Overall COPY format: text (also used for CSV) or binary.
+	Format DataFormat
+
+	// Formats of every column; all of them are text if the overall format is text.
+	ColumnFormats []DataFormat
+}
+
+// Compile time check to make sure that CopyOutResponseMessage implements the Message interface.
+var _ Message = &CopyOutResponseMessage{}
+
+// ParseCopyOutResponseMessage parses CopyOutResponseMessage from a network frame.
+func ParseCopyOutResponseMessage(frame Frame) (*CopyOutResponseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != CopyOutResponseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	format, columnFormats, err := readCopyResponse(frame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CopyOutResponseMessage{Format: format, ColumnFormats: columnFormats}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *CopyOutResponseMessage) Frame() Frame {
+	return NewStandardFrame(CopyOutResponseMessageType, writeCopyResponse(m.Format, m.ColumnFormats))
+}
+
+// readCopyResponse reads body of CopyInResponse, CopyOutResponse and CopyBothResponse messages:
+// int8 overall format followed by int16 count of columns and int16 format of every column.
+func readCopyResponse(frame Frame) (DataFormat, []DataFormat, error) {
+	messageData := ReadBuffer(frame.MessageBody())
+
+	format, err := messageData.ReadByte()
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	columnFormats, err := readDataFormats(&messageData)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return DataFormat(format), columnFormats, nil
+}
+
+// writeCopyResponse writes body of CopyInResponse, CopyOutResponse and CopyBothResponse messages.
+func writeCopyResponse(format DataFormat, columnFormats []DataFormat) WriteBuffer {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteByte(byte(format))
+	writeDataFormats(&messageBuffer, columnFormats)
+
+	return messageBuffer
+}
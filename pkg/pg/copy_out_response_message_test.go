@@ -0,0 +1,31 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden COPY out response message packet (COPY users (id, email) TO STDOUT)
+const GoldenCopyOutResponseMessagePacket = "\x48\x00\x00\x00\x0b\x00\x00\x02\x00\x00\x00\x00"
+
+func TestParseCopyOutResponseMessage(t *testing.T) {
+	msg, err := ParseCopyOutResponseMessage(StandardFrame(GoldenCopyOutResponseMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, DataFormatText, msg.Format)
+	assert.Equal(t, []DataFormat{DataFormatText, DataFormatText}, msg.ColumnFormats)
+
+	// Test invalid type
+	_, err = ParseCopyOutResponseMessage(append(StandardFrame{'X'}, GoldenCopyOutResponseMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test truncated message
+	_, err = ParseCopyOutResponseMessage(NewStandardFrame(CopyOutResponseMessageType, []byte{0, 0}))
+	assert.Error(t, err)
+}
+
+func TestCopyOutResponseMessageFrame(t *testing.T) {
+	msg := &CopyOutResponseMessage{Format: DataFormatText, ColumnFormats: []DataFormat{DataFormatText, DataFormatText}}
+	assert.Equal(t, []byte(GoldenCopyOutResponseMessagePacket), msg.Frame().Bytes())
+}
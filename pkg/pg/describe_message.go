@@ -0,0 +1,73 @@
+package pg
+
+// DescribeMessageType identifies DescribeMessage message.
+const DescribeMessageType = 'D'
+
+// DescribeMessage is sent by a frontend to request a description of a prepared statement or a portal.
+type DescribeMessage struct {
+	// Describe a prepared statement or a portal.
+	ObjectType ObjectType
+
+	// The name of the prepared statement or portal to describe (an empty string selects the unnamed one).
+	Name string
+}
+
+// ObjectType identifies the kind of an object referenced by Describe and Close messages.
+type ObjectType byte
+
+const (
+	ObjectTypeStatement ObjectType = 'S' // Prepared statement
+	ObjectTypePortal    ObjectType = 'P' // Portal
+)
+
+// Compile time check to make sure that DescribeMessage implements the Message interface.
+var _ Message = &DescribeMessage{}
+
+// ParseDescribeMessage parses DescribeMessage from a network frame.
+func ParseDescribeMessage(frame Frame) (*DescribeMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != DescribeMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	objectType, name, err := readObjectReference(&messageData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DescribeMessage{ObjectType: objectType, Name: name}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *DescribeMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteByte(byte(m.ObjectType))
+	messageBuffer.WriteString(m.Name)
+
+	return NewStandardFrame(DescribeMessageType, messageBuffer)
+}
+
+// readObjectReference reads object type and name of the object referenced by Describe and Close messages.
+func readObjectReference(messageData *ReadBuffer) (ObjectType, string, error) {
+	objectType, err := messageData.ReadByte()
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	if ObjectType(objectType) != ObjectTypeStatement && ObjectType(objectType) != ObjectTypePortal {
+		return 0, "", ErrMalformedMessage
+	}
+
+	name, err := messageData.ReadString()
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	return ObjectType(objectType), name, nil
+}
@@ -0,0 +1,31 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Describe message packet of the unnamed portal
+const GoldenDescribeMessagePacket = "\x44\x00\x00\x00\x06\x50\x00"
+
+func TestParseDescribeMessage(t *testing.T) {
+	msg, err := ParseDescribeMessage(StandardFrame(GoldenDescribeMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ObjectTypePortal, msg.ObjectType)
+	assert.Equal(t, "", msg.Name)
+
+	// Test invalid type
+	_, err = ParseDescribeMessage(append(StandardFrame{'X'}, GoldenDescribeMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test invalid object type
+	_, err = ParseDescribeMessage(NewStandardFrame(DescribeMessageType, []byte("X\x00")))
+	assert.Equal(t, ErrMalformedMessage, err)
+}
+
+func TestDescribeMessageFrame(t *testing.T) {
+	msg := &DescribeMessage{ObjectType: ObjectTypePortal}
+	assert.Equal(t, []byte(GoldenDescribeMessagePacket), msg.Frame().Bytes())
+}
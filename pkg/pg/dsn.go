@@ -4,15 +4,50 @@ import (
 	"fmt"
 	"net/url"
 	"os/user"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // ConnectionParams is a map containing PostgreSQL connection parameters.
 type ConnectionParams map[string]string
 
-// ParseDatabaseURL parses given PostgreSQL connection string in URI format and returns connection params as a map.
+// Valid values of the target_session_attrs connection parameter
+var targetSessionAttrs = map[string]bool{
+	"any":            true,
+	"read-write":     true,
+	"read-only":      true,
+	"primary":        true,
+	"standby":        true,
+	"prefer-standby": true,
+}
+
+// Connection parameters that only configure the client; they are not PostgreSQL run-time parameters
+var clientParams = map[string]bool{
+	"hostaddr":             true,
+	"passfile":             true,
+	"service":              true,
+	"sslcrl":               true,
+	"target_session_attrs": true,
+}
+
+// ParseDatabaseURI parses given PostgreSQL connection string and returns connection params as a map. Despite
+// the name, both URI and keyword/value formats are accepted: it's the same as ParseConnString.
+// As in libpq, the connection string may list several hosts (e.g. postgresql://h1:5432,h2:5433/db); the host
+// and port params are comma-separated lists then (see Hosts).
 // See the documentation: https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
 func ParseDatabaseURI(connString string) (ConnectionParams, error) {
+	return ParseConnString(connString)
+}
+
+// parseURI parses the connection URI without applying defaults.
+func parseURI(connString string) (ConnectionParams, error) {
+	connString, hosts, ports, err := splitURIHosts(connString)
+
+	if err != nil {
+		return nil, err
+	}
+
 	url, err := url.Parse(connString)
 
 	if err != nil {
@@ -24,7 +59,7 @@ func ParseDatabaseURI(connString string) (ConnectionParams, error) {
 		return nil, fmt.Errorf("invalid PostgreSQL database URI %s", url)
 	}
 
-	settings := defaultConnectionSettings()
+	settings := make(ConnectionParams)
 
 	// Username and password
 	if url.User != nil {
@@ -45,21 +80,211 @@ func ParseDatabaseURI(connString string) (ConnectionParams, error) {
 		settings["port"] = port
 	}
 
+	// List of hosts
+	if len(hosts) > 0 {
+		settings["host"] = strings.Join(hosts, ",")
+		settings["port"] = strings.Join(ports, ",")
+	}
+
 	// Database name
 	if database := strings.TrimLeft(url.Path, "/"); database != "" {
 		settings["database"] = database
-	} else {
-		// Default is the same as database user
-		settings["database"] = settings["user"]
 	}
 
 	for k, v := range url.Query() {
-		settings[k] = v[0]
+		settings[paramName(k)] = v[0]
 	}
 
 	return settings, nil
 }
 
+// paramName returns the name of the connection parameter in ConnectionParams: libpq calls the database dbname.
+func paramName(keyword string) string {
+	if keyword == "dbname" {
+		return "database"
+	}
+
+	return keyword
+}
+
+// withDefaults returns the connection params with PostgreSQL implicit defaults for the missing ones.
+func withDefaults(params ConnectionParams) ConnectionParams {
+	settings := defaultConnectionSettings()
+
+	for k, v := range params {
+		settings[k] = v
+	}
+
+	// Default is the same as database user
+	if params["database"] == "" {
+		settings["database"] = settings["user"]
+	}
+
+	return settings
+}
+
+// validate checks the values of the connection params.
+func (p ConnectionParams) validate() error {
+	if attrs, ok := p["target_session_attrs"]; ok && !targetSessionAttrs[attrs] {
+		return fmt.Errorf("invalid target_session_attrs value %q", attrs)
+	}
+
+	if hostaddr := p["hostaddr"]; hostaddr != "" {
+		hosts, addrs := strings.Count(p["host"], ",")+1, strings.Count(hostaddr, ",")+1
+
+		if hosts != addrs {
+			return fmt.Errorf("could not match %d host names to %d hostaddr values", hosts, addrs)
+		}
+	}
+
+	return nil
+}
+
+// splitURIHosts cuts the list of hosts out of the connection URI, since url.Parse doesn't accept it. Hosts and
+// ports are returned in the same order; missing ones are defaulted. URIs with a single host are returned as is.
+func splitURIHosts(connString string) (string, []string, []string, error) {
+	schemeEnd := strings.Index(connString, "://")
+
+	if schemeEnd < 0 {
+		return connString, nil, nil, nil
+	}
+
+	authorityStart := schemeEnd + len("://")
+	authorityEnd := len(connString)
+
+	if i := strings.IndexAny(connString[authorityStart:], "/?#"); i >= 0 {
+		authorityEnd = authorityStart + i
+	}
+
+	authority := connString[authorityStart:authorityEnd]
+	hostsStart := strings.LastIndex(authority, "@") + 1
+
+	if !strings.Contains(authority[hostsStart:], ",") {
+		return connString, nil, nil, nil
+	}
+
+	var hosts, ports []string
+
+	for _, hostPort := range strings.Split(authority[hostsStart:], ",") {
+		host, port := hostPort, ""
+
+		if strings.HasPrefix(hostPort, "[") {
+			// IPv6 address
+			end := strings.Index(hostPort, "]")
+
+			if end < 0 {
+				return "", nil, nil, fmt.Errorf("invalid host %q in PostgreSQL database URI", hostPort)
+			}
+
+			host, port = hostPort[1:end], strings.TrimPrefix(hostPort[end+1:], ":")
+		} else if i := strings.LastIndex(hostPort, ":"); i >= 0 {
+			host, port = hostPort[:i], hostPort[i+1:]
+		}
+
+		host, err := url.PathUnescape(host)
+
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		if host == "" {
+			host = "localhost"
+		}
+
+		if port == "" {
+			port = "5432"
+		}
+
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return "", nil, nil, fmt.Errorf("invalid port %q in PostgreSQL database URI", port)
+		}
+
+		hosts = append(hosts, host)
+		ports = append(ports, port)
+	}
+
+	return connString[:authorityStart] + authority[:hostsStart] + connString[authorityEnd:], hosts, ports, nil
+}
+
+// Hosts returns connection params of every host listed in the host param, in the same order. As in libpq, a single
+// port applies to all hosts, hostaddr lists the addresses of the hosts, and the password is looked up in the password
+// file for every host unless it is set.
+func (p ConnectionParams) Hosts() []ConnectionParams {
+	hosts := strings.Split(p["host"], ",")
+	ports := strings.Split(p["port"], ",")
+	addrs := strings.Split(p["hostaddr"], ",")
+	result := make([]ConnectionParams, len(hosts))
+
+	for i, host := range hosts {
+		params := make(ConnectionParams, len(p))
+
+		for k, v := range p {
+			params[k] = v
+		}
+
+		if len(hosts) > 1 {
+			params["host"] = host
+
+			if len(ports) == len(hosts) {
+				params["port"] = ports[i]
+			} else {
+				params["port"] = ports[0]
+			}
+
+			// NB: empty entries of the keyword/value format stand for the defaults
+			if params["host"] == "" {
+				params["host"] = "localhost"
+			}
+
+			if params["port"] == "" {
+				params["port"] = "5432"
+			}
+
+			if len(addrs) == len(hosts) {
+				params["hostaddr"] = addrs[i]
+			}
+		}
+
+		if params["password"] == "" {
+			if password := lookupPassword(params); password != "" {
+				params["password"] = password
+			}
+		}
+
+		result[i] = params
+	}
+
+	return result
+}
+
+// DSN returns the connection params as a keyword/value connection string (e.g. "dbname='app' host='db'").
+func (p ConnectionParams) DSN() string {
+	keys := make([]string, 0, len(p))
+
+	for k := range p {
+		// NB: lib/pq would send them to the server as run-time parameters
+		if !clientParams[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+
+	for i, k := range keys {
+		value := strings.ReplaceAll(strings.ReplaceAll(p[k], `\`, `\\`), `'`, `\'`)
+
+		if k == "database" {
+			k = "dbname"
+		}
+
+		pairs[i] = fmt.Sprintf("%s='%s'", k, value)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
 // defaultConnectionSettings returns PostgreSQL implicit default connection settings.
 func defaultConnectionSettings() ConnectionParams {
 	settings := ConnectionParams{
@@ -0,0 +1,51 @@
+package pg
+
+// ExecuteMessageType identifies ExecuteMessage message.
+const ExecuteMessageType = 'E'
+
+// ExecuteMessage is sent by a frontend to execute a portal.
+type ExecuteMessage struct {
+	// The name of the portal to execute (an empty string selects the unnamed portal).
+	Portal string
+
+	// Maximum number of rows to return, if portal contains a query that returns rows (ignored otherwise).
+	// Zero denotes "no limit".
+	MaxRows int32
+}
+
+// Compile time check to make sure that ExecuteMessage implements the Message interface.
+var _ Message = &ExecuteMessage{}
+
+// ParseExecuteMessage parses ExecuteMessage from a network frame.
+func ParseExecuteMessage(frame Frame) (*ExecuteMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != ExecuteMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	portal, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	maxRows, err := messageData.ReadInt32()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecuteMessage{Portal: portal, MaxRows: maxRows}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *ExecuteMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteString(m.Portal)
+	messageBuffer.WriteInt32(m.MaxRows)
+
+	return NewStandardFrame(ExecuteMessageType, messageBuffer)
+}
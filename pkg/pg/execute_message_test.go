@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Execute message packet of the unnamed portal without rows limit
+const GoldenExecuteMessagePacket = "\x45\x00\x00\x00\x09\x00\x00\x00\x00\x00"
+
+func TestParseExecuteMessage(t *testing.T) {
+	msg, err := ParseExecuteMessage(StandardFrame(GoldenExecuteMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", msg.Portal)
+	assert.Equal(t, int32(0), msg.MaxRows)
+
+	// Test invalid type
+	_, err = ParseExecuteMessage(append(StandardFrame{'X'}, GoldenExecuteMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+}
+
+func TestExecuteMessageFrame(t *testing.T) {
+	msg := &ExecuteMessage{}
+	assert.Equal(t, []byte(GoldenExecuteMessagePacket), msg.Frame().Bytes())
+
+	// Round trip with a rows limit
+	msg = &ExecuteMessage{Portal: "cursor", MaxRows: 100}
+	parsed, err := ParseExecuteMessage(msg.Frame())
+
+	assert.NoError(t, err)
+	assert.Equal(t, msg, parsed)
+}
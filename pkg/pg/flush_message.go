@@ -0,0 +1,30 @@
+package pg
+
+// FlushMessageType identifies FlushMessage message.
+const FlushMessageType = 'H'
+
+// FlushMessage is sent by a frontend to force the backend to deliver any data pending in its output buffers.
+type FlushMessage struct{}
+
+// Compile time check to make sure that FlushMessage implements the Message interface.
+var _ Message = &FlushMessage{}
+
+// ParseFlushMessage parses FlushMessage from a network frame.
+func ParseFlushMessage(frame Frame) (*FlushMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != FlushMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &FlushMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *FlushMessage) Frame() Frame {
+	return NewStandardFrame(FlushMessageType, nil)
+}
@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden flush message packet (the message has no body)
+const GoldenFlushMessagePacket = "\x48\x00\x00\x00\x04"
+
+func TestParseFlushMessage(t *testing.T) {
+	{
+		_, err := ParseFlushMessage(StandardFrame(GoldenFlushMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseFlushMessage(append(StandardFrame{'!'}, GoldenFlushMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseFlushMessage(NewStandardFrame(FlushMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestFlushMessageFrame(t *testing.T) {
+	msg := &FlushMessage{}
+	assert.Equal(t, []byte(GoldenFlushMessagePacket), msg.Frame().Bytes())
+}
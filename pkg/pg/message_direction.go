@@ -0,0 +1,171 @@
+package pg
+
+import (
+	"bytes"
+)
+
+// ParseInitialMessage parses the first message sent by a frontend over a new connection from a startup frame:
+// StartupMessage (including SSLRequest) or CancelRequestMessage.
+func ParseInitialMessage(frame Frame) (Message, error) {
+	messageData := ReadBuffer(frame.MessageBody())
+
+	code, err := messageData.ReadInt32()
+
+	if err != nil {
+		return nil, ErrMalformedMessage
+	}
+
+	if code == CancelRequestMagic {
+		return ParseCancelRequestMessage(frame)
+	}
+
+	return ParseStartupMessage(frame)
+}
+
+// ParseFrontendMessage parses a message sent by a frontend from a network frame.
+// Unknown messages are parsed as GenericMessage.
+func ParseFrontendMessage(frame Frame) (Message, error) {
+	switch frame.MessageType() {
+	case PasswordMessageType:
+		return parseAuthenticationResponse(frame)
+
+	case QueryMessageType:
+		return ParseQueryMessage(frame)
+
+	case ParseMessageType:
+		return ParseParseMessage(frame)
+
+	case BindMessageType:
+		return ParseBindMessage(frame)
+
+	case DescribeMessageType:
+		return ParseDescribeMessage(frame)
+
+	case ExecuteMessageType:
+		return ParseExecuteMessage(frame)
+
+	case CloseMessageType:
+		return ParseCloseMessage(frame)
+
+	case SyncMessageType:
+		return ParseSyncMessage(frame)
+
+	case FlushMessageType:
+		return ParseFlushMessage(frame)
+
+	case TerminateMessageType:
+		return ParseTerminateMessage(frame)
+
+	case CopyDataMessageType:
+		return ParseCopyDataMessage(frame)
+
+	case CopyDoneMessageType:
+		return ParseCopyDoneMessage(frame)
+
+	case CopyFailMessageType:
+		return ParseCopyFailMessage(frame)
+
+	default:
+		return ParseGenericMessage(frame)
+	}
+}
+
+// parseAuthenticationResponse parses PasswordMessage, SASLInitialResponse or SASLResponse from a network frame.
+// The messages share the same type byte, so they are told apart by the layout of the body: a password is
+// a single string, an initial response is a mechanism name followed by the length of the rest of the message,
+// and anything else is a SASL response.
+func parseAuthenticationResponse(frame Frame) (Message, error) {
+	body := frame.MessageBody()
+	end := bytes.IndexByte(body, 0)
+
+	switch {
+	case end >= 0 && end == len(body)-1:
+		return ParsePasswordMessage(frame)
+
+	case end >= 0:
+		// NB: the initial response is validated by the parser
+		if msg, err := ParseSASLInitialResponseMessage(frame); err == nil {
+			return msg, nil
+		}
+	}
+
+	return ParseSASLResponseMessage(frame)
+}
+
+// ParseBackendMessage parses a message sent by a backend from a network frame.
+// Unknown messages are parsed as GenericMessage.
+func ParseBackendMessage(frame Frame) (Message, error) {
+	switch frame.MessageType() {
+	case AuthenticationRequestMessageType:
+		return ParseAuthenticationRequestMessage(frame)
+
+	case NegotiateProtocolVersionMessageType:
+		return ParseNegotiateProtocolVersionMessage(frame)
+
+	case BackendKeyDataMessageType:
+		return ParseBackendKeyDataMessage(frame)
+
+	case ParameterStatusMessageType:
+		return ParseParameterStatusMessage(frame)
+
+	case ReadyForQueryMessageType:
+		return ParseReadyForQueryMessage(frame)
+
+	case RowDescriptionMessageType:
+		return ParseRowDescriptionMessage(frame)
+
+	case DataRowMessageType:
+		return ParseDataRowMessage(frame)
+
+	case CommandCompleteMessageType:
+		return ParseCommandCompleteMessage(frame)
+
+	case EmptyQueryResponseMessageType:
+		return ParseEmptyQueryResponseMessage(frame)
+
+	case ErrorResponseMessageType:
+		return ParseErrorResponseMessage(frame)
+
+	case NoticeResponseMessageType:
+		return ParseNoticeResponseMessage(frame)
+
+	case NotificationResponseMessageType:
+		return ParseNotificationResponseMessage(frame)
+
+	case ParameterDescriptionMessageType:
+		return ParseParameterDescriptionMessage(frame)
+
+	case NoDataMessageType:
+		return ParseNoDataMessage(frame)
+
+	case ParseCompleteMessageType:
+		return ParseParseCompleteMessage(frame)
+
+	case BindCompleteMessageType:
+		return ParseBindCompleteMessage(frame)
+
+	case CloseCompleteMessageType:
+		return ParseCloseCompleteMessage(frame)
+
+	case PortalSuspendedMessageType:
+		return ParsePortalSuspendedMessage(frame)
+
+	case CopyInResponseMessageType:
+		return ParseCopyInResponseMessage(frame)
+
+	case CopyOutResponseMessageType:
+		return ParseCopyOutResponseMessage(frame)
+
+	case CopyBothResponseMessageType:
+		return ParseCopyBothResponseMessage(frame)
+
+	case CopyDataMessageType:
+		return ParseCopyDataMessage(frame)
+
+	case CopyDoneMessageType:
+		return ParseCopyDoneMessage(frame)
+
+	default:
+		return ParseGenericMessage(frame)
+	}
+}
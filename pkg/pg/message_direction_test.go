@@ -0,0 +1,114 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInitialMessage(t *testing.T) {
+	testCases := []struct {
+		packet   string
+		expected Message
+	}{
+		{GoldenStartupMessagePacket, &StartupMessage{}},
+		{GoldenSSLRequestMessagePacket, &StartupMessage{}},
+		{GoldenCancelRequestMessagePacket, &CancelRequestMessage{}},
+	}
+
+	for _, tc := range testCases {
+		msg, err := ParseInitialMessage(StartupFrame(tc.packet))
+
+		require.NoError(t, err)
+		assert.IsType(t, tc.expected, msg)
+
+		// Round trip
+		assert.Equal(t, []byte(tc.packet), msg.Frame().Bytes())
+	}
+
+	_, err := ParseInitialMessage(StartupFrame("\x00\x00\x00\x04"))
+	assert.Equal(t, ErrMalformedMessage, err)
+}
+
+func TestParseFrontendMessage(t *testing.T) {
+	testCases := []struct {
+		packet   string
+		expected Message
+	}{
+		{GoldenPasswordMessagePacket, &PasswordMessage{}},
+		{GoldenSASLInitialResponseMessagePacket, &SASLInitialResponseMessage{}},
+		{GoldenEmptySASLInitialResponseMessagePacket, &SASLInitialResponseMessage{}},
+		{GoldenSASLResponseMessagePacket, &SASLResponseMessage{}},
+		{"p\x00\x00\x00\x05\x00", &PasswordMessage{}},
+		{"p\x00\x00\x00\x04", &SASLResponseMessage{}},
+		{GoldenQueryMesagePacket, &QueryMessage{}},
+		{GoldenParseMessagePacket, &ParseMessage{}},
+		{GoldenBindMessagePacket, &BindMessage{}},
+		{GoldenDescribeMessagePacket, &DescribeMessage{}},
+		{GoldenExecuteMessagePacket, &ExecuteMessage{}},
+		{GoldenCloseMessagePacket, &CloseMessage{}},
+		{GoldenSyncMessagePacket, &SyncMessage{}},
+		{GoldenFlushMessagePacket, &FlushMessage{}},
+		{GoldenTerminateMesagePacket, &TerminateMessage{}},
+		{GoldenCopyDataMessagePacket, &CopyDataMessage{}},
+		{GoldenCopyDoneMessagePacket, &CopyDoneMessage{}},
+		{GoldenCopyFailMessagePacket, &CopyFailMessage{}},
+		{"$\x00\x00\x00\x04", &GenericMessage{}},
+	}
+
+	for _, tc := range testCases {
+		msg, err := ParseFrontendMessage(StandardFrame(tc.packet))
+
+		require.NoError(t, err)
+		assert.IsType(t, tc.expected, msg)
+
+		// Round trip
+		assert.Equal(t, []byte(tc.packet), msg.Frame().Bytes())
+	}
+}
+
+func TestParseBackendMessage(t *testing.T) {
+	testCases := []struct {
+		packet   string
+		expected Message
+	}{
+		{GoldenAuthenticationMD5PasswordMessagePacket, &AuthenticationMD5PasswordMessage{}},
+		{GoldenAuthenticationSASLMessagePacket, &AuthenticationSASLMessage{}},
+		{GoldenAuthenticationSASLContinueMessagePacket, &AuthenticationSASLContinueMessage{}},
+		{GoldenAuthenticationSASLFinalMessagePacket, &AuthenticationSASLFinalMessage{}},
+		{GoldenNegotiateProtocolVersionMessagePacket, &NegotiateProtocolVersionMessage{}},
+		{GoldenBakendKeyDataMesagePacket, &BackendKeyDataMessage{}},
+		{GoldenParameterStatusMessagePacket, &ParameterStatusMessage{}},
+		{GoldenReadyForQueryMesagePacket, &ReadyForQueryMessage{}},
+		{GoldenErrorMessagePacket, &ErrorResponseMessage{}},
+		{GoldenNoticeMessagePacket, &NoticeResponseMessage{}},
+		{GoldenRowDescriptionMessagePacket, &RowDescriptionMessage{}},
+		{GoldenDataRowMesagePacket, &DataRowMessage{}},
+		{GoldenCommandCompleteMesagePacket, &CommandCompleteMessage{}},
+		{GoldenEmptyQueryResponseMesagePacket, &EmptyQueryResponseMessage{}},
+		{GoldenParameterDescriptionMessagePacket, &ParameterDescriptionMessage{}},
+		{GoldenNoDataMessagePacket, &NoDataMessage{}},
+		{GoldenParseCompleteMessagePacket, &ParseCompleteMessage{}},
+		{GoldenBindCompleteMessagePacket, &BindCompleteMessage{}},
+		{GoldenCloseCompleteMessagePacket, &CloseCompleteMessage{}},
+		{GoldenPortalSuspendedMessagePacket, &PortalSuspendedMessage{}},
+		{GoldenCopyInResponseMessagePacket, &CopyInResponseMessage{}},
+		{GoldenCopyOutResponseMessagePacket, &CopyOutResponseMessage{}},
+		{GoldenCopyBothResponseMessagePacket, &CopyBothResponseMessage{}},
+		{GoldenCopyDataMessagePacket, &CopyDataMessage{}},
+		{GoldenCopyDoneMessagePacket, &CopyDoneMessage{}},
+		{GoldenNotificationResponseMessagePacket, &NotificationResponseMessage{}},
+		{"$\x00\x00\x00\x04", &GenericMessage{}},
+	}
+
+	for _, tc := range testCases {
+		msg, err := ParseBackendMessage(StandardFrame(tc.packet))
+
+		require.NoError(t, err)
+		assert.IsType(t, tc.expected, msg)
+
+		// Round trip
+		assert.Equal(t, []byte(tc.packet), msg.Frame().Bytes())
+	}
+}
@@ -0,0 +1,30 @@
+package pg
+
+// NoDataMessageType identifies NoDataMessage message.
+const NoDataMessageType = 'n'
+
+// NoDataMessage is sent by a backend in response to Describe when the statement or portal returns no rows.
+type NoDataMessage struct{}
+
+// Compile time check to make sure that NoDataMessage implements the Message interface.
+var _ Message = &NoDataMessage{}
+
+// ParseNoDataMessage parses NoDataMessage from a network frame.
+func ParseNoDataMessage(frame Frame) (*NoDataMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != NoDataMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &NoDataMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *NoDataMessage) Frame() Frame {
+	return NewStandardFrame(NoDataMessageType, nil)
+}
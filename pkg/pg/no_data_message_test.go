@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden no data message packet (the message has no body)
+const GoldenNoDataMessagePacket = "\x6e\x00\x00\x00\x04"
+
+func TestParseNoDataMessage(t *testing.T) {
+	{
+		_, err := ParseNoDataMessage(StandardFrame(GoldenNoDataMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseNoDataMessage(append(StandardFrame{'!'}, GoldenNoDataMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseNoDataMessage(NewStandardFrame(NoDataMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestNoDataMessageFrame(t *testing.T) {
+	msg := &NoDataMessage{}
+	assert.Equal(t, []byte(GoldenNoDataMessagePacket), msg.Frame().Bytes())
+}
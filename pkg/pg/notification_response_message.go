@@ -0,0 +1,61 @@
+package pg
+
+// NotificationResponseMessageType identifies NotificationResponseMessage message.
+const NotificationResponseMessageType = 'A'
+
+// NotificationResponseMessage is sent by a backend to deliver a NOTIFY event to a frontend that has
+// executed LISTEN for the channel. It can arrive at any time, not only in response to a query.
+type NotificationResponseMessage struct {
+	// The process ID of the notifying backend.
+	ProcessID int32
+
+	// The name of the channel that the notify has been raised on.
+	Channel string
+
+	// The "payload" string passed from the notifying process.
+	Payload string
+}
+
+// Compile time check to make sure that NotificationResponseMessage implements the Message interface.
+var _ Message = &NotificationResponseMessage{}
+
+// ParseNotificationResponseMessage parses NotificationResponseMessage from a network frame.
+func ParseNotificationResponseMessage(frame Frame) (*NotificationResponseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != NotificationResponseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	processID, err := messageData.ReadInt32()
+
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationResponseMessage{ProcessID: processID, Channel: channel, Payload: payload}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *NotificationResponseMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteInt32(m.ProcessID)
+	messageBuffer.WriteString(m.Channel)
+	messageBuffer.WriteString(m.Payload)
+
+	return NewStandardFrame(NotificationResponseMessageType, messageBuffer)
+}
@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Notification raised with NOTIFY user_events, '{"email":"jane@example.com"}'
+//   PID: 28822
+const GoldenNotificationResponseMessagePacket = "\x41\x00\x00\x00\x31\x00\x00\x70\x96" +
+	"user_events\x00" + `{"email":"jane@example.com"}` + "\x00"
+
+func TestParseNotificationResponseMessage(t *testing.T) {
+	{
+		msg, err := ParseNotificationResponseMessage(StandardFrame(GoldenNotificationResponseMessagePacket))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(28822), msg.ProcessID)
+		assert.Equal(t, "user_events", msg.Channel)
+		assert.Equal(t, `{"email":"jane@example.com"}`, msg.Payload)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseNotificationResponseMessage(append(StandardFrame{'X'}, GoldenNotificationResponseMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestNotificationResponseMessageFrame(t *testing.T) {
+	msg := &NotificationResponseMessage{ProcessID: 28822, Channel: "user_events", Payload: `{"email":"jane@example.com"}`}
+	assert.Equal(t, []byte(GoldenNotificationResponseMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,64 @@
+package pg
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// ParameterDescriptionMessageType identifies ParameterDescriptionMessage message.
+const ParameterDescriptionMessageType = 't'
+
+// ParameterDescriptionMessage is sent by a backend to describe parameters of a prepared statement.
+type ParameterDescriptionMessage struct {
+	// Object IDs of the parameter data types.
+	ParameterTypes []oid.Oid
+}
+
+// Compile time check to make sure that ParameterDescriptionMessage implements the Message interface.
+var _ Message = &ParameterDescriptionMessage{}
+
+// ParseParameterDescriptionMessage parses ParameterDescriptionMessage from a network frame.
+func ParseParameterDescriptionMessage(frame Frame) (*ParameterDescriptionMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != ParameterDescriptionMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	typesCount, err := messageData.ReadInt16()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if typesCount < 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	types, err := messageData.ReadInt32Array(int(typesCount))
+
+	if err != nil {
+		return nil, err
+	}
+
+	parameterTypes := make([]oid.Oid, typesCount)
+
+	for i, typ := range types {
+		parameterTypes[i] = oid.Oid(typ)
+	}
+
+	return &ParameterDescriptionMessage{ParameterTypes: parameterTypes}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *ParameterDescriptionMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteInt16(int16(len(m.ParameterTypes)))
+
+	for _, typ := range m.ParameterTypes {
+		messageBuffer.WriteInt32(int32(typ))
+	}
+
+	return NewStandardFrame(ParameterDescriptionMessageType, messageBuffer)
+}
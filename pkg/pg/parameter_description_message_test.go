@@ -0,0 +1,28 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Parameter description message packet
+// SQL: SELECT * FROM users WHERE id = $1 AND email = $2
+const GoldenParameterDescriptionMessagePacket = "\x74\x00\x00\x00\x0e\x00\x02\x00\x00\x00\x17\x00\x00\x04\x13"
+
+func TestParseParameterDescriptionMessage(t *testing.T) {
+	msg, err := ParseParameterDescriptionMessage(StandardFrame(GoldenParameterDescriptionMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []oid.Oid{oid.T_int4, oid.T_varchar}, msg.ParameterTypes)
+
+	// Test invalid type
+	_, err = ParseParameterDescriptionMessage(append(StandardFrame{'X'}, GoldenParameterDescriptionMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+}
+
+func TestParameterDescriptionMessageFrame(t *testing.T) {
+	msg := &ParameterDescriptionMessage{ParameterTypes: []oid.Oid{oid.T_int4, oid.T_varchar}}
+	assert.Equal(t, []byte(GoldenParameterDescriptionMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,30 @@
+package pg
+
+// ParseCompleteMessageType identifies ParseCompleteMessage message.
+const ParseCompleteMessageType = '1'
+
+// ParseCompleteMessage is sent by a backend when Parse has been completed.
+type ParseCompleteMessage struct{}
+
+// Compile time check to make sure that ParseCompleteMessage implements the Message interface.
+var _ Message = &ParseCompleteMessage{}
+
+// ParseParseCompleteMessage parses ParseCompleteMessage from a network frame.
+func ParseParseCompleteMessage(frame Frame) (*ParseCompleteMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != ParseCompleteMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &ParseCompleteMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *ParseCompleteMessage) Frame() Frame {
+	return NewStandardFrame(ParseCompleteMessageType, nil)
+}
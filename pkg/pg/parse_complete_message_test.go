@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden parse complete message packet (the message has no body)
+const GoldenParseCompleteMessagePacket = "\x31\x00\x00\x00\x04"
+
+func TestParseParseCompleteMessage(t *testing.T) {
+	{
+		_, err := ParseParseCompleteMessage(StandardFrame(GoldenParseCompleteMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseParseCompleteMessage(append(StandardFrame{'!'}, GoldenParseCompleteMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseParseCompleteMessage(NewStandardFrame(ParseCompleteMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestParseCompleteMessageFrame(t *testing.T) {
+	msg := &ParseCompleteMessage{}
+	assert.Equal(t, []byte(GoldenParseCompleteMessagePacket), msg.Frame().Bytes())
+}
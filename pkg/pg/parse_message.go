@@ -0,0 +1,85 @@
+package pg
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// ParseMessageType identifies ParseMessage message.
+const ParseMessageType = 'P'
+
+// ParseMessage is sent by a frontend to create a prepared statement (extended query protocol).
+type ParseMessage struct {
+	// The name of the destination prepared statement (an empty string selects the unnamed prepared statement).
+	Name string
+
+	// The query string to be parsed.
+	Query string
+
+	// Object IDs of the parameter data types. Placing a zero here is equivalent to leaving the type unspecified.
+	// The number of parameter types specified can be less than the number of parameters in the query.
+	ParameterTypes []oid.Oid
+}
+
+// Compile time check to make sure that ParseMessage implements the Message interface.
+var _ Message = &ParseMessage{}
+
+// ParseParseMessage parses ParseMessage from a network frame.
+func ParseParseMessage(frame Frame) (*ParseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != ParseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	name, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	typesCount, err := messageData.ReadInt16()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if typesCount < 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	types, err := messageData.ReadInt32Array(int(typesCount))
+
+	if err != nil {
+		return nil, err
+	}
+
+	parameterTypes := make([]oid.Oid, typesCount)
+
+	for i, typ := range types {
+		parameterTypes[i] = oid.Oid(typ)
+	}
+
+	return &ParseMessage{Name: name, Query: query, ParameterTypes: parameterTypes}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *ParseMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteString(m.Name)
+	messageBuffer.WriteString(m.Query)
+	messageBuffer.WriteInt16(int16(len(m.ParameterTypes)))
+
+	for _, typ := range m.ParameterTypes {
+		messageBuffer.WriteInt32(int32(typ))
+	}
+
+	return NewStandardFrame(ParseMessageType, messageBuffer)
+}
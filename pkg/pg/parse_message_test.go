@@ -0,0 +1,42 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Parse message packet of an unnamed statement with the second parameter type left unspecified
+// SQL: SELECT name, email FROM users WHERE id = $1 AND company_id = $2
+const GoldenParseMessagePacket = "\x50\x00\x00\x00\x4f\x00\x53\x45\x4c\x45\x43\x54\x20\x6e\x61\x6d" +
+	"\x65\x2c\x20\x65\x6d\x61\x69\x6c\x20\x46\x52\x4f\x4d\x20\x75\x73" +
+	"\x65\x72\x73\x20\x57\x48\x45\x52\x45\x20\x69\x64\x20\x3d\x20\x24" +
+	"\x31\x20\x41\x4e\x44\x20\x63\x6f\x6d\x70\x61\x6e\x79\x5f\x69\x64" +
+	"\x20\x3d\x20\x24\x32\x00\x00\x02\x00\x00\x00\x17\x00\x00\x00\x00"
+
+func TestParseParseMessage(t *testing.T) {
+	msg, err := ParseParseMessage(StandardFrame(GoldenParseMessagePacket))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", msg.Name)
+	assert.Equal(t, "SELECT name, email FROM users WHERE id = $1 AND company_id = $2", msg.Query)
+	assert.Equal(t, []oid.Oid{oid.T_int4, 0}, msg.ParameterTypes)
+
+	// Test invalid type
+	_, err = ParseParseMessage(append(StandardFrame{'X'}, GoldenParseMessagePacket[1:]...))
+	assert.Equal(t, ErrMalformedMessage, err)
+
+	// Test truncated message
+	_, err = ParseParseMessage(NewStandardFrame(ParseMessageType, []byte("stmt\x00SELECT 1\x00\x00\x01")))
+	assert.Error(t, err)
+}
+
+func TestParseMessageFrame(t *testing.T) {
+	msg := &ParseMessage{
+		Query:          "SELECT name, email FROM users WHERE id = $1 AND company_id = $2",
+		ParameterTypes: []oid.Oid{oid.T_int4, 0},
+	}
+
+	assert.Equal(t, []byte(GoldenParseMessagePacket), msg.Frame().Bytes())
+}
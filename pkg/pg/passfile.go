@@ -0,0 +1,109 @@
+package pg
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lookupPassword finds the password for the host of the connection params in the password file: the passfile
+// param, PGPASSFILE (applied to the params already) or ~/.pgpass. It returns an empty string if there is no
+// matching entry or the file cannot be used.
+// See the documentation: https://www.postgresql.org/docs/current/libpq-pgpass.html
+func lookupPassword(params ConnectionParams) string {
+	path := params["passfile"]
+
+	if path == "" {
+		home, err := os.UserHomeDir()
+
+		if err != nil {
+			return ""
+		}
+
+		path = filepath.Join(home, ".pgpass")
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return ""
+	}
+
+	// NB: libpq ignores such files with a warning
+	if info.Mode().Perm()&0077 != 0 {
+		log.Warnf("pg: password file %s has group or world access; permissions should be u=rw (0600) or less", path)
+		return ""
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return ""
+	}
+
+	defer file.Close()
+
+	key := []string{params["host"], params["port"], params["database"], params["user"]}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// hostname:port:database:username:password
+		entry := splitPasswordFileLine(line)
+
+		if len(entry) != 5 {
+			continue
+		}
+
+		matches := true
+
+		for i, field := range entry[:4] {
+			if field != "*" && field != key[i] {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return entry[4]
+		}
+	}
+
+	return ""
+}
+
+// splitPasswordFileLine splits the line of the password file into fields separated by colons. A backslash
+// escapes the next character.
+func splitPasswordFileLine(line string) []string {
+	var (
+		fields []string
+		field  strings.Builder
+	)
+
+	runes := []rune(line)
+
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes):
+			i++
+			field.WriteRune(runes[i])
+
+		case runes[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+
+		default:
+			field.WriteRune(runes[i])
+		}
+	}
+
+	return append(fields, field.String())
+}
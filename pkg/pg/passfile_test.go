@@ -0,0 +1,48 @@
+package pg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupPassword(t *testing.T) {
+	defer withEnv(t, nil)()
+
+	path := filepath.Join(os.Getenv("HOME"), "passfile")
+	content := "localhost:5432:app:alice:first\n" +
+		"# comment\n" +
+		`db\:colon:*:*:bob:pass\:word\\` + "\n" +
+		"*:*:*:alice:wildcard\n" +
+		"invalid:line\n"
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	tests := []struct {
+		params   ConnectionParams
+		password string
+	}{
+		{ConnectionParams{"host": "localhost", "port": "5432", "database": "app", "user": "alice"}, "first"},
+		{ConnectionParams{"host": "localhost", "port": "5432", "database": "other", "user": "alice"}, "wildcard"},
+		{ConnectionParams{"host": "db:colon", "port": "5433", "database": "app", "user": "bob"}, `pass:word\`},
+		{ConnectionParams{"host": "localhost", "port": "5432", "database": "app", "user": "bob"}, ""},
+	}
+
+	for _, tt := range tests {
+		tt.params["passfile"] = path
+
+		assert.Equal(t, tt.password, lookupPassword(tt.params))
+	}
+
+	// Missing file
+	assert.Equal(t, "", lookupPassword(ConnectionParams{"passfile": path + ".missing", "host": "localhost"}))
+
+	// Files readable by others are ignored as in libpq
+	require.NoError(t, os.Chmod(path, 0644))
+
+	assert.Equal(t, "", lookupPassword(tests[0].params))
+}
@@ -0,0 +1,31 @@
+package pg
+
+// PortalSuspendedMessageType identifies PortalSuspendedMessage message.
+const PortalSuspendedMessageType = 's'
+
+// PortalSuspendedMessage is sent by a backend when Execute has reached the row limit before
+// the portal has been completed.
+type PortalSuspendedMessage struct{}
+
+// Compile time check to make sure that PortalSuspendedMessage implements the Message interface.
+var _ Message = &PortalSuspendedMessage{}
+
+// ParsePortalSuspendedMessage parses PortalSuspendedMessage from a network frame.
+func ParsePortalSuspendedMessage(frame Frame) (*PortalSuspendedMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != PortalSuspendedMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &PortalSuspendedMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *PortalSuspendedMessage) Frame() Frame {
+	return NewStandardFrame(PortalSuspendedMessageType, nil)
+}
@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden portal suspended message packet (the message has no body)
+const GoldenPortalSuspendedMessagePacket = "\x73\x00\x00\x00\x04"
+
+func TestParsePortalSuspendedMessage(t *testing.T) {
+	{
+		_, err := ParsePortalSuspendedMessage(StandardFrame(GoldenPortalSuspendedMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParsePortalSuspendedMessage(append(StandardFrame{'!'}, GoldenPortalSuspendedMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParsePortalSuspendedMessage(NewStandardFrame(PortalSuspendedMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestPortalSuspendedMessageFrame(t *testing.T) {
+	msg := &PortalSuspendedMessage{}
+	assert.Equal(t, []byte(GoldenPortalSuspendedMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,74 @@
+package pg
+
+// SASLInitialResponseMessageType identifies SASLInitialResponse message.
+// NB: PasswordMessage, SASLInitialResponse and SASLResponse share the same type byte.
+const SASLInitialResponseMessageType = 'p'
+
+// SASLInitialResponseMessage is sent by a frontend to start SASL authentication with the selected mechanism.
+type SASLInitialResponseMessage struct {
+	// Name of the SASL authentication mechanism that the client selected.
+	Mechanism string
+
+	// SASL mechanism specific "Initial Response"; nil if there is no initial response.
+	Data []byte
+}
+
+// Compile time check to make sure that SASLInitialResponseMessage implements the Message interface.
+var _ Message = &SASLInitialResponseMessage{}
+
+// ParseSASLInitialResponseMessage parses SASLInitialResponseMessage from a network frame.
+func ParseSASLInitialResponseMessage(frame Frame) (*SASLInitialResponseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != SASLInitialResponseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	messageData := ReadBuffer(frame.MessageBody())
+
+	mechanism, err := messageData.ReadString()
+
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := messageData.ReadInt32()
+
+	if err != nil {
+		return nil, err
+	}
+
+	message := &SASLInitialResponseMessage{Mechanism: mechanism}
+
+	// -1 indicates no initial response
+	if length == -1 {
+		return message, nil
+	}
+
+	if length < 0 || int(length) != messageData.Len() {
+		return nil, ErrMalformedMessage
+	}
+
+	message.Data, err = messageData.ReadBytes(int(length))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *SASLInitialResponseMessage) Frame() Frame {
+	var messageBuffer WriteBuffer
+
+	messageBuffer.WriteString(m.Mechanism)
+
+	if m.Data == nil {
+		messageBuffer.WriteInt32(-1)
+	} else {
+		messageBuffer.WriteInt32(int32(len(m.Data)))
+		messageBuffer.WriteBytes(m.Data)
+	}
+
+	return NewStandardFrame(SASLInitialResponseMessageType, messageBuffer)
+}
@@ -0,0 +1,60 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SCRAM-SHA-256 client-first-message from RFC 7677 with the PostgreSQL framing
+// Mechanism: SCRAM-SHA-256
+// Data: n,,n=,r=rOprNGfwEbeRWgbNEkqO
+const GoldenSASLInitialResponseMessagePacket = "\x70\x00\x00\x00\x32" + "SCRAM-SHA-256\x00" + "\x00\x00\x00\x1c" +
+	"n,,n=,r=rOprNGfwEbeRWgbNEkqO"
+
+// SASLInitialResponse without the initial response
+const GoldenEmptySASLInitialResponseMessagePacket = "\x70\x00\x00\x00\x16" + "SCRAM-SHA-256\x00" + "\xff\xff\xff\xff"
+
+func TestParseSASLInitialResponseMessage(t *testing.T) {
+	{
+		msg, err := ParseSASLInitialResponseMessage(StandardFrame(GoldenSASLInitialResponseMessagePacket))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SCRAM-SHA-256", msg.Mechanism)
+		assert.Equal(t, []byte("n,,n=,r=rOprNGfwEbeRWgbNEkqO"), msg.Data)
+	}
+
+	{
+		msg, err := ParseSASLInitialResponseMessage(StandardFrame(GoldenEmptySASLInitialResponseMessagePacket))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SCRAM-SHA-256", msg.Mechanism)
+		assert.Nil(t, msg.Data)
+	}
+
+	// Test invalid length
+	{
+		_, err := ParseSASLInitialResponseMessage(StandardFrame("\x70\x00\x00\x00\x17" + "SCRAM-SHA-256\x00" + "\x00\x00\x00\x02" + "n"))
+
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseSASLInitialResponseMessage(append(StandardFrame{'X'}, GoldenSASLInitialResponseMessagePacket[1:]...))
+
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestSASLInitialResponseMessageFrame(t *testing.T) {
+	{
+		msg := &SASLInitialResponseMessage{"SCRAM-SHA-256", []byte("n,,n=,r=rOprNGfwEbeRWgbNEkqO")}
+		assert.Equal(t, []byte(GoldenSASLInitialResponseMessagePacket), msg.Frame().Bytes())
+	}
+
+	{
+		msg := &SASLInitialResponseMessage{Mechanism: "SCRAM-SHA-256"}
+		assert.Equal(t, []byte(GoldenEmptySASLInitialResponseMessagePacket), msg.Frame().Bytes())
+	}
+}
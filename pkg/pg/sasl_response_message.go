@@ -0,0 +1,29 @@
+package pg
+
+// SASLResponseMessageType identifies SASLResponse message.
+// NB: PasswordMessage, SASLInitialResponse and SASLResponse share the same type byte.
+const SASLResponseMessageType = 'p'
+
+// SASLResponseMessage is sent by a frontend in response to a SASL challenge.
+type SASLResponseMessage struct {
+	// SASL mechanism specific message data.
+	Data []byte
+}
+
+// Compile time check to make sure that SASLResponseMessage implements the Message interface.
+var _ Message = &SASLResponseMessage{}
+
+// ParseSASLResponseMessage parses SASLResponseMessage from a network frame.
+func ParseSASLResponseMessage(frame Frame) (*SASLResponseMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != SASLResponseMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	return &SASLResponseMessage{Data: frame.MessageBody()}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *SASLResponseMessage) Frame() Frame {
+	return NewStandardFrame(SASLResponseMessageType, m.Data)
+}
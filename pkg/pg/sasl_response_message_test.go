@@ -0,0 +1,32 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SCRAM-SHA-256 client-final-message from RFC 7677 with the PostgreSQL framing
+const GoldenSASLResponseMessagePacket = "\x70\x00\x00\x00\x6e" +
+	"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+
+func TestParseSASLResponseMessage(t *testing.T) {
+	{
+		msg, err := ParseSASLResponseMessage(StandardFrame(GoldenSASLResponseMessagePacket))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(GoldenSASLResponseMessagePacket[5:]), msg.Data)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseSASLResponseMessage(append(StandardFrame{'X'}, GoldenSASLResponseMessagePacket[1:]...))
+
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestSASLResponseMessageFrame(t *testing.T) {
+	msg := &SASLResponseMessage{[]byte(GoldenSASLResponseMessagePacket[5:])}
+	assert.Equal(t, []byte(GoldenSASLResponseMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,108 @@
+package pg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory of the system-wide connection service file unless PGSYSCONFDIR is set
+const defaultSysConfDir = "/etc/postgresql-common"
+
+// lookupService returns the connection params of the service from the connection service file: PGSERVICEFILE
+// or ~/.pg_service.conf, then pg_service.conf in PGSYSCONFDIR.
+// See the documentation: https://www.postgresql.org/docs/current/libpq-pgservice.html
+func lookupService(name string) (ConnectionParams, error) {
+	var paths []string
+
+	if path := os.Getenv("PGSERVICEFILE"); path != "" {
+		paths = append(paths, path)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".pg_service.conf"))
+	}
+
+	sysConfDir := os.Getenv("PGSYSCONFDIR")
+
+	if sysConfDir == "" {
+		sysConfDir = defaultSysConfDir
+	}
+
+	paths = append(paths, filepath.Join(sysConfDir, "pg_service.conf"))
+
+	for _, path := range paths {
+		params, err := readServiceFile(path, name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if params != nil {
+			return params, nil
+		}
+	}
+
+	return nil, fmt.Errorf("definition of service %q not found", name)
+}
+
+// readServiceFile reads the params of the service from the INI-like file. It returns nil if the file
+// or the service doesn't exist.
+func readServiceFile(path, name string) (ConnectionParams, error) {
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var params ConnectionParams
+
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+
+		case strings.HasPrefix(line, "["):
+			// The service is over
+			if params != nil {
+				return params, nil
+			}
+
+			if strings.TrimSuffix(strings.TrimPrefix(line, "["), "]") == name {
+				params = make(ConnectionParams)
+			}
+
+		case params != nil:
+			i := strings.Index(line, "=")
+
+			if i < 0 {
+				return nil, fmt.Errorf("syntax error in service file %s, line %d", path, lineNumber)
+			}
+
+			keyword := strings.TrimSpace(line[:i])
+
+			if keyword == "service" {
+				return nil, fmt.Errorf("nested service specifications not supported in service file %s, line %d",
+					path, lineNumber)
+			}
+
+			params[paramName(keyword)] = strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
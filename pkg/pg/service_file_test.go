@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupService(t *testing.T) {
+	defer withEnv(t, nil)()
+
+	home := os.Getenv("HOME")
+	userFile := filepath.Join(home, "services.conf")
+	systemFile := filepath.Join(home, "pg_service.conf")
+
+	require.NoError(t, ioutil.WriteFile(userFile, []byte("[app]\nhost=user-db\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(systemFile, []byte("[app]\nhost=system-db\n[analytics]\ndbname=analytics\n"), 0600))
+	require.NoError(t, os.Setenv("PGSERVICEFILE", userFile))
+
+	// The user file takes precedence
+	params, err := lookupService("app")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectionParams{"host": "user-db"}, params)
+
+	params, err = lookupService("analytics")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectionParams{"database": "analytics"}, params)
+
+	_, err = lookupService("unknown")
+	assert.EqualError(t, err, `definition of service "unknown" not found`)
+
+	// Invalid files
+	require.NoError(t, ioutil.WriteFile(userFile, []byte("[app]\nhost\n"), 0600))
+
+	_, err = lookupService("app")
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(userFile, []byte("[app]\nservice=other\n"), 0600))
+
+	_, err = lookupService("app")
+	assert.Error(t, err)
+}
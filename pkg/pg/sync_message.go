@@ -0,0 +1,31 @@
+package pg
+
+// SyncMessageType identifies SyncMessage message.
+const SyncMessageType = 'S'
+
+// SyncMessage is sent by a frontend to close the current extended query transaction step.
+// The backend responds with ReadyForQuery.
+type SyncMessage struct{}
+
+// Compile time check to make sure that SyncMessage implements the Message interface.
+var _ Message = &SyncMessage{}
+
+// ParseSyncMessage parses SyncMessage from a network frame.
+func ParseSyncMessage(frame Frame) (*SyncMessage, error) {
+	// Assert the message type
+	if frame.MessageType() != SyncMessageType {
+		return nil, ErrMalformedMessage
+	}
+
+	// Just in case assert that there is no message body
+	if len(frame.MessageBody()) > 0 {
+		return nil, ErrMalformedMessage
+	}
+
+	return &SyncMessage{}, nil
+}
+
+// Frame serializes the message into a network frame.
+func (m *SyncMessage) Frame() Frame {
+	return NewStandardFrame(SyncMessageType, nil)
+}
@@ -0,0 +1,34 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Golden sync message packet (the message has no body)
+const GoldenSyncMessagePacket = "\x53\x00\x00\x00\x04"
+
+func TestParseSyncMessage(t *testing.T) {
+	{
+		_, err := ParseSyncMessage(StandardFrame(GoldenSyncMessagePacket))
+		assert.NoError(t, err)
+	}
+
+	// Test invalid type
+	{
+		_, err := ParseSyncMessage(append(StandardFrame{'!'}, GoldenSyncMessagePacket[1:]...))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+
+	// Test unexpected body
+	{
+		_, err := ParseSyncMessage(NewStandardFrame(SyncMessageType, []byte{0}))
+		assert.Equal(t, ErrMalformedMessage, err)
+	}
+}
+
+func TestSyncMessageFrame(t *testing.T) {
+	msg := &SyncMessage{}
+	assert.Equal(t, []byte(GoldenSyncMessagePacket), msg.Frame().Bytes())
+}
@@ -0,0 +1,90 @@
+package pgmeta
+
+import (
+	"github.com/lib/pq/oid"
+)
+
+// ColumnCatalog is a snapshot of the database columns metadata.
+// Use Inspector.ColumnCatalog to load it. ColumnCatalog is read-only and can be used concurrently.
+type ColumnCatalog struct {
+	// All columns keyed by table OID and attribute number
+	columns map[ColumnID]*Column
+
+	// Columns of every table ordered by attribute number
+	tables map[oid.Oid][]*Column
+
+	// Table OIDs keyed by table name
+	tableOIDs map[Table]oid.Oid
+}
+
+// NewColumnCatalog initializes a ColumnCatalog from the given list of columns keyed by table OIDs.
+// It is useful in tests; use Inspector.ColumnCatalog to load the catalog from a database.
+func NewColumnCatalog(columns map[oid.Oid][]*Column) *ColumnCatalog {
+	catalog := &ColumnCatalog{columns: make(map[ColumnID]*Column), tableOIDs: make(map[Table]oid.Oid)}
+
+	for tableOID, tableColumns := range columns {
+		for _, column := range tableColumns {
+			catalog.add(tableOID, column)
+		}
+	}
+
+	return catalog
+}
+
+// Lookup returns the column identified by the table OID and the attribute number (as in RowDescription).
+// The second returned value is false when the column is unknown.
+func (c *ColumnCatalog) Lookup(tableOID oid.Oid, index int16) (*Column, bool) {
+	column, ok := c.columns[ColumnID{TableOID: tableOID, Index: index}]
+	return column, ok
+}
+
+// LookupTable returns OID of the table with the given name. The second returned value is false
+// when the table is unknown.
+func (c *ColumnCatalog) LookupTable(table Table) (oid.Oid, bool) {
+	tableOID, ok := c.tableOIDs[table]
+	return tableOID, ok
+}
+
+// TableColumns returns live (i.e., not dropped) columns of the table in the attribute number order, which is
+// the order of `SELECT *` and `COPY table TO` output. It returns nil if the table is unknown.
+func (c *ColumnCatalog) TableColumns(tableOID oid.Oid) []*Column {
+	var columns []*Column
+
+	for _, column := range c.tables[tableOID] {
+		if !column.Dropped {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// Len returns the number of columns in the catalog including dropped ones.
+func (c *ColumnCatalog) Len() int {
+	return len(c.columns)
+}
+
+// add adds a new column to the catalog, keeping table columns sorted.
+func (c *ColumnCatalog) add(tableOID oid.Oid, column *Column) {
+	c.columns[ColumnID{TableOID: tableOID, Index: column.Index}] = column
+	c.tableOIDs[column.Table] = tableOID
+
+	if c.tables == nil {
+		c.tables = make(map[oid.Oid][]*Column)
+	}
+
+	tableColumns := c.tables[tableOID]
+
+	// Insertion sort by attribute number; columns normally arrive already sorted
+	i := len(tableColumns)
+
+	for i > 0 && tableColumns[i-1].Index > column.Index {
+		i--
+	}
+
+	tableColumns = append(tableColumns, nil)
+	copy(tableColumns[i+1:], tableColumns[i:])
+	tableColumns[i] = column
+
+	c.tables[tableOID] = tableColumns
+}
@@ -0,0 +1,47 @@
+package pgmeta
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnCatalog(t *testing.T) {
+	users := Table{"public", "users"}
+
+	id := &Column{Table: users, Name: "id", Index: 1, TypeOID: oid.T_int4}
+	dropped := &Column{Table: users, Name: "........pg.dropped.2........", Index: 2, Dropped: true}
+	email := &Column{Table: users, Name: "email", Index: 3, TypeOID: oid.T_varchar}
+
+	// NB: columns are not sorted
+	catalog := NewColumnCatalog(map[oid.Oid][]*Column{
+		42: {email, id, dropped},
+	})
+
+	assert.Equal(t, 3, catalog.Len())
+
+	column, ok := catalog.Lookup(42, 3)
+	assert.True(t, ok)
+	assert.Same(t, email, column)
+
+	column, ok = catalog.Lookup(42, 2)
+	assert.True(t, ok)
+	assert.Same(t, dropped, column)
+
+	_, ok = catalog.Lookup(42, 4)
+	assert.False(t, ok)
+
+	_, ok = catalog.Lookup(43, 1)
+	assert.False(t, ok)
+
+	tableOID, ok := catalog.LookupTable(users)
+	assert.True(t, ok)
+	assert.Equal(t, oid.Oid(42), tableOID)
+
+	_, ok = catalog.LookupTable(Table{"public", "companies"})
+	assert.False(t, ok)
+
+	assert.Equal(t, []*Column{id, email}, catalog.TableColumns(42))
+	assert.Nil(t, catalog.TableColumns(43))
+}
@@ -15,6 +15,41 @@ type Table struct {
 	Name   string
 }
 
+// Column represents a table column.
+type Column struct {
+	// Table that the column belongs to.
+	Table Table
+
+	// Column name.
+	Name string
+
+	// Column attribute number (pg_attribute.attnum); numbering starts from 1.
+	Index int16
+
+	// The object ID of the column's data type; zero for dropped columns.
+	TypeOID oid.Oid
+
+	// The column's data type name (pg_type.typname), e.g. "varchar".
+	TypeName string
+
+	// The type modifier (pg_attribute.atttypmod), e.g. maximum length of a varchar column plus 4.
+	TypeModifier int32
+
+	// True when the column has NOT NULL constraint.
+	NotNull bool
+
+	// True when the column has been dropped. PostgreSQL keeps dropped columns in the catalog so that
+	// attribute numbers of other columns remain the same.
+	Dropped bool
+}
+
+// ColumnID identifies a table column the same way RowDescription does: by the table OID and
+// the column attribute number (pg_attribute.attnum).
+type ColumnID struct {
+	TableOID oid.Oid
+	Index    int16
+}
+
 // Inspector allows getting meta-information about PostgreSQL database.
 type Inspector struct {
 	db *sql.DB
@@ -61,7 +96,7 @@ func (i *Inspector) OIDTableMapping() (map[oid.Oid]Table, error) {
         JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
        WHERE n.nspname NOT IN ('information_schema')
          AND n.nspname NOT LIKE 'pg_%'
-		 AND c.relkind IN ('r', 'm', 'v');`,
+		 AND c.relkind IN ('r', 'p', 'f', 'm', 'v');`,
 	)
 
 	if err != nil {
@@ -89,6 +124,66 @@ func (i *Inspector) OIDTableMapping() (map[oid.Oid]Table, error) {
 	return mapping, nil
 }
 
+// ColumnCatalog returns all columns of the database tables including dropped ones.
+func (i *Inspector) ColumnCatalog() (*ColumnCatalog, error) {
+	rows, err := i.db.Query(`
+      SELECT c.oid AS oid
+           , c.relname AS table
+           , n.nspname AS schema
+           , a.attnum AS index
+           , a.attname AS column
+           , a.atttypid AS type_oid
+           , COALESCE(t.typname, '') AS type_name
+           , a.atttypmod AS type_modifier
+           , a.attnotnull AS not_null
+           , a.attisdropped AS dropped
+        FROM pg_attribute a
+        JOIN pg_class c ON c.oid = a.attrelid
+        JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+   LEFT JOIN pg_type t ON t.oid = a.atttypid
+       WHERE n.nspname NOT IN ('information_schema')
+         AND n.nspname NOT LIKE 'pg_%'
+         AND c.relkind IN ('r', 'p', 'f', 'm', 'v')
+         AND a.attnum > 0
+    ORDER BY c.oid, a.attnum;`,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	catalog := &ColumnCatalog{columns: make(map[ColumnID]*Column), tableOIDs: make(map[Table]oid.Oid)}
+
+	for rows.Next() {
+		var tableOID oid.Oid
+
+		column := &Column{}
+
+		err := rows.Scan(
+			&tableOID,
+			&column.Table.Name,
+			&column.Table.Schema,
+			&column.Index,
+			&column.Name,
+			&column.TypeOID,
+			&column.TypeName,
+			&column.TypeModifier,
+			&column.NotNull,
+			&column.Dropped,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		catalog.add(tableOID, column)
+	}
+
+	return catalog, rows.Err()
+}
+
 // Close closes database connection.
 func (i *Inspector) Close() error {
 	return i.db.Close()
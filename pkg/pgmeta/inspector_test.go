@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/lib/pq/oid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -61,9 +62,83 @@ func TestInspectorOIDTableMapping(t *testing.T) {
 	assert.ElementsMatch(t, tables, []Table{
 		{"public", "companies"},
 		{"public", "users"},
+		{"public", "events"},
+		{"public", "events_2020"},
 	})
 }
 
+func TestInspectorColumnCatalog(t *testing.T) {
+	inspector, err := Inspect(databaseURL)
+	require.NoError(t, err)
+
+	defer inspector.Close()
+
+	catalog, err := inspector.ColumnCatalog()
+	require.NoError(t, err)
+
+	tables, err := inspector.OIDTableMapping()
+	require.NoError(t, err)
+
+	// Find tables OIDs by names
+	tableOIDs := make(map[string]oid.Oid)
+
+	for tableOID, table := range tables {
+		tableOIDs[table.Name] = tableOID
+	}
+
+	companies := Table{"public", "companies"}
+	users := Table{"public", "users"}
+
+	assert.Equal(t, []*Column{
+		{Table: companies, Name: "id", Index: 1, TypeOID: oid.T_int4, TypeName: "int4", TypeModifier: -1, NotNull: true},
+		{Table: companies, Name: "name", Index: 2, TypeOID: oid.T_varchar, TypeName: "varchar", TypeModifier: 259, NotNull: true},
+	}, catalog.TableColumns(tableOIDs["companies"]))
+
+	// NB: the dropped column is not returned
+	assert.Equal(t, []*Column{
+		{Table: users, Name: "id", Index: 1, TypeOID: oid.T_int4, TypeName: "int4", TypeModifier: -1, NotNull: true},
+		{Table: users, Name: "company_id", Index: 2, TypeOID: oid.T_int4, TypeName: "int4", TypeModifier: -1, NotNull: true},
+		{Table: users, Name: "name", Index: 4, TypeOID: oid.T_varchar, TypeName: "varchar", TypeModifier: 259, NotNull: true},
+		{Table: users, Name: "email", Index: 5, TypeOID: oid.T_varchar, TypeName: "varchar", TypeModifier: 259, NotNull: true},
+	}, catalog.TableColumns(tableOIDs["users"]))
+
+	// Dropped column is still in the catalog
+	column, ok := catalog.Lookup(tableOIDs["users"], 3)
+
+	if assert.True(t, ok) {
+		assert.True(t, column.Dropped)
+		assert.Equal(t, oid.Oid(0), column.TypeOID)
+	}
+
+	// Unknown column
+	_, ok = catalog.Lookup(tableOIDs["users"], 42)
+	assert.False(t, ok)
+}
+
+func TestInspectorColumnCatalogPartitionedTable(t *testing.T) {
+	inspector, err := Inspect(databaseURL)
+	require.NoError(t, err)
+
+	defer inspector.Close()
+
+	catalog, err := inspector.ColumnCatalog()
+	require.NoError(t, err)
+
+	// NB: the parent of the partitions is what queries see in RowDescription
+	for _, name := range []string{"events", "events_2020"} {
+		table := Table{"public", name}
+
+		tableOID, ok := catalog.LookupTable(table)
+		require.True(t, ok, name)
+
+		assert.Equal(t, []*Column{
+			{Table: table, Name: "id", Index: 1, TypeOID: oid.T_int4, TypeName: "int4", TypeModifier: -1, NotNull: true},
+			{Table: table, Name: "created_at", Index: 2, TypeOID: oid.T_date, TypeName: "date", TypeModifier: -1, NotNull: true},
+			{Table: table, Name: "payload", Index: 3, TypeOID: oid.T_text, TypeName: "text", TypeModifier: -1},
+		}, catalog.TableColumns(tableOID), name)
+	}
+}
+
 func TestInspectorClose(t *testing.T) {
 	inspector, err := Inspect(databaseURL)
 	require.NoError(t, err)
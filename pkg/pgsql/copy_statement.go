@@ -0,0 +1,387 @@
+package pgsql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CopyFormat is a data format of the COPY command.
+type CopyFormat string
+
+const (
+	CopyFormatText   CopyFormat = "text"
+	CopyFormatCSV    CopyFormat = "csv"
+	CopyFormatBinary CopyFormat = "binary"
+)
+
+// CopyStatement is a parsed COPY ... TO STDOUT statement.
+type CopyStatement struct {
+	// Source table; empty if the source is a query
+	Schema string
+	Table  string
+
+	// Copied columns; empty means all columns of the table
+	Columns []string
+
+	// Source query of COPY (query) TO STDOUT
+	Query string
+
+	// Data format and its options
+	Format    CopyFormat
+	Delimiter byte
+	Null      string
+	Header    bool
+	Quote     byte
+	Escape    byte
+}
+
+// ParseCopyStatement parses a COPY ... TO STDOUT statement. Both the current option syntax
+// (WITH (FORMAT csv, HEADER)) and the pre-9.0 one (WITH CSV HEADER) are supported.
+func ParseCopyStatement(statement *Statement) (*CopyStatement, error) {
+	p := &copyParser{statement: statement, options: make(map[string][]Token)}
+
+	return p.parse()
+}
+
+// copyParser parses COPY statements.
+type copyParser struct {
+	statement *Statement
+	pos       int
+
+	// Values of the options keyed by lower case option name
+	options map[string][]Token
+}
+
+// parse parses the statement.
+func (p *copyParser) parse() (*CopyStatement, error) {
+	if !p.accept("copy") {
+		return nil, fmt.Errorf("pgsql: not a COPY statement")
+	}
+
+	stmt := &CopyStatement{Format: CopyFormatText}
+
+	// Pre-7.3 syntax: COPY BINARY table
+	if p.accept("binary") {
+		p.options["format"] = []Token{{Kind: TokenIdentifier, Value: "binary"}}
+	}
+
+	err := p.source(stmt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.accept("to") || !p.accept("stdout") {
+		return nil, fmt.Errorf("pgsql: not a COPY TO STDOUT statement")
+	}
+
+	p.accept("with")
+
+	if p.accept("(") {
+		err = p.optionList()
+	} else {
+		err = p.legacyOptionList()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.statement.Tokens) {
+		return nil, p.unexpected()
+	}
+
+	err = stmt.setOptions(p.options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// source parses the copied table with an optional column list or a query in parentheses.
+func (p *copyParser) source(stmt *CopyStatement) error {
+	if p.peek().Is("(") {
+		start := p.peek().End
+		end, ok := p.closingParen()
+
+		if !ok {
+			return fmt.Errorf("pgsql: unterminated COPY query")
+		}
+
+		stmt.Query = strings.TrimSpace(p.statement.SQL[start:p.statement.Tokens[end].Start])
+		p.pos = end + 1
+
+		return nil
+	}
+
+	p.accept("only")
+
+	name, ok := p.name()
+
+	if !ok {
+		return p.unexpected()
+	}
+
+	stmt.Table = name
+
+	if p.accept(".") {
+		stmt.Schema = name
+
+		if stmt.Table, ok = p.name(); !ok {
+			return p.unexpected()
+		}
+	}
+
+	if !p.accept("(") {
+		return nil
+	}
+
+	for {
+		column, ok := p.name()
+
+		if !ok {
+			return p.unexpected()
+		}
+
+		stmt.Columns = append(stmt.Columns, column)
+
+		if p.accept(")") {
+			return nil
+		}
+
+		if !p.accept(",") {
+			return p.unexpected()
+		}
+	}
+}
+
+// optionList parses the parenthesized option list: (FORMAT csv, HEADER, DELIMITER ';').
+func (p *copyParser) optionList() error {
+	for {
+		name, ok := p.name()
+
+		if !ok {
+			return p.unexpected()
+		}
+
+		// Option value is everything up to the next comma
+		var value []Token
+
+		for depth := 0; p.pos < len(p.statement.Tokens); p.pos++ {
+			token := p.peek()
+
+			if depth == 0 && (token.Is(",") || token.Is(")")) {
+				break
+			}
+
+			if token.Is("(") {
+				depth++
+			} else if token.Is(")") {
+				depth--
+			}
+
+			value = append(value, token)
+		}
+
+		p.options[name] = value
+
+		if p.accept(")") {
+			return nil
+		}
+
+		if !p.accept(",") {
+			return p.unexpected()
+		}
+	}
+}
+
+// legacyOptionList parses options in the pre-9.0 syntax: BINARY, DELIMITER [AS] 'c', NULL [AS] 's', CSV,
+// HEADER, QUOTE [AS] 'q', ESCAPE [AS] 'e', FORCE QUOTE columns and ENCODING 'name'.
+func (p *copyParser) legacyOptionList() error {
+	for p.pos < len(p.statement.Tokens) {
+		name, ok := p.name()
+
+		if !ok {
+			return p.unexpected()
+		}
+
+		switch name {
+		case "binary", "csv":
+			p.options["format"] = []Token{{Kind: TokenIdentifier, Value: name}}
+
+		case "header":
+			p.options[name] = nil
+
+		case "oids":
+
+		case "delimiter", "null", "quote", "escape", "encoding":
+			p.accept("as")
+
+			if p.peek().Kind != TokenString {
+				return p.unexpected()
+			}
+
+			p.options[name] = []Token{p.next()}
+
+		case "force":
+			// FORCE QUOTE only affects quoting of the output
+			p.accept("quote")
+
+			if p.accept("*") {
+				continue
+			}
+
+			for {
+				if _, ok := p.name(); !ok {
+					return p.unexpected()
+				}
+
+				if !p.accept(",") {
+					break
+				}
+			}
+
+		default:
+			return fmt.Errorf("pgsql: unknown COPY option %q", name)
+		}
+	}
+
+	return nil
+}
+
+// setOptions sets the format and its options from the option values; options that have not been set
+// get the default values of the format.
+func (stmt *CopyStatement) setOptions(options map[string][]Token) error {
+	if value, ok := options["format"]; ok {
+		switch format := CopyFormat(strings.ToLower(optionString(value))); format {
+		case CopyFormatText, CopyFormatCSV, CopyFormatBinary:
+			stmt.Format = format
+
+		default:
+			return fmt.Errorf("pgsql: unknown COPY format %q", optionString(value))
+		}
+	}
+
+	if value, ok := options["header"]; ok {
+		// HEADER, HEADER true, HEADER 1 or HEADER match
+		switch strings.ToLower(optionString(value)) {
+		case "false", "off", "0":
+		default:
+			stmt.Header = true
+		}
+	}
+
+	stmt.Delimiter, stmt.Null = '\t', `\N`
+
+	if stmt.Format == CopyFormatCSV {
+		stmt.Delimiter, stmt.Null, stmt.Quote = ',', "", '"'
+	}
+
+	for name, target := range map[string]*byte{"delimiter": &stmt.Delimiter, "quote": &stmt.Quote, "escape": &stmt.Escape} {
+		value, ok := options[name]
+
+		if !ok {
+			continue
+		}
+
+		if len(value) != 1 || value[0].Kind != TokenString || len(value[0].Value) != 1 {
+			return fmt.Errorf("pgsql: COPY %s must be a single one-byte character", name)
+		}
+
+		*target = value[0].Value[0]
+	}
+
+	if stmt.Format == CopyFormatCSV && stmt.Escape == 0 {
+		stmt.Escape = stmt.Quote
+	}
+
+	if value, ok := options["null"]; ok {
+		if len(value) != 1 || value[0].Kind != TokenString {
+			return fmt.Errorf("pgsql: COPY null must be a string")
+		}
+
+		stmt.Null = value[0].Value
+	}
+
+	// Other options (e.g. ENCODING or FORCE_QUOTE) don't affect parsing of the data
+	return nil
+}
+
+// optionString returns the value of a single token option or an empty string.
+func optionString(value []Token) string {
+	if len(value) != 1 {
+		return ""
+	}
+
+	return value[0].Value
+}
+
+// peek returns the current token or an empty token at the end of the statement.
+func (p *copyParser) peek() Token {
+	if p.pos >= len(p.statement.Tokens) {
+		return Token{Kind: -1}
+	}
+
+	return p.statement.Tokens[p.pos]
+}
+
+// next returns the current token and moves to the next one.
+func (p *copyParser) next() Token {
+	token := p.peek()
+	p.pos++
+
+	return token
+}
+
+// accept moves to the next token if the current one is the given keyword or operator.
+func (p *copyParser) accept(value string) bool {
+	if p.peek().Is(value) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// name parses an identifier.
+func (p *copyParser) name() (string, bool) {
+	name, ok := p.peek().Name()
+
+	if ok {
+		p.pos++
+	}
+
+	return name, ok
+}
+
+// closingParen returns index of the parenthesis closing the current one.
+func (p *copyParser) closingParen() (int, bool) {
+	depth := 0
+
+	for i := p.pos; i < len(p.statement.Tokens); i++ {
+		switch token := p.statement.Tokens[i]; {
+		case token.Is("("):
+			depth++
+
+		case token.Is(")"):
+			depth--
+
+			if depth == 0 {
+				return i, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// unexpected returns an error about the current token.
+func (p *copyParser) unexpected() error {
+	if p.pos >= len(p.statement.Tokens) {
+		return fmt.Errorf("pgsql: unexpected end of COPY statement")
+	}
+
+	return fmt.Errorf("pgsql: unexpected %q in COPY statement", p.peek().Value)
+}
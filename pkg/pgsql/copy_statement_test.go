@@ -0,0 +1,66 @@
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseCopy parses a single COPY statement.
+func parseCopy(sql string) (*CopyStatement, error) {
+	return ParseCopyStatement(SplitStatements(sql)[0])
+}
+
+func TestParseCopyStatement(t *testing.T) {
+	testCases := map[string]*CopyStatement{
+		// psql's \copy users to 'users.txt'
+		"COPY  users TO STDOUT": {
+			Table: "users", Format: CopyFormatText, Delimiter: '\t', Null: `\N`,
+		},
+		`copy billing."Cards" (id, "Number") to stdout with (format csv, header, delimiter ';', null 'NULL')`: {
+			Schema: "billing", Table: "Cards", Columns: []string{"id", "Number"},
+			Format: CopyFormatCSV, Delimiter: ';', Null: "NULL", Header: true, Quote: '"', Escape: '"',
+		},
+		"COPY users TO STDOUT (FORMAT binary)": {
+			Table: "users", Format: CopyFormatBinary, Delimiter: '\t', Null: `\N`,
+		},
+		"COPY users TO STDOUT (FORMAT 'csv', HEADER false, QUOTE '''', ESCAPE '\\', FORCE_QUOTE (email))": {
+			Table: "users", Format: CopyFormatCSV, Delimiter: ',', Quote: '\'', Escape: '\\',
+		},
+		"COPY (SELECT email FROM users WHERE (id > 1)) TO STDOUT WITH CSV HEADER": {
+			Query: "SELECT email FROM users WHERE (id > 1)", Format: CopyFormatCSV, Delimiter: ',', Header: true,
+			Quote: '"', Escape: '"',
+		},
+		"COPY users TO STDOUT WITH DELIMITER AS '|' NULL AS '' CSV QUOTE AS '\"' FORCE QUOTE *": {
+			Table: "users", Format: CopyFormatCSV, Delimiter: '|', Quote: '"', Escape: '"',
+		},
+		"COPY BINARY users TO STDOUT": {
+			Table: "users", Format: CopyFormatBinary, Delimiter: '\t', Null: `\N`,
+		},
+	}
+
+	for sql, expected := range testCases {
+		stmt, err := parseCopy(sql)
+		require.NoError(t, err, sql)
+
+		assert.Equal(t, expected, stmt, sql)
+	}
+}
+
+func TestParseCopyStatementErrors(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT 1",
+		"COPY users FROM STDIN",
+		"COPY users TO '/tmp/users.txt'",
+		"COPY users (id TO STDOUT",
+		"COPY (SELECT 1 TO STDOUT",
+		"COPY users TO STDOUT (FORMAT xml)",
+		"COPY users TO STDOUT (DELIMITER ';;')",
+		"COPY users TO STDOUT WITH UNKNOWN",
+		"COPY users TO STDOUT (FORMAT csv) extra",
+	} {
+		_, err := parseCopy(sql)
+		assert.Error(t, err, sql)
+	}
+}
@@ -0,0 +1,293 @@
+package pgsql
+
+import (
+	"strings"
+)
+
+// TokenKind identifies the kind of a SQL token.
+type TokenKind int
+
+const (
+	// TokenIdentifier is an unquoted identifier or a keyword; the value is lower-cased.
+	TokenIdentifier TokenKind = iota
+
+	// TokenQuotedIdentifier is a double-quoted identifier; the value is unquoted.
+	TokenQuotedIdentifier
+
+	// TokenString is a string constant ('...', E'...' or $$...$$); the value is unquoted and unescaped.
+	TokenString
+
+	// TokenNumber is a numeric constant.
+	TokenNumber
+
+	// TokenParameter is a positional parameter ($1).
+	TokenParameter
+
+	// TokenOperator is an operator or a punctuation character like "(", "," or ";".
+	TokenOperator
+)
+
+// Token is a lexical token of a SQL query.
+type Token struct {
+	Kind TokenKind
+
+	// Token value (see TokenKind for the details)
+	Value string
+
+	// Byte offsets of the token in the query
+	Start int
+	End   int
+}
+
+// Is returns true if the token is the given keyword (in lower case) or operator.
+func (t Token) Is(value string) bool {
+	return (t.Kind == TokenIdentifier || t.Kind == TokenOperator) && t.Value == value
+}
+
+// Name returns the object name denoted by an identifier token. The second value is false for other tokens.
+func (t Token) Name() (string, bool) {
+	return t.Value, t.Kind == TokenIdentifier || t.Kind == TokenQuotedIdentifier
+}
+
+// Tokenize splits a SQL query into tokens skipping whitespace and comments. Tokenize never fails:
+// unterminated quotes and comments run until the end of the query.
+func Tokenize(sql string) []Token {
+	var tokens []Token
+
+	for pos := 0; pos < len(sql); {
+		c := sql[pos]
+
+		switch {
+		case isSpace(c):
+			pos++
+			continue
+
+		case strings.HasPrefix(sql[pos:], "--"):
+			end := strings.IndexByte(sql[pos:], '\n')
+
+			if end < 0 {
+				return tokens
+			}
+
+			pos += end + 1
+
+			continue
+
+		case strings.HasPrefix(sql[pos:], "/*"):
+			pos = skipBlockComment(sql, pos)
+			continue
+		}
+
+		token := Token{Start: pos}
+
+		switch {
+		case c == '\'':
+			token.Kind = TokenString
+			token.Value, pos = scanQuoted(sql, pos, '\'', false)
+
+		case (c == 'e' || c == 'E') && pos+1 < len(sql) && sql[pos+1] == '\'':
+			token.Kind = TokenString
+			token.Value, pos = scanQuoted(sql, pos+1, '\'', true)
+
+		case (c == 'b' || c == 'B' || c == 'x' || c == 'X' || c == 'n' || c == 'N') && pos+1 < len(sql) && sql[pos+1] == '\'':
+			// Bit strings and national character strings
+			token.Kind = TokenString
+			token.Value, pos = scanQuoted(sql, pos+1, '\'', false)
+
+		case c == '"':
+			token.Kind = TokenQuotedIdentifier
+			token.Value, pos = scanQuoted(sql, pos, '"', false)
+
+		case c == '$':
+			token.Kind, token.Value, pos = scanDollar(sql, pos)
+
+		case isIdentifierStart(c):
+			end := pos + 1
+
+			for end < len(sql) && isIdentifierPart(sql[end]) {
+				end++
+			}
+
+			token.Kind, token.Value, pos = TokenIdentifier, strings.ToLower(sql[pos:end]), end
+
+		case isDigit(c) || (c == '.' && pos+1 < len(sql) && isDigit(sql[pos+1])):
+			end := scanNumber(sql, pos)
+			token.Kind, token.Value, pos = TokenNumber, sql[pos:end], end
+
+		case isOperatorChar(c):
+			end := pos + 1
+
+			for end < len(sql) && isOperatorChar(sql[end]) && !strings.HasPrefix(sql[end:], "--") &&
+				!strings.HasPrefix(sql[end:], "/*") {
+				end++
+			}
+
+			token.Kind, token.Value, pos = TokenOperator, sql[pos:end], end
+
+		default:
+			// Punctuation: ( ) [ ] , ; : .
+			token.Kind, token.Value, pos = TokenOperator, sql[pos:pos+1], pos+1
+		}
+
+		token.End = pos
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+// skipBlockComment returns the position after a (possibly nested) block comment starting at pos.
+func skipBlockComment(sql string, pos int) int {
+	depth := 0
+
+	for pos < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[pos:], "/*"):
+			depth++
+			pos += 2
+
+		case strings.HasPrefix(sql[pos:], "*/"):
+			depth--
+			pos += 2
+
+			if depth == 0 {
+				return pos
+			}
+
+		default:
+			pos++
+		}
+	}
+
+	return pos
+}
+
+// scanQuoted scans a quoted string or identifier starting at pos. Doubled quotes stand for a quote;
+// backslash escapes are processed if escapes is true. It returns the unquoted value and the end position.
+func scanQuoted(sql string, pos int, quote byte, escapes bool) (string, int) {
+	var sb strings.Builder
+
+	for pos++; pos < len(sql); pos++ {
+		c := sql[pos]
+
+		switch {
+		case c == quote && pos+1 < len(sql) && sql[pos+1] == quote:
+			sb.WriteByte(quote)
+			pos++
+
+		case c == quote:
+			return sb.String(), pos + 1
+
+		case c == '\\' && escapes && pos+1 < len(sql):
+			pos++
+			sb.WriteByte(unescapeChar(sql[pos]))
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String(), pos
+}
+
+// unescapeChar returns the character denoted by a backslash escape sequence.
+func unescapeChar(c byte) byte {
+	switch c {
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	default:
+		return c
+	}
+}
+
+// scanDollar scans a positional parameter ($1) or a dollar-quoted string ($$...$$ or $tag$...$tag$).
+func scanDollar(sql string, pos int) (TokenKind, string, int) {
+	end := pos + 1
+
+	// Positional parameter
+	if end < len(sql) && isDigit(sql[end]) {
+		for end < len(sql) && isDigit(sql[end]) {
+			end++
+		}
+
+		return TokenParameter, sql[pos:end], end
+	}
+
+	for end < len(sql) && sql[end] != '$' && isIdentifierPart(sql[end]) {
+		end++
+	}
+
+	// Not a dollar quote (e.g. a stray dollar sign)
+	if end >= len(sql) || sql[end] != '$' {
+		return TokenOperator, "$", pos + 1
+	}
+
+	tag := sql[pos : end+1]
+	body := end + 1
+	closing := strings.Index(sql[body:], tag)
+
+	if closing < 0 {
+		return TokenString, sql[body:], len(sql)
+	}
+
+	return TokenString, sql[body : body+closing], body + closing + len(tag)
+}
+
+// scanNumber returns the end position of a numeric constant starting at pos.
+func scanNumber(sql string, pos int) int {
+	for pos < len(sql) && (isDigit(sql[pos]) || sql[pos] == '.' || sql[pos] == '_') {
+		pos++
+	}
+
+	// Exponent
+	if pos < len(sql) && (sql[pos] == 'e' || sql[pos] == 'E') {
+		exp := pos + 1
+
+		if exp < len(sql) && (sql[exp] == '+' || sql[exp] == '-') {
+			exp++
+		}
+
+		if exp < len(sql) && isDigit(sql[exp]) {
+			pos = exp
+
+			for pos < len(sql) && isDigit(sql[pos]) {
+				pos++
+			}
+		}
+	}
+
+	return pos
+}
+
+// isSpace returns true for whitespace characters.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// isDigit returns true for decimal digits.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentifierStart returns true for characters that can start an unquoted identifier.
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// isIdentifierPart returns true for characters that can continue an unquoted identifier.
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c) || c == '$'
+}
+
+// isOperatorChar returns true for characters of operators.
+func isOperatorChar(c byte) bool {
+	return strings.IndexByte("+-*/<>=~!@#%^&|`?", c) >= 0
+}
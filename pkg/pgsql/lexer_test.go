@@ -0,0 +1,76 @@
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenValues returns kinds and values of the tokens.
+func tokenValues(tokens []Token) []Token {
+	values := make([]Token, len(tokens))
+
+	for i, token := range tokens {
+		values[i] = Token{Kind: token.Kind, Value: token.Value}
+	}
+
+	return values
+}
+
+func TestTokenize(t *testing.T) {
+	testCases := map[string][]Token{
+		`SELECT "Email", e.name FROM users e WHERE id = $1`: {
+			{Kind: TokenIdentifier, Value: "select"},
+			{Kind: TokenQuotedIdentifier, Value: "Email"},
+			{Kind: TokenOperator, Value: ","},
+			{Kind: TokenIdentifier, Value: "e"},
+			{Kind: TokenOperator, Value: "."},
+			{Kind: TokenIdentifier, Value: "name"},
+			{Kind: TokenIdentifier, Value: "from"},
+			{Kind: TokenIdentifier, Value: "users"},
+			{Kind: TokenIdentifier, Value: "e"},
+			{Kind: TokenIdentifier, Value: "where"},
+			{Kind: TokenIdentifier, Value: "id"},
+			{Kind: TokenOperator, Value: "="},
+			{Kind: TokenParameter, Value: "$1"},
+		},
+		`'it''s' E'a\tb\'' $$x;y$$ $fn$ $$ $fn$ B'101'`: {
+			{Kind: TokenString, Value: "it's"},
+			{Kind: TokenString, Value: "a\tb'"},
+			{Kind: TokenString, Value: "x;y"},
+			{Kind: TokenString, Value: " $$ "},
+			{Kind: TokenString, Value: "101"},
+		},
+		"1 -- comment ;\n+ /* nested /* comment */ ; */ 2.5e-3 <> .5": {
+			{Kind: TokenNumber, Value: "1"},
+			{Kind: TokenOperator, Value: "+"},
+			{Kind: TokenNumber, Value: "2.5e-3"},
+			{Kind: TokenOperator, Value: "<>"},
+			{Kind: TokenNumber, Value: ".5"},
+		},
+		`"a""b" 'unterminated`: {
+			{Kind: TokenQuotedIdentifier, Value: `a"b`},
+			{Kind: TokenString, Value: "unterminated"},
+		},
+	}
+
+	for sql, expected := range testCases {
+		assert.Equal(t, expected, tokenValues(Tokenize(sql)), sql)
+	}
+}
+
+func TestTokenizeOffsets(t *testing.T) {
+	sql := "SELECT 'a' ;"
+	tokens := Tokenize(sql)
+
+	assert.Equal(t, "SELECT", sql[tokens[0].Start:tokens[0].End])
+	assert.Equal(t, "'a'", sql[tokens[1].Start:tokens[1].End])
+	assert.Equal(t, ";", sql[tokens[2].Start:tokens[2].End])
+}
+
+func TestTokenIs(t *testing.T) {
+	assert.True(t, Token{Kind: TokenIdentifier, Value: "copy"}.Is("copy"))
+	assert.True(t, Token{Kind: TokenOperator, Value: "("}.Is("("))
+	assert.False(t, Token{Kind: TokenQuotedIdentifier, Value: "copy"}.Is("copy"))
+	assert.False(t, Token{Kind: TokenString, Value: "copy"}.Is("copy"))
+}
@@ -0,0 +1,66 @@
+package pgsql
+
+import (
+	"strings"
+)
+
+// Functions that modify the database or the session state even when called from SELECT
+var writingFunctions = map[string]bool{
+	"nextval":                 true,
+	"setval":                  true,
+	"currval":                 true,
+	"lastval":                 true,
+	"set_config":              true,
+	"pg_notify":               true,
+	"txid_current":            true,
+	"pg_current_xact_id":      true,
+	"pg_advisory_lock":        true,
+	"pg_advisory_xact_lock":   true,
+	"pg_try_advisory_lock":    true,
+	"pg_advisory_unlock":      true,
+	"pg_advisory_unlock_all":  true,
+	"pg_cancel_backend":       true,
+	"pg_terminate_backend":    true,
+	"pg_reload_conf":          true,
+	"pg_switch_wal":           true,
+	"pg_create_restore_point": true,
+	"dblink_exec":             true,
+}
+
+// IsReadOnlySelect returns true if the statement is a SELECT (possibly with WITH) that can be executed on a read-only
+// replica: it doesn't write with data-modifying CTEs or SELECT INTO, doesn't lock rows and doesn't call functions
+// that are known to modify the state. Side effects of user-defined functions cannot be detected.
+func (s *Statement) IsReadOnlySelect() bool {
+	if len(s.Tokens) == 0 || !s.Tokens[0].Is("select") && !s.Tokens[0].Is("with") {
+		return false
+	}
+
+	for i, token := range s.Tokens {
+		var next Token
+
+		if i+1 < len(s.Tokens) {
+			next = s.Tokens[i+1]
+		}
+
+		switch {
+		// Data-modifying CTEs and SELECT INTO
+		case token.Is("insert") || token.Is("update") || token.Is("delete") || token.Is("merge") || token.Is("into"):
+			return false
+
+		// FOR SHARE and FOR KEY SHARE lock rows (FOR UPDATE and FOR NO KEY UPDATE are caught above)
+		case token.Is("for") && (next.Is("share") || next.Is("no") || next.Is("key")):
+			return false
+		}
+
+		if name, ok := token.Name(); ok && next.Is("(") && isWritingFunction(strings.ToLower(name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isWritingFunction returns true if the function is known to modify the database or the session state.
+func isWritingFunction(name string) bool {
+	return writingFunctions[name] || strings.HasPrefix(name, "lo_")
+}
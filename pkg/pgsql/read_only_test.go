@@ -0,0 +1,38 @@
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementIsReadOnlySelect(t *testing.T) {
+	testCases := []struct {
+		sql      string
+		readOnly bool
+	}{
+		{"SELECT id, email FROM users WHERE id = $1", true},
+		{"select 'insert into users', \"update\" from events", true},
+		{"WITH recent AS (SELECT * FROM events) SELECT count(*) FROM recent", true},
+		{"SELECT * FROM users FOR UPDATE", false},
+		{"SELECT * FROM users FOR NO KEY UPDATE", false},
+		{"SELECT * FROM users FOR SHARE", false},
+		{"SELECT * INTO backup FROM users", false},
+		{"WITH deleted AS (DELETE FROM users RETURNING *) SELECT * FROM deleted", false},
+		{"SELECT nextval('users_id_seq')", false},
+		{"SELECT pg_catalog.set_config('search_path', '', false)", false},
+		{"SELECT lo_import('/etc/passwd')", false},
+		{"SELECT pg_advisory_lock(1)", false},
+		{"INSERT INTO users VALUES (1)", false},
+		{"BEGIN", false},
+		{"VALUES (1)", false},
+	}
+
+	for _, tc := range testCases {
+		statements := SplitStatements(tc.sql)
+
+		if assert.Len(t, statements, 1, tc.sql) {
+			assert.Equal(t, tc.readOnly, statements[0].IsReadOnlySelect(), tc.sql)
+		}
+	}
+}
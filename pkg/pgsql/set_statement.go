@@ -0,0 +1,88 @@
+package pgsql
+
+import (
+	"strings"
+)
+
+// Parameters of SET and RESET that are named with keywords rather than with the parameter name
+var settingAliases = map[string]string{
+	"schema": "search_path",
+	"names":  "client_encoding",
+	"time":   "timezone",
+}
+
+// SessionSetting is a change of a session parameter made with SET or RESET.
+type SessionSetting struct {
+	// Parameter name in lower case; "all" for RESET ALL. Multi-word forms are named after their words
+	// (e.g. "session authorization").
+	Name string
+
+	// The parameter is reset to its default value (RESET or SET ... TO DEFAULT)
+	Reset bool
+}
+
+// SessionSetting returns the session parameter changed by the statement. The second value is false if
+// the statement is not SET or RESET of a session parameter: e.g. SET LOCAL, SET TRANSACTION and SET CONSTRAINTS
+// only last until the end of the transaction.
+func (s *Statement) SessionSetting() (*SessionSetting, bool) {
+	if len(s.Tokens) < 2 {
+		return nil, false
+	}
+
+	reset := s.Tokens[0].Is("reset")
+
+	if !reset && !s.Tokens[0].Is("set") {
+		return nil, false
+	}
+
+	tokens := s.Tokens[1:]
+
+	if tokens[0].Is("local") {
+		return nil, false
+	}
+
+	// SESSION is the default scope of SET, unless it starts SESSION AUTHORIZATION or SESSION CHARACTERISTICS
+	if tokens[0].Is("session") && len(tokens) > 1 && !tokens[1].Is("authorization") && !tokens[1].Is("characteristics") {
+		tokens = tokens[1:]
+	}
+
+	switch {
+	case tokens[0].Is("transaction") || tokens[0].Is("constraints"):
+		return nil, false
+
+	case tokens[0].Is("session") && len(tokens) > 1:
+		return &SessionSetting{Name: "session " + tokens[1].Value, Reset: reset}, true
+	}
+
+	if name, ok := settingAliases[tokens[0].Value]; ok && tokens[0].Kind == TokenIdentifier {
+		value := tokens[1:]
+
+		if tokens[0].Is("time") && len(value) > 0 && value[0].Is("zone") {
+			value = value[1:]
+		}
+
+		return &SessionSetting{Name: name, Reset: reset || isDefaultValue(value)}, true
+	}
+
+	name, ok := tokens[0].Name()
+
+	if !ok {
+		return nil, false
+	}
+
+	// Parameter names may be qualified (e.g. "app.user_id")
+	for tokens = tokens[1:]; len(tokens) > 1 && tokens[0].Is("."); tokens = tokens[2:] {
+		name += "." + tokens[1].Value
+	}
+
+	if len(tokens) > 0 && (tokens[0].Is("to") || tokens[0].Is("=")) {
+		tokens = tokens[1:]
+	}
+
+	return &SessionSetting{Name: strings.ToLower(name), Reset: reset || isDefaultValue(tokens)}, true
+}
+
+// isDefaultValue returns true if the value tokens of SET are the DEFAULT keyword.
+func isDefaultValue(tokens []Token) bool {
+	return len(tokens) == 1 && tokens[0].Is("default")
+}
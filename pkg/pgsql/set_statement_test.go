@@ -0,0 +1,43 @@
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementSessionSetting(t *testing.T) {
+	testCases := []struct {
+		sql     string
+		setting *SessionSetting
+	}{
+		{"SET search_path TO billing, public", &SessionSetting{Name: "search_path"}},
+		{"set SESSION Search_Path = 'billing'", &SessionSetting{Name: "search_path"}},
+		{"SET search_path TO DEFAULT", &SessionSetting{Name: "search_path", Reset: true}},
+		{"SET app.user_id = 42", &SessionSetting{Name: "app.user_id"}},
+		{"SET SCHEMA 'billing'", &SessionSetting{Name: "search_path"}},
+		{"SET TIME ZONE 'UTC'", &SessionSetting{Name: "timezone"}},
+		{"SET TIME ZONE DEFAULT", &SessionSetting{Name: "timezone", Reset: true}},
+		{"SET NAMES 'UTF8'", &SessionSetting{Name: "client_encoding"}},
+		{"SET ROLE analyst", &SessionSetting{Name: "role"}},
+		{"SET SESSION AUTHORIZATION alice", &SessionSetting{Name: "session authorization"}},
+		{"SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY", &SessionSetting{Name: "session characteristics"}},
+		{"RESET statement_timeout", &SessionSetting{Name: "statement_timeout", Reset: true}},
+		{"RESET ALL", &SessionSetting{Name: "all", Reset: true}},
+		{"SET LOCAL search_path TO billing", nil},
+		{"SET TRANSACTION ISOLATION LEVEL SERIALIZABLE", nil},
+		{"SET CONSTRAINTS ALL DEFERRED", nil},
+		{"SELECT set_config('search_path', 'billing', false)", nil},
+	}
+
+	for _, tc := range testCases {
+		statements := SplitStatements(tc.sql)
+
+		if assert.Len(t, statements, 1, tc.sql) {
+			setting, ok := statements[0].SessionSetting()
+
+			assert.Equal(t, tc.setting != nil, ok, tc.sql)
+			assert.Equal(t, tc.setting, setting, tc.sql)
+		}
+	}
+}
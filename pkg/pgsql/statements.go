@@ -0,0 +1,63 @@
+package pgsql
+
+import (
+	"strings"
+)
+
+// Statement is a single statement of a SQL query.
+type Statement struct {
+	// Statement text without the terminating semicolon
+	SQL string
+
+	// Statement tokens; offsets are relative to the statement text
+	Tokens []Token
+}
+
+// SplitStatements splits a query (e.g. of a simple Query message) into statements separated by semicolons.
+// Empty statements are skipped, so the result matches the statements that PostgreSQL executes.
+func SplitStatements(sql string) []*Statement {
+	var (
+		statements []*Statement
+		tokens     []Token
+		depth      int
+	)
+
+	flush := func(end int) {
+		if len(tokens) > 0 {
+			offset := tokens[0].Start
+			statement := &Statement{SQL: strings.TrimSpace(sql[offset:end]), Tokens: make([]Token, len(tokens))}
+
+			for i, token := range tokens {
+				token.Start -= offset
+				token.End -= offset
+				statement.Tokens[i] = token
+			}
+
+			statements = append(statements, statement)
+		}
+
+		tokens = nil
+	}
+
+	for _, token := range Tokenize(sql) {
+		switch {
+		case token.Is("(") || token.Is("["):
+			depth++
+
+		case token.Is(")") || token.Is("]"):
+			if depth > 0 {
+				depth--
+			}
+
+		case token.Is(";") && depth == 0:
+			flush(token.Start)
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	flush(len(sql))
+
+	return statements
+}
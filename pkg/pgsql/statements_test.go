@@ -0,0 +1,29 @@
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	statements := SplitStatements("SELECT ';' ; ; CREATE FUNCTION f() AS $$ SELECT 1; $$ LANGUAGE sql;\n-- done\nCOPY users TO STDOUT")
+
+	var sqls []string
+
+	for _, statement := range statements {
+		sqls = append(sqls, statement.SQL)
+	}
+
+	assert.Equal(t, []string{
+		"SELECT ';'",
+		"CREATE FUNCTION f() AS $$ SELECT 1; $$ LANGUAGE sql",
+		"COPY users TO STDOUT",
+	}, sqls)
+
+	// Token offsets are relative to the statement
+	last := statements[2]
+	assert.Equal(t, "users", last.SQL[last.Tokens[1].Start:last.Tokens[1].End])
+
+	assert.Empty(t, SplitStatements(" ; -- nothing"))
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hired/gevulot/pkg/auth"
+)
+
+// Client authentication methods.
+const (
+	// AuthMethodSCRAM authenticates clients with SCRAM-SHA-256.
+	AuthMethodSCRAM = "scram-sha-256"
+
+	// AuthMethodMD5 authenticates clients with MD5 hashed passwords. Users with SCRAM secrets are authenticated
+	// with SCRAM-SHA-256 as PostgreSQL does.
+	AuthMethodMD5 = "md5"
+)
+
+// AuthConfig configures authentication of clients by Gevulot itself. When it's set, clients authenticate with
+// their own credentials and Gevulot logs into the database with the credentials from the database URL, so
+// clients never know the real database password.
+type AuthConfig struct {
+	// Authentication method: "scram-sha-256" (default) or "md5".
+	Method string
+
+	// Users declared in the config.
+	Users []*auth.User `toml:"user"`
+
+	// Path to the users file in the PgBouncer userlist.txt format (optional).
+	UsersFile string `toml:"users-file"`
+
+	// Users read from the users file by Load
+	fileUsers []*auth.User
+}
+
+// Load resolves the users file path relative to the given directory (i.e., the directory of the config file),
+// reads the users and validates the config.
+func (c *AuthConfig) Load(dir string) error {
+	if c == nil {
+		return nil
+	}
+
+	if c.UsersFile != "" {
+		if !filepath.IsAbs(c.UsersFile) {
+			c.UsersFile = filepath.Join(dir, c.UsersFile)
+		}
+
+		var err error
+
+		c.fileUsers, err = auth.ReadUsersFile(c.UsersFile)
+
+		if err != nil {
+			return fmt.Errorf("server: can't read users file: %w", err)
+		}
+	}
+
+	return c.Validate()
+}
+
+// Files returns path of the users file.
+func (c *AuthConfig) Files() []string {
+	if c == nil || c.UsersFile == "" {
+		return nil
+	}
+
+	return []string{c.UsersFile}
+}
+
+// Validate checks the authentication method and the users.
+func (c *AuthConfig) Validate() error {
+	switch c.Method {
+	case "", AuthMethodSCRAM, AuthMethodMD5:
+
+	default:
+		return fmt.Errorf("server: unknown auth method %q", c.Method)
+	}
+
+	for _, user := range append(append([]*auth.User{}, c.Users...), c.fileUsers...) {
+		if user.Name == "" {
+			return fmt.Errorf("server: auth user without name")
+		}
+
+		if auth.IsSCRAMSecret(user.Password) {
+			_, err := auth.ParseSCRAMSecret(user.Password)
+
+			if err != nil {
+				return fmt.Errorf("server: auth user %s: %w", user.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// password returns password (or its hash) of the user with the given name. Users declared in the config
+// take precedence over the users file.
+func (c *AuthConfig) password(name string) (string, bool) {
+	for _, users := range [][]*auth.User{c.Users, c.fileUsers} {
+		for _, user := range users {
+			if user.Name == name {
+				return user.Password, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/auth"
+)
+
+func TestAuthConfigLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "users.txt"), []byte(`"alice" "file"`+"\n"+`"bob" "file"`), 0600))
+
+	config := &AuthConfig{Users: []*auth.User{{Name: "alice", Password: "config"}}, UsersFile: "users.txt"}
+	require.NoError(t, config.Load(dir))
+
+	assert.Equal(t, []string{filepath.Join(dir, "users.txt")}, config.Files())
+
+	// Users declared in the config take precedence
+	password, ok := config.password("alice")
+	assert.True(t, ok)
+	assert.Equal(t, "config", password)
+
+	password, ok = config.password("bob")
+	assert.True(t, ok)
+	assert.Equal(t, "file", password)
+
+	_, ok = config.password("mallory")
+	assert.False(t, ok)
+
+	// Invalid configs
+	for _, invalid := range []*AuthConfig{
+		{UsersFile: "missing.txt"},
+		{Method: "trust"},
+		{Users: []*auth.User{{Password: "secret"}}},
+		{Users: []*auth.User{{Name: "alice", Password: "SCRAM-SHA-256$4096:salt"}}},
+	} {
+		assert.Error(t, invalid.Load(dir))
+	}
+
+	// Not configured
+	var nilConfig *AuthConfig
+
+	assert.NoError(t, nilConfig.Load(dir))
+	assert.Empty(t, nilConfig.Files())
+}
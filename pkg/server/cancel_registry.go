@@ -0,0 +1,73 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// cancelRegistry maps the secret-key data that Gevulot issues to clients in place of the database's
+// BackendKeyData onto the sessions, so that CancelRequest can be forwarded to the right database backend
+// without exposing the real keys to clients.
+type cancelRegistry struct {
+	// Guards sessions
+	mu sync.Mutex
+
+	// Sessions keyed by the issued key data
+	sessions map[pg.BackendKeyDataMessage]*Session
+}
+
+// newCancelRegistry initializes a new cancelRegistry.
+func newCancelRegistry() *cancelRegistry {
+	return &cancelRegistry{sessions: make(map[pg.BackendKeyDataMessage]*Session)}
+}
+
+// register issues random unique key data for the session.
+func (r *cancelRegistry) register(s *Session) (*pg.BackendKeyDataMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		var buf [8]byte
+
+		_, err := rand.Read(buf[:])
+
+		if err != nil {
+			return nil, err
+		}
+
+		// NB: process IDs are positive
+		key := pg.BackendKeyDataMessage{
+			ProcessID: int32(binary.BigEndian.Uint32(buf[:4]) & 0x7fffffff),
+			Key:       int32(binary.BigEndian.Uint32(buf[4:])),
+		}
+
+		if _, ok := r.sessions[key]; ok || key.ProcessID == 0 {
+			continue
+		}
+
+		r.sessions[key] = s
+
+		return &key, nil
+	}
+}
+
+// lookup returns the session the key data has been issued for.
+func (r *cancelRegistry) lookup(processID, key int32) (*Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[pg.BackendKeyDataMessage{ProcessID: processID, Key: key}]
+
+	return s, ok
+}
+
+// unregister forgets the issued key data.
+func (r *cancelRegistry) unregister(key *pg.BackendKeyDataMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, *key)
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lib/pq/oid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/masking"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgmeta"
+)
+
+// Minimal interval between column catalog reloads caused by unknown columns.
+const columnCatalogReloadInterval = 5 * time.Second
+
+// columnCatalog resolves RowDescription fields to the table columns of the proxied database.
+// Column metadata is loaded lazily and reloaded when the database URL changes in the config or
+// when an unknown column is requested (e.g., a table has been created after the last load).
+type columnCatalog struct {
+	// Guards following
+	mu sync.Mutex
+
+	// Configuration provider
+	cfg ConfigStore
+
+	// Name of the database in Config.Databases; empty for the database from Config.DatabaseURL
+	database string
+
+	// Database URL the columns were loaded from
+	databaseURL string
+
+	// Loaded columns
+	catalog *pgmeta.ColumnCatalog
+
+	// Time of the last load
+	loadedAt time.Time
+
+	// The last load has failed
+	loadFailed bool
+}
+
+// Compile time check to make sure that columnCatalog implements the masking.FallibleColumnResolver interface.
+var _ masking.FallibleColumnResolver = &columnCatalog{}
+
+// newColumnCatalog initializes a new columnCatalog of the database with the given name in Config.Databases
+// (empty for the database from Config.DatabaseURL).
+func newColumnCatalog(cfg ConfigStore, database string) *columnCatalog {
+	return &columnCatalog{cfg: cfg, database: database}
+}
+
+// columnCatalogs keeps column catalogs of all proxied databases.
+type columnCatalogs struct {
+	// Guards catalogs
+	mu sync.Mutex
+
+	// Configuration provider
+	cfg ConfigStore
+
+	// Catalogs keyed by the database name in Config.Databases
+	catalogs map[string]*columnCatalog
+}
+
+// newColumnCatalogs initializes a new columnCatalogs.
+func newColumnCatalogs(cfg ConfigStore) *columnCatalogs {
+	return &columnCatalogs{cfg: cfg, catalogs: make(map[string]*columnCatalog)}
+}
+
+// resolver returns the column catalog of the database with the given name in Config.Databases
+// (empty for the database from Config.DatabaseURL).
+func (c *columnCatalogs) resolver(database string) masking.ColumnResolver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	catalog, ok := c.catalogs[database]
+
+	if !ok {
+		catalog = newColumnCatalog(c.cfg, database)
+		c.catalogs[database] = catalog
+	}
+
+	return catalog
+}
+
+// ResolveColumn returns the column identified by the table OID and the column attribute number.
+func (c *columnCatalog) ResolveColumn(tableOID oid.Oid, index int16) (*pgmeta.Column, bool) {
+	var column *pgmeta.Column
+
+	found := c.lookup(func(catalog *pgmeta.ColumnCatalog) bool {
+		var ok bool
+		column, ok = catalog.Lookup(tableOID, index)
+
+		return ok && !column.Dropped
+	})
+
+	return column, found
+}
+
+// ResolveTable returns live columns of the table in the attribute number order.
+func (c *columnCatalog) ResolveTable(table pgmeta.Table) ([]*pgmeta.Column, bool) {
+	var columns []*pgmeta.Column
+
+	found := c.lookup(func(catalog *pgmeta.ColumnCatalog) bool {
+		tableOID, ok := catalog.LookupTable(table)
+
+		if ok {
+			columns = catalog.TableColumns(tableOID)
+		}
+
+		return ok
+	})
+
+	return columns, found
+}
+
+// LoadFailed returns true if the last load of the columns has failed; unknown columns may be masked then.
+func (c *columnCatalog) LoadFailed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.loadFailed
+}
+
+// lookup runs the lookup function against the loaded catalog. If it fails (e.g. the column is unknown),
+// the catalog is reloaded and the lookup is retried.
+func (c *columnCatalog) lookup(fn func(catalog *pgmeta.ColumnCatalog) bool) bool {
+	config, err := c.cfg.Get()
+
+	if err != nil {
+		return false
+	}
+
+	databaseURL, ok := config.databaseURL(c.database)
+
+	// Database has been removed from the config
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Database has changed — drop everything we know
+	if c.databaseURL != databaseURL {
+		c.databaseURL = databaseURL
+		c.catalog = nil
+		c.loadedAt = time.Time{}
+		c.loadFailed = false
+	}
+
+	if c.catalog != nil && fn(c.catalog) {
+		return true
+	}
+
+	// Unknown column; reload the catalog unless we did it recently
+	if time.Since(c.loadedAt) < columnCatalogReloadInterval {
+		return false
+	}
+
+	err = c.loadLocked(databaseURL)
+
+	if err != nil {
+		log.Errorf("column_catalog: error loading columns: %v", err)
+		return false
+	}
+
+	return fn(c.catalog)
+}
+
+// loadLocked loads columns from the database without locking the mutex.
+func (c *columnCatalog) loadLocked(databaseURL string) error {
+	// Do not retry too often even if the load fails
+	c.loadedAt = time.Now()
+	c.loadFailed = true
+
+	inspector, err := inspectDatabase(databaseURL)
+
+	if err != nil {
+		return err
+	}
+
+	defer inspector.Close()
+
+	catalog, err := inspector.ColumnCatalog()
+
+	if err != nil {
+		return err
+	}
+
+	c.catalog = catalog
+	c.loadFailed = false
+
+	log.Debugf("column_catalog: loaded %d columns", catalog.Len())
+
+	return nil
+}
+
+// inspectDatabase connects to the database to load its metadata. lib/pq doesn't support multi-host connection
+// strings, service files and a few other libpq features, so it's given the resolved params of a single host;
+// hosts are tried one by one, since the catalog is the same on the primary and the standbys.
+func inspectDatabase(databaseURL string) (*pgmeta.Inspector, error) {
+	params, err := pg.ParseConnString(databaseURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var inspector *pgmeta.Inspector
+
+	for _, host := range params.Hosts() {
+		inspector, err = pgmeta.Inspect(host.DSN())
+
+		if err == nil {
+			return inspector, nil
+		}
+
+		log.Warnf("column_catalog: database host %s is not available: %v", host["host"], err)
+	}
+
+	return nil, err
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnCatalogLoadFailed(t *testing.T) {
+	catalog := newColumnCatalog(testConfigStore(t, &Config{DatabaseURL: closedDatabaseURL(t)}), "")
+
+	_, ok := catalog.ResolveColumn(usersTableOID, 1)
+	assert.False(t, ok)
+	assert.True(t, catalog.LoadFailed())
+
+	// Failed loads are throttled as well
+	loadedAt := catalog.loadedAt
+
+	_, ok = catalog.ResolveColumn(usersTableOID, 2)
+	assert.False(t, ok)
+	assert.Equal(t, loadedAt, catalog.loadedAt)
+	assert.True(t, catalog.LoadFailed())
+}
@@ -1,11 +1,194 @@
 package server
 
+import (
+	"fmt"
+
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/masking"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
 // Config contains configuration parameters for the server package.
 // cli package use this to unmarshall the gevulot.toml.
 type Config struct {
 	// Local IP address and port on which Gevolut will listen for client connections.
 	Listen string
 
+	// TLS termination of client connections (optional).
+	TLS *TLSConfig `toml:"tls"`
+
 	// Database connection string for the proxied PostgreSQL server.
 	DatabaseURL string `toml:"database-url"`
+
+	// Connection strings of the read-only replicas of the DatabaseURL database.
+	ReplicaURLs []string `toml:"replica-urls"`
+
+	// Routing of read-only traffic to the replicas (optional).
+	ReadRouting *ReadRoutingConfig `toml:"read-routing"`
+
+	// More proxied databases keyed by the database name that clients connect to.
+	Databases map[string]*DatabaseConfig `toml:"databases"`
+
+	// Authentication of clients by Gevulot itself (optional).
+	Auth *AuthConfig `toml:"auth"`
+
+	// Transaction-level pooling of database connections (optional; requires Auth).
+	Pool *PoolConfig `toml:"pool"`
+
+	// Masking policies bound to client identities.
+	Policies []*Policy `toml:"policy"`
+
+	// Column masking rules.
+	Mask []*masking.Rule `toml:"mask"`
+
+	// Masking rules of LISTEN/NOTIFY notification payloads.
+	MaskNotifications []*masking.NotificationRule `toml:"mask-notification"`
+
+	// Scrubbing modes of error and notice messages keyed by severity (e.g. "ERROR" = "redact").
+	ErrorMasking map[string]string `toml:"error-masking"`
+
+	// Versioned secret keys used by keyed masking strategies.
+	MaskingKeys []*masker.Key `toml:"masking-key"`
+}
+
+// DatabaseConfig configures a proxied database that clients connect to by its name in Config.Databases.
+type DatabaseConfig struct {
+	// Database connection string.
+	URL string `toml:"url"`
+
+	// Connection strings of the read-only replicas of the database.
+	ReplicaURLs []string `toml:"replica-urls"`
+
+	// Column masking rules of the database; the top level rules only apply to the database from DatabaseURL.
+	Mask []*masking.Rule `toml:"mask"`
+}
+
+// ReadRoutingConfig configures routing of read-only traffic to the replicas. Clients requesting a read-only
+// session with the target_session_attrs startup parameter are always routed to the replicas.
+type ReadRoutingConfig struct {
+	// Sessions of the clients with these application names are routed to the replicas.
+	ApplicationNames []string `toml:"application-names"`
+
+	// Pure SELECT statements outside of a transaction block are routed to the replicas (pooled sessions only).
+	Selects bool `toml:"selects"`
+}
+
+// Validate checks the parts of the config that are not validated on load.
+func (c *Config) Validate() error {
+	for _, url := range c.ReplicaURLs {
+		_, err := pg.ParseConnString(url)
+
+		if err != nil {
+			return fmt.Errorf("server: replica: %w", err)
+		}
+	}
+
+	for name, db := range c.Databases {
+		if db == nil || db.URL == "" {
+			return fmt.Errorf("server: database %s: missing url", name)
+		}
+
+		for _, url := range append([]string{db.URL}, db.ReplicaURLs...) {
+			_, err := pg.ParseConnString(url)
+
+			if err != nil {
+				return fmt.Errorf("server: database %s: %w", name, err)
+			}
+		}
+	}
+
+	if c.Pool != nil {
+		// NB: pooled connections are logged in as the user from the database URL
+		if c.Auth == nil {
+			return fmt.Errorf("server: pool requires auth")
+		}
+
+		if c.Pool.Size < 0 {
+			return fmt.Errorf("server: invalid pool size %d", c.Pool.Size)
+		}
+	}
+
+	err := c.validateMasking()
+
+	if err != nil {
+		return err
+	}
+
+	return validatePolicies(c.Policies, c.Auth != nil)
+}
+
+// validateMasking builds the masking components of every database the way sessions do, so that invalid rules,
+// strategies, options and keys are rejected on load rather than at the session start.
+func (c *Config) validateMasking() error {
+	keyring, err := masker.NewKeyring(c.MaskingKeys)
+
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+
+	registry := masker.DefaultRegistry()
+	registry.SetKeyring(keyring)
+
+	engine, err := masking.NewEngineWithRegistry(c.Mask, nil, registry)
+
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+
+	for name, db := range c.Databases {
+		_, err := masking.NewEngineWithRegistry(db.Mask, nil, registry)
+
+		if err != nil {
+			return fmt.Errorf("server: database %s: %w", name, err)
+		}
+	}
+
+	_, err = masking.NewErrorScrubber(engine, c.ErrorMasking)
+
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+
+	_, err = masking.NewNotificationMasker(c.MaskNotifications, registry)
+
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+
+	return nil
+}
+
+// databaseURL returns URL of the database with the given name in Databases; an empty name stands for
+// the database from DatabaseURL.
+func (c *Config) databaseURL(name string) (string, bool) {
+	if name == "" {
+		return c.DatabaseURL, c.DatabaseURL != ""
+	}
+
+	db, ok := c.Databases[name]
+
+	if !ok {
+		return "", false
+	}
+
+	return db.URL, true
+}
+
+// replicaURLs returns URLs of the replicas of the database with the given name in Databases; an empty name
+// stands for the database from DatabaseURL.
+func (c *Config) replicaURLs(name string) []string {
+	if name == "" {
+		return c.ReplicaURLs
+	}
+
+	if db, ok := c.Databases[name]; ok {
+		return db.ReplicaURLs
+	}
+
+	return nil
+}
+
+// Files returns paths of the files referred by the config (e.g., TLS certificates).
+func (c *Config) Files() []string {
+	return append(c.TLS.Files(), c.Auth.Files()...)
 }
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// SSL modes of the database connection (the sslmode parameter of the database URL).
+// See https://www.postgresql.org/docs/current/libpq-ssl.html#LIBPQ-SSL-SSLMODE-STATEMENTS
+const (
+	// sslModeDisable only tries a non-SSL connection.
+	sslModeDisable = "disable"
+
+	// sslModeAllow tries a non-SSL connection first; if that fails, tries an SSL connection.
+	sslModeAllow = "allow"
+
+	// sslModePrefer tries an SSL connection first; if that fails, tries a non-SSL connection (default).
+	sslModePrefer = "prefer"
+
+	// sslModeRequire only tries an SSL connection. If a root CA file is present, the server certificate
+	// is verified as in verify-ca.
+	sslModeRequire = "require"
+
+	// sslModeVerifyCA only tries an SSL connection and verifies that the server certificate is issued by
+	// a trusted CA.
+	sslModeVerifyCA = "verify-ca"
+
+	// sslModeVerifyFull only tries an SSL connection and verifies that the server certificate is issued by
+	// a trusted CA and matches the server host name.
+	sslModeVerifyFull = "verify-full"
+)
+
+// sslRootCertSystem is a special value of sslrootcert to use the system's trusted CA roots.
+const sslRootCertSystem = "system"
+
+// dbTLSConfig is TLS configuration of the database connection.
+type dbTLSConfig struct {
+	// SSL mode
+	mode string
+
+	// TLS config for the handshake; nil if mode is disable
+	config *tls.Config
+}
+
+// newDBTLSConfig builds TLS configuration of the database connection from the sslmode, sslrootcert, sslcert
+// and sslkey connection parameters. Like libpq, it uses ~/.postgresql/root.crt, ~/.postgresql/postgresql.crt
+// and ~/.postgresql/postgresql.key if the files are not set explicitly.
+func newDBTLSConfig(params pg.ConnectionParams) (*dbTLSConfig, error) {
+	mode := params["sslmode"]
+
+	if mode == "" {
+		mode = sslModePrefer
+	}
+
+	switch mode {
+	case sslModeDisable:
+		return &dbTLSConfig{mode: mode}, nil
+
+	case sslModeAllow, sslModePrefer, sslModeRequire, sslModeVerifyCA, sslModeVerifyFull:
+
+	default:
+		return nil, fmt.Errorf("server: invalid sslmode %q", mode)
+	}
+
+	config := &tls.Config{
+		ServerName: params["host"],
+		MinVersion: tls.VersionTLS12,
+	}
+
+	err := loadDBClientCertificate(config, params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rootCertPath, explicitRootCert := params["sslrootcert"], params["sslrootcert"] != ""
+
+	if !explicitRootCert {
+		rootCertPath = defaultPostgresFile("root.crt")
+	}
+
+	// NB: require verifies the server certificate like verify-ca if a root CA file is present
+	if mode == sslModeRequire && (explicitRootCert || fileExists(rootCertPath)) {
+		mode = sslModeVerifyCA
+	}
+
+	if mode != sslModeVerifyCA && mode != sslModeVerifyFull {
+		// Server certificate is not verified: the connection is encrypted, but not authenticated
+		config.InsecureSkipVerify = true //nolint:gosec
+
+		return &dbTLSConfig{mode: mode, config: config}, nil
+	}
+
+	// The system's roots are used if RootCAs is nil
+	if rootCertPath != sslRootCertSystem {
+		config.RootCAs, err = loadDBRootCerts(rootCertPath)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if mode == sslModeVerifyCA {
+		// Chain is verified manually to skip the host name verification
+		config.InsecureSkipVerify = true //nolint:gosec
+		config.VerifyPeerCertificate = verifyCertificateChain(config.RootCAs)
+	}
+
+	return &dbTLSConfig{mode: mode, config: config}, nil
+}
+
+// loadDBClientCertificate loads the client certificate presented to the database, if any.
+func loadDBClientCertificate(config *tls.Config, params pg.ConnectionParams) error {
+	certPath, keyPath := params["sslcert"], params["sslkey"]
+
+	if certPath == "" {
+		certPath = defaultPostgresFile("postgresql.crt")
+
+		// Default certificate is optional
+		if !fileExists(certPath) {
+			return nil
+		}
+	}
+
+	if keyPath == "" {
+		keyPath = defaultPostgresFile("postgresql.key")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+
+	if err != nil {
+		return fmt.Errorf("server: can't load database client certificate: %w", err)
+	}
+
+	config.Certificates = []tls.Certificate{cert}
+
+	return nil
+}
+
+// loadDBRootCerts reads trusted CA certificates of the database server.
+func loadDBRootCerts(path string) (*x509.CertPool, error) {
+	contents, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("server: can't read root certificate file to verify the database: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("server: no certificates found in the root certificate file %s", path)
+	}
+
+	return pool, nil
+}
+
+// verifyCertificateChain returns a tls.Config.VerifyPeerCertificate callback verifying that the peer certificate
+// is issued by one of the given roots regardless of the host name.
+func verifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server: database didn't present a certificate")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+
+			if err != nil {
+				return fmt.Errorf("server: invalid database certificate: %w", err)
+			}
+
+			certs[i] = cert
+		}
+
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(opts)
+
+		return err
+	}
+}
+
+// defaultPostgresFile returns path of the file in the ~/.postgresql directory.
+func defaultPostgresFile(name string) string {
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".postgresql", name)
+}
+
+// fileExists returns true if the file at the given path exists.
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+
+	_, err := os.Stat(path)
+
+	return err == nil
+}
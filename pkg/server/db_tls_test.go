@@ -0,0 +1,271 @@
+package server
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// withHome sets HOME to the given directory, so that default ~/.postgresql files are looked up there.
+func withHome(t *testing.T, dir string) func() {
+	t.Helper()
+
+	home := os.Getenv("HOME")
+	require.NoError(t, os.Setenv("HOME", dir))
+
+	return func() { os.Setenv("HOME", home) }
+}
+
+func TestNewDBTLSConfig(t *testing.T) {
+	dir, cleanup := testTLSDir(t)
+	defer cleanup()
+
+	defer withHome(t, dir)()
+
+	newConfig := func(params pg.ConnectionParams) *dbTLSConfig {
+		params["host"] = "db.example.com"
+
+		config, err := newDBTLSConfig(params)
+		require.NoError(t, err)
+
+		return config
+	}
+
+	// Default mode is prefer
+	config := newConfig(pg.ConnectionParams{})
+	assert.Equal(t, sslModePrefer, config.mode)
+	assert.True(t, config.config.InsecureSkipVerify)
+	assert.Empty(t, config.config.Certificates)
+
+	config = newConfig(pg.ConnectionParams{"sslmode": "disable"})
+	assert.Equal(t, sslModeDisable, config.mode)
+	assert.Nil(t, config.config)
+
+	config = newConfig(pg.ConnectionParams{"sslmode": "require"})
+	assert.Equal(t, sslModeRequire, config.mode)
+	assert.True(t, config.config.InsecureSkipVerify)
+
+	// require verifies the CA if a root certificate is set
+	config = newConfig(pg.ConnectionParams{"sslmode": "require", "sslrootcert": filepath.Join(dir, "server.crt")})
+	assert.Equal(t, sslModeVerifyCA, config.mode)
+	assert.NotNil(t, config.config.VerifyPeerCertificate)
+
+	config = newConfig(pg.ConnectionParams{"sslmode": "verify-ca", "sslrootcert": filepath.Join(dir, "server.crt")})
+	assert.Equal(t, sslModeVerifyCA, config.mode)
+	assert.True(t, config.config.InsecureSkipVerify)
+	assert.NotNil(t, config.config.RootCAs)
+
+	config = newConfig(pg.ConnectionParams{"sslmode": "verify-full", "sslrootcert": filepath.Join(dir, "server.crt")})
+	assert.Equal(t, sslModeVerifyFull, config.mode)
+	assert.False(t, config.config.InsecureSkipVerify)
+	assert.Equal(t, "db.example.com", config.config.ServerName)
+
+	// System roots
+	config = newConfig(pg.ConnectionParams{"sslmode": "verify-full", "sslrootcert": "system"})
+	assert.Nil(t, config.config.RootCAs)
+
+	// Client certificate
+	config = newConfig(pg.ConnectionParams{"sslcert": filepath.Join(dir, "client.crt"), "sslkey": filepath.Join(dir, "client.key")})
+	assert.Len(t, config.config.Certificates, 1)
+
+	// Invalid params
+	for _, params := range []pg.ConnectionParams{
+		{"sslmode": "always"},
+		{"sslmode": "verify-full"}, // no ~/.postgresql/root.crt
+		{"sslmode": "verify-ca", "sslrootcert": filepath.Join(dir, "server.key")},
+		{"sslcert": filepath.Join(dir, "client.crt"), "sslkey": filepath.Join(dir, "server.key")},
+	} {
+		_, err := newDBTLSConfig(params)
+		assert.Error(t, err, "params: %v", params)
+	}
+
+	t.Run("default files", func(t *testing.T) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".postgresql"), 0700))
+		require.NoError(t, os.Rename(filepath.Join(dir, "server.crt"), filepath.Join(dir, ".postgresql", "root.crt")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "client.crt"), filepath.Join(dir, ".postgresql", "postgresql.crt")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "client.key"), filepath.Join(dir, ".postgresql", "postgresql.key")))
+
+		config := newConfig(pg.ConnectionParams{"sslmode": "require"})
+		assert.Equal(t, sslModeVerifyCA, config.mode)
+		assert.Len(t, config.config.Certificates, 1)
+	})
+}
+
+// startTestDB accepts a single connection and answers its SSLRequest with the given byte. If the response is 'S',
+// it performs TLS handshake with the given certificate.
+func startTestDB(t *testing.T, response byte, cert *tls.Config) (net.Listener, <-chan *pg.StartupMessage) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	startup := make(chan *pg.StartupMessage, 1)
+
+	go func() {
+		conn, err := l.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		dbConn := pg.NewConn(conn)
+		msg, err := dbConn.RecvStartupMessage()
+
+		if err != nil || msg.ProtocolVersion != pg.SSLRequestMagic {
+			startup <- msg
+			return
+		}
+
+		if dbConn.SendByte(response) != nil {
+			return
+		}
+
+		if response == 'S' {
+			dbConn = pg.NewConn(tls.Server(conn, cert))
+		}
+
+		msg, _ = dbConn.RecvStartupMessage()
+		startup <- msg
+	}()
+
+	return l, startup
+}
+
+func TestConnectDB(t *testing.T) {
+	dir, cleanup := testTLSDir(t)
+	defer cleanup()
+
+	defer withHome(t, dir)()
+
+	serverConfig, err := (&TLSConfig{Cert: filepath.Join(dir, "server.crt"), Key: filepath.Join(dir, "server.key")}).serverConfig()
+	require.NoError(t, err)
+
+	connect := func(l net.Listener, params pg.ConnectionParams, useTLS bool) (*pg.Conn, error) {
+		host, port, err := net.SplitHostPort(l.Addr().String())
+		require.NoError(t, err)
+
+		params["host"], params["port"] = host, port
+
+		tlsConfig, err := newDBTLSConfig(params)
+		require.NoError(t, err)
+
+		return connectDB(params, tlsConfig, useTLS)
+	}
+
+	startupMessage := &pg.StartupMessage{ProtocolVersion: pg.DefaultProtocolVersion}
+
+	t.Run("TLS", func(t *testing.T) {
+		l, startup := startTestDB(t, 'S', serverConfig)
+		defer l.Close()
+
+		// NB: certificate is issued for localhost, so verify-full needs the host name
+		conn, err := connect(l, pg.ConnectionParams{"sslmode": "verify-ca", "sslrootcert": filepath.Join(dir, "server.crt")}, true)
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		_, ok := conn.Unwrap().(*tls.Conn)
+		assert.True(t, ok)
+
+		require.NoError(t, conn.SendMessage(startupMessage))
+		assert.Equal(t, startupMessage, <-startup)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		l, _ := startTestDB(t, 'S', serverConfig)
+		defer l.Close()
+
+		_, err := connect(l, pg.ConnectionParams{"sslmode": "verify-ca", "sslrootcert": filepath.Join(dir, "client.crt")}, true)
+		assert.Error(t, err)
+	})
+
+	t.Run("SSL is not supported", func(t *testing.T) {
+		l, _ := startTestDB(t, 'N', nil)
+		defer l.Close()
+
+		_, err := connect(l, pg.ConnectionParams{"sslmode": "require"}, true)
+		assert.Error(t, err)
+	})
+
+	t.Run("prefer falls back to non-SSL", func(t *testing.T) {
+		l, startup := startTestDB(t, 'N', nil)
+		defer l.Close()
+
+		conn, err := connect(l, pg.ConnectionParams{"sslmode": "prefer"}, true)
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		require.NoError(t, conn.SendMessage(startupMessage))
+		assert.Equal(t, startupMessage, <-startup)
+	})
+
+	t.Run("stalled SSL negotiation", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer l.Close()
+
+		// The database accepts the connection, but never answers
+		go func() {
+			conn, err := l.Accept()
+
+			if err == nil {
+				defer conn.Close()
+				_, _ = ioutil.ReadAll(conn)
+			}
+		}()
+
+		start := time.Now()
+
+		_, err = connect(l, pg.ConnectionParams{"sslmode": "require", "connect_timeout": "1"}, true)
+		assert.Error(t, err)
+		assert.True(t, time.Since(start) < 5*time.Second)
+	})
+
+	t.Run("hostaddr", func(t *testing.T) {
+		l, startup := startTestDB(t, 'N', nil)
+		defer l.Close()
+
+		host, port, err := net.SplitHostPort(l.Addr().String())
+		require.NoError(t, err)
+
+		// NB: the host name is not resolved
+		params := pg.ConnectionParams{"host": "db.invalid", "hostaddr": host, "port": port, "sslmode": "disable"}
+
+		tlsConfig, err := newDBTLSConfig(params)
+		require.NoError(t, err)
+
+		conn, err := connectDB(params, tlsConfig, false)
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		require.NoError(t, conn.SendMessage(startupMessage))
+		assert.Equal(t, startupMessage, <-startup)
+	})
+
+	t.Run("non-SSL", func(t *testing.T) {
+		l, startup := startTestDB(t, 'N', nil)
+		defer l.Close()
+
+		conn, err := connect(l, pg.ConnectionParams{"sslmode": "disable"}, false)
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		require.NoError(t, conn.SendMessage(startupMessage))
+		assert.Equal(t, startupMessage, <-startup)
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// SQLSTATE codes of the errors reported by Gevulot itself.
+// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	// sqlStateConnectionFailure is reported when Gevulot cannot connect or log into the database.
+	sqlStateConnectionFailure = "08006"
+
+	// sqlStateProtocolViolation is reported when a client violates the protocol.
+	sqlStateProtocolViolation = "08P01"
+
+	// sqlStateFeatureNotSupported is reported when a client requests an unsupported protocol version.
+	sqlStateFeatureNotSupported = "0A000"
+
+	// sqlStateInvalidAuthorization is reported when a client doesn't specify the user name.
+	sqlStateInvalidAuthorization = "28000"
+
+	// sqlStateInvalidPassword is reported when a client fails authentication.
+	sqlStateInvalidPassword = "28P01"
+
+	// sqlStateInvalidCatalogName is reported when a client connects to an unknown database.
+	sqlStateInvalidCatalogName = "3D000"
+
+	// sqlStateInternalError is reported when Gevulot fails for any other reason (e.g. invalid masking rules).
+	sqlStateInternalError = "XX000"
+)
+
+// newFatalErrorMessage returns ErrorResponse with FATAL severity that is sent to a client before the session
+// is terminated.
+func newFatalErrorMessage(code, message string) *pg.ErrorResponseMessage {
+	return &pg.ErrorResponseMessage{Fields: []*pg.MessageField{
+		{Type: pg.MessageFieldSeverityLocalized, Value: "FATAL"},
+		{Type: pg.MessageFieldSeverity, Value: "FATAL"},
+		{Type: pg.MessageFieldCode, Value: code},
+		{Type: pg.MessageFieldMessage, Value: message},
+	}}
+}
+
+// rejectClient sends FATAL ErrorResponse with the given code and message to the client during session negotiation
+// and returns the error that terminates the session. The message is sent on the best effort basis: the client may
+// have gone already.
+func (s *Session) rejectClient(err error, code, message string) error {
+	sendErr := s.clientConn.SendMessage(newFatalErrorMessage(code, message))
+
+	if sendErr != nil {
+		log.Debugf("session: can't send error to the client: %v", sendErr)
+	}
+
+	return err
+}
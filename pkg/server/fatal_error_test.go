@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// errorCode returns the SQLSTATE code of the error.
+func errorCode(msg *pg.ErrorResponseMessage) string {
+	for _, field := range msg.Fields {
+		if field.Type == pg.MessageFieldCode {
+			return field.Value
+		}
+	}
+
+	return ""
+}
+
+func TestSessionRejectClient(t *testing.T) {
+	// Nothing listens on the port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	closedAddr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	cfg := testConfigStore(t, &Config{DatabaseURL: "postgres://gevulot@" + closedAddr + "/hired_dev?sslmode=disable"})
+
+	// negotiate runs session negotiation and returns the message sent to the client
+	negotiate := func(startup *pg.StartupMessage) (*pg.ErrorResponseMessage, error) {
+		client, server := net.Pipe()
+		defer client.Close()
+
+		session := NewSession(server, cfg, nil, nil)
+		done := make(chan error, 1)
+
+		go func() {
+			err := session.negotiateSessionParams()
+			server.Close()
+
+			done <- err
+		}()
+
+		conn := pg.NewConn(client)
+		require.NoError(t, conn.SendMessage(startup))
+
+		msg, err := conn.RecvBackendMessage()
+		require.NoError(t, err)
+		require.IsType(t, &pg.ErrorResponseMessage{}, msg)
+
+		return msg.(*pg.ErrorResponseMessage), <-done
+	}
+
+	params := func(params ...string) []*pg.StartupMessageParameter {
+		var result []*pg.StartupMessageParameter
+
+		for i := 0; i < len(params); i += 2 {
+			result = append(result, &pg.StartupMessageParameter{Name: params[i], Value: params[i+1]})
+		}
+
+		return result
+	}
+
+	testCases := []struct {
+		name    string
+		startup *pg.StartupMessage
+		code    string
+		message string
+	}{
+		{
+			"unsupported protocol version",
+			&pg.StartupMessage{ProtocolVersion: 2 << 16, Parameters: params("user", "alice")},
+			sqlStateFeatureNotSupported,
+			"unsupported frontend protocol 2.0: server supports 3.0 to 3.0",
+		},
+		{
+			"no user",
+			&pg.StartupMessage{ProtocolVersion: pg.DefaultProtocolVersion, Parameters: params("database", "hired_dev")},
+			sqlStateInvalidAuthorization,
+			"no PostgreSQL user name specified in startup packet",
+		},
+		{
+			"unknown database",
+			&pg.StartupMessage{ProtocolVersion: pg.DefaultProtocolVersion, Parameters: params("user", "alice", "database", "billing")},
+			sqlStateInvalidCatalogName,
+			`database "billing" does not exist`,
+		},
+		{
+			"database is not available",
+			&pg.StartupMessage{ProtocolVersion: pg.DefaultProtocolVersion, Parameters: params("user", "alice", "database", "hired_dev")},
+			sqlStateConnectionFailure,
+			"could not connect to the database",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := negotiate(tc.startup)
+			assert.Error(t, err)
+
+			assert.Equal(t, tc.code, errorCode(msg))
+			assert.Equal(t, tc.message, errorMessageText(msg))
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+const (
+	// Interval between health checks of a database host.
+	hostCheckInterval = 5 * time.Second
+
+	// Maximum duration of a single health check.
+	hostCheckTimeout = 5 * time.Second
+
+	// Hosts that are not connected to for this long are no longer checked.
+	hostIdleTimeout = 10 * time.Minute
+)
+
+// hostStatus is the state of a database host according to the last health check.
+type hostStatus int
+
+const (
+	// The host hasn't been checked yet, or it's reachable, but its role is unknown (e.g. the credentials
+	// for the check are missing)
+	hostUnknown hostStatus = iota
+
+	// The host doesn't accept connections
+	hostDown
+
+	// The host accepts writes
+	hostPrimary
+
+	// The host is in recovery (a hot standby)
+	hostStandby
+)
+
+// String returns the status name for logs.
+func (s hostStatus) String() string {
+	switch s {
+	case hostDown:
+		return "down"
+	case hostPrimary:
+		return "primary"
+	case hostStandby:
+		return "standby"
+	default:
+		return "unknown"
+	}
+}
+
+// rank returns the preference of the host with the status for the given target_session_attrs: hosts with lower
+// ranks are tried first. It returns -1 if the host must not be connected to.
+func (s hostStatus) rank(attrs string) int {
+	switch attrs {
+	case "primary", "read-write":
+		return map[hostStatus]int{hostPrimary: 0, hostUnknown: 1, hostDown: 2, hostStandby: -1}[s]
+
+	case "standby", "read-only":
+		return map[hostStatus]int{hostStandby: 0, hostUnknown: 1, hostDown: 2, hostPrimary: -1}[s]
+
+	case "prefer-standby":
+		return map[hostStatus]int{hostStandby: 0, hostUnknown: 1, hostPrimary: 2, hostDown: 3}[s]
+
+	default:
+		if s == hostDown {
+			return 1
+		}
+
+		return 0
+	}
+}
+
+// monitoredHost is a database host checked by hostMonitor.
+type monitoredHost struct {
+	// Connection parameters the host is checked with
+	params pg.ConnectionParams
+
+	// Result of the last check (guarded by hostMonitor.mu)
+	status hostStatus
+
+	// Time the host was last requested (guarded by hostMonitor.mu)
+	usedAt time.Time
+
+	// Fired when the first check is done
+	checked *Event
+}
+
+// hostMonitor checks the health and the role of the hosts of multi-host database URLs in background, so that
+// connections are opened to a healthy host with the right role. Hosts are checked from the first connection
+// attempt until they are not used for hostIdleTimeout.
+type hostMonitor struct {
+	// Guards hosts
+	mu sync.Mutex
+
+	// Monitored hosts keyed by hostKey
+	hosts map[string]*monitoredHost
+
+	// Interval between checks of the same host
+	interval time.Duration
+
+	// Fired when the monitor is closed
+	closed *Event
+}
+
+// newHostMonitor initializes a new hostMonitor.
+func newHostMonitor() *hostMonitor {
+	return &hostMonitor{
+		hosts:    make(map[string]*monitoredHost),
+		interval: hostCheckInterval,
+		closed:   NewEvent(),
+	}
+}
+
+// candidates returns the hosts of the connection parameters in the order they should be tried according to
+// target_session_attrs (any by default, as in libpq). Hosts that are not known yet are checked before candidates returns.
+// A single host is returned as is, as well as all hosts when the monitor is nil.
+func (m *hostMonitor) candidates(params pg.ConnectionParams) ([]pg.ConnectionParams, error) {
+	hosts := params.Hosts()
+
+	if m == nil || len(hosts) == 1 {
+		return hosts, nil
+	}
+
+	monitored := make([]*monitoredHost, len(hosts))
+
+	for i, host := range hosts {
+		monitored[i] = m.watch(host)
+	}
+
+	statuses := make([]hostStatus, len(hosts))
+
+	for i, host := range monitored {
+		select {
+		case <-host.checked.Done():
+		case <-m.closed.Done():
+		}
+
+		m.mu.Lock()
+		statuses[i] = host.status
+		m.mu.Unlock()
+	}
+
+	attrs := params["target_session_attrs"]
+
+	if attrs == "" {
+		attrs = "any"
+	}
+
+	var candidates []int
+
+	for i, status := range statuses {
+		if status.rank(attrs) >= 0 {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("session: no database host matches target_session_attrs=%s", attrs)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return statuses[candidates[i]].rank(attrs) < statuses[candidates[j]].rank(attrs)
+	})
+
+	result := make([]pg.ConnectionParams, len(candidates))
+
+	for i, index := range candidates {
+		result[i] = hosts[index]
+	}
+
+	return result, nil
+}
+
+// watch returns the monitored host with the connection parameters, starting to check it if it's new.
+func (m *hostMonitor) watch(params pg.ConnectionParams) *monitoredHost {
+	key := hostKey(params)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	host, ok := m.hosts[key]
+
+	if !ok {
+		host = &monitoredHost{params: params, checked: NewEvent()}
+		m.hosts[key] = host
+
+		go m.check(key, host)
+	}
+
+	host.usedAt = time.Now()
+
+	return host
+}
+
+// check checks the host periodically until the monitor is closed or the host is no longer used.
+func (m *hostMonitor) check(key string, host *monitoredHost) {
+	address := net.JoinHostPort(host.params["host"], host.params["port"])
+
+	for {
+		status, err := checkHost(host.params)
+
+		m.mu.Lock()
+
+		if status != host.status {
+			if err != nil {
+				log.Warnf("session: database host %s is %s: %v", address, status, err)
+			} else {
+				log.Infof("session: database host %s is %s", address, status)
+			}
+		}
+
+		host.status = status
+		idle := time.Since(host.usedAt) > hostIdleTimeout
+
+		if idle {
+			delete(m.hosts, key)
+		}
+
+		m.mu.Unlock()
+
+		host.checked.Fire()
+
+		if idle {
+			return
+		}
+
+		select {
+		case <-time.After(m.interval):
+		case <-m.closed.Done():
+			return
+		}
+	}
+}
+
+// hostKey identifies the monitored host. Besides the address it includes the parameters the check depends on,
+// so hosts shared by URLs with different credentials or SSL settings are checked separately.
+func hostKey(params pg.ConnectionParams) string {
+	return fmt.Sprintf("%s@%s/%s?hostaddr=%s&sslmode=%s", params["user"],
+		net.JoinHostPort(params["host"], params["port"]), params["database"], params["hostaddr"], params["sslmode"])
+}
+
+// close stops the health checks.
+func (m *hostMonitor) close() {
+	if m != nil {
+		m.closed.Fire()
+	}
+}
+
+// checkHost connects to the host, logs in with the credentials from the connection parameters and asks
+// the database whether it's in recovery.
+func checkHost(params pg.ConnectionParams) (hostStatus, error) {
+	startupMessage := &pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters: []*pg.StartupMessageParameter{
+			{Name: "user", Value: params["user"]},
+			{Name: "database", Value: params["database"]},
+			{Name: "application_name", Value: "gevulot health check"},
+		},
+	}
+
+	if params["connect_timeout"] == "" {
+		params = withConnectTimeout(params, hostCheckTimeout)
+	}
+
+	conn, response, err := dialDB(params, startupMessage)
+
+	if err != nil {
+		var netErr net.Error
+
+		if errors.As(err, &netErr) {
+			return hostDown, err
+		}
+
+		// NB: e.g. TLS failures; the host is up, though the sessions are not likely to connect either
+		return hostUnknown, err
+	}
+
+	defer conn.Close()
+
+	_ = conn.Unwrap().SetDeadline(time.Now().Add(hostCheckTimeout))
+
+	recv := func() (pg.Message, error) {
+		if response != nil {
+			msg := response
+			response = nil
+
+			return msg, nil
+		}
+
+		return conn.RecvBackendMessage()
+	}
+
+	err = exchangeDBCredentials(conn, params, recv)
+
+	if err == nil {
+		err = waitForReady(recv)
+	}
+
+	if err == nil {
+		err = conn.SendMessage(&pg.QueryMessage{Query: "SELECT pg_is_in_recovery()"})
+	}
+
+	if err != nil {
+		return hostUnknown, err
+	}
+
+	status := hostUnknown
+
+	for {
+		msg, err := recv()
+
+		if err != nil {
+			return hostUnknown, err
+		}
+
+		switch v := msg.(type) {
+		case *pg.DataRowMessage:
+			if len(v.Values) == 1 && string(v.Values[0]) == "t" {
+				status = hostStandby
+			} else {
+				status = hostPrimary
+			}
+
+		case *pg.ErrorResponseMessage:
+			return hostUnknown, fmt.Errorf("session: health check failed: %s", errorMessageText(v))
+
+		case *pg.ReadyForQueryMessage:
+			_ = conn.SendMessage(&pg.TerminateMessage{})
+			return status, nil
+		}
+	}
+}
+
+// waitForReady receives messages up to the first ReadyForQuery after the authentication.
+func waitForReady(recv func() (pg.Message, error)) error {
+	for {
+		msg, err := recv()
+
+		if err != nil {
+			return err
+		}
+
+		switch v := msg.(type) {
+		case *pg.ErrorResponseMessage:
+			return fmt.Errorf("session: database rejected the connection: %s", errorMessageText(v))
+
+		case *pg.ReadyForQueryMessage:
+			return nil
+		}
+	}
+}
+
+// withConnectTimeout returns a copy of the connection parameters with connect_timeout set.
+func withConnectTimeout(params pg.ConnectionParams, timeout time.Duration) pg.ConnectionParams {
+	result := make(pg.ConnectionParams, len(params)+1)
+
+	for k, v := range params {
+		result[k] = v
+	}
+
+	result["connect_timeout"] = fmt.Sprint(int(timeout.Seconds()))
+
+	return result
+}
+
+// preferStandby makes connections to the hosts of replica URLs prefer standbys unless the URL sets
+// target_session_attrs. It modifies and returns the given connection parameters.
+func preferStandby(params pg.ConnectionParams) pg.ConnectionParams {
+	if _, ok := params["target_session_attrs"]; !ok {
+		params["target_session_attrs"] = "prefer-standby"
+	}
+
+	return params
+}
+
+// dialDBHost connects to the first available host of the connection parameters, trying them in the order of
+// the host monitor (see hostMonitor.candidates). It returns the parameters of the connected host along with
+// the results of dialDB.
+func dialDBHost(hosts *hostMonitor, params pg.ConnectionParams, startupMessage pg.Message) (
+	*pg.Conn, pg.Message, pg.ConnectionParams, error) {
+	candidates, err := hosts.candidates(params)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for i, host := range candidates {
+		var (
+			conn     *pg.Conn
+			response pg.Message
+		)
+
+		conn, response, err = dialDB(host, startupMessage)
+
+		if err == nil {
+			return conn, response, host, nil
+		}
+
+		if i < len(candidates)-1 {
+			log.Warnf("session: database host %s is not available: %v", net.JoinHostPort(host["host"], host["port"]), err)
+		}
+	}
+
+	return nil, nil, nil, err
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// multiHostURL returns URL listing the hosts of the fake databases.
+func multiHostURL(addresses ...string) string {
+	return fmt.Sprintf("postgres://gevulot:db-secret@%s/hired_dev?sslmode=disable", strings.Join(addresses, ","))
+}
+
+// candidateAddresses returns addresses of the hosts returned by hostMonitor.candidates.
+func candidateAddresses(t *testing.T, monitor *hostMonitor, params pg.ConnectionParams) []string {
+	t.Helper()
+
+	candidates, err := monitor.candidates(params)
+	require.NoError(t, err)
+
+	addresses := make([]string, len(candidates))
+
+	for i, host := range candidates {
+		addresses[i] = host["host"] + ":" + host["port"]
+	}
+
+	return addresses
+}
+
+func TestHostMonitor(t *testing.T) {
+	db1 := startTestPoolDB(t)
+	defer db1.close()
+
+	db2 := startTestPoolDB(t)
+	defer db2.close()
+
+	db2.setInRecovery(true)
+
+	addr1, addr2 := db1.l.Addr().String(), db2.l.Addr().String()
+
+	monitor := newHostMonitor()
+	monitor.interval = 10 * time.Millisecond
+
+	defer monitor.close()
+
+	params, err := pg.ParseDatabaseURI(multiHostURL(addr1, addr2))
+	require.NoError(t, err)
+
+	// withAttrs returns the params with the target_session_attrs
+	withAttrs := func(attrs string) pg.ConnectionParams {
+		params, err := pg.ParseDatabaseURI(multiHostURL(addr1, addr2) + "&target_session_attrs=" + attrs)
+		require.NoError(t, err)
+
+		return params
+	}
+
+	// Any host by default as in libpq
+	assert.Equal(t, []string{addr1, addr2}, candidateAddresses(t, monitor, params))
+	assert.Equal(t, []string{addr1}, candidateAddresses(t, monitor, withAttrs("primary")))
+	assert.Equal(t, []string{addr2}, candidateAddresses(t, monitor, withAttrs("standby")))
+	assert.Equal(t, []string{addr2, addr1}, candidateAddresses(t, monitor, withAttrs("prefer-standby")))
+	assert.Equal(t, []string{addr1, addr2}, candidateAddresses(t, monitor, withAttrs("any")))
+
+	// Health checks are not recorded
+	assert.Empty(t, db1.recorded())
+
+	// The primary moves
+	db1.setInRecovery(true)
+	db2.setInRecovery(false)
+
+	assert.Eventually(t, func() bool {
+		candidates, err := monitor.candidates(withAttrs("primary"))
+		return err == nil && len(candidates) == 1 && candidates[0]["host"]+":"+candidates[0]["port"] == addr2
+	}, time.Second, 10*time.Millisecond)
+
+	// No primary at all
+	db2.setInRecovery(true)
+
+	assert.Eventually(t, func() bool {
+		_, err := monitor.candidates(withAttrs("read-write"))
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// Hosts that are down are tried last
+	db1.close()
+
+	assert.Eventually(t, func() bool {
+		candidates, err := monitor.candidates(withAttrs("any"))
+		return err == nil && candidates[0]["host"]+":"+candidates[0]["port"] == addr2
+	}, time.Second, 10*time.Millisecond)
+
+	// Single host is not checked
+	single, err := pg.ParseDatabaseURI(multiHostURL(addr1))
+	require.NoError(t, err)
+	assert.Equal(t, []pg.ConnectionParams{single}, mustCandidates(t, monitor, single))
+}
+
+func TestHostMonitorWatch(t *testing.T) {
+	monitor := newHostMonitor()
+	defer monitor.close()
+
+	params, err := pg.ParseDatabaseURI(closedDatabaseURL(t))
+	require.NoError(t, err)
+
+	// with returns a copy of the params with the given param changed
+	with := func(name, value string) pg.ConnectionParams {
+		result := withConnectTimeout(params, hostCheckTimeout)
+		result[name] = value
+
+		return result
+	}
+
+	host := monitor.watch(params)
+	assert.True(t, host == monitor.watch(with("connect_timeout", "1")))
+
+	// The same address with other credentials or SSL settings is checked separately
+	for _, other := range []pg.ConnectionParams{with("user", "bob"), with("database", "other"), with("sslmode", "require")} {
+		assert.False(t, host == monitor.watch(other), other)
+	}
+
+	monitor.mu.Lock()
+	assert.Len(t, monitor.hosts, 4)
+	monitor.mu.Unlock()
+}
+
+// mustCandidates runs hostMonitor.candidates and requires it to succeed.
+func mustCandidates(t *testing.T, monitor *hostMonitor, params pg.ConnectionParams) []pg.ConnectionParams {
+	t.Helper()
+
+	candidates, err := monitor.candidates(params)
+	require.NoError(t, err)
+
+	return candidates
+}
+
+func TestSessionEstablishDBConnectionFailover(t *testing.T) {
+	db := startTestPoolDB(t)
+	defer db.close()
+
+	closedURL, err := pg.ParseDatabaseURI(closedDatabaseURL(t))
+	require.NoError(t, err)
+
+	closedAddr := closedURL["host"] + ":" + closedURL["port"]
+	startup := &pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters:      []*pg.StartupMessageParameter{{Name: "user", Value: "gevulot"}},
+	}
+
+	for _, monitor := range []*hostMonitor{nil, newHostMonitor()} {
+		session := NewSession(nil, testConfigStore(t, &Config{
+			DatabaseURL: multiHostURL(closedAddr, db.l.Addr().String()),
+		}), nil, nil)
+
+		session.hosts = monitor
+
+		require.NoError(t, session.establishDBConnection(startup))
+
+		// Cancel requests are sent to the connected host
+		assert.Equal(t, db.l.Addr().String(), session.dbConnectionParams["host"]+":"+session.dbConnectionParams["port"])
+
+		session.dbConn.Close()
+		monitor.close()
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// Masking policy modes.
+const (
+	// PolicyMasked applies the masking rules. This is the default.
+	PolicyMasked = "masked"
+
+	// PolicyUnmasked forwards data in cleartext.
+	PolicyUnmasked = "unmasked"
+
+	// PolicyInherit takes the mode of the policy named in Inherits.
+	PolicyInherit = "inherit"
+)
+
+// Policy binds a masking mode to client identities. A policy matches a client if every non-empty list of
+// criteria contains a matching value; a policy without criteria matches everyone. Sessions get the mode of the
+// first matching policy, and clients that match no policy are masked.
+type Policy struct {
+	// Name of the policy; referred by Inherits of other policies.
+	Name string
+
+	// Startup user names.
+	Users []string
+
+	// Values of the application_name startup parameter.
+	ApplicationNames []string `toml:"application-names"`
+
+	// Common names of client TLS certificates.
+	CertCNs []string `toml:"cert-cns"`
+
+	// Client source networks in CIDR notation (e.g. "10.0.0.0/8").
+	CIDRs []string `toml:"cidrs"`
+
+	// Masking mode: "masked" (default), "unmasked" or "inherit".
+	Mode string
+
+	// Name of the policy to take the mode from when Mode is "inherit".
+	Inherits string
+}
+
+// clientIdentity identifies the client of a session for policy matching.
+type clientIdentity struct {
+	// Startup user
+	user string
+
+	// application_name startup parameter
+	applicationName string
+
+	// Common name of the client TLS certificate; empty if the client hasn't presented one
+	certCN string
+
+	// Client IP address; nil if unknown
+	ip net.IP
+}
+
+// newClientIdentity returns identity of the client connected with the given connection and startup message.
+func newClientIdentity(conn net.Conn, startup *pg.StartupMessage) *clientIdentity {
+	id := &clientIdentity{user: startup.GetParameter("user"), applicationName: startup.GetParameter("application_name")}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			id.certCN = certs[0].Subject.CommonName
+		}
+	}
+
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		id.ip = addr.IP
+	}
+
+	return id
+}
+
+// validatePolicies checks modes, networks and inheritance of the policies. authenticated tells whether Gevulot
+// authenticates clients itself (see AuthConfig); otherwise startup user names are not verified by Gevulot.
+func validatePolicies(policies []*Policy, authenticated bool) error {
+	names := make(map[string]*Policy, len(policies))
+
+	for _, policy := range policies {
+		if policy.Name == "" {
+			return fmt.Errorf("server: policy without name")
+		}
+
+		if _, ok := names[policy.Name]; ok {
+			return fmt.Errorf("server: duplicate policy %s", policy.Name)
+		}
+
+		names[policy.Name] = policy
+
+		switch policy.Mode {
+		case "", PolicyMasked, PolicyUnmasked:
+			if policy.Inherits != "" {
+				return fmt.Errorf("server: policy %s: inherits requires the inherit mode", policy.Name)
+			}
+
+		case PolicyInherit:
+			if policy.Inherits == "" {
+				return fmt.Errorf("server: policy %s: inherit mode requires inherits", policy.Name)
+			}
+
+		default:
+			return fmt.Errorf("server: policy %s: unknown mode %q", policy.Name, policy.Mode)
+		}
+
+		for _, cidr := range policy.CIDRs {
+			_, _, err := net.ParseCIDR(cidr)
+
+			if err != nil {
+				return fmt.Errorf("server: policy %s: %w", policy.Name, err)
+			}
+		}
+	}
+
+	for _, policy := range policies {
+		mode, err := policyMode(names, policy)
+
+		if err != nil {
+			return err
+		}
+
+		if mode == PolicyUnmasked && !policy.verified(authenticated) {
+			return fmt.Errorf("server: policy %s: unmasked policy must check a client identity verified by Gevulot "+
+				"(cert-cns, cidrs, or users with auth)", policy.Name)
+		}
+	}
+
+	return nil
+}
+
+// verified returns true if the policy criteria include a client identity that Gevulot verifies, or if the policy
+// has no criteria at all. application_name is sent by the client, and so is the startup user name unless
+// Gevulot authenticates clients itself: a client can claim any of them.
+func (p *Policy) verified(authenticated bool) bool {
+	if len(p.CertCNs) > 0 || len(p.CIDRs) > 0 || (len(p.Users) > 0 && authenticated) {
+		return true
+	}
+
+	return len(p.Users) == 0 && len(p.ApplicationNames) == 0
+}
+
+// policyMode resolves the mode of the policy following the inheritance chain.
+func policyMode(names map[string]*Policy, policy *Policy) (string, error) {
+	visited := make(map[string]bool)
+
+	for policy.Mode == PolicyInherit {
+		visited[policy.Name] = true
+
+		parent, ok := names[policy.Inherits]
+
+		if !ok {
+			return "", fmt.Errorf("server: policy %s inherits unknown policy %s", policy.Name, policy.Inherits)
+		}
+
+		if visited[parent.Name] {
+			return "", fmt.Errorf("server: policy %s: inheritance cycle", policy.Name)
+		}
+
+		policy = parent
+	}
+
+	if policy.Mode == "" {
+		return PolicyMasked, nil
+	}
+
+	return policy.Mode, nil
+}
+
+// matches returns true if the policy applies to the client.
+func (p *Policy) matches(id *clientIdentity) bool {
+	if len(p.Users) > 0 && !containsString(p.Users, id.user) {
+		return false
+	}
+
+	if len(p.ApplicationNames) > 0 && !containsString(p.ApplicationNames, id.applicationName) {
+		return false
+	}
+
+	if len(p.CertCNs) > 0 && (id.certCN == "" || !containsString(p.CertCNs, id.certCN)) {
+		return false
+	}
+
+	if len(p.CIDRs) > 0 {
+		if id.ip == nil {
+			return false
+		}
+
+		for _, cidr := range p.CIDRs {
+			// NB: networks are validated with the config
+			_, network, err := net.ParseCIDR(cidr)
+
+			if err == nil && network.Contains(id.ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// sessionPolicy returns the first policy matching the client and its resolved mode. Clients that match no
+// policy are masked, so a misconfiguration never exposes data.
+func sessionPolicy(policies []*Policy, id *clientIdentity) (*Policy, string) {
+	names := make(map[string]*Policy, len(policies))
+
+	for _, policy := range policies {
+		names[policy.Name] = policy
+	}
+
+	for _, policy := range policies {
+		if !policy.matches(id) {
+			continue
+		}
+
+		mode, err := policyMode(names, policy)
+
+		if err != nil {
+			return policy, PolicyMasked
+		}
+
+		return policy, mode
+	}
+
+	return nil, PolicyMasked
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masking"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+func TestValidatePolicies(t *testing.T) {
+	valid := []*Policy{
+		{Name: "dba", Users: []string{"postgres"}, CIDRs: []string{"10.0.0.0/8"}, Mode: PolicyUnmasked},
+		{Name: "oncall", CertCNs: []string{"oncall"}, Mode: PolicyInherit, Inherits: "dba"},
+		{Name: "default"},
+	}
+
+	assert.NoError(t, validatePolicies(valid, false))
+	assert.NoError(t, validatePolicies(nil, false))
+
+	// Startup user names are verified when Gevulot authenticates clients
+	authenticated := []*Policy{{Name: "dba", Users: []string{"postgres"}, Mode: PolicyUnmasked}}
+
+	assert.NoError(t, validatePolicies(authenticated, true))
+	assert.Error(t, validatePolicies(authenticated, false))
+
+	for _, policies := range [][]*Policy{
+		{{}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Mode: "cleartext"}},
+		{{Name: "a", Inherits: "b"}, {Name: "b"}},
+		{{Name: "a", Mode: PolicyInherit}},
+		{{Name: "a", Mode: PolicyInherit, Inherits: "b"}},
+		{{Name: "a", Mode: PolicyInherit, Inherits: "b"}, {Name: "b", Mode: PolicyInherit, Inherits: "a"}},
+		{{Name: "a", CIDRs: []string{"10.0.0.0"}}},
+		{{Name: "a", ApplicationNames: []string{"psql"}, Mode: PolicyUnmasked}},
+		{{Name: "a", ApplicationNames: []string{"psql"}, Mode: PolicyInherit, Inherits: "b"}, {Name: "b", Mode: PolicyUnmasked}},
+	} {
+		assert.Error(t, validatePolicies(policies, true), "policies: %v", policies)
+	}
+}
+
+func TestSessionPolicy(t *testing.T) {
+	policies := []*Policy{
+		{Name: "dba", Users: []string{"postgres", "admin"}, CIDRs: []string{"10.0.0.0/8", "192.168.1.0/24"}, Mode: PolicyUnmasked},
+		{Name: "oncall", CertCNs: []string{"oncall"}, Mode: PolicyInherit, Inherits: "dba"},
+		{Name: "psql", Users: []string{"postgres"}, ApplicationNames: []string{"psql"}, Mode: PolicyUnmasked},
+		{Name: "support", Users: []string{"support"}, Mode: PolicyMasked},
+	}
+
+	testCases := []struct {
+		id     clientIdentity
+		policy string
+		mode   string
+	}{
+		{clientIdentity{user: "postgres", ip: net.ParseIP("10.1.2.3")}, "dba", PolicyUnmasked},
+		{clientIdentity{user: "admin", ip: net.ParseIP("192.168.1.10")}, "dba", PolicyUnmasked},
+		{clientIdentity{user: "oncall", certCN: "oncall"}, "oncall", PolicyUnmasked},
+		{clientIdentity{user: "postgres", applicationName: "psql", ip: net.ParseIP("172.16.0.1")}, "psql", PolicyUnmasked},
+		{clientIdentity{user: "postgres", ip: net.ParseIP("172.16.0.1")}, "", PolicyMasked},
+		{clientIdentity{user: "admin"}, "", PolicyMasked},
+		{clientIdentity{user: "support", certCN: "support"}, "support", PolicyMasked},
+	}
+
+	for _, tc := range testCases {
+		policy, mode := sessionPolicy(policies, &tc.id)
+		assert.Equal(t, tc.mode, mode, "identity: %+v", tc.id)
+
+		if tc.policy == "" {
+			assert.Nil(t, policy, "identity: %+v", tc.id)
+		} else if assert.NotNil(t, policy, "identity: %+v", tc.id) {
+			assert.Equal(t, tc.policy, policy.Name)
+		}
+	}
+
+	// Default policy
+	policy, mode := sessionPolicy(append(policies, &Policy{Name: "everyone", Mode: PolicyUnmasked}), &clientIdentity{user: "jane"})
+	assert.Equal(t, "everyone", policy.Name)
+	assert.Equal(t, PolicyUnmasked, mode)
+}
+
+func TestNewClientIdentity(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	startup := &pg.StartupMessage{Parameters: []*pg.StartupMessageParameter{
+		{Name: "user", Value: "alice"},
+		{Name: "application_name", Value: "psql"},
+	}}
+
+	id := newClientIdentity(conn, startup)
+	assert.Equal(t, "alice", id.user)
+	assert.Equal(t, "psql", id.applicationName)
+	assert.Equal(t, "", id.certCN)
+	assert.True(t, id.ip.Equal(net.ParseIP("127.0.0.1")))
+}
+
+func TestSessionMaskingPolicy(t *testing.T) {
+	src := make(chan *Config)
+	defer close(src)
+
+	cfg := NewConfigDistributor(src)
+	defer cfg.Close()
+
+	rules := []*masking.Rule{{Table: "users", Column: "email", Strategy: "redact"}}
+	masked := &Config{Mask: rules}
+
+	go func() { src <- masked }()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	session := NewSession(server, cfg, nil, nil)
+	session.identity = &clientIdentity{user: "postgres"}
+
+	require.NoError(t, session.initMasking())
+	assert.NotNil(t, session.currentMasking())
+	assert.NotNil(t, session.queries.masking)
+
+	go session.watchConfig()
+	defer session.Close()
+
+	// Config that cannot be applied keeps the current masking
+	src <- &Config{Mask: []*masking.Rule{{Table: "users", Column: "email", Strategy: "redcat"}}}
+	src <- &Config{Mask: rules}
+
+	select {
+	case <-session.maskingUpdated:
+	case <-time.After(time.Second):
+		require.FailNow(t, "masking policy is not re-evaluated")
+	}
+
+	select {
+	case <-session.closed.Done():
+		require.FailNow(t, "session is closed")
+	default:
+	}
+
+	assert.NotNil(t, session.currentMasking())
+
+	// Config reload makes the session unmasked
+	src <- &Config{Mask: rules, Policies: []*Policy{{Name: "dba", Users: []string{"postgres"}, Mode: PolicyUnmasked}}}
+
+	select {
+	case <-session.maskingUpdated:
+	case <-time.After(time.Second):
+		require.FailNow(t, "masking policy is not re-evaluated")
+	}
+
+	assert.Nil(t, session.currentMasking())
+	assert.Nil(t, session.errors)
+	assert.Nil(t, session.notifications)
+}
@@ -0,0 +1,516 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+const (
+	// DefaultPoolSize is the default maximum number of database connections per database and user.
+	DefaultPoolSize = 20
+
+	// DefaultPoolResetQuery is the default query resetting the session state of released connections.
+	DefaultPoolResetQuery = "DISCARD ALL"
+)
+
+// errBackendClosed is returned when the pooled database connection is closed.
+var errBackendClosed = errors.New("pool: database connection closed")
+
+// PoolConfig configures transaction-level pooling of database connections.
+type PoolConfig struct {
+	// Maximum number of database connections per database and user (DefaultPoolSize if not set).
+	Size int `toml:"size"`
+
+	// Query resetting the session state before a connection is leased again (DefaultPoolResetQuery if not set).
+	ResetQuery string `toml:"reset-query"`
+}
+
+// size returns the maximum number of database connections.
+func (c *PoolConfig) size() int {
+	if c.Size == 0 {
+		return DefaultPoolSize
+	}
+
+	return c.Size
+}
+
+// resetQuery returns the query resetting the session state.
+func (c *PoolConfig) resetQuery() string {
+	if c.ResetQuery == "" {
+		return DefaultPoolResetQuery
+	}
+
+	return c.ResetQuery
+}
+
+// backendConn is a pooled database connection. Messages from the database are received in background,
+// so the connection can be watched while it's idle.
+type backendConn struct {
+	conn *pg.Conn
+
+	// Pool the connection belongs to
+	pool *backendPool
+
+	// Connection parameters of the connected host
+	params pg.ConnectionParams
+
+	// Messages from the database; closed when the connection fails
+	in chan pg.Message
+
+	// Secret-key data of the database backend
+	key *pg.BackendKeyDataMessage
+
+	// Current values of the parameters reported by the database with ParameterStatus
+	parameters map[string]string
+
+	// Session parameters set on the connection since the last reset
+	settings map[string]string
+
+	// Fired when the connection is closed
+	closed *Event
+}
+
+// startReceiving receives messages from the database until the connection is closed.
+func (c *backendConn) startReceiving() {
+	defer close(c.in)
+
+	for {
+		msg, err := c.conn.RecvBackendMessage()
+
+		if err != nil {
+			if !c.closed.HasFired() {
+				log.Warnf("pool: database connection failed: %v", err)
+			}
+
+			return
+		}
+
+		select {
+		case c.in <- msg:
+		case <-c.closed.Done():
+			return
+		}
+	}
+}
+
+// close closes the database connection.
+func (c *backendConn) close() {
+	if c.closed.Fire() {
+		_ = c.conn.Close()
+	}
+}
+
+// parameterStatus registers a new value of the parameter reported by the database.
+func (c *backendConn) parameterStatus(msg *pg.ParameterStatusMessage) {
+	c.parameters[msg.Name] = msg.Value
+}
+
+// drain discards messages that the database has sent while the connection was idle (e.g. notices). It returns
+// false if the connection has failed.
+func (c *backendConn) drain() bool {
+	for {
+		select {
+		case msg, ok := <-c.in:
+			if !ok {
+				return false
+			}
+
+			if status, ok := msg.(*pg.ParameterStatusMessage); ok {
+				c.parameterStatus(status)
+			}
+
+		default:
+			return true
+		}
+	}
+}
+
+// exec runs the query with the simple query protocol and waits for its completion. Results are discarded.
+func (c *backendConn) exec(query string) error {
+	err := c.conn.SendMessage(&pg.QueryMessage{Query: query})
+
+	if err != nil {
+		return err
+	}
+
+	var queryErr error
+
+	for {
+		msg, ok := <-c.in
+
+		if !ok {
+			return errBackendClosed
+		}
+
+		switch v := msg.(type) {
+		case *pg.ParameterStatusMessage:
+			c.parameterStatus(v)
+
+		case *pg.ErrorResponseMessage:
+			queryErr = fmt.Errorf("pool: %s: %s", query, errorMessageText(v))
+
+		case *pg.ReadyForQueryMessage:
+			return queryErr
+		}
+	}
+}
+
+// apply sets the session parameters that differ from the ones set on the connection.
+func (c *backendConn) apply(settings map[string]string) error {
+	var names []string
+
+	for name, value := range settings {
+		if current, ok := c.settings[name]; !ok || current != value {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	// NB: deterministic order for the sake of logs and tests
+	sort.Strings(names)
+
+	statements := make([]string, len(names))
+
+	for i, name := range names {
+		statements[i] = fmt.Sprintf("SET %s TO %s", quoteIdentifier(name), quoteLiteral(settings[name]))
+	}
+
+	err := c.exec(strings.Join(statements, "; "))
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		c.settings[name] = settings[name]
+	}
+
+	return nil
+}
+
+// reset resets the session state of the connection with the reset query. Statements prepared with
+// the extended query protocol are always deallocated, since sessions re-prepare them on every lease.
+func (c *backendConn) reset(resetQuery string) error {
+	err := c.exec(resetQuery)
+
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(resetQuery), DefaultPoolResetQuery) {
+		err = c.exec("DEALLOCATE ALL")
+
+		if err != nil {
+			return err
+		}
+	}
+
+	c.settings = make(map[string]string)
+
+	return nil
+}
+
+// backendPool is a pool of connections to a database logged in as the same user. Sessions lease
+// connections for a transaction at a time.
+type backendPool struct {
+	// Database connection parameters
+	params pg.ConnectionParams
+
+	// Database URL the parameters are parsed from
+	url string
+
+	// Pool configuration
+	config PoolConfig
+
+	// Health checks of the database hosts (optional)
+	hosts *hostMonitor
+
+	// One token per leased connection; limits the number of connections
+	leases chan struct{}
+
+	// Guards idle and closed
+	mu sync.Mutex
+
+	// Connections waiting for a lease
+	idle []*backendConn
+
+	// The pool is closed: released connections are closed
+	closed bool
+}
+
+// newBackendPool initializes a new backendPool.
+func newBackendPool(url string, params pg.ConnectionParams, config PoolConfig, hosts *hostMonitor) *backendPool {
+	return &backendPool{
+		params: params,
+		url:    url,
+		config: config,
+		hosts:  hosts,
+		leases: make(chan struct{}, config.size()),
+	}
+}
+
+// acquire leases a connection, opening a new one if there are no idle connections. If the pool is exhausted,
+// acquire waits until a connection is released or the cancel channel is closed.
+func (p *backendPool) acquire(cancel <-chan struct{}) (*backendConn, error) {
+	select {
+	case p.leases <- struct{}{}:
+	case <-cancel:
+		return nil, ErrSessionClosed
+	}
+
+	for {
+		p.mu.Lock()
+
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if conn.drain() {
+			return conn, nil
+		}
+
+		conn.close()
+	}
+
+	conn, err := p.dial()
+
+	if err != nil {
+		<-p.leases
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// release returns the leased connection to the pool. Reusable connections are reset in background before
+// they become available again; the rest are closed.
+func (p *backendPool) release(conn *backendConn, reusable bool) {
+	if !reusable {
+		conn.close()
+		<-p.leases
+
+		return
+	}
+
+	go func() {
+		defer func() { <-p.leases }()
+
+		err := conn.reset(p.config.resetQuery())
+
+		if err != nil {
+			log.Errorf("pool: can't reset database connection: %v", err)
+
+			conn.close()
+
+			return
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.closed {
+			conn.close()
+			return
+		}
+
+		p.idle = append(p.idle, conn)
+	}()
+}
+
+// close closes idle connections; leased connections are closed once they are released.
+func (p *backendPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+
+	for _, conn := range p.idle {
+		conn.close()
+	}
+
+	p.idle = nil
+}
+
+// dial opens a new database connection and logs in with the credentials from the connection parameters.
+func (p *backendPool) dial() (*backendConn, error) {
+	startupMessage := &pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters: []*pg.StartupMessageParameter{
+			{Name: "user", Value: p.params["user"]},
+			{Name: "database", Value: p.params["database"]},
+		},
+	}
+
+	conn, response, params, err := dialDBHost(p.hosts, p.params, startupMessage)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recv := func() (pg.Message, error) {
+		if response != nil {
+			msg := response
+			response = nil
+
+			return msg, nil
+		}
+
+		return conn.RecvBackendMessage()
+	}
+
+	backend := &backendConn{
+		conn:       conn,
+		pool:       p,
+		params:     params,
+		in:         make(chan pg.Message, 64),
+		parameters: make(map[string]string),
+		settings:   make(map[string]string),
+		closed:     NewEvent(),
+	}
+
+	err = backend.login(params, recv)
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	log.Infof("pool: opened connection to database %s (backend %d)", p.params["database"], backend.key.ProcessID)
+
+	go backend.startReceiving()
+
+	return backend, nil
+}
+
+// login authenticates the connection and receives the backend parameters up to the first ReadyForQuery.
+func (c *backendConn) login(params pg.ConnectionParams, recv func() (pg.Message, error)) error {
+	err := exchangeDBCredentials(c.conn, params, recv)
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		msg, err := recv()
+
+		if err != nil {
+			return err
+		}
+
+		switch v := msg.(type) {
+		case *pg.ParameterStatusMessage:
+			c.parameterStatus(v)
+
+		case *pg.BackendKeyDataMessage:
+			c.key = v
+
+		case *pg.ErrorResponseMessage:
+			return fmt.Errorf("pool: database rejected the connection: %s", errorMessageText(v))
+
+		case *pg.ReadyForQueryMessage:
+			if c.key == nil {
+				return errors.New("pool: database didn't send BackendKeyData")
+			}
+
+			return nil
+		}
+	}
+}
+
+// poolKey identifies a pool by the database name that clients connect to, the database user and the node:
+// 0 is the primary, replicas are numbered from 1 in the order of the config.
+type poolKey struct {
+	database string
+	user     string
+	node     int
+}
+
+// backendPools holds the connection pools of all proxied databases.
+type backendPools struct {
+	// Guards pools
+	mu sync.Mutex
+
+	pools map[poolKey]*backendPool
+
+	// Health checks of the database hosts (optional)
+	hosts *hostMonitor
+}
+
+// newBackendPools initializes a new backendPools.
+func newBackendPools(hosts *hostMonitor) *backendPools {
+	return &backendPools{pools: make(map[poolKey]*backendPool), hosts: hosts}
+}
+
+// get returns the pool of the node (see poolKey) of the database with the given name in Config.Databases
+// (empty for Config.DatabaseURL). The pool is replaced when the node URL or the pool configuration changes.
+func (p *backendPools) get(database string, node int, url string, config *PoolConfig) (*backendPool, error) {
+	params, err := pg.ParseConnString(url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node > 0 {
+		params = preferStandby(params)
+	}
+
+	key := poolKey{database: database, user: params["user"], node: node}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pool, ok := p.pools[key]
+
+	if ok && pool.url == url && pool.config == *config {
+		return pool, nil
+	}
+
+	if ok {
+		pool.close()
+	}
+
+	pool = newBackendPool(url, params, *config, p.hosts)
+	p.pools[key] = pool
+
+	return pool, nil
+}
+
+// close closes all pools.
+func (p *backendPools) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pool := range p.pools {
+		pool.close()
+		delete(p.pools, key)
+	}
+}
+
+// quoteIdentifier quotes the SQL identifier.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes the SQL string literal.
+func quoteLiteral(value string) string {
+	if strings.Contains(value, `\`) {
+		return `E'` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `'`, `''`) + `'`
+	}
+
+	return `'` + strings.ReplaceAll(value, `'`, `''`) + `'`
+}
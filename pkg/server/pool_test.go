@@ -0,0 +1,304 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// testPoolDB is a fake database accepting any number of connections. It records the requests of every
+// connection prefixed with the backend process ID (e.g. "1: SELECT 1"), except for health checks.
+type testPoolDB struct {
+	l net.Listener
+
+	// Guards requests, lastPID and inRecovery
+	mu sync.Mutex
+
+	requests   []string
+	lastPID    int32
+	inRecovery bool
+}
+
+// startTestPoolDB starts a new fake database.
+func startTestPoolDB(t *testing.T) *testPoolDB {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	db := &testPoolDB{l: l}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go db.serve(pg.NewConn(conn))
+		}
+	}()
+
+	return db
+}
+
+// url returns the database URL of the fake database.
+func (db *testPoolDB) url() string {
+	return fmt.Sprintf("postgres://gevulot:db-secret@%s/hired_dev?sslmode=disable", db.l.Addr())
+}
+
+// close stops accepting new connections.
+func (db *testPoolDB) close() {
+	db.l.Close()
+}
+
+// setInRecovery changes the result of pg_is_in_recovery().
+func (db *testPoolDB) setInRecovery(inRecovery bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.inRecovery = inRecovery
+}
+
+// recorded returns the requests received so far.
+func (db *testPoolDB) recorded() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return append([]string(nil), db.requests...)
+}
+
+// serve serves a single connection.
+func (db *testPoolDB) serve(conn *pg.Conn) {
+	defer conn.Close()
+
+	_, err := conn.RecvStartupMessage()
+
+	if err != nil {
+		return
+	}
+
+	db.mu.Lock()
+	db.lastPID++
+	pid := db.lastPID
+	db.mu.Unlock()
+
+	record := func(request string) {
+		db.mu.Lock()
+		db.requests = append(db.requests, fmt.Sprintf("%d: %s", pid, request))
+		db.mu.Unlock()
+	}
+
+	txStatus := pg.TxStatusIdle
+
+	messages := []pg.Message{
+		&pg.AuthenticationOkMessage{},
+		&pg.ParameterStatusMessage{Name: "application_name", Value: ""},
+		&pg.ParameterStatusMessage{Name: "server_version", Value: "12.2"},
+		&pg.BackendKeyDataMessage{ProcessID: pid, Key: 42},
+		&pg.ReadyForQueryMessage{TxStatus: txStatus},
+	}
+
+	for {
+		for _, msg := range messages {
+			if conn.SendMessage(msg) != nil {
+				return
+			}
+		}
+
+		msg, err := conn.RecvFrontendMessage()
+
+		if err != nil {
+			return
+		}
+
+		switch v := msg.(type) {
+		case *pg.QueryMessage:
+			if v.Query == "SELECT pg_is_in_recovery()" {
+				db.mu.Lock()
+				inRecovery := map[bool]string{true: "t", false: "f"}[db.inRecovery]
+				db.mu.Unlock()
+
+				messages = []pg.Message{
+					&pg.DataRowMessage{Values: [][]byte{[]byte(inRecovery)}},
+					&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+					&pg.ReadyForQueryMessage{TxStatus: txStatus},
+				}
+
+				continue
+			}
+
+			record(v.Query)
+
+			tag := "OK"
+
+			switch {
+			case v.Query == "BEGIN":
+				txStatus = pg.TxStatusActive
+			case v.Query == "COMMIT":
+				txStatus = pg.TxStatusIdle
+			case v.Query == "ROLLBACK":
+				tag, txStatus = "ROLLBACK", pg.TxStatusIdle
+			case strings.HasPrefix(v.Query, "SET "):
+				tag = "SET"
+			}
+
+			messages = []pg.Message{&pg.CommandCompleteMessage{Tag: tag}, &pg.ReadyForQueryMessage{TxStatus: txStatus}}
+
+			for _, statement := range strings.Split(v.Query, "; ") {
+				if value := strings.TrimPrefix(statement, `SET "application_name" TO `); value != statement {
+					value = strings.ReplaceAll(strings.Trim(value, "'"), "''", "'")
+					messages = append([]pg.Message{&pg.ParameterStatusMessage{Name: "application_name", Value: value}}, messages...)
+				}
+			}
+
+		case *pg.ParseMessage:
+			record("Parse " + v.Name)
+			messages = []pg.Message{&pg.ParseCompleteMessage{}}
+
+		case *pg.BindMessage:
+			record("Bind " + v.Statement)
+			messages = []pg.Message{&pg.BindCompleteMessage{}}
+
+		case *pg.DescribeMessage:
+			messages = []pg.Message{&pg.NoDataMessage{}}
+
+		case *pg.ExecuteMessage:
+			messages = []pg.Message{&pg.CommandCompleteMessage{Tag: "OK"}}
+
+		case *pg.SyncMessage:
+			messages = []pg.Message{&pg.ReadyForQueryMessage{TxStatus: txStatus}}
+
+		case *pg.TerminateMessage:
+			return
+
+		default:
+			messages = nil
+		}
+	}
+}
+
+func TestBackendPool(t *testing.T) {
+	db := startTestPoolDB(t)
+	defer db.close()
+
+	params, err := pg.ParseDatabaseURI(db.url())
+	require.NoError(t, err)
+
+	pool := newBackendPool(db.url(), params, PoolConfig{Size: 1}, nil)
+	defer pool.close()
+
+	conn, err := pool.acquire(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), conn.key.ProcessID)
+	assert.Equal(t, "12.2", conn.parameters["server_version"])
+	require.NoError(t, conn.exec("SELECT 1"))
+
+	// The pool is exhausted
+	cancel := make(chan struct{})
+	close(cancel)
+
+	_, err = pool.acquire(cancel)
+	assert.Equal(t, ErrSessionClosed, err)
+
+	// Released connection is reset and reused
+	pool.release(conn, true)
+
+	conn, err = pool.acquire(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), conn.key.ProcessID)
+
+	// Broken connection is closed
+	pool.release(conn, false)
+
+	conn, err = pool.acquire(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), conn.key.ProcessID)
+
+	pool.release(conn, false)
+
+	assert.Equal(t, []string{"1: SELECT 1", "1: DISCARD ALL"}, db.recorded())
+}
+
+func TestBackendConnReset(t *testing.T) {
+	db := startTestPoolDB(t)
+	defer db.close()
+
+	params, err := pg.ParseDatabaseURI(db.url())
+	require.NoError(t, err)
+
+	pool := newBackendPool(db.url(), params, PoolConfig{ResetQuery: "RESET ALL"}, nil)
+	defer pool.close()
+
+	conn, err := pool.acquire(nil)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.apply(map[string]string{"application_name": "it's", "search_path": `a\b`}))
+	assert.Equal(t, "it's", conn.parameters["application_name"])
+
+	// Settings that are already applied are not set again
+	require.NoError(t, conn.apply(map[string]string{"application_name": "it's"}))
+
+	// Prepared statements are deallocated even if the reset query doesn't do that
+	require.NoError(t, conn.reset(pool.config.resetQuery()))
+	assert.Empty(t, conn.settings)
+
+	pool.release(conn, false)
+
+	assert.Equal(t, []string{
+		`1: SET "application_name" TO 'it''s'; SET "search_path" TO E'a\\b'`,
+		"1: RESET ALL",
+		"1: DEALLOCATE ALL",
+	}, db.recorded())
+}
+
+func TestBackendPools(t *testing.T) {
+	pools := newBackendPools(nil)
+	config := &PoolConfig{Size: 5}
+
+	pool, err := pools.get("", 0, "postgres://gevulot@localhost/hired_dev", config)
+	require.NoError(t, err)
+
+	same, err := pools.get("", 0, "postgres://gevulot@localhost/hired_dev", config)
+	require.NoError(t, err)
+	assert.Same(t, pool, same)
+
+	// Pools are kept per database and user
+	other, err := pools.get("analytics", 0, "postgres://gevulot@localhost/analytics", config)
+	require.NoError(t, err)
+	assert.False(t, pool == other)
+
+	// Changed URL or configuration replaces the pool
+	replaced, err := pools.get("", 0, "postgres://gevulot@127.0.0.1/hired_dev", config)
+	require.NoError(t, err)
+	assert.False(t, pool == replaced)
+	assert.True(t, pool.closed)
+
+	pool, err = pools.get("", 0, "postgres://gevulot@127.0.0.1/hired_dev", &PoolConfig{Size: 10})
+	require.NoError(t, err)
+	assert.False(t, pool == replaced)
+	assert.Equal(t, 10, cap(pool.leases))
+
+	_, err = pools.get("", 0, "mysql://localhost", config)
+	assert.Error(t, err)
+}
+
+func TestConfigValidatePool(t *testing.T) {
+	config := &Config{DatabaseURL: "postgres://gevulot@localhost/hired_dev", Pool: &PoolConfig{}}
+	assert.EqualError(t, config.Validate(), "server: pool requires auth")
+
+	config.Auth = &AuthConfig{}
+	assert.NoError(t, config.Validate())
+
+	config.Pool.Size = -1
+	assert.Error(t, config.Validate())
+}
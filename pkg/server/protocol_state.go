@@ -0,0 +1,181 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// ErrProtocolViolation is returned when the client or the database sends a message that is not allowed
+// in the current state of the protocol.
+var ErrProtocolViolation = errors.New("session: protocol violation")
+
+// protocolPhase is a phase of the frontend/backend protocol.
+type protocolPhase int
+
+const (
+	// Authentication and backend startup: until the first ReadyForQuery
+	phaseStartup protocolPhase = iota
+
+	// Normal operation: responses to the client's requests
+	phaseReady
+
+	// COPY FROM STDIN: the client sends data; the database sends nothing until the end of data
+	phaseCopyIn
+
+	// COPY TO STDOUT: the database sends data
+	phaseCopyOut
+
+	// Streaming replication: both sides send data
+	phaseCopyBoth
+)
+
+// String returns a human readable name of the phase.
+func (p protocolPhase) String() string {
+	switch p {
+	case phaseStartup:
+		return "startup"
+	case phaseReady:
+		return "ready"
+	case phaseCopyIn:
+		return "COPY FROM STDIN"
+	case phaseCopyOut:
+		return "COPY TO STDOUT"
+	default:
+		return "COPY BOTH"
+	}
+}
+
+// isAsyncMessage returns true for messages the database may send at any time regardless of the requests.
+func isAsyncMessage(msg pg.Message) bool {
+	switch msg.(type) {
+	case *pg.NoticeResponseMessage, *pg.ParameterStatusMessage, *pg.NotificationResponseMessage:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// isAuthenticationResponse returns true if the message is sent by a client in response to an authentication request.
+func isAuthenticationResponse(msg pg.Message) bool {
+	switch msg.(type) {
+	case *pg.PasswordMessage, *pg.SASLInitialResponseMessage, *pg.SASLResponseMessage:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// checkFrontendMessage checks that the client may send the message in the current phase.
+func (t *queryTracker) checkFrontendMessage(msg pg.Message) error {
+	isAuthResponse := isAuthenticationResponse(msg)
+	_, isTerminate := msg.(*pg.TerminateMessage)
+
+	switch {
+	case isTerminate:
+		return nil
+
+	// Only authentication responses are expected until the session is established
+	case t.phase == phaseStartup && !isAuthResponse:
+		return fmt.Errorf("%w: unexpected %T from the client during %s", ErrProtocolViolation, msg, t.phase)
+
+	case t.phase != phaseStartup && isAuthResponse:
+		return fmt.Errorf("%w: unexpected %T from the client after authentication", ErrProtocolViolation, msg)
+	}
+
+	return nil
+}
+
+// checkBackendMessage checks that the database may send the message in the current phase in response
+// to the oldest pending request.
+func (t *queryTracker) checkBackendMessage(msg pg.Message) error {
+	// Errors and asynchronous messages can arrive at any time
+	if _, ok := msg.(*pg.ErrorResponseMessage); ok || isAsyncMessage(msg) {
+		return nil
+	}
+
+	switch t.phase {
+	case phaseStartup:
+		switch msg.(type) {
+		case *pg.NegotiateProtocolVersionMessage, *pg.BackendKeyDataMessage, *pg.ReadyForQueryMessage:
+			return nil
+		}
+
+		// NB: AuthenticationRequestMessage interface is implemented by any message
+		if msg.Frame().MessageType() == pg.AuthenticationRequestMessageType {
+			return nil
+		}
+
+		return fmt.Errorf("%w: unexpected %T during %s", ErrProtocolViolation, msg, t.phase)
+
+	case phaseCopyOut, phaseCopyBoth:
+		switch msg.(type) {
+		case *pg.CopyDataMessage, *pg.CopyDoneMessage:
+			return nil
+		}
+
+		return fmt.Errorf("%w: unexpected %T during %s", ErrProtocolViolation, msg, t.phase)
+
+	case phaseCopyIn:
+		return fmt.Errorf("%w: unexpected %T during %s", ErrProtocolViolation, msg, t.phase)
+	}
+
+	head := t.head()
+
+	if head == nil {
+		return fmt.Errorf("%w: unexpected %T without a pending request", ErrProtocolViolation, msg)
+	}
+
+	if !head.expects(msg) {
+		return fmt.Errorf("%w: unexpected %T while waiting for %s", ErrProtocolViolation, msg, head.kind)
+	}
+
+	return nil
+}
+
+// expects returns true if the message is a valid response to the request in its current state.
+func (r *pendingResponse) expects(msg pg.Message) bool {
+	switch v := msg.(type) {
+	case *pg.ParseCompleteMessage:
+		return r.kind == responseParse
+
+	case *pg.BindCompleteMessage:
+		return r.kind == responseBind
+
+	case *pg.CloseCompleteMessage:
+		return r.kind == responseClose
+
+	case *pg.ParameterDescriptionMessage:
+		return r.kind == responseDescribeStatement && !r.parametersDescribed
+
+	case *pg.NoDataMessage:
+		return r.kind == responseDescribeStatement || r.kind == responseDescribePortal
+
+	case *pg.RowDescriptionMessage:
+		return r.kind == responseDescribeStatement || r.kind == responseDescribePortal || r.kind == responseQuery
+
+	case *pg.DataRowMessage:
+		// Rows of a simple query are preceded by RowDescription
+		return r.kind == responseExecute || (r.kind == responseQuery && r.described)
+
+	case *pg.CommandCompleteMessage, *pg.EmptyQueryResponseMessage,
+		*pg.CopyInResponseMessage, *pg.CopyOutResponseMessage, *pg.CopyBothResponseMessage:
+		return r.kind == responseExecute || r.kind == responseQuery
+
+	case *pg.PortalSuspendedMessage:
+		return r.kind == responseExecute
+
+	case *pg.ReadyForQueryMessage:
+		return r.kind == responseSync || r.kind == responseQuery || r.kind == responseFunctionCall
+
+	case *pg.GenericMessage:
+		// FunctionCallResponse
+		return r.kind == responseFunctionCall && v.Type == 'V'
+
+	default:
+		return false
+	}
+}
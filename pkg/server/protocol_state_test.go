@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+func TestIsAsyncMessage(t *testing.T) {
+	assert.True(t, isAsyncMessage(&pg.NoticeResponseMessage{}))
+	assert.True(t, isAsyncMessage(&pg.ParameterStatusMessage{}))
+	assert.True(t, isAsyncMessage(&pg.NotificationResponseMessage{}))
+
+	assert.False(t, isAsyncMessage(&pg.ErrorResponseMessage{}))
+	assert.False(t, isAsyncMessage(&pg.DataRowMessage{}))
+}
+
+func TestQueryTrackerStartup(t *testing.T) {
+	tracker := newQueryTracker(nil)
+
+	sendFrontend(t, tracker, &pg.PasswordMessage{Password: "secret"})
+
+	sendBackend(t, tracker,
+		&pg.AuthenticationOkMessage{},
+		&pg.ParameterStatusMessage{Name: "server_version", Value: "12.1"},
+		&pg.BackendKeyDataMessage{ProcessID: 28822, Key: 42},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Equal(t, phaseReady, tracker.phase)
+
+	// Queries are not allowed before the session is established
+	_, err := newQueryTracker(nil).frontendMessage(&pg.QueryMessage{Query: "SELECT 1"})
+	assert.True(t, errors.Is(err, ErrProtocolViolation))
+
+	// Rows are not expected during startup
+	_, err = newQueryTracker(nil).backendMessage(&pg.DataRowMessage{})
+	assert.True(t, errors.Is(err, ErrProtocolViolation))
+
+	// Password is not expected after startup
+	_, err = tracker.frontendMessage(&pg.PasswordMessage{Password: "secret"})
+	assert.True(t, errors.Is(err, ErrProtocolViolation))
+
+	_, err = tracker.frontendMessage(&pg.SASLResponseMessage{Data: []byte("c=biws")})
+	assert.True(t, errors.Is(err, ErrProtocolViolation))
+}
+
+func TestQueryTrackerSCRAMStartup(t *testing.T) {
+	tracker := newQueryTracker(nil)
+
+	sendBackend(t, tracker, &pg.AuthenticationSASLMessage{Mechanisms: []string{"SCRAM-SHA-256"}})
+	sendFrontend(t, tracker, &pg.SASLInitialResponseMessage{Mechanism: "SCRAM-SHA-256", Data: []byte("n,,n=,r=nonce")})
+	sendBackend(t, tracker, &pg.AuthenticationSASLContinueMessage{Data: []byte("r=nonce,s=salt,i=4096")})
+	sendFrontend(t, tracker, &pg.SASLResponseMessage{Data: []byte("c=biws,r=nonce,p=proof")})
+
+	sendBackend(t, tracker,
+		&pg.AuthenticationSASLFinalMessage{Data: []byte("v=signature")},
+		&pg.AuthenticationOkMessage{},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Equal(t, phaseReady, tracker.phase)
+}
+
+func TestQueryTrackerProtocolViolations(t *testing.T) {
+	testCases := map[string]struct {
+		requests  []pg.Message
+		responses []pg.Message
+	}{
+		"response without a request": {
+			responses: []pg.Message{&pg.CommandCompleteMessage{Tag: "SELECT 1"}},
+		},
+		"wrong response": {
+			requests:  []pg.Message{&pg.ParseMessage{Query: "SELECT 1"}, &pg.SyncMessage{}},
+			responses: []pg.Message{&pg.BindCompleteMessage{}},
+		},
+		"ReadyForQuery before Sync": {
+			requests:  []pg.Message{&pg.ParseMessage{Query: "SELECT 1"}},
+			responses: []pg.Message{&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle}},
+		},
+		"DataRow without RowDescription": {
+			requests:  []pg.Message{&pg.QueryMessage{Query: "SELECT 1"}},
+			responses: []pg.Message{&pg.DataRowMessage{Values: [][]byte{[]byte("1")}}},
+		},
+		"second ParameterDescription": {
+			requests:  []pg.Message{&pg.DescribeMessage{ObjectType: pg.ObjectTypeStatement}},
+			responses: []pg.Message{&pg.ParameterDescriptionMessage{}, &pg.ParameterDescriptionMessage{}},
+		},
+		"RowDescription during COPY": {
+			requests: []pg.Message{&pg.QueryMessage{Query: "COPY companies TO STDOUT"}},
+			responses: []pg.Message{
+				&pg.CopyOutResponseMessage{ColumnFormats: []pg.DataFormat{pg.DataFormatText}},
+				&pg.RowDescriptionMessage{},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tracker := newTestQueryTracker(t)
+
+		sendFrontend(t, tracker, tc.requests...)
+
+		var err error
+
+		for _, msg := range tc.responses {
+			if _, err = tracker.backendMessage(msg); err != nil {
+				break
+			}
+		}
+
+		assert.True(t, errors.Is(err, ErrProtocolViolation), name)
+	}
+}
+
+func TestQueryTrackerAsyncMessages(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	// Asynchronous messages and errors are allowed at any time
+	forwarded := sendBackend(t, tracker,
+		&pg.NoticeResponseMessage{},
+		&pg.ParameterStatusMessage{Name: "TimeZone", Value: "UTC"},
+		&pg.NotificationResponseMessage{Channel: "user_events"},
+		&pg.ErrorResponseMessage{},
+	)
+
+	assert.Len(t, forwarded, 4)
+}
+
+func TestQueryTrackerDiscardsUntilSync(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	sendFrontend(t, tracker, &pg.ParseMessage{Query: "SELECT broken"})
+	sendBackend(t, tracker, &pg.ErrorResponseMessage{})
+
+	// The database discards these messages
+	sendFrontend(t, tracker, &pg.BindMessage{}, &pg.ExecuteMessage{Portal: "p1"})
+	assert.Empty(t, tracker.pending)
+
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+	sendBackend(t, tracker, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	assert.Empty(t, tracker.pending)
+	assert.False(t, tracker.discarding)
+}
+
+func TestQueryTrackerCopyIn(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	// Sync sent ahead of the data is ignored by the database
+	sendFrontend(t, tracker,
+		&pg.ParseMessage{Query: "COPY users FROM STDIN"},
+		&pg.BindMessage{},
+		&pg.ExecuteMessage{},
+		&pg.SyncMessage{},
+	)
+
+	sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.BindCompleteMessage{},
+		&pg.NoDataMessage{},
+		&pg.CopyInResponseMessage{ColumnFormats: []pg.DataFormat{pg.DataFormatText, pg.DataFormatText}},
+	)
+
+	assert.Equal(t, phaseCopyIn, tracker.phase)
+
+	sendFrontend(t, tracker, &pg.CopyDataMessage{Data: []byte("1\tjane@example.com\n")}, &pg.FlushMessage{}, &pg.CopyDoneMessage{})
+
+	assert.Equal(t, phaseReady, tracker.phase)
+
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+
+	sendBackend(t, tracker,
+		&pg.CommandCompleteMessage{Tag: "COPY 1"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Empty(t, tracker.pending)
+}
+
+func TestQueryTrackerFunctionCall(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	sendFrontend(t, tracker, &pg.GenericMessage{Type: 'F'})
+
+	sendBackend(t, tracker,
+		&pg.GenericMessage{Type: 'V'},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Empty(t, tracker.pending)
+}
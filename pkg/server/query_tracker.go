@@ -0,0 +1,766 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/hired/gevulot/pkg/masking"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgsql"
+)
+
+// queryTracker follows queries of a single session to find out how to mask the results. It is also the protocol
+// state machine of the session: it knows which messages the database may send in response to the client's
+// requests and reports anything else as a protocol violation (see checkBackendMessage).
+//
+// With the simple query protocol every result set is preceded by a RowDescription. With the extended query
+// protocol the shape of the rows returned by Execute comes from an earlier Describe of the portal or of its
+// prepared statement (and the result formats from Bind), so queryTracker keeps a registry of prepared
+// statements and portals as the client sees them, and a queue of responses expected from the database.
+// If the client executes a portal that has never been described, queryTracker injects a Describe itself
+// and hides the response from the client.
+//
+// With transaction pooling the session moves between database connections, so queryTracker also remembers
+// which statements have been prepared on the current connection and re-prepares the missing ones with
+// an injected Parse before they are used.
+//
+// queryTracker is not safe for concurrent use.
+type queryTracker struct {
+	// Masks query results (nil means that nothing is masked)
+	masking *masking.Engine
+
+	// Prepared statements keyed by name; "" is the unnamed statement
+	statements map[string]*preparedStatement
+
+	// Portals keyed by name; "" is the unnamed portal
+	portals map[string]*portal
+
+	// Responses expected from the database in the order of requests
+	pending []*pendingResponse
+
+	// Masks rows of the current simple query result set (nil if there is nothing to mask)
+	rowMasker *masking.RowMasker
+
+	// Masks data of the current COPY TO STDOUT (nil if there is nothing to mask)
+	copyMasker *masking.CopyMasker
+
+	// Current phase of the protocol
+	phase protocolPhase
+
+	// After an error the database discards extended query messages until Sync
+	discarding bool
+
+	// Transaction status reported by the last ReadyForQuery
+	txStatus pg.TxStatus
+
+	// Statements prepared on the current database connection keyed by name; nil unless the session is pooled
+	backendStatements map[string]*preparedStatement
+
+	// Statements that changed session parameters not reported by the database, one per parameter in the order
+	// of execution; pooled sessions replay them on every leased connection
+	setStatements []*setStatement
+
+	// Session parameter changes of the current transaction; they are kept if it commits
+	pendingSetStatements []*setStatement
+
+	// The current transaction is rolled back (explicitly or by an error)
+	rolledBack bool
+
+	// Query of the request that has failed with the last ErrorResponse; its values may be echoed in the error
+	failedQuery string
+}
+
+// setStatement is a SET or RESET statement executed by the client.
+type setStatement struct {
+	// Changed parameter
+	setting *pgsql.SessionSetting
+
+	// The statement text
+	sql string
+}
+
+// preparedStatement is a prepared statement created with Parse.
+type preparedStatement struct {
+	// The statement query
+	query string
+
+	// Parse message that created the statement (nil if the statement is unknown to Gevulot)
+	parse *pg.ParseMessage
+
+	// Describe has been sent for the statement
+	described bool
+
+	// Description of the statement result (formats are always text); nil until described
+	rowDescription *pg.RowDescriptionMessage
+}
+
+// portal is a portal created with Bind.
+type portal struct {
+	// Source prepared statement (nil if the portal wasn't created with Bind, e.g. a cursor)
+	statement *preparedStatement
+
+	// Bind message that created the portal
+	bind *pg.BindMessage
+
+	// Describe has been sent for the portal
+	described bool
+
+	// Description of the portal result; nil until described
+	rowDescription *pg.RowDescriptionMessage
+}
+
+// responseKind identifies a request that waits for a response from the database.
+type responseKind int
+
+const (
+	responseParse responseKind = iota
+	responseBind
+	responseDescribeStatement
+	responseDescribePortal
+	responseExecute
+	responseClose
+	responseSync
+	responseQuery
+	responseFunctionCall
+)
+
+// String returns a human readable name of the request.
+func (k responseKind) String() string {
+	switch k {
+	case responseParse:
+		return "Parse"
+	case responseBind:
+		return "Bind"
+	case responseDescribeStatement:
+		return "Describe (statement)"
+	case responseDescribePortal:
+		return "Describe (portal)"
+	case responseExecute:
+		return "Execute"
+	case responseClose:
+		return "Close"
+	case responseSync:
+		return "Sync"
+	case responseFunctionCall:
+		return "FunctionCall"
+	default:
+		return "Query"
+	}
+}
+
+// pendingResponse is a request sent to the database that waits for a response.
+type pendingResponse struct {
+	kind responseKind
+
+	// Statement the request refers to
+	statement *preparedStatement
+
+	// Statement that has been replaced by Parse (restored if Parse fails)
+	replaced *preparedStatement
+
+	// Statement that has been replaced by Parse on the database connection (restored if Parse fails)
+	backendReplaced *preparedStatement
+
+	// Name of the statement created with Parse
+	name string
+
+	// Portal the request refers to
+	portal *portal
+
+	// The request has been injected by Gevulot; the response is not forwarded to the client
+	injected bool
+
+	// Masks rows returned by Execute; resolved on the first DataRow
+	rowMasker *masking.RowMasker
+	resolved  bool
+
+	// Statements of a simple query and the number of completed ones
+	statements []*pgsql.Statement
+	completed  int
+
+	// ParameterDescription of the statement has been received
+	parametersDescribed bool
+
+	// RowDescription of the current simple query result set has been received
+	described bool
+}
+
+// newQueryTracker initializes a new queryTracker.
+func newQueryTracker(engine *masking.Engine) *queryTracker {
+	return &queryTracker{
+		masking:    engine,
+		statements: make(map[string]*preparedStatement),
+		portals:    make(map[string]*portal),
+	}
+}
+
+// frontendMessage registers a message sent by the client and returns messages to send to the database instead.
+func (t *queryTracker) frontendMessage(msg pg.Message) ([]pg.Message, error) {
+	err := t.checkFrontendMessage(msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	forward := []pg.Message{msg}
+
+	switch msg.(type) {
+	case *pg.CopyDoneMessage, *pg.CopyFailMessage:
+		if t.phase == phaseCopyIn {
+			t.phase = phaseReady
+		}
+
+		return forward, nil
+
+	case *pg.SyncMessage, *pg.FlushMessage:
+		// The database ignores Sync and Flush during COPY FROM STDIN
+		if t.phase == phaseCopyIn {
+			return forward, nil
+		}
+	}
+
+	// Messages discarded by the database are not tracked
+	if t.discarding {
+		if _, ok := msg.(*pg.SyncMessage); !ok {
+			return forward, nil
+		}
+
+		t.discarding = false
+	}
+
+	switch v := msg.(type) {
+	case *pg.QueryMessage:
+		// Simple query destroys the unnamed statement and portal
+		delete(t.statements, "")
+		delete(t.portals, "")
+		delete(t.backendStatements, "")
+
+		t.expect(&pendingResponse{kind: responseQuery, statements: pgsql.SplitStatements(v.Query)})
+
+	case *pg.ParseMessage:
+		statement := &preparedStatement{query: v.Query, parse: v}
+
+		t.expect(&pendingResponse{kind: responseParse, statement: statement, replaced: t.statements[v.Name],
+			backendReplaced: t.backendStatements[v.Name], name: v.Name})
+		t.statements[v.Name] = statement
+		t.prepared(v.Name, statement)
+
+	case *pg.BindMessage:
+		p := &portal{statement: t.statements[v.Statement], bind: v}
+		parse := t.reprepare(v.Statement)
+
+		t.expect(&pendingResponse{kind: responseBind, portal: p})
+		t.portals[v.Portal] = p
+
+		if parse != nil {
+			return []pg.Message{parse, msg}, nil
+		}
+
+	case *pg.DescribeMessage:
+		if v.ObjectType == pg.ObjectTypeStatement {
+			parse := t.reprepare(v.Name)
+
+			statement := t.statement(v.Name)
+			statement.described = true
+
+			t.expect(&pendingResponse{kind: responseDescribeStatement, statement: statement})
+
+			if parse != nil {
+				return []pg.Message{parse, msg}, nil
+			}
+		} else {
+			p := t.portal(v.Name)
+			p.described = true
+
+			t.expect(&pendingResponse{kind: responseDescribePortal, portal: p})
+		}
+
+	case *pg.ExecuteMessage:
+		p := t.portal(v.Portal)
+
+		// We don't know the shape of the rows, so ask the database first
+		if !p.described && (p.statement == nil || !p.statement.described) {
+			p.described = true
+
+			t.expect(&pendingResponse{kind: responseDescribePortal, portal: p, injected: true})
+			t.expect(&pendingResponse{kind: responseExecute, portal: p})
+
+			return []pg.Message{&pg.DescribeMessage{ObjectType: pg.ObjectTypePortal, Name: v.Portal}, msg}, nil
+		}
+
+		t.expect(&pendingResponse{kind: responseExecute, portal: p})
+
+	case *pg.CloseMessage:
+		if v.ObjectType == pg.ObjectTypeStatement {
+			delete(t.statements, v.Name)
+			delete(t.backendStatements, v.Name)
+		} else {
+			delete(t.portals, v.Name)
+		}
+
+		t.expect(&pendingResponse{kind: responseClose})
+
+	case *pg.SyncMessage:
+		t.expect(&pendingResponse{kind: responseSync})
+
+	case *pg.GenericMessage:
+		// FunctionCall is completed with ReadyForQuery just like a simple query
+		if v.Type == 'F' {
+			t.expect(&pendingResponse{kind: responseFunctionCall})
+		}
+	}
+
+	return forward, nil
+}
+
+// backendMessage registers a message sent by the database and masks it. It returns false if the message
+// must not be forwarded to the client. Messages that violate the protocol are reported as errors.
+func (t *queryTracker) backendMessage(msg pg.Message) (bool, error) {
+	err := t.checkBackendMessage(msg)
+
+	if err != nil {
+		return false, err
+	}
+
+	switch v := msg.(type) {
+	case *pg.ParseCompleteMessage:
+		head := t.head()
+		t.pop()
+
+		return !head.injected, nil
+
+	case *pg.BindCompleteMessage, *pg.CloseCompleteMessage, *pg.PortalSuspendedMessage:
+		t.pop()
+
+	case *pg.ParameterDescriptionMessage:
+		// Followed by RowDescription or NoData
+		t.head().parametersDescribed = true
+
+	case *pg.RowDescriptionMessage:
+		return t.rowDescription(v), nil
+
+	case *pg.NoDataMessage:
+		return t.rowDescription(&pg.RowDescriptionMessage{}), nil
+
+	case *pg.DataRowMessage:
+		return true, t.maskRow(v)
+
+	case *pg.CopyInResponseMessage:
+		t.copyIn()
+
+	case *pg.CopyOutResponseMessage:
+		t.phase = phaseCopyOut
+		return true, t.copyOut(v)
+
+	case *pg.CopyBothResponseMessage:
+		t.phase = phaseCopyBoth
+
+	case *pg.CopyDataMessage:
+		return t.maskCopyData(v)
+
+	case *pg.CopyDoneMessage:
+		t.phase = phaseReady
+
+		if t.copyMasker != nil {
+			return true, t.copyMasker.Close()
+		}
+
+	case *pg.CommandCompleteMessage, *pg.EmptyQueryResponseMessage:
+		t.copyMasker = nil
+
+		if complete, ok := msg.(*pg.CommandCompleteMessage); ok {
+			t.commandComplete(complete)
+		}
+
+		if head := t.head(); head.kind == responseQuery {
+			// Simple query may consist of several statements
+			t.rowMasker = nil
+			head.completed++
+			head.described = false
+		} else {
+			t.pop()
+		}
+
+	case *pg.ErrorResponseMessage:
+		t.error()
+
+	case *pg.ReadyForQueryMessage:
+		t.readyForQuery(v)
+	}
+
+	return true, nil
+}
+
+// rowDescription registers a RowDescription (or NoData) of a statement, a portal or a simple query result.
+// It returns false if the message has been requested by Gevulot.
+func (t *queryTracker) rowDescription(desc *pg.RowDescriptionMessage) bool {
+	head := t.head()
+
+	switch head.kind {
+	case responseQuery:
+		head.described = true
+		t.rowMasker = t.masking.RowMasker(desc)
+
+	case responseDescribeStatement:
+		head.statement.rowDescription = desc
+		t.pop()
+
+	case responseDescribePortal:
+		head.portal.rowDescription = desc
+		t.pop()
+
+		return !head.injected
+	}
+
+	return true
+}
+
+// maskRow masks a row of the current result set.
+func (t *queryTracker) maskRow(row *pg.DataRowMessage) error {
+	head := t.head()
+
+	switch head.kind {
+	case responseQuery:
+		if t.rowMasker != nil {
+			return t.rowMasker.MaskRow(row)
+		}
+
+		return nil
+
+	default:
+		if !head.resolved {
+			desc := head.portal.resultDescription()
+
+			// Fail closed: we can't tell which values to mask
+			if desc == nil {
+				return fmt.Errorf("session: DataRow of a portal that has not been described")
+			}
+
+			head.rowMasker = t.masking.RowMasker(desc)
+			head.resolved = true
+		}
+
+		if head.rowMasker != nil {
+			return head.rowMasker.MaskRow(row)
+		}
+
+		return nil
+	}
+}
+
+// copyIn enters COPY FROM STDIN. The database ignores Sync messages until the end of the COPY data,
+// so Syncs sent ahead by the client are not completed with ReadyForQuery.
+func (t *queryTracker) copyIn() {
+	t.phase = phaseCopyIn
+
+	pending := t.pending[:1]
+
+	for _, response := range t.pending[1:] {
+		if response.kind != responseSync {
+			pending = append(pending, response)
+		}
+	}
+
+	t.pending = pending
+}
+
+// currentStatement returns the statement that the database is executing or nil if it's unknown.
+func (t *queryTracker) currentStatement() *pgsql.Statement {
+	switch head := t.head(); {
+	case head.kind == responseQuery && head.completed < len(head.statements):
+		return head.statements[head.completed]
+
+	case head.kind == responseExecute && head.portal.statement != nil:
+		statements := pgsql.SplitStatements(head.portal.statement.query)
+
+		if len(statements) == 1 {
+			return statements[0]
+		}
+	}
+
+	return nil
+}
+
+// requestQuery returns the query of the oldest request awaiting a response; "" if it is unknown.
+func (t *queryTracker) requestQuery() string {
+	switch head := t.head(); {
+	case head == nil:
+		return ""
+
+	case head.kind == responseQuery && head.completed < len(head.statements):
+		return head.statements[head.completed].SQL
+
+	case head.statement != nil:
+		return head.statement.query
+
+	case head.portal != nil && head.portal.statement != nil:
+		return head.portal.statement.query
+	}
+
+	return ""
+}
+
+// copyOut starts masking of COPY TO STDOUT data of the current statement.
+func (t *queryTracker) copyOut(resp *pg.CopyOutResponseMessage) error {
+	var err error
+
+	t.copyMasker, err = t.masking.CopyMasker(t.currentStatement(), resp)
+
+	return err
+}
+
+// commandComplete registers changes of session parameters made by the completed statement.
+func (t *queryTracker) commandComplete(msg *pg.CommandCompleteMessage) {
+	switch msg.Tag {
+	case "ROLLBACK":
+		t.rolledBack = true
+
+	case "SET", "RESET":
+		statement := t.currentStatement()
+
+		if statement == nil {
+			return
+		}
+
+		// NB: reported parameters are tracked with ParameterStatus
+		if setting, ok := statement.SessionSetting(); ok && !isReportedSetting(setting.Name) {
+			t.pendingSetStatements = append(t.pendingSetStatements, &setStatement{setting: setting, sql: statement.SQL})
+		}
+	}
+}
+
+// commitSettings keeps the session parameter changes of the committed transaction.
+func (t *queryTracker) commitSettings() {
+	for _, change := range t.pendingSetStatements {
+		var statements []*setStatement
+
+		for _, statement := range t.setStatements {
+			name := statement.setting.Name
+
+			// NB: RESET ALL doesn't reset the role
+			if change.setting.Name == "all" && name != "role" && name != "session authorization" ||
+				change.setting.Name == name {
+				continue
+			}
+
+			statements = append(statements, statement)
+		}
+
+		if !change.setting.Reset {
+			statements = append(statements, change)
+		}
+
+		t.setStatements = statements
+	}
+
+	t.pendingSetStatements = nil
+}
+
+// sessionSettings returns the statements that restore session parameters changed by the client and not
+// reported by the database (e.g. search_path).
+func (t *queryTracker) sessionSettings() []string {
+	statements := make([]string, len(t.setStatements))
+
+	for i, statement := range t.setStatements {
+		statements[i] = statement.sql
+	}
+
+	return statements
+}
+
+// maskCopyData masks COPY TO STDOUT data. It returns false if the message contains no complete rows yet.
+func (t *queryTracker) maskCopyData(msg *pg.CopyDataMessage) (bool, error) {
+	if t.copyMasker == nil {
+		return true, nil
+	}
+
+	data, err := t.copyMasker.Mask(msg.Data)
+
+	if err != nil {
+		return false, err
+	}
+
+	msg.Data = data
+
+	return len(data) > 0, nil
+}
+
+// error handles ErrorResponse. After an error in the extended query protocol the database discards all
+// messages until Sync, so their responses are not expected anymore.
+func (t *queryTracker) error() {
+	t.copyMasker = nil
+	t.failedQuery = t.requestQuery()
+
+	// NB: the transaction is either aborted or rolled back completely
+	t.rolledBack = true
+
+	// Error aborts COPY
+	if t.phase != phaseStartup {
+		t.phase = phaseReady
+	}
+
+	head := t.head()
+
+	// Simple query (or an asynchronous error) is completed with ReadyForQuery
+	if head == nil || head.kind == responseQuery || head.kind == responseFunctionCall {
+		t.rowMasker = nil
+		return
+	}
+
+	// Failed Parse doesn't replace the statement
+	if head.kind == responseParse && !head.injected && t.statements[head.name] == head.statement {
+		if head.replaced != nil {
+			t.statements[head.name] = head.replaced
+		} else {
+			delete(t.statements, head.name)
+		}
+	}
+
+	if head.kind == responseParse && t.backendStatements != nil && t.backendStatements[head.name] == head.statement {
+		if head.backendReplaced != nil {
+			t.backendStatements[head.name] = head.backendReplaced
+		} else {
+			delete(t.backendStatements, head.name)
+		}
+	}
+
+	for len(t.pending) > 0 && t.pending[0].kind != responseSync {
+		t.pop()
+	}
+
+	// Sync hasn't been sent yet: the following messages are discarded too
+	t.discarding = len(t.pending) == 0
+}
+
+// readyForQuery handles ReadyForQuery that completes Sync or a simple query.
+func (t *queryTracker) readyForQuery(msg *pg.ReadyForQueryMessage) {
+	t.rowMasker, t.copyMasker = nil, nil
+	t.txStatus = msg.TxStatus
+	t.failedQuery = ""
+
+	// NB: ROLLBACK TO SAVEPOINT keeps the changes made in the transaction
+	if msg.TxStatus == pg.TxStatusIdle {
+		if !t.rolledBack {
+			t.commitSettings()
+		}
+
+		t.pendingSetStatements = nil
+	}
+
+	t.rolledBack = false
+
+	// The first ReadyForQuery after authentication isn't a response to any request
+	if t.phase == phaseStartup {
+		t.phase = phaseReady
+	} else {
+		t.pop()
+	}
+
+	// Portals live until the end of the transaction
+	if msg.TxStatus == pg.TxStatusIdle {
+		t.portals = make(map[string]*portal)
+	}
+}
+
+// idle returns true if the session is outside of a transaction block and nothing is in progress, so that
+// the database connection can be handed over to another session.
+func (t *queryTracker) idle() bool {
+	return t.phase == phaseReady && t.txStatus == pg.TxStatusIdle && len(t.pending) == 0 && !t.discarding
+}
+
+// resetBackend starts tracking of statements prepared on a new database connection. It is called every time
+// a pooled session gets a connection.
+func (t *queryTracker) resetBackend() {
+	t.backendStatements = make(map[string]*preparedStatement)
+}
+
+// prepared registers the statement prepared on the current database connection.
+func (t *queryTracker) prepared(name string, statement *preparedStatement) {
+	if t.backendStatements != nil {
+		t.backendStatements[name] = statement
+	}
+}
+
+// reprepare returns Parse of the statement if it hasn't been prepared on the current database connection
+// yet, or nil otherwise. The response to the Parse is hidden from the client.
+func (t *queryTracker) reprepare(name string) *pg.ParseMessage {
+	statement, ok := t.statements[name]
+
+	if t.backendStatements == nil || !ok || statement.parse == nil || t.backendStatements[name] == statement {
+		return nil
+	}
+
+	t.expect(&pendingResponse{kind: responseParse, statement: statement, backendReplaced: t.backendStatements[name],
+		name: name, injected: true})
+	t.prepared(name, statement)
+
+	return statement.parse
+}
+
+// statement returns the prepared statement with the given name. Statements unknown to Gevulot (e.g.
+// created with PREPARE) are registered on the fly.
+func (t *queryTracker) statement(name string) *preparedStatement {
+	statement, ok := t.statements[name]
+
+	if !ok {
+		statement = &preparedStatement{}
+		t.statements[name] = statement
+	}
+
+	return statement
+}
+
+// portal returns the portal with the given name. Portals unknown to Gevulot (e.g. created with DECLARE)
+// are registered on the fly.
+func (t *queryTracker) portal(name string) *portal {
+	p, ok := t.portals[name]
+
+	if !ok {
+		p = &portal{}
+		t.portals[name] = p
+	}
+
+	return p
+}
+
+// expect adds a request to the queue of requests waiting for a response.
+func (t *queryTracker) expect(response *pendingResponse) {
+	t.pending = append(t.pending, response)
+}
+
+// head returns the oldest request waiting for a response or nil if there is none.
+func (t *queryTracker) head() *pendingResponse {
+	if len(t.pending) == 0 {
+		return nil
+	}
+
+	return t.pending[0]
+}
+
+// pop removes the oldest request from the queue.
+func (t *queryTracker) pop() {
+	t.pending[0] = nil
+	t.pending = t.pending[1:]
+}
+
+// resultDescription returns description of the rows returned by the portal or nil if it is unknown.
+func (p *portal) resultDescription() *pg.RowDescriptionMessage {
+	if p.rowDescription != nil {
+		return p.rowDescription
+	}
+
+	if p.statement == nil || p.statement.rowDescription == nil {
+		return nil
+	}
+
+	// Statement description has text formats; actual formats are requested by Bind
+	fields := make([]*pg.FieldDescriptor, len(p.statement.rowDescription.Fields))
+
+	for i, field := range p.statement.rowDescription.Fields {
+		f := *field
+		f.Format = p.bind.ResultFormat(i)
+
+		fields[i] = &f
+	}
+
+	return &pg.RowDescriptionMessage{Fields: fields}
+}
@@ -0,0 +1,483 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masking"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgmeta"
+)
+
+// Fake users table OID
+const usersTableOID = 787969
+
+// testColumnResolver resolves columns of the fake users table.
+type testColumnResolver struct {
+	*pgmeta.ColumnCatalog
+}
+
+func (r testColumnResolver) ResolveColumn(tableOID oid.Oid, index int16) (*pgmeta.Column, bool) {
+	return r.Lookup(tableOID, index)
+}
+
+func (r testColumnResolver) ResolveTable(table pgmeta.Table) ([]*pgmeta.Column, bool) {
+	tableOID, ok := r.LookupTable(table)
+
+	if !ok {
+		return nil, false
+	}
+
+	return r.TableColumns(tableOID), true
+}
+
+func newTestQueryTracker(t *testing.T) *queryTracker {
+	users := pgmeta.Table{Schema: "public", Name: "users"}
+
+	resolver := testColumnResolver{pgmeta.NewColumnCatalog(map[oid.Oid][]*pgmeta.Column{
+		usersTableOID: {
+			{Table: users, Name: "id", Index: 1, TypeOID: oid.T_int4, TypeName: "int4"},
+			{Table: users, Name: "email", Index: 2, TypeOID: oid.T_varchar, TypeName: "varchar"},
+		},
+	})}
+
+	engine, err := masking.NewEngine([]*masking.Rule{{Table: "users", Column: "email", Strategy: "redact"}}, resolver)
+	require.NoError(t, err)
+
+	tracker := newQueryTracker(engine)
+
+	// Complete the startup
+	_, err = tracker.backendMessage(&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+	require.NoError(t, err)
+
+	return tracker
+}
+
+func usersRowDescription(format pg.DataFormat) *pg.RowDescriptionMessage {
+	return &pg.RowDescriptionMessage{
+		Fields: []*pg.FieldDescriptor{
+			{Name: "id", TableOID: usersTableOID, ColumnIndex: 1, DataTypeOID: oid.T_int4, Format: format},
+			{Name: "email", TableOID: usersTableOID, ColumnIndex: 2, DataTypeOID: oid.T_varchar, Format: format},
+		},
+	}
+}
+
+func usersDataRow() *pg.DataRowMessage {
+	return &pg.DataRowMessage{Values: [][]byte{[]byte("1"), []byte("jane@example.com")}}
+}
+
+// sendFrontend feeds messages from the client to the tracker and returns messages sent to the database.
+func sendFrontend(t *testing.T, tracker *queryTracker, messages ...pg.Message) []pg.Message {
+	t.Helper()
+
+	var sent []pg.Message
+
+	for _, msg := range messages {
+		forward, err := tracker.frontendMessage(msg)
+		require.NoError(t, err)
+
+		sent = append(sent, forward...)
+	}
+
+	return sent
+}
+
+// sendBackend feeds messages from the database to the tracker and returns messages forwarded to the client.
+func sendBackend(t *testing.T, tracker *queryTracker, messages ...pg.Message) []pg.Message {
+	t.Helper()
+
+	var forwarded []pg.Message
+
+	for _, msg := range messages {
+		forward, err := tracker.backendMessage(msg)
+		require.NoError(t, err)
+
+		if forward {
+			forwarded = append(forwarded, msg)
+		}
+	}
+
+	return forwarded
+}
+
+func TestQueryTrackerSimpleQuery(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	sendFrontend(t, tracker, &pg.QueryMessage{Query: "SELECT id, email FROM users; SELECT 1"})
+
+	row := usersDataRow()
+	other := &pg.DataRowMessage{Values: [][]byte{[]byte("jane@example.com")}}
+
+	sendBackend(t, tracker,
+		usersRowDescription(pg.DataFormatText),
+		row,
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.RowDescriptionMessage{Fields: []*pg.FieldDescriptor{{Name: "?column?", DataTypeOID: oid.T_text}}},
+		other,
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Equal(t, []byte("***"), row.Values[1])
+	assert.Equal(t, []byte("jane@example.com"), other.Values[0])
+	assert.Empty(t, tracker.pending)
+}
+
+func TestQueryTrackerDescribedPortal(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	// Parse/Bind/Describe/Execute/Sync as sent by most drivers for unnamed statements
+	for _, msg := range []pg.Message{
+		&pg.ParseMessage{Query: "SELECT id, email FROM users"},
+		&pg.BindMessage{ResultFormats: []pg.DataFormat{pg.DataFormatText}},
+		&pg.DescribeMessage{ObjectType: pg.ObjectTypePortal},
+		&pg.ExecuteMessage{},
+		&pg.SyncMessage{},
+	} {
+		assert.Equal(t, []pg.Message{msg}, sendFrontend(t, tracker, msg))
+	}
+
+	row := usersDataRow()
+
+	forwarded := sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.BindCompleteMessage{},
+		usersRowDescription(pg.DataFormatText),
+		row,
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Len(t, forwarded, 6)
+	assert.Equal(t, []byte("***"), row.Values[1])
+	assert.Empty(t, tracker.pending)
+}
+
+func TestQueryTrackerDescribedStatement(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	// Prepare and describe the statement
+	sendFrontend(t, tracker, &pg.ParseMessage{Name: "stmt1", Query: "SELECT id, email FROM users"})
+	sendFrontend(t, tracker, &pg.DescribeMessage{ObjectType: pg.ObjectTypeStatement, Name: "stmt1"})
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+
+	sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.ParameterDescriptionMessage{},
+		usersRowDescription(pg.DataFormatText),
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	// Execute it twice in a pipeline requesting binary email
+	var sent []pg.Message
+
+	for _, msg := range []pg.Message{
+		&pg.BindMessage{Portal: "p1", Statement: "stmt1", ResultFormats: []pg.DataFormat{pg.DataFormatText, pg.DataFormatText}},
+		&pg.ExecuteMessage{Portal: "p1"},
+		&pg.BindMessage{Portal: "p2", Statement: "stmt1", ResultFormats: []pg.DataFormat{pg.DataFormatText, pg.DataFormatBinary}},
+		&pg.ExecuteMessage{Portal: "p2"},
+		&pg.SyncMessage{},
+	} {
+		sent = append(sent, sendFrontend(t, tracker, msg)...)
+	}
+
+	// No need to inject Describe
+	assert.Len(t, sent, 5)
+
+	text, binary := usersDataRow(), usersDataRow()
+
+	sendBackend(t, tracker,
+		&pg.BindCompleteMessage{},
+		text,
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.BindCompleteMessage{},
+		binary,
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Equal(t, []byte("***"), text.Values[1])
+	assert.Equal(t, []byte("***"), binary.Values[1])
+	assert.Empty(t, tracker.pending)
+}
+
+func TestQueryTrackerInjectsDescribe(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	sendFrontend(t, tracker, &pg.ParseMessage{Query: "SELECT id, email FROM users"})
+	sendFrontend(t, tracker, &pg.BindMessage{})
+
+	sent := sendFrontend(t, tracker, &pg.ExecuteMessage{})
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, &pg.DescribeMessage{ObjectType: pg.ObjectTypePortal}, sent[0])
+	assert.Equal(t, &pg.ExecuteMessage{}, sent[1])
+
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+
+	row := usersDataRow()
+
+	forwarded := sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.BindCompleteMessage{},
+		usersRowDescription(pg.DataFormatText),
+		row,
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	// Injected RowDescription is not forwarded
+	assert.Len(t, forwarded, 5)
+	assert.NotContains(t, forwarded, pg.Message(usersRowDescription(pg.DataFormatText)))
+
+	assert.Equal(t, []byte("***"), row.Values[1])
+	assert.Empty(t, tracker.pending)
+}
+
+func TestQueryTrackerError(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	sendFrontend(t, tracker, &pg.ParseMessage{Name: "stmt1", Query: "SELECT id, email FROM users"})
+	sendFrontend(t, tracker, &pg.ParseMessage{Name: "stmt1", Query: "SELECT broken"})
+	sendFrontend(t, tracker, &pg.BindMessage{Statement: "stmt1"})
+	sendFrontend(t, tracker, &pg.ExecuteMessage{})
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+
+	// The second Parse fails; the rest is skipped by the database until Sync
+	sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.ErrorResponseMessage{},
+	)
+
+	assert.Equal(t, "SELECT broken", tracker.failedQuery)
+
+	sendBackend(t, tracker, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	assert.Empty(t, tracker.pending)
+	assert.Empty(t, tracker.failedQuery)
+	assert.Equal(t, "SELECT id, email FROM users", tracker.statements["stmt1"].query)
+
+	// Statements of a simple query fail separately
+	sendFrontend(t, tracker, &pg.QueryMessage{Query: "SELECT 1; SELECT email::int FROM users"})
+
+	sendBackend(t, tracker,
+		&pg.RowDescriptionMessage{},
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.ErrorResponseMessage{},
+	)
+
+	assert.Equal(t, "SELECT email::int FROM users", tracker.failedQuery)
+}
+
+func TestQueryTrackerSessionSettings(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	// query runs a simple query that completes with the given tags (an empty tag stands for an error)
+	query := func(sql string, txStatus pg.TxStatus, tags ...string) {
+		t.Helper()
+
+		sendFrontend(t, tracker, &pg.QueryMessage{Query: sql})
+
+		for _, tag := range tags {
+			if tag == "" {
+				sendBackend(t, tracker, &pg.ErrorResponseMessage{})
+			} else {
+				sendBackend(t, tracker, &pg.CommandCompleteMessage{Tag: tag})
+			}
+		}
+
+		sendBackend(t, tracker, &pg.ReadyForQueryMessage{TxStatus: txStatus})
+	}
+
+	query("SET search_path TO billing; SET TimeZone = 'UTC'; SET LOCAL work_mem = '1GB'", pg.TxStatusIdle, "SET", "SET", "SET")
+	query("SET statement_timeout = 0; SELECT 1 / 0", pg.TxStatusIdle, "SET", "")
+	query("BEGIN; SET lock_timeout = 0", pg.TxStatusActive, "BEGIN", "SET")
+	query("SELECT 1 / 0", pg.TxStatusFailed, "")
+	query("ROLLBACK", pg.TxStatusIdle, "ROLLBACK")
+
+	// Reported parameters, SET LOCAL and rolled back changes are not tracked
+	assert.Equal(t, []string{"SET search_path TO billing"}, tracker.sessionSettings())
+
+	// Extended query protocol
+	sendFrontend(t, tracker,
+		&pg.ParseMessage{Query: "SET ROLE analyst"},
+		&pg.BindMessage{},
+		&pg.ExecuteMessage{},
+		&pg.SyncMessage{},
+	)
+
+	sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.BindCompleteMessage{},
+		&pg.NoDataMessage{},
+		&pg.CommandCompleteMessage{Tag: "SET"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	query("SET search_path = public", pg.TxStatusIdle, "SET")
+	assert.Equal(t, []string{"SET ROLE analyst", "SET search_path = public"}, tracker.sessionSettings())
+
+	// RESET ALL keeps the role
+	query("RESET ALL", pg.TxStatusIdle, "RESET")
+	assert.Equal(t, []string{"SET ROLE analyst"}, tracker.sessionSettings())
+}
+
+func TestQueryTrackerClose(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	sendFrontend(t, tracker, &pg.ParseMessage{Name: "stmt1", Query: "SELECT 1"})
+	sendFrontend(t, tracker, &pg.CloseMessage{ObjectType: pg.ObjectTypeStatement, Name: "stmt1"})
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+
+	sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.CloseCompleteMessage{},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Empty(t, tracker.statements)
+	assert.Empty(t, tracker.pending)
+}
+
+func TestQueryTrackerReprepare(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+	tracker.resetBackend()
+
+	parse := &pg.ParseMessage{Name: "stmt1", Query: "SELECT id, email FROM users"}
+
+	sendFrontend(t, tracker, parse, &pg.SyncMessage{})
+	sendBackend(t, tracker, &pg.ParseCompleteMessage{}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	assert.True(t, tracker.idle())
+
+	// The statement is still prepared on the same connection
+	bind := &pg.BindMessage{Statement: "stmt1"}
+	assert.Equal(t, []pg.Message{bind}, sendFrontend(t, tracker, bind))
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+	sendBackend(t, tracker, &pg.BindCompleteMessage{}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	// The session moves to another connection
+	tracker.resetBackend()
+
+	describe := &pg.DescribeMessage{ObjectType: pg.ObjectTypeStatement, Name: "stmt1"}
+	assert.Equal(t, []pg.Message{parse, describe}, sendFrontend(t, tracker, describe))
+	assert.Equal(t, []pg.Message{bind}, sendFrontend(t, tracker, bind))
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+
+	// ParseComplete of the injected Parse is hidden
+	forwarded := sendBackend(t, tracker,
+		&pg.ParseCompleteMessage{},
+		&pg.ParameterDescriptionMessage{},
+		usersRowDescription(pg.DataFormatText),
+		&pg.BindCompleteMessage{},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	)
+
+	assert.Len(t, forwarded, 4)
+	assert.IsType(t, &pg.ParameterDescriptionMessage{}, forwarded[0])
+
+	// Failed Parse is injected again on the next use
+	tracker.resetBackend()
+
+	assert.Equal(t, []pg.Message{parse, bind}, sendFrontend(t, tracker, bind))
+	sendFrontend(t, tracker, &pg.SyncMessage{})
+	sendBackend(t, tracker, &pg.ErrorResponseMessage{}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	assert.Equal(t, "SELECT id, email FROM users", tracker.statements["stmt1"].query)
+	assert.Equal(t, []pg.Message{parse, bind}, sendFrontend(t, tracker, bind))
+}
+
+func TestQueryTrackerIdle(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+	assert.True(t, tracker.idle())
+
+	sendFrontend(t, tracker, &pg.QueryMessage{Query: "BEGIN"})
+	assert.False(t, tracker.idle())
+
+	sendBackend(t, tracker, &pg.CommandCompleteMessage{Tag: "BEGIN"}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusActive})
+	assert.False(t, tracker.idle())
+
+	sendFrontend(t, tracker, &pg.QueryMessage{Query: "COMMIT"})
+	sendBackend(t, tracker, &pg.CommandCompleteMessage{Tag: "COMMIT"}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+	assert.True(t, tracker.idle())
+}
+
+func TestQueryTrackerUndescribedDataRow(t *testing.T) {
+	tracker := newTestQueryTracker(t)
+
+	// Rows without a preceding query are never forwarded
+	_, err := tracker.backendMessage(usersDataRow())
+	assert.Error(t, err)
+}
+
+func TestQueryTrackerCopyOut(t *testing.T) {
+	t.Run("simple query", func(t *testing.T) {
+		tracker := newTestQueryTracker(t)
+
+		sendFrontend(t, tracker, &pg.QueryMessage{Query: "SELECT 1; COPY users TO STDOUT"})
+
+		first := &pg.CopyDataMessage{Data: []byte("1\tjane@exa")}
+		second := &pg.CopyDataMessage{Data: []byte("mple.com\n2\t\\N\n")}
+
+		forwarded := sendBackend(t, tracker,
+			&pg.RowDescriptionMessage{Fields: []*pg.FieldDescriptor{{Name: "?column?", DataTypeOID: oid.T_int4}}},
+			&pg.DataRowMessage{Values: [][]byte{[]byte("1")}},
+			&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+			&pg.CopyOutResponseMessage{ColumnFormats: []pg.DataFormat{pg.DataFormatText, pg.DataFormatText}},
+			first,
+			second,
+			&pg.CopyDoneMessage{},
+			&pg.CommandCompleteMessage{Tag: "COPY 2"},
+			&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+		)
+
+		// The first chunk contains no complete row
+		assert.NotContains(t, forwarded, first)
+		assert.Contains(t, forwarded, second)
+
+		assert.Equal(t, "1\t***\n2\t\\N\n", string(second.Data))
+		assert.Nil(t, tracker.copyMasker)
+	})
+
+	t.Run("extended query", func(t *testing.T) {
+		tracker := newTestQueryTracker(t)
+
+		for _, msg := range []pg.Message{
+			&pg.ParseMessage{Query: "COPY users (email) TO STDOUT WITH (FORMAT csv)"},
+			&pg.BindMessage{},
+			&pg.ExecuteMessage{},
+			&pg.SyncMessage{},
+		} {
+			sendFrontend(t, tracker, msg)
+		}
+
+		data := &pg.CopyDataMessage{Data: []byte("jane@example.com\n")}
+
+		sendBackend(t, tracker,
+			&pg.ParseCompleteMessage{},
+			&pg.BindCompleteMessage{},
+			&pg.NoDataMessage{},
+			&pg.CopyOutResponseMessage{ColumnFormats: []pg.DataFormat{pg.DataFormatText}},
+			data,
+			&pg.CopyDoneMessage{},
+			&pg.CommandCompleteMessage{Tag: "COPY 1"},
+			&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+		)
+
+		assert.Equal(t, "***\n", string(data.Data))
+		assert.Empty(t, tracker.pending)
+	})
+
+	t.Run("unknown statement", func(t *testing.T) {
+		tracker := newTestQueryTracker(t)
+
+		// COPY executed by a function cannot be masked
+		sendFrontend(t, tracker, &pg.QueryMessage{Query: "SELECT copy_users()"})
+
+		_, err := tracker.backendMessage(&pg.CopyOutResponseMessage{ColumnFormats: []pg.DataFormat{pg.DataFormatText}})
+		assert.Error(t, err)
+	})
+}
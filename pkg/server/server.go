@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"errors"
 	"net"
 	"strings"
@@ -42,6 +43,21 @@ type Server struct {
 	// List of currently active database sessions
 	sessions map[*Session]struct{}
 
+	// Column metadata of the proxied databases shared by all sessions
+	columns *columnCatalogs
+
+	// Key data issued to clients for query cancellation
+	cancels *cancelRegistry
+
+	// Database connection pools shared by pooled sessions
+	pools *backendPools
+
+	// Health checks of the hosts of multi-host database URLs
+	hosts *hostMonitor
+
+	// TLS configuration of client connections; nil if TLS is disabled (guarded by mu)
+	tlsConfig *tls.Config
+
 	// When set, called after Serve successfully set a new listener
 	// but before is started to accept client connections
 	testHookServe func(net.Listener)
@@ -52,8 +68,14 @@ type Server struct {
 
 // NewServer initializes a new Server instance.
 func NewServer(config ConfigStore) *Server {
+	hosts := newHostMonitor()
+
 	return &Server{
 		config:   config,
+		columns:  newColumnCatalogs(config),
+		cancels:  newCancelRegistry(),
+		pools:    newBackendPools(hosts),
+		hosts:    hosts,
 		start:    NewEvent(),
 		shutdown: NewEvent(),
 	}
@@ -64,7 +86,8 @@ func NewServer(config ConfigStore) *Server {
 // to the proxied database.
 //
 // When Server's configuration changed (e.g., when listen port has been modified
-// in the configuration file), Start automatically changes the listener.
+// in the configuration file), Start automatically changes the listener. Changes of the TLS
+// configuration (including the certificate files) apply to new client connections.
 //
 // Start can only be called once per Server instance.
 //
@@ -87,7 +110,7 @@ func (srv *Server) Start() error {
 	defer close(serverConfigurationUpdates)
 
 	err := srv.config.Subscribe(serverConfigurationUpdates, func(oldConfig, newConfig *Config) bool {
-		return oldConfig == nil || oldConfig.Listen != newConfig.Listen
+		return oldConfig == nil || oldConfig.Listen != newConfig.Listen || !oldConfig.TLS.Equal(newConfig.TLS)
 	})
 
 	if err != nil {
@@ -97,10 +120,24 @@ func (srv *Server) Start() error {
 	// For debugging purposes
 	defer log.Debug("server: Start() loop finished")
 
+	// Config that is currently applied
+	var currentConfig *Config
+
 	for {
 		select {
 		// Wait for the new config
 		case config := <-serverConfigurationUpdates:
+			oldConfig := currentConfig
+			currentConfig = config
+
+			if oldConfig == nil || !oldConfig.TLS.Equal(config.TLS) {
+				srv.updateTLSConfig(config.TLS)
+			}
+
+			if oldConfig != nil && oldConfig.Listen == config.Listen {
+				continue
+			}
+
 			log.Infof("server: serving on %s", config.Listen)
 
 			// Initialize a new listener
@@ -186,7 +223,10 @@ func (srv *Server) ServeConn(conn net.Conn) error {
 	log.Infof("server: new client connection from %s", conn.RemoteAddr().String())
 
 	// Initialize a new session
-	session := NewSession(conn, srv.config)
+	session := NewSession(conn, srv.config, srv.columns.resolver, srv.currentTLSConfig())
+	session.cancels = srv.cancels
+	session.pools = srv.pools
+	session.hosts = srv.hosts
 
 	// Register session in the list of active server sessions; the err could be ErrServerClosed
 	err := srv.registerSession(session)
@@ -247,6 +287,10 @@ func (srv *Server) Close() error {
 	}
 	srv.mu.Unlock()
 
+	// Close idle database connections
+	srv.pools.close()
+	srv.hosts.close()
+
 	// Run a watchdog in background that will panic if there are any running goroutines
 	// left after we closed everything.
 	watchdog := time.AfterFunc(time.Second*5, func() {
@@ -262,6 +306,35 @@ func (srv *Server) Close() error {
 	return resultErr
 }
 
+// updateTLSConfig applies the new TLS configuration of client connections. Invalid configuration is
+// logged and ignored: the previous one stays active.
+func (srv *Server) updateTLSConfig(config *TLSConfig) {
+	tlsConfig, err := config.serverConfig()
+
+	if err != nil {
+		log.Errorf("server: invalid TLS configuration, keeping the previous one: %v", err)
+		return
+	}
+
+	srv.mu.Lock()
+	srv.tlsConfig = tlsConfig
+	srv.mu.Unlock()
+
+	if tlsConfig != nil {
+		log.Info("server: TLS configuration for client connections has been loaded")
+	} else {
+		log.Info("server: TLS for client connections is disabled")
+	}
+}
+
+// currentTLSConfig returns TLS configuration of client connections or nil if TLS is disabled.
+func (srv *Server) currentTLSConfig() *tls.Config {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.tlsConfig
+}
+
 // changeListener sets the new Server's listener closing existing one. It can be called concurrently.
 func (srv *Server) changeListener(ln net.Listener) error {
 	// Refuse to change a listener if Server is closed
@@ -1,20 +1,25 @@
 package server
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/hired/gevulot/pkg/masker"
+	"github.com/hired/gevulot/pkg/masking"
 	"github.com/hired/gevulot/pkg/pg"
 )
 
 // Session represents a proxied PostgreSQL database session.
 type Session struct {
-	// Guards dbConnectionParams
+	// Guards dbConnectionParams, database and backendKey
 	mu sync.Mutex
 
 	// Global configuration
@@ -26,9 +31,81 @@ type Session struct {
 	// Connection from the Gevulot to the database
 	dbConn *pg.Conn
 
+	// TLS configuration of the client connection; nil if TLS is disabled
+	tlsConfig *tls.Config
+
+	// The client has sent SSLRequest already
+	sslRequested bool
+
 	// Cached database connection parameters from the config
 	dbConnectionParams pg.ConnectionParams
 
+	// Name of the database in Config.Databases the client is connected to; empty for Config.DatabaseURL
+	database string
+
+	// The session is routed to the replicas of the database
+	readOnly bool
+
+	// Pure SELECT statements outside of a transaction block are routed to the replicas (pooled sessions only)
+	routeSelects bool
+
+	// Connection pools shared by the sessions; only used if pooling is enabled
+	pools *backendPools
+
+	// Health checks of the database hosts; nil if the hosts of multi-host URLs are tried in order
+	hosts *hostMonitor
+
+	// Pool of the session's database; nil unless the session is pooled
+	pool *backendPool
+
+	// Pools of the replicas of the session's database
+	replicaPools []*backendPool
+
+	// Database connection leased from the pool; nil between transactions
+	backend *backendConn
+
+	// Extended query messages received before Sync while no connection is leased (see bufferPipeline)
+	pipeline []pg.Message
+
+	// Session parameters set on every leased connection
+	settings map[string]string
+
+	// Issues key data to the client for query cancellation; nil if the database keys are forwarded as is
+	cancels *cancelRegistry
+
+	// Secret-key data of the database backend
+	backendKey *pg.BackendKeyDataMessage
+
+	// Secret-key data issued to the client in place of backendKey
+	issuedKey *pg.BackendKeyDataMessage
+
+	// Returns resolvers of result set fields to table columns by the database name
+	columns ColumnResolverFn
+
+	// Identity of the client used to pick the masking policy
+	identity *clientIdentity
+
+	// Guards masking, errors, notifications and maskingConfig
+	maskingMu sync.RWMutex
+
+	// Config the masking components are built from
+	maskingConfig *Config
+
+	// Signals startProcessing that the masking components have been rebuilt
+	maskingUpdated chan struct{}
+
+	// Masks query results; initialized during session negotiation (nil if the session is unmasked)
+	masking *masking.Engine
+
+	// Tracks queries, prepared statements and portals to mask their results
+	queries *queryTracker
+
+	// Masks values leaked through error and notice messages
+	errors *masking.ErrorScrubber
+
+	// Masks payloads of asynchronous notifications
+	notifications *masking.NotificationMasker
+
 	// ┌──────────┐                  ┌─────────────────┐                  ┌──────────┐
 	// │          │◀───── dbOut ─────│                 │◀─── clientIn ────│          │
 	// │    DB    │                  │     Gevulot     │                  │  Client  │
@@ -44,6 +121,9 @@ type Session struct {
 	dbIn      chan pg.Message // DB -> Gevulot
 	dbOut     chan pg.Message // Gevulot -> DB
 
+	// Fired when the session is established (i.e., after the first ReadyForQuery)
+	ready *Event
+
 	// Fired when session is closed
 	closed *Event
 }
@@ -51,19 +131,36 @@ type Session struct {
 var (
 	// ErrSessionClosed is returned by the Server's Start after a call to Close.
 	ErrSessionClosed = errors.New("session: Session closed")
+
+	// errCancelRequestServed is returned by negotiateSessionParams when the client connected to cancel a query
+	// in another session rather than to start a new one.
+	errCancelRequestServed = errors.New("session: cancel request served")
+
+	// errDatabaseRemoved is returned by applyMaskingPolicy when the session's database is no longer configured.
+	errDatabaseRemoved = errors.New("session: database has been removed from the config")
 )
 
-// NewSession initializes a new Session.
-func NewSession(client net.Conn, config ConfigStore) *Session {
+// ColumnResolverFn returns the column resolver of the database with the given name in Config.Databases
+// (empty for the database from Config.DatabaseURL).
+type ColumnResolverFn func(database string) masking.ColumnResolver
+
+// NewSession initializes a new Session. Clients requesting SSL are served with the given TLS configuration;
+// SSL is denied if it is nil.
+func NewSession(client net.Conn, config ConfigStore, columns ColumnResolverFn, tlsConfig *tls.Config) *Session {
 	return &Session{
 		cfg:        config,
 		clientConn: pg.NewConn(client),
+		columns:    columns,
+		tlsConfig:  tlsConfig,
+
+		maskingUpdated: make(chan struct{}, 1),
 
 		clientIn:  make(chan pg.Message, 64),
 		clientOut: make(chan pg.Message, 64),
 		dbIn:      make(chan pg.Message, 64),
 		dbOut:     make(chan pg.Message, 64),
 
+		ready:  NewEvent(),
 		closed: NewEvent(),
 	}
 }
@@ -85,12 +182,36 @@ func (s *Session) Start() error {
 	// Initialize connection params (SSL, encoding, etc.)
 	err := s.negotiateSessionParams()
 
+	if errors.Is(err, errCancelRequestServed) {
+		return nil
+	}
+
 	if err != nil {
 		return err
 	}
 
+	// Re-evaluate the masking policy on config reload
+	go s.watchConfig()
+
 	g := errgroup.Group{}
 
+	if s.pool != nil {
+		err = s.startPooled(&g)
+	} else {
+		err = s.startDirect(&g)
+	}
+
+	if err != nil {
+		log.Errorf("session: error: %v", err)
+	} else {
+		log.Info("session: completed")
+	}
+
+	return err
+}
+
+// startDirect runs the goroutines of a session with its own database connection.
+func (s *Session) startDirect(g *errgroup.Group) error {
 	// Run session goroutines capturing errors
 	g.Go(s.startClientInPump)
 	g.Go(s.startClientOutPump)
@@ -99,15 +220,36 @@ func (s *Session) Start() error {
 	g.Go(s.startProcessing)
 
 	// Wait for the first error (or successful exit)
-	err = g.Wait()
+	return g.Wait()
+}
 
-	if err != nil {
-		log.Errorf("session: error: %v", err)
-	} else {
-		log.Info("session: completed")
-	}
+// startPooled runs the goroutines of a pooled session. The session is closed as soon as either the client
+// hangs up or the processing stops, so that the leased database connection is released.
+func (s *Session) startPooled(g *errgroup.Group) error {
+	processed := NewEvent()
 
-	return err
+	g.Go(func() error {
+		err := s.startClientInPump()
+		_ = s.Close()
+
+		// NB: the processing goroutine closes the client connection once it's done
+		if processed.HasFired() {
+			return nil
+		}
+
+		return err
+	})
+
+	g.Go(func() error {
+		err := s.startPooledProcessing()
+
+		processed.Fire()
+		_ = s.clientConn.Close()
+
+		return err
+	})
+
+	return g.Wait()
 }
 
 // Close immediately closes Session's underlying network connections.
@@ -135,6 +277,13 @@ func (s *Session) Close() (err error) {
 		log.Debugf("session: db connection is closed; err = %v", err)
 	}
 
+	// Cancel requests cannot refer to this session anymore
+	s.mu.Lock()
+	if s.issuedKey != nil {
+		s.cancels.unregister(s.issuedKey)
+	}
+	s.mu.Unlock()
+
 	// Close all message channels — this will stop out pumps and processing goroutine
 	close(s.clientIn)
 	close(s.clientOut)
@@ -152,85 +301,585 @@ func (s *Session) negotiateSessionParams() error {
 	log.Debug("session: waiting for the client startup message")
 
 	// Receiving initial startup message from the client. It contains username, database name etc.
-	startupMessage, err := s.clientConn.RecvStartupMessage()
+	initialMessage, err := s.clientConn.RecvInitialMessage()
 
 	if err != nil {
 		return err
 	}
 
+	// Clients cancel queries over new connections
+	if cancelRequest, ok := initialMessage.(*pg.CancelRequestMessage); ok {
+		s.forwardCancelRequest(cancelRequest)
+		return errCancelRequestServed
+	}
+
+	startupMessage := initialMessage.(*pg.StartupMessage)
+
 	// Check if startup message is a SSL request
 	if startupMessage.ProtocolVersion == pg.SSLRequestMagic {
-		log.Info("session: client requested SSL; denying")
-
-		err = s.clientConn.SendByte('N')
+		err = s.negotiateTLS()
 
 		if err != nil {
 			return err
 		}
 
-		// DANGER! There is a possibility of an infinite loop here.
+		// The startup message follows
 		return s.negotiateSessionParams()
 	}
 
 	// Check the protocol version just in case
-	if startupMessage.ProtocolVersion != pg.DefaultProtocolVersion {
-		return fmt.Errorf("session: unsupported PG protocol version %v", startupMessage.ProtocolVersion)
+	if version := startupMessage.ProtocolVersion; version != pg.DefaultProtocolVersion {
+		return s.rejectClient(fmt.Errorf("session: unsupported PG protocol version %v", version), sqlStateFeatureNotSupported,
+			fmt.Sprintf("unsupported frontend protocol %d.%d: server supports 3.0 to 3.0", version>>16, version&0xffff))
+	}
+
+	if startupMessage.GetParameter("user") == "" {
+		return s.rejectClient(fmt.Errorf("session: no user name in the startup message"), sqlStateInvalidAuthorization,
+			"no PostgreSQL user name specified in startup packet")
 	}
 
-	// Check that client is trying to connect to the database that we are proxying
-	allowedDB, err := s.getDBConnnectionParam("database")
+	// Find the database that the client is trying to connect to
+	startupMessage, err = s.routeDatabase(startupMessage)
 
 	if err != nil {
 		return err
 	}
 
-	if dbName := startupMessage.GetParameter("database"); dbName != allowedDB {
-		return fmt.Errorf("session: database mismatch: %v != %v", dbName, allowedDB)
+	// Masking policy is chosen by the client identity
+	s.identity = newClientIdentity(s.clientConn.Unwrap(), startupMessage)
+
+	// Initialize masking rules
+	err = s.initMasking()
+
+	if err != nil {
+		return s.rejectClient(err, sqlStateInternalError, "could not initialize masking")
+	}
+
+	config, err := s.cfg.Get()
+
+	if err != nil {
+		return s.rejectClient(err, sqlStateInternalError, "could not read the configuration")
+	}
+
+	// Read-only sessions are routed to the replicas
+	startupMessage = s.routeReadOnly(startupMessage, config)
+
+	// Without proxy-side authentication the client authenticates with the database directly
+	if config.Auth == nil {
+		return s.connectToDB(startupMessage, false)
+	}
+
+	err = s.authenticateClient(startupMessage, config.Auth)
+
+	if err != nil {
+		return err
 	}
 
-	// Establish DB connection on behalf of the client
-	return s.establishDBConnection(startupMessage)
+	// Pooled sessions share database connections logged in as the user from the database URL
+	if config.Pool != nil {
+		return s.joinPool(startupMessage, config)
+	}
+
+	// Log into the database as the user from the database URL
+	err = s.connectToDB(startupMessage, true)
+
+	if err != nil {
+		return err
+	}
+
+	return s.authenticateDB()
+}
+
+// connectToDB establishes the database connection; read-only sessions try the replicas first. If asDBUser is
+// true, the session logs in as the user from the database URL. The client is rejected if the database is
+// not available.
+func (s *Session) connectToDB(startupMessage *pg.StartupMessage, asDBUser bool) error {
+	if s.readOnly && s.connectToReplica(startupMessage, asDBUser) {
+		return nil
+	}
+
+	if asDBUser {
+		var err error
+
+		startupMessage, err = s.withDBUser(startupMessage)
+
+		if err != nil {
+			return s.rejectClient(err, sqlStateInternalError, "could not read the configuration")
+		}
+	}
+
+	err := s.establishDBConnection(startupMessage)
+
+	if err != nil {
+		return s.rejectClient(err, sqlStateConnectionFailure, "could not connect to the database")
+	}
+
+	return nil
+}
+
+// routeDatabase finds the proxied database by the name in the startup message. Databases from Config.Databases
+// take precedence over the one from Config.DatabaseURL. It returns the startup message to send to the database,
+// which refers to the database by its real name. Clients connecting to an unknown database are rejected.
+func (s *Session) routeDatabase(startupMessage *pg.StartupMessage) (*pg.StartupMessage, error) {
+	config, err := s.cfg.Get()
+
+	if err != nil {
+		return nil, s.rejectClient(err, sqlStateInternalError, "could not read the configuration")
+	}
+
+	name := startupMessage.GetParameter("database")
+
+	// Database name defaults to the user name as in PostgreSQL
+	if name == "" {
+		name = startupMessage.GetParameter("user")
+	}
+
+	if db, ok := config.Databases[name]; ok {
+		params, err := pg.ParseConnString(db.URL)
+
+		if err != nil {
+			return nil, s.rejectClient(err, sqlStateInternalError, "could not read the configuration")
+		}
+
+		s.mu.Lock()
+		s.database = name
+		s.dbConnectionParams = params
+		s.mu.Unlock()
+
+		return withStartupParameter(startupMessage, "database", params["database"]), nil
+	}
+
+	if config.DatabaseURL != "" {
+		defaultDB, err := s.getDBConnnectionParam("database")
+
+		if err != nil {
+			return nil, s.rejectClient(err, sqlStateInternalError, "could not read the configuration")
+		}
+
+		if name == defaultDB {
+			return withStartupParameter(startupMessage, "database", defaultDB), nil
+		}
+	}
+
+	return nil, s.rejectClient(fmt.Errorf("session: unknown database %s", name), sqlStateInvalidCatalogName,
+		fmt.Sprintf("database \"%s\" does not exist", name))
+}
+
+// withoutStartupParameter returns a copy of the startup message without the parameter. The message is returned
+// as is if there is no such parameter.
+func withoutStartupParameter(startupMessage *pg.StartupMessage, name string) *pg.StartupMessage {
+	msg := &pg.StartupMessage{ProtocolVersion: startupMessage.ProtocolVersion}
+
+	for _, param := range startupMessage.Parameters {
+		if param.Name != name {
+			msg.Parameters = append(msg.Parameters, param)
+		}
+	}
+
+	if len(msg.Parameters) == len(startupMessage.Parameters) {
+		return startupMessage
+	}
+
+	return msg
+}
+
+// withStartupParameter returns a copy of the startup message with the parameter set to the given value.
+func withStartupParameter(startupMessage *pg.StartupMessage, name, value string) *pg.StartupMessage {
+	msg := &pg.StartupMessage{ProtocolVersion: startupMessage.ProtocolVersion}
+	found := false
+
+	for _, param := range startupMessage.Parameters {
+		if param.Name == name {
+			param = &pg.StartupMessageParameter{Name: name, Value: value}
+			found = true
+		}
+
+		msg.Parameters = append(msg.Parameters, param)
+	}
+
+	if !found {
+		msg.Parameters = append(msg.Parameters, &pg.StartupMessageParameter{Name: name, Value: value})
+	}
+
+	return msg
+}
+
+// negotiateTLS answers the client's SSLRequest: it either denies SSL or performs TLS handshake and
+// continues the session over the encrypted connection.
+func (s *Session) negotiateTLS() error {
+	// SSLRequest is only allowed once, before the startup message
+	if s.sslRequested {
+		return s.rejectClient(fmt.Errorf("session: repeated SSLRequest"), sqlStateProtocolViolation,
+			"unsupported frontend protocol 1234.5679: server supports 3.0 to 3.0")
+	}
+
+	s.sslRequested = true
+
+	if s.tlsConfig == nil {
+		log.Info("session: client requested SSL; denying")
+
+		return s.clientConn.SendByte('N')
+	}
+
+	log.Debug("session: client requested SSL; starting TLS handshake")
+
+	err := s.clientConn.SendByte('S')
+
+	if err != nil {
+		return err
+	}
+
+	tlsConn := tls.Server(s.clientConn.Unwrap(), s.tlsConfig)
+
+	err = tlsConn.Handshake()
+
+	if err != nil {
+		return fmt.Errorf("session: TLS handshake failed: %w", err)
+	}
+
+	s.clientConn = pg.NewConn(tlsConn)
+
+	return nil
 }
 
 // establishDBConnection connects to the database using connection parameters from the config.
+// The connection is encrypted according to the sslmode parameter. Hosts of a multi-host URL are tried in the order
+// of their health and role (see hostMonitor).
 func (s *Session) establishDBConnection(startupMessage pg.Message) error {
-	// Get database connection params from the config
-	host, err := s.getDBConnnectionParam("host")
+	params, err := s.getDBConnectionParams()
 
 	if err != nil {
 		return err
 	}
 
-	port, err := s.getDBConnnectionParam("port")
+	var response pg.Message
+
+	s.dbConn, response, params, err = dialDBHost(s.hosts, params, startupMessage)
 
 	if err != nil {
 		return err
 	}
 
-	// Connect to the database
-	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	// NB: cancel requests are sent to the connected host
+	s.setDBConnectionParams(params)
+
+	// The response is processed as usual
+	if response != nil {
+		s.dbIn <- response
+	}
+
+	return nil
+}
+
+// dialDB connects to the database and sends the startup message. With sslmode=allow the first response of
+// the database has to be received to find out whether the non-SSL connection is accepted; it is returned
+// along with the connection (nil otherwise).
+func dialDB(params pg.ConnectionParams, startupMessage pg.Message) (*pg.Conn, pg.Message, error) {
+	tlsConfig, err := newDBTLSConfig(params)
 
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	// Convert to pg.NewConn
-	s.dbConn = pg.NewConn(conn)
+	// NB: allow tries a non-SSL connection first
+	useTLS := tlsConfig.mode != sslModeDisable && tlsConfig.mode != sslModeAllow
+
+	conn, err := connectDB(params, tlsConfig, useTLS)
+
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Send initial startup message that we received from the client
-	err = s.dbConn.SendMessage(startupMessage)
+	err = conn.SendMessage(startupMessage)
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if tlsConfig.mode != sslModeAllow {
+		return conn, nil, nil
+	}
+
+	// Database may reject non-SSL connections (e.g. hostssl entries in pg_hba.conf)
+	response, err := conn.RecvBackendMessage()
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if _, ok := response.(*pg.ErrorResponseMessage); !ok {
+		return conn, response, nil
+	}
+
+	log.Info("session: database rejected non-SSL connection; retrying with SSL")
+
+	_ = conn.Close()
+
+	conn, err = connectDB(params, tlsConfig, true)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = conn.SendMessage(startupMessage)
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, nil, nil
+}
+
+// connectDB opens a network connection to the database. If useTLS is true, it negotiates SSL first: depending
+// on the SSL mode, the connection either falls back to a non-SSL one or fails if SSL is not available.
+func connectDB(params pg.ConnectionParams, tlsConfig *dbTLSConfig, useTLS bool) (*pg.Conn, error) {
+	var dialer net.Dialer
+
+	if timeout, err := strconv.Atoi(params["connect_timeout"]); err == nil && timeout > 0 {
+		dialer.Timeout = time.Duration(timeout) * time.Second
+	}
+
+	// NB: as in libpq, hostaddr saves a name lookup; the host name is still used to verify the certificate
+	address := params["hostaddr"]
+
+	if address == "" {
+		address = params["host"]
+	}
+
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(address, params["port"]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !useTLS {
+		return pg.NewConn(conn), nil
+	}
+
+	// NB: connect_timeout limits SSL negotiation too, so that a host that accepts connections but doesn't answer
+	// cannot hang the session
+	if dialer.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(dialer.Timeout))
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	dbConn := pg.NewConn(conn)
+
+	accepted, err := dbConn.RequestSSL()
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !accepted {
+		if tlsConfig.mode == sslModePrefer {
+			log.Info("session: database doesn't support SSL; using non-SSL connection")
+			return dbConn, nil
+		}
+
+		conn.Close()
+
+		return nil, fmt.Errorf("session: database doesn't support SSL, but sslmode is %s", tlsConfig.mode)
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig.config)
+
+	err = tlsConn.Handshake()
+
+	if err != nil {
+		conn.Close()
+
+		// NB: prefer retries without SSL as libpq does
+		if tlsConfig.mode == sslModePrefer {
+			log.Warnf("session: TLS handshake with the database failed: %v; retrying without SSL", err)
+			return connectDB(params, tlsConfig, false)
+		}
+
+		return nil, fmt.Errorf("session: TLS handshake with the database failed: %w", err)
+	}
+
+	return pg.NewConn(tlsConn), nil
+}
+
+// initMasking initializes the masking engine with the rules from the config according to the session's policy.
+func (s *Session) initMasking() error {
+	config, err := s.cfg.Get()
 
 	if err != nil {
 		return err
 	}
 
+	err = s.applyMaskingPolicy(config)
+
+	if err != nil {
+		return err
+	}
+
+	s.queries = newQueryTracker(s.currentMasking())
+
+	return nil
+}
+
+// applyMaskingPolicy evaluates the masking policy of the session and rebuilds the masking components from
+// the given config. Unmasked sessions get no masking components at all.
+func (s *Session) applyMaskingPolicy(config *Config) error {
+	rules := config.Mask
+
+	if s.database != "" {
+		db, ok := config.Databases[s.database]
+
+		if !ok {
+			return fmt.Errorf("%w: %s", errDatabaseRemoved, s.database)
+		}
+
+		rules = db.Mask
+	}
+
+	policy, mode := sessionPolicy(config.Policies, s.identity)
+
+	if policy != nil {
+		log.Infof("session: masking policy %s (%s)", policy.Name, mode)
+	} else {
+		log.Infof("session: no matching masking policy (%s)", mode)
+	}
+
+	var (
+		engine        *masking.Engine
+		scrubber      *masking.ErrorScrubber
+		notifications *masking.NotificationMasker
+	)
+
+	if mode != PolicyUnmasked {
+		keyring, err := masker.NewKeyring(config.MaskingKeys)
+
+		if err != nil {
+			return err
+		}
+
+		registry := masker.DefaultRegistry()
+		registry.SetKeyring(keyring)
+
+		engine, err = masking.NewEngineWithRegistry(rules, s.columnResolver(), registry)
+
+		if err != nil {
+			return err
+		}
+
+		scrubber, err = masking.NewErrorScrubber(engine, config.ErrorMasking)
+
+		if err != nil {
+			return err
+		}
+
+		notifications, err = masking.NewNotificationMasker(config.MaskNotifications, registry)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	s.maskingMu.Lock()
+	defer s.maskingMu.Unlock()
+
+	s.masking = engine
+	s.errors = scrubber
+	s.notifications = notifications
+	s.maskingConfig = config
+
 	return nil
 }
 
+// columnResolver returns the column resolver of the session's database.
+func (s *Session) columnResolver() masking.ColumnResolver {
+	if s.columns == nil {
+		return nil
+	}
+
+	return s.columns(s.database)
+}
+
+// currentMasking returns the masking engine of the session.
+func (s *Session) currentMasking() *masking.Engine {
+	s.maskingMu.RLock()
+	defer s.maskingMu.RUnlock()
+
+	return s.masking
+}
+
+// watchConfig re-evaluates the masking policy of the session on every config update until the session is closed.
+// The session keeps the current masking if the new config cannot be applied, and is closed if its database has
+// been removed from the config.
+func (s *Session) watchConfig() {
+	updates := make(chan *Config, 1)
+
+	err := s.cfg.Subscribe(updates)
+
+	if err != nil {
+		log.Errorf("session: can't watch config updates: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case config := <-updates:
+			s.maskingMu.RLock()
+			applied := config == s.maskingConfig
+			s.maskingMu.RUnlock()
+
+			if applied {
+				continue
+			}
+
+			err := s.applyMaskingPolicy(config)
+
+			if errors.Is(err, errDatabaseRemoved) {
+				log.Errorf("session: can't apply the new config: %v; closing", err)
+
+				_ = s.Close()
+
+				continue
+			}
+
+			if err != nil {
+				log.Errorf("session: can't apply the new config: %v; keeping the current masking", err)
+				continue
+			}
+
+			// NB: a pending signal covers this update as well
+			select {
+			case s.maskingUpdated <- struct{}{}:
+			default:
+			}
+
+		case <-s.closed.Done():
+			// NB: the distributor may be blocked sending to the channel, so keep draining it until unsubscribed
+			unsubscribed := make(chan struct{})
+
+			go func() {
+				s.cfg.Unsubscribe(updates)
+				close(unsubscribed)
+			}()
+
+			for {
+				select {
+				case <-updates:
+				case <-unsubscribed:
+					return
+				}
+			}
+		}
+	}
+}
+
 // startClientInPump pumps messages from the client into the clientIn channel.
 func (s *Session) startClientInPump() error {
 	for {
-		message, err := s.clientConn.RecvMessage()
+		message, err := s.clientConn.RecvFrontendMessage()
 
 		if err != nil {
 			return err
@@ -260,10 +909,10 @@ func (s *Session) startClientOutPump() error {
 	}
 }
 
-// startClientOutPump pumps messages from the database into the dbIn channel.
+// startDBInPump pumps messages from the database into the dbIn channel.
 func (s *Session) startDBInPump() error {
 	for {
-		message, err := s.dbConn.RecvMessage()
+		message, err := s.dbConn.RecvBackendMessage()
 
 		if err != nil {
 			return err
@@ -293,9 +942,10 @@ func (s *Session) startDBOutPump() error {
 	}
 }
 
-// startProcessing dispatches messages between the database and the client.
+// startProcessing dispatches messages between the client and the database. Asynchronous messages (NoticeResponse,
+// ParameterStatus and NotificationResponse) are forwarded in the order they arrive, so that e.g. notices precede
+// ReadyForQuery of their query. Messages that violate the protocol terminate the session.
 func (s *Session) startProcessing() error {
-	// FIXME: temporary just proxy everything from the client to the database and vice versa
 	for {
 		select {
 		case clientMsg, ok := <-s.clientIn:
@@ -303,43 +953,112 @@ func (s *Session) startProcessing() error {
 				return nil
 			}
 
-			log.Infof("-> %s", clientMsg.Frame().Bytes())
+			// NB: tracker may inject additional messages (e.g. Describe before Execute)
+			messages, err := s.queries.frontendMessage(clientMsg)
 
-			s.dbOut <- clientMsg
+			if err != nil {
+				s.clientOut <- newFatalErrorMessage(sqlStateProtocolViolation, "protocol violation")
+				return err
+			}
+
+			for _, msg := range messages {
+				s.dbOut <- msg
+			}
 
 		case dbMsg, ok := <-s.dbIn:
 			if !ok {
 				return nil
 			}
 
-			log.Infof("<- %s", dbMsg.Frame().Bytes())
+			forward := true
+
+			if !isAsyncMessage(dbMsg) {
+				var err error
+
+				forward, err = s.queries.backendMessage(dbMsg)
 
-			s.clientOut <- dbMsg
+				if err != nil {
+					s.clientOut <- newFatalErrorMessage(sqlStateConnectionFailure, "lost synchronization with the database")
+					return err
+				}
+			}
+
+			if forward {
+				if key, ok := dbMsg.(*pg.BackendKeyDataMessage); ok {
+					issued, err := s.issueBackendKey(key)
+
+					if err != nil {
+						return err
+					}
+
+					dbMsg = issued
+				}
+
+				s.scrubMessage(dbMsg, s.queries.failedQuery)
+				s.clientOut <- dbMsg
+			}
+
+			if _, ok := dbMsg.(*pg.ReadyForQueryMessage); ok {
+				s.ready.Fire()
+			}
+
+		case <-s.maskingUpdated:
+			// NB: result sets in progress keep their maskers; the new policy applies to the next ones
+			s.queries.masking = s.currentMasking()
 		}
 	}
 }
 
+// scrubMessage masks values of the masked columns in error and notice messages, and payloads of notifications.
+// failedQuery is the query of the request that has failed with the error ("" if unknown).
+func (s *Session) scrubMessage(msg pg.Message, failedQuery string) {
+	s.maskingMu.RLock()
+	defer s.maskingMu.RUnlock()
+
+	switch v := msg.(type) {
+	case *pg.ErrorResponseMessage:
+		v.Fields = s.errors.Scrub(v.Fields, failedQuery)
+
+	case *pg.NoticeResponseMessage:
+		v.Fields = s.errors.Scrub(v.Fields, "")
+
+	case *pg.NotificationResponseMessage:
+		s.notifications.Mask(v)
+	}
+}
+
 // getDBConnnectionParam returns connection parameter with given name from the config.
 func (s *Session) getDBConnnectionParam(name string) (string, error) {
+	params, err := s.getDBConnectionParams()
+
+	if err != nil {
+		return "", err
+	}
+
+	return params[name], nil
+}
+
+// getDBConnectionParams returns database connection parameters from the config.
+func (s *Session) getDBConnectionParams() (pg.ConnectionParams, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// First call to getDBConnnectionParam — parse params from the config
+	// First call to getDBConnectionParams — parse params from the config
 	if s.dbConnectionParams == nil {
 		// Get() will block until we have a config
 		config, err := s.cfg.Get()
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		// Parse database URI
-		s.dbConnectionParams, err = pg.ParseDatabaseURI(config.DatabaseURL)
+		s.dbConnectionParams, err = pg.ParseConnString(config.DatabaseURL)
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
-	return s.dbConnectionParams[name], nil
+	return s.dbConnectionParams, nil
 }
@@ -0,0 +1,364 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/auth"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// authenticateClient authenticates the client with the credentials from the auth config.
+func (s *Session) authenticateClient(startupMessage *pg.StartupMessage, config *AuthConfig) error {
+	user := startupMessage.GetParameter("user")
+	password, known := config.password(user)
+
+	var err error
+
+	// NB: MD5 cannot be used with SCRAM secrets, so such users are authenticated with SCRAM as PostgreSQL does.
+	// Unknown users get an MD5 challenge too, so the type of the challenge doesn't reveal whether the user exists.
+	if config.Method == AuthMethodMD5 && (!known || !auth.IsSCRAMSecret(password)) {
+		err = s.authenticateClientMD5(user, password, known)
+	} else {
+		err = s.authenticateClientSCRAM(user, password, known)
+	}
+
+	if err != nil {
+		// NB: the same error is reported regardless of the reason, so clients cannot probe user names
+		return s.rejectClient(fmt.Errorf("session: authentication of user %s failed: %w", user, err), sqlStateInvalidPassword,
+			fmt.Sprintf("password authentication failed for user \"%s\"", user))
+	}
+
+	log.Infof("session: user %s has been authenticated", user)
+
+	return nil
+}
+
+// authenticateClientMD5 authenticates the client with the MD5 hashed password. Unknown users are sent
+// a challenge with a random salt as well, and always fail.
+func (s *Session) authenticateClientMD5(user, password string, known bool) error {
+	msg := &pg.AuthenticationMD5PasswordMessage{}
+
+	_, err := rand.Read(msg.Salt[:])
+
+	if err != nil {
+		return err
+	}
+
+	err = s.clientConn.SendMessage(msg)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := s.recvClientAuthResponse()
+
+	if err != nil {
+		return err
+	}
+
+	passwordMessage, ok := response.(*pg.PasswordMessage)
+
+	if !ok {
+		return fmt.Errorf("session: unexpected %T in response to MD5 password request", response)
+	}
+
+	if !known || password == "" {
+		return auth.ErrAuthenticationFailed
+	}
+
+	if !auth.IsMD5Password(password) {
+		password = auth.MD5Password(user, password)
+	}
+
+	expected := auth.MD5Response(password, msg.Salt)
+
+	if subtle.ConstantTimeCompare([]byte(passwordMessage.Password), []byte(expected)) != 1 {
+		return auth.ErrAuthenticationFailed
+	}
+
+	return nil
+}
+
+// authenticateClientSCRAM authenticates the client with SCRAM-SHA-256. Unknown users go through the whole
+// exchange with a random secret, so they cannot be told apart from the users with a wrong password.
+func (s *Session) authenticateClientSCRAM(user, password string, known bool) error {
+	var (
+		secret *auth.SCRAMSecret
+		err    error
+	)
+
+	// NB: MD5 hashes cannot be used for SCRAM
+	valid := known && password != "" && !auth.IsMD5Password(password)
+
+	switch {
+	case valid && auth.IsSCRAMSecret(password):
+		secret, err = auth.ParseSCRAMSecret(password)
+
+	case valid:
+		secret = auth.NewSCRAMSecretWithSalt(password, scramSalt(user))
+
+	default:
+		secret, err = newMockSCRAMSecret(user)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = s.clientConn.SendMessage(&pg.AuthenticationSASLMessage{Mechanisms: []string{auth.SCRAMSHA256}})
+
+	if err != nil {
+		return err
+	}
+
+	response, err := s.recvClientAuthResponse()
+
+	if err != nil {
+		return err
+	}
+
+	initialResponse, ok := response.(*pg.SASLInitialResponseMessage)
+
+	if !ok || initialResponse.Mechanism != auth.SCRAMSHA256 {
+		return fmt.Errorf("session: client didn't select %s mechanism", auth.SCRAMSHA256)
+	}
+
+	server := auth.NewSCRAMServer(secret)
+	serverFirst, err := server.First(initialResponse.Data)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.clientConn.SendMessage(&pg.AuthenticationSASLContinueMessage{Data: serverFirst})
+
+	if err != nil {
+		return err
+	}
+
+	response, err = s.recvClientAuthResponse()
+
+	if err != nil {
+		return err
+	}
+
+	finalResponse, ok := response.(*pg.SASLResponseMessage)
+
+	if !ok {
+		return fmt.Errorf("session: unexpected %T in SCRAM exchange", response)
+	}
+
+	serverFinal, err := server.Final(finalResponse.Data)
+
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return auth.ErrAuthenticationFailed
+	}
+
+	return s.clientConn.SendMessage(&pg.AuthenticationSASLFinalMessage{Data: serverFinal})
+}
+
+// recvClientAuthResponse receives the client's response to an authentication request.
+func (s *Session) recvClientAuthResponse() (pg.Message, error) {
+	msg, err := s.clientConn.RecvFrontendMessage()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAuthenticationResponse(msg) {
+		return nil, fmt.Errorf("%w: unexpected %T from the client during authentication", ErrProtocolViolation, msg)
+	}
+
+	return msg, nil
+}
+
+// authenticateDB logs into the database with the credentials from the database URL on behalf of the client,
+// then lets the client know that the authentication has completed.
+func (s *Session) authenticateDB() error {
+	params, err := s.getDBConnectionParams()
+
+	if err == nil {
+		err = exchangeDBCredentials(s.dbConn, params, s.recvDBStartupMessage)
+	}
+
+	if err == nil {
+		return s.clientConn.SendMessage(&pg.AuthenticationOkMessage{})
+	}
+
+	// NB: the database error is not forwarded, so the real database user is not disclosed to the client
+	return s.rejectClient(err, sqlStateConnectionFailure, "could not log into the database")
+}
+
+// exchangeDBCredentials answers the database's authentication requests received with recv until
+// the authentication has completed.
+func exchangeDBCredentials(conn *pg.Conn, params pg.ConnectionParams, recv func() (pg.Message, error)) error {
+	user, password := params["user"], params["password"]
+
+	var scram *auth.SCRAMClient
+
+	for {
+		msg, err := recv()
+
+		if err != nil {
+			return err
+		}
+
+		var response pg.Message
+
+		switch v := msg.(type) {
+		case *pg.AuthenticationOkMessage:
+			return nil
+
+		case *pg.AuthenticationCleartextPasswordMessage:
+			response = &pg.PasswordMessage{Password: password}
+
+		case *pg.AuthenticationMD5PasswordMessage:
+			response = &pg.PasswordMessage{Password: auth.MD5Response(auth.MD5Password(user, password), v.Salt)}
+
+		case *pg.AuthenticationSASLMessage:
+			if !containsString(v.Mechanisms, auth.SCRAMSHA256) {
+				return fmt.Errorf("session: database doesn't support %s: %v", auth.SCRAMSHA256, v.Mechanisms)
+			}
+
+			scram = auth.NewSCRAMClient(password)
+			data, err := scram.First()
+
+			if err != nil {
+				return err
+			}
+
+			response = &pg.SASLInitialResponseMessage{Mechanism: auth.SCRAMSHA256, Data: data}
+
+		case *pg.AuthenticationSASLContinueMessage:
+			if scram == nil {
+				return errors.New("session: unexpected SASL challenge from the database")
+			}
+
+			data, err := scram.Continue(v.Data)
+
+			if err != nil {
+				return err
+			}
+
+			response = &pg.SASLResponseMessage{Data: data}
+
+		case *pg.AuthenticationSASLFinalMessage:
+			if scram == nil {
+				return errors.New("session: unexpected SASL outcome from the database")
+			}
+
+			err = scram.Final(v.Data)
+
+			if err != nil {
+				return fmt.Errorf("session: database SCRAM signature: %w", err)
+			}
+
+		case *pg.ErrorResponseMessage:
+			return fmt.Errorf("session: database authentication failed: %s", errorMessageText(v))
+
+		default:
+			return fmt.Errorf("session: unsupported database authentication request %T", msg)
+		}
+
+		if response != nil {
+			err = conn.SendMessage(response)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// recvDBStartupMessage receives the next message from the database before the session pumps are started.
+func (s *Session) recvDBStartupMessage() (pg.Message, error) {
+	select {
+	// NB: establishDBConnection may have received the first message already
+	case msg := <-s.dbIn:
+		return msg, nil
+
+	default:
+		return s.dbConn.RecvBackendMessage()
+	}
+}
+
+// withDBUser returns the copy of the startup message with the user from the database URL.
+func (s *Session) withDBUser(startupMessage *pg.StartupMessage) (*pg.StartupMessage, error) {
+	user, err := s.getDBConnnectionParam("user")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return withStartupParameter(startupMessage, "user", user), nil
+}
+
+// Secret key of the process used to derive SCRAM salts (see scramSalt)
+var scramSaltKey = func() []byte {
+	key := make([]byte, 32)
+
+	_, err := rand.Read(key)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}()
+
+// scramSalt returns the salt of the SCRAM secrets that Gevulot computes for the user (i.e., of plain-text
+// passwords and of unknown users). The salt is derived from the user name as PostgreSQL's scram_mock_salt does,
+// so that it stays the same from one attempt to another, whether the user exists or not.
+func scramSalt(user string) []byte {
+	mac := hmac.New(sha256.New, scramSaltKey)
+	_, _ = mac.Write([]byte(user))
+
+	// NB: the salt length of the secrets created by PostgreSQL
+	return mac.Sum(nil)[:16]
+}
+
+// newMockSCRAMSecret returns a secret of a random password for the unknown user.
+func newMockSCRAMSecret(user string) (*auth.SCRAMSecret, error) {
+	password := make([]byte, 32)
+
+	_, err := rand.Read(password)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewSCRAMSecretWithSalt(string(password), scramSalt(user)), nil
+}
+
+// errorMessageText returns the message field of the error.
+func errorMessageText(msg *pg.ErrorResponseMessage) string {
+	for _, field := range msg.Fields {
+		if field.Type == pg.MessageFieldMessage {
+			return field.Value
+		}
+	}
+
+	return ""
+}
+
+// containsString returns true if the slice contains the string.
+func containsString(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/auth"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// testAuthSession returns a session with the given client connection.
+func testAuthSession(client net.Conn) *Session {
+	session := NewSession(client, nil, nil, nil)
+	session.dbConnectionParams = pg.ConnectionParams{"user": "gevulot", "password": "db-secret"}
+
+	return session
+}
+
+// scramLogin authenticates with SCRAM-SHA-256 over the connection and returns the final server message.
+func scramLogin(t *testing.T, conn *pg.Conn, password string) pg.Message {
+	t.Helper()
+
+	msg, err := conn.RecvBackendMessage()
+	require.NoError(t, err)
+	require.Equal(t, &pg.AuthenticationSASLMessage{Mechanisms: []string{auth.SCRAMSHA256}}, msg)
+
+	client := auth.NewSCRAMClient(password)
+	clientFirst, err := client.First()
+	require.NoError(t, err)
+	require.NoError(t, conn.SendMessage(&pg.SASLInitialResponseMessage{Mechanism: auth.SCRAMSHA256, Data: clientFirst}))
+
+	msg, err = conn.RecvBackendMessage()
+	require.NoError(t, err)
+	require.IsType(t, &pg.AuthenticationSASLContinueMessage{}, msg)
+
+	clientFinal, err := client.Continue(msg.(*pg.AuthenticationSASLContinueMessage).Data)
+	require.NoError(t, err)
+	require.NoError(t, conn.SendMessage(&pg.SASLResponseMessage{Data: clientFinal}))
+
+	msg, err = conn.RecvBackendMessage()
+	require.NoError(t, err)
+
+	if final, ok := msg.(*pg.AuthenticationSASLFinalMessage); ok {
+		assert.NoError(t, client.Final(final.Data))
+	}
+
+	return msg
+}
+
+func TestSessionAuthenticateClient(t *testing.T) {
+	secret, err := auth.NewSCRAMSecret("pencil")
+	require.NoError(t, err)
+
+	config := &AuthConfig{Users: []*auth.User{
+		{Name: "alice", Password: "pencil"},
+		{Name: "bob", Password: secret.String()},
+		{Name: "carol", Password: auth.MD5Password("carol", "pencil")},
+	}}
+
+	authenticate := func(config *AuthConfig, user string, login func(conn *pg.Conn) pg.Message) (pg.Message, error) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		done := make(chan error, 1)
+
+		go func() {
+			startup := &pg.StartupMessage{Parameters: []*pg.StartupMessageParameter{{Name: "user", Value: user}}}
+			done <- testAuthSession(server).authenticateClient(startup, config)
+
+			// NB: nothing is sent to the client after successful MD5 authentication
+			server.Close()
+		}()
+
+		msg := login(pg.NewConn(client))
+
+		return msg, <-done
+	}
+
+	scram := func(password string) func(conn *pg.Conn) pg.Message {
+		return func(conn *pg.Conn) pg.Message { return scramLogin(t, conn, password) }
+	}
+
+	md5 := func(user, password string) func(conn *pg.Conn) pg.Message {
+		return func(conn *pg.Conn) pg.Message {
+			msg, err := conn.RecvBackendMessage()
+			require.NoError(t, err)
+			require.IsType(t, &pg.AuthenticationMD5PasswordMessage{}, msg)
+
+			salt := msg.(*pg.AuthenticationMD5PasswordMessage).Salt
+			require.NoError(t, conn.SendMessage(&pg.PasswordMessage{Password: auth.MD5Response(auth.MD5Password(user, password), salt)}))
+
+			// Connection is closed without errors
+			msg, _ = conn.RecvBackendMessage()
+
+			return msg
+		}
+	}
+
+	t.Run("SCRAM", func(t *testing.T) {
+		for _, user := range []string{"alice", "bob"} {
+			msg, err := authenticate(config, user, scram("pencil"))
+			assert.NoError(t, err, user)
+			assert.IsType(t, &pg.AuthenticationSASLFinalMessage{}, msg, user)
+		}
+
+		// Wrong password
+		msg, err := authenticate(config, "alice", scram("pen"))
+		assert.Error(t, err)
+		assert.IsType(t, &pg.ErrorResponseMessage{}, msg)
+
+		// Unknown user and MD5 hash go through the whole exchange
+		for _, user := range []string{"mallory", "carol"} {
+			msg, err := authenticate(config, user, scram("pencil"))
+			assert.Error(t, err, user)
+			assert.IsType(t, &pg.ErrorResponseMessage{}, msg)
+		}
+	})
+
+	t.Run("SCRAM salt", func(t *testing.T) {
+		// serverFirst starts SCRAM exchange and returns the server-first-message without the nonce
+		serverFirst := func(conn *pg.Conn) pg.Message {
+			msg, err := conn.RecvBackendMessage()
+			require.NoError(t, err)
+			require.IsType(t, &pg.AuthenticationSASLMessage{}, msg)
+
+			clientFirst, err := auth.NewSCRAMClient("pencil").First()
+			require.NoError(t, err)
+			require.NoError(t, conn.SendMessage(&pg.SASLInitialResponseMessage{Mechanism: auth.SCRAMSHA256, Data: clientFirst}))
+
+			msg, err = conn.RecvBackendMessage()
+			require.NoError(t, err)
+			require.IsType(t, &pg.AuthenticationSASLContinueMessage{}, msg)
+
+			// The exchange is abandoned
+			conn.Close()
+
+			// NB: the nonce comes first
+			attrs := strings.SplitN(string(msg.(*pg.AuthenticationSASLContinueMessage).Data), ",", 2)
+
+			return &pg.AuthenticationSASLContinueMessage{Data: []byte(attrs[1])}
+		}
+
+		// Salts of plain-text passwords and of unknown users are the same on every attempt
+		for _, user := range []string{"alice", "mallory"} {
+			first, _ := authenticate(config, user, serverFirst)
+			second, _ := authenticate(config, user, serverFirst)
+
+			assert.Equal(t, first, second, user)
+			assert.Contains(t, string(first.(*pg.AuthenticationSASLContinueMessage).Data), ",i=4096", user)
+		}
+
+		first, _ := authenticate(config, "mallory", serverFirst)
+		other, _ := authenticate(config, "trudy", serverFirst)
+		assert.NotEqual(t, first, other)
+	})
+
+	t.Run("MD5", func(t *testing.T) {
+		config := &AuthConfig{Method: AuthMethodMD5, Users: config.Users}
+
+		for _, user := range []string{"alice", "carol"} {
+			msg, err := authenticate(config, user, md5(user, "pencil"))
+			assert.NoError(t, err, user)
+			assert.Nil(t, msg, user)
+		}
+
+		msg, err := authenticate(config, "carol", md5("carol", "pen"))
+		assert.Error(t, err)
+		assert.IsType(t, &pg.ErrorResponseMessage{}, msg)
+
+		// Unknown user gets an MD5 challenge too
+		msg, err = authenticate(config, "mallory", md5("mallory", "pencil"))
+		assert.Error(t, err)
+		assert.IsType(t, &pg.ErrorResponseMessage{}, msg)
+
+		// SCRAM secrets cannot be used with MD5
+		msg, err = authenticate(config, "bob", scram("pencil"))
+		assert.NoError(t, err)
+		assert.IsType(t, &pg.AuthenticationSASLFinalMessage{}, msg)
+	})
+}
+
+func TestSessionAuthenticateDB(t *testing.T) {
+	secret, err := auth.NewSCRAMSecret("db-secret")
+	require.NoError(t, err)
+
+	// authenticate runs authenticateDB against a fake database and returns the message sent to the client
+	authenticate := func(db func(conn *pg.Conn)) (pg.Message, error) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		dbClient, dbServer := net.Pipe()
+		defer dbClient.Close()
+		defer dbServer.Close()
+
+		session := testAuthSession(server)
+		session.dbConn = pg.NewConn(dbClient)
+
+		done := make(chan error, 1)
+
+		go func() {
+			done <- session.authenticateDB()
+		}()
+
+		go db(pg.NewConn(dbServer))
+
+		msg, err := pg.NewConn(client).RecvBackendMessage()
+		require.NoError(t, err)
+
+		return msg, <-done
+	}
+
+	scramDB := func(conn *pg.Conn) {
+		server := auth.NewSCRAMServer(secret)
+
+		_ = conn.SendMessage(&pg.AuthenticationSASLMessage{Mechanisms: []string{"SCRAM-SHA-256-PLUS", auth.SCRAMSHA256}})
+
+		msg, _ := conn.RecvFrontendMessage()
+		serverFirst, err := server.First(msg.(*pg.SASLInitialResponseMessage).Data)
+
+		if err != nil {
+			panic(err)
+		}
+
+		_ = conn.SendMessage(&pg.AuthenticationSASLContinueMessage{Data: serverFirst})
+
+		msg, _ = conn.RecvFrontendMessage()
+		serverFinal, err := server.Final(msg.(*pg.SASLResponseMessage).Data)
+
+		if err != nil {
+			_ = conn.SendMessage(newFatalErrorMessage(sqlStateInvalidPassword, "password authentication failed"))
+			return
+		}
+
+		_ = conn.SendMessage(&pg.AuthenticationSASLFinalMessage{Data: serverFinal})
+		_ = conn.SendMessage(&pg.AuthenticationOkMessage{})
+	}
+
+	t.Run("SCRAM", func(t *testing.T) {
+		msg, err := authenticate(scramDB)
+		assert.NoError(t, err)
+		assert.Equal(t, &pg.AuthenticationOkMessage{}, msg)
+	})
+
+	t.Run("MD5", func(t *testing.T) {
+		msg, err := authenticate(func(conn *pg.Conn) {
+			salt := [4]byte{1, 2, 3, 4}
+			_ = conn.SendMessage(&pg.AuthenticationMD5PasswordMessage{Salt: salt})
+
+			msg, _ := conn.RecvFrontendMessage()
+
+			if msg.(*pg.PasswordMessage).Password == auth.MD5Response(auth.MD5Password("gevulot", "db-secret"), salt) {
+				_ = conn.SendMessage(&pg.AuthenticationOkMessage{})
+			}
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &pg.AuthenticationOkMessage{}, msg)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		secret, err = auth.NewSCRAMSecret("other")
+		require.NoError(t, err)
+
+		msg, err := authenticate(scramDB)
+		assert.Error(t, err)
+
+		// Database error is not forwarded
+		if assert.IsType(t, &pg.ErrorResponseMessage{}, msg) {
+			assert.Equal(t, "could not log into the database", errorMessageText(msg.(*pg.ErrorResponseMessage)))
+		}
+	})
+
+	t.Run("unsupported request", func(t *testing.T) {
+		msg, err := authenticate(func(conn *pg.Conn) {
+			_ = conn.SendMessage(&pg.AuthenticationGSSMessage{})
+		})
+
+		assert.Error(t, err)
+		assert.IsType(t, &pg.ErrorResponseMessage{}, msg)
+	})
+}
+
+func TestSessionWithDBUser(t *testing.T) {
+	startup := &pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters: []*pg.StartupMessageParameter{
+			{Name: "user", Value: "alice"},
+			{Name: "database", Value: "hired_dev"},
+		},
+	}
+
+	msg, err := testAuthSession(nil).withDBUser(startup)
+	require.NoError(t, err)
+
+	assert.Equal(t, "gevulot", msg.GetParameter("user"))
+	assert.Equal(t, "hired_dev", msg.GetParameter("database"))
+
+	// The original message is not modified
+	assert.Equal(t, "alice", startup.GetParameter("user"))
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// issueBackendKey remembers the database's secret-key data and returns the key data issued to the client instead.
+func (s *Session) issueBackendKey(key *pg.BackendKeyDataMessage) (*pg.BackendKeyDataMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.backendKey = key
+
+	if s.cancels == nil {
+		return key, nil
+	}
+
+	if s.issuedKey == nil {
+		issuedKey, err := s.cancels.register(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.issuedKey = issuedKey
+	}
+
+	return s.issuedKey, nil
+}
+
+// forwardCancelRequest cancels the query of the session that the client's key data has been issued for.
+// As PostgreSQL does, nothing is sent back to the client, even if the key is unknown.
+func (s *Session) forwardCancelRequest(msg *pg.CancelRequestMessage) {
+	if s.cancels == nil {
+		return
+	}
+
+	target, ok := s.cancels.lookup(msg.ProcessID, msg.Key)
+
+	if !ok {
+		log.Warn("session: cancel request with unknown key data")
+		return
+	}
+
+	err := target.cancelQuery()
+
+	if err != nil {
+		log.Errorf("session: error forwarding cancel request: %v", err)
+	}
+}
+
+// cancelQuery sends CancelRequest with the backend's secret-key data to the database over a new connection.
+func (s *Session) cancelQuery() error {
+	s.mu.Lock()
+	key := s.backendKey
+	params := s.dbConnectionParams
+	s.mu.Unlock()
+
+	if key == nil {
+		return nil
+	}
+
+	tlsConfig, err := newDBTLSConfig(params)
+
+	if err != nil {
+		return err
+	}
+
+	useTLS := tlsConfig.mode != sslModeDisable && tlsConfig.mode != sslModeAllow
+
+	conn, err := connectDB(params, tlsConfig, useTLS)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	log.Infof("session: cancelling query of backend %d", key.ProcessID)
+
+	return conn.SendMessage(&pg.CancelRequestMessage{ProcessID: key.ProcessID, Key: key.Key})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+func TestCancelRegistry(t *testing.T) {
+	registry := newCancelRegistry()
+	session := &Session{}
+
+	key, err := registry.register(session)
+	require.NoError(t, err)
+	assert.True(t, key.ProcessID > 0)
+
+	other, err := registry.register(&Session{})
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	found, ok := registry.lookup(key.ProcessID, key.Key)
+	assert.True(t, ok)
+	assert.Same(t, session, found)
+
+	// Both the process ID and the key must match
+	_, ok = registry.lookup(key.ProcessID, key.Key+1)
+	assert.False(t, ok)
+
+	registry.unregister(key)
+
+	_, ok = registry.lookup(key.ProcessID, key.Key)
+	assert.False(t, ok)
+}
+
+func TestSessionIssueBackendKey(t *testing.T) {
+	backendKey := &pg.BackendKeyDataMessage{ProcessID: 28822, Key: -636998870}
+
+	// Without registry the key is forwarded as is
+	session := &Session{}
+
+	key, err := session.issueBackendKey(backendKey)
+	require.NoError(t, err)
+	assert.Same(t, backendKey, key)
+
+	session = &Session{cancels: newCancelRegistry()}
+
+	key, err = session.issueBackendKey(backendKey)
+	require.NoError(t, err)
+	assert.NotEqual(t, backendKey, key)
+	assert.Same(t, backendKey, session.backendKey)
+
+	found, ok := session.cancels.lookup(key.ProcessID, key.Key)
+	assert.True(t, ok)
+	assert.Same(t, session, found)
+}
+
+func TestSessionForwardCancelRequest(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	received := make(chan pg.Message, 1)
+
+	go func() {
+		conn, err := l.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		msg, _ := pg.NewConn(conn).RecvInitialMessage()
+		received <- msg
+	}()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	registry := newCancelRegistry()
+	backendKey := &pg.BackendKeyDataMessage{ProcessID: 28822, Key: -636998870}
+
+	// Session running the query
+	target := &Session{cancels: registry, dbConnectionParams: pg.ConnectionParams{"host": host, "port": port, "sslmode": "disable"}}
+
+	issuedKey, err := target.issueBackendKey(backendKey)
+	require.NoError(t, err)
+
+	// Session serving the cancel request
+	client, server := net.Pipe()
+	defer client.Close()
+
+	session := NewSession(server, nil, nil, nil)
+	session.cancels = registry
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- session.negotiateSessionParams()
+	}()
+
+	require.NoError(t, pg.NewConn(client).SendMessage(&pg.CancelRequestMessage{ProcessID: issuedKey.ProcessID, Key: issuedKey.Key}))
+	assert.Equal(t, errCancelRequestServed, <-done)
+
+	// The database gets the real key
+	assert.Equal(t, &pg.CancelRequestMessage{ProcessID: backendKey.ProcessID, Key: backendKey.Key}, <-received)
+}
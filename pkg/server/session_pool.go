@@ -0,0 +1,340 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// Startup message parameters that are not session parameters
+var nonSettingParameters = map[string]bool{
+	"user":        true,
+	"database":    true,
+	"options":     true,
+	"replication": true,
+}
+
+// Session parameters reported with ParameterStatus that clients can change with SET
+var reportedSettings = map[string]bool{
+	"application_name":            true,
+	"client_encoding":             true,
+	"DateStyle":                   true,
+	"IntervalStyle":               true,
+	"standard_conforming_strings": true,
+	"TimeZone":                    true,
+}
+
+// isReportedSetting returns true if the session parameter is reported with ParameterStatus.
+func isReportedSetting(name string) bool {
+	for setting := range reportedSettings {
+		if strings.EqualFold(setting, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinPool completes the startup of a pooled session: the client gets the parameters and the secret-key data
+// as if it was connected to the database, but database connections are only leased for a transaction at a time.
+func (s *Session) joinPool(startupMessage *pg.StartupMessage, config *Config) error {
+	if s.pools == nil {
+		return s.rejectClient(errors.New("session: connection pooling is not available"), sqlStateInternalError,
+			"could not connect to the database")
+	}
+
+	url, _ := config.databaseURL(s.database)
+	urls := append([]string{url}, config.replicaURLs(s.database)...)
+
+	for node, url := range urls {
+		pool, err := s.pools.get(s.database, node, url, config.Pool)
+
+		if err != nil {
+			return s.rejectClient(err, sqlStateInternalError, "could not read the configuration")
+		}
+
+		if node == 0 {
+			s.pool = pool
+		} else {
+			s.replicaPools = append(s.replicaPools, pool)
+		}
+	}
+
+	s.settings = make(map[string]string)
+
+	for _, param := range startupMessage.Parameters {
+		if nonSettingParameters[param.Name] {
+			if param.Name == "options" {
+				log.Warn("session: command-line options are not supported by pooled sessions; ignoring")
+			}
+
+			continue
+		}
+
+		s.settings[param.Name] = param.Value
+	}
+
+	// NB: invalid session parameters fail the startup as they do in PostgreSQL
+	err := s.leaseBackend(s.readOnly)
+
+	if err != nil {
+		return s.rejectClient(err, sqlStateConnectionFailure, "could not connect to the database")
+	}
+
+	// NB: the connection is closed rather than reused if the startup fails
+	defer s.releaseBackend()
+
+	backend := s.backend
+
+	key, err := s.issueBackendKey(backend.key)
+
+	if err != nil {
+		return err
+	}
+
+	messages := []pg.Message{&pg.AuthenticationOkMessage{}}
+
+	names := make([]string, 0, len(backend.parameters))
+
+	for name := range backend.parameters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		messages = append(messages, &pg.ParameterStatusMessage{Name: name, Value: backend.parameters[name]})
+	}
+
+	messages = append(messages, key, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	// The session is established
+	_, err = s.queries.backendMessage(&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	if err != nil {
+		return err
+	}
+
+	s.ready.Fire()
+
+	for _, msg := range messages {
+		err = s.clientConn.SendMessage(msg)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// leaseBackend leases a database connection and sets the session parameters on it. Read-only transactions
+// try the replicas in random order first and fall back to the primary.
+func (s *Session) leaseBackend(readOnly bool) error {
+	var (
+		backend *backendConn
+		err     error
+	)
+
+	if readOnly {
+		for _, i := range rand.Perm(len(s.replicaPools)) {
+			backend, err = s.acquireBackend(s.replicaPools[i])
+
+			if err == nil {
+				break
+			}
+
+			log.Warnf("session: replica %s is not available: %v", s.replicaPools[i].params["host"], err)
+		}
+	}
+
+	if backend == nil {
+		backend, err = s.acquireBackend(s.pool)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("session: leased database backend %d", backend.key.ProcessID)
+
+	s.mu.Lock()
+	s.backendKey = backend.key
+	s.dbConnectionParams = backend.params
+	s.mu.Unlock()
+
+	s.backend = backend
+	s.queries.resetBackend()
+
+	return nil
+}
+
+// acquireBackend leases a database connection from the pool and sets the session parameters on it: the reported
+// ones are applied with SET, the rest are restored by running the client's SET statements again.
+func (s *Session) acquireBackend(pool *backendPool) (*backendConn, error) {
+	backend, err := pool.acquire(s.closed.Done())
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = backend.apply(s.settings)
+
+	if statements := s.queries.sessionSettings(); err == nil && len(statements) > 0 {
+		err = backend.exec(strings.Join(statements, "; "))
+	}
+
+	if err != nil {
+		pool.release(backend, false)
+		return nil, err
+	}
+
+	return backend, nil
+}
+
+// releaseBackend returns the leased database connection to the pool. The connection is only reused
+// if the session is not in the middle of a transaction.
+func (s *Session) releaseBackend() {
+	if s.backend == nil {
+		return
+	}
+
+	log.Debugf("session: released database backend %d", s.backend.key.ProcessID)
+
+	s.mu.Lock()
+	s.backendKey = nil
+	s.mu.Unlock()
+
+	s.backend.pool.release(s.backend, s.queries.idle())
+	s.backend = nil
+}
+
+// backendIn returns the channel of messages from the leased database connection or nil if there is none.
+func (s *Session) backendIn() <-chan pg.Message {
+	if s.backend == nil {
+		return nil
+	}
+
+	return s.backend.in
+}
+
+// startPooledProcessing dispatches the request/response flow of a pooled session. A database connection is
+// leased on the first request and released as soon as the database is ready for a query outside of
+// a transaction block. Messages are sent to the client directly, so that errors are delivered before
+// the session is closed.
+func (s *Session) startPooledProcessing() error {
+	defer s.releaseBackend()
+
+	for {
+		select {
+		case clientMsg, ok := <-s.clientIn:
+			if !ok {
+				return nil
+			}
+
+			// NB: pooled connections outlive the session
+			if _, ok := clientMsg.(*pg.TerminateMessage); ok {
+				return nil
+			}
+
+			requests := []pg.Message{clientMsg}
+
+			// NB: the connection is chosen for the whole request up to Sync, since the rest of it is sent
+			// to the same connection
+			if s.backend == nil {
+				if s.bufferPipeline(clientMsg) {
+					continue
+				}
+
+				requests, s.pipeline = s.pipeline, nil
+
+				err := s.leaseBackend(s.readOnly || s.readOnlyRequest(requests))
+
+				if err != nil {
+					s.sendFatalError(sqlStateConnectionFailure, "could not connect to the database")
+					return err
+				}
+			}
+
+			for _, request := range requests {
+				messages, err := s.queries.frontendMessage(request)
+
+				if err != nil {
+					s.sendFatalError(sqlStateProtocolViolation, "protocol violation")
+					return err
+				}
+
+				for _, msg := range messages {
+					err = s.backend.conn.SendMessage(msg)
+
+					if err != nil {
+						s.sendFatalError(sqlStateConnectionFailure, "lost connection to the database")
+						return err
+					}
+				}
+			}
+
+		case dbMsg, ok := <-s.backendIn():
+			if !ok {
+				s.sendFatalError(sqlStateConnectionFailure, "lost connection to the database")
+				return errBackendClosed
+			}
+
+			// The client changed a session parameter (e.g. with SET)
+			if status, ok := dbMsg.(*pg.ParameterStatusMessage); ok {
+				s.backend.parameterStatus(status)
+
+				if reportedSettings[status.Name] {
+					s.backend.settings[status.Name] = status.Value
+					s.settings[status.Name] = status.Value
+				}
+			}
+
+			forward := true
+
+			if !isAsyncMessage(dbMsg) {
+				var err error
+
+				forward, err = s.queries.backendMessage(dbMsg)
+
+				if err != nil {
+					s.sendFatalError(sqlStateConnectionFailure, "lost synchronization with the database")
+					return err
+				}
+			}
+
+			if forward {
+				s.scrubMessage(dbMsg, s.queries.failedQuery)
+
+				err := s.clientConn.SendMessage(dbMsg)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			if _, ok := dbMsg.(*pg.ReadyForQueryMessage); ok && s.queries.idle() {
+				s.releaseBackend()
+			}
+
+		case <-s.maskingUpdated:
+			// NB: result sets in progress keep their maskers; the new policy applies to the next ones
+			s.queries.masking = s.currentMasking()
+		}
+	}
+}
+
+// sendFatalError sends FATAL ErrorResponse to the client; the session is about to be closed, so errors are
+// only logged.
+func (s *Session) sendFatalError(code, message string) {
+	err := s.clientConn.SendMessage(newFatalErrorMessage(code, message))
+
+	if err != nil {
+		log.Debugf("session: can't send error to the client: %v", err)
+	}
+}
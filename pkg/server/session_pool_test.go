@@ -0,0 +1,234 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/auth"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// startPooledSession starts a pooled session of alice and returns the client connection after the startup
+// along with the parameters and the key data received by the client.
+func startPooledSession(t *testing.T, cfg ConfigStore, pools *backendPools, params ...*pg.StartupMessageParameter) (
+	*pg.Conn, map[string]string, *pg.BackendKeyDataMessage, <-chan error) {
+	t.Helper()
+
+	client, server := net.Pipe()
+
+	session := NewSession(server, cfg, nil, nil)
+	session.cancels = newCancelRegistry()
+	session.pools = pools
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- session.Start()
+	}()
+
+	conn := pg.NewConn(client)
+
+	require.NoError(t, conn.SendMessage(&pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters: append([]*pg.StartupMessageParameter{
+			{Name: "user", Value: "alice"},
+			{Name: "database", Value: "hired_dev"},
+		}, params...),
+	}))
+
+	require.IsType(t, &pg.AuthenticationSASLFinalMessage{}, scramLogin(t, conn, "pencil"))
+
+	msg, err := conn.RecvBackendMessage()
+	require.NoError(t, err)
+	require.Equal(t, &pg.AuthenticationOkMessage{}, msg)
+
+	parameters := make(map[string]string)
+
+	var key *pg.BackendKeyDataMessage
+
+	for {
+		msg, err := conn.RecvBackendMessage()
+		require.NoError(t, err)
+
+		switch v := msg.(type) {
+		case *pg.ParameterStatusMessage:
+			parameters[v.Name] = v.Value
+
+		case *pg.BackendKeyDataMessage:
+			key = v
+
+		case *pg.ReadyForQueryMessage:
+			require.Equal(t, pg.TxStatusIdle, v.TxStatus)
+			return conn, parameters, key, done
+
+		default:
+			require.Failf(t, "unexpected message", "%T", msg)
+		}
+	}
+}
+
+// expectMessages receives the given messages.
+func expectMessages(t *testing.T, conn *pg.Conn, messages ...pg.Message) {
+	t.Helper()
+
+	for _, expected := range messages {
+		msg, err := conn.RecvBackendMessage()
+		require.NoError(t, err)
+		require.Equal(t, expected, msg)
+	}
+}
+
+func TestSessionPooled(t *testing.T) {
+	db := startTestPoolDB(t)
+	defer db.close()
+
+	cfg := testConfigStore(t, &Config{
+		DatabaseURL: db.url(),
+		Auth:        &AuthConfig{Users: []*auth.User{{Name: "alice", Password: "pencil"}}},
+		Pool:        &PoolConfig{Size: 1},
+	})
+
+	pools := newBackendPools(nil)
+	defer pools.close()
+
+	// connect starts a pooled session with the application name
+	connect := func(applicationName string) (*pg.Conn, <-chan error) {
+		conn, parameters, key, done := startPooledSession(t, cfg, pools,
+			&pg.StartupMessageParameter{Name: "application_name", Value: applicationName})
+
+		assert.Equal(t, map[string]string{"application_name": applicationName, "server_version": "12.2"}, parameters)
+
+		// The key data is not the one of the database backend
+		assert.NotEqual(t, int32(42), key.Key)
+
+		return conn, done
+	}
+
+	expect := func(conn *pg.Conn, messages ...pg.Message) {
+		t.Helper()
+		expectMessages(t, conn, messages...)
+	}
+
+	alice, aliceDone := connect("alice")
+	bob, bobDone := connect("bob")
+
+	// Alice prepares a statement and starts a transaction that holds the only connection
+	require.NoError(t, alice.SendMessage(&pg.ParseMessage{Name: "s1", Query: "SELECT 1"}))
+	require.NoError(t, alice.SendMessage(&pg.SyncMessage{}))
+	expect(alice, &pg.ParseCompleteMessage{}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	require.NoError(t, alice.SendMessage(&pg.QueryMessage{Query: "BEGIN"}))
+	expect(alice, &pg.CommandCompleteMessage{Tag: "OK"}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusActive})
+
+	// Bob waits for the connection
+	require.NoError(t, bob.SendMessage(&pg.QueryMessage{Query: "SELECT 2"}))
+
+	require.NoError(t, alice.SendMessage(&pg.QueryMessage{Query: "COMMIT"}))
+	expect(alice, &pg.CommandCompleteMessage{Tag: "OK"}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	expect(bob, &pg.CommandCompleteMessage{Tag: "OK"}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	// The statement is prepared again on the connection after the reset
+	require.NoError(t, alice.SendMessage(&pg.BindMessage{Statement: "s1"}))
+	require.NoError(t, alice.SendMessage(&pg.ExecuteMessage{}))
+	require.NoError(t, alice.SendMessage(&pg.SyncMessage{}))
+	expect(alice, &pg.BindCompleteMessage{}, &pg.CommandCompleteMessage{Tag: "OK"}, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle})
+
+	require.NoError(t, alice.SendMessage(&pg.TerminateMessage{}))
+	assert.NoError(t, <-aliceDone)
+
+	// Bob hangs up without Terminate
+	require.NoError(t, bob.Close())
+	<-bobDone
+
+	// Wait until the connection is reset
+	pool, err := pools.get("", 0, db.url(), &PoolConfig{Size: 1})
+	require.NoError(t, err)
+
+	conn, err := pool.acquire(nil)
+	require.NoError(t, err)
+
+	pool.release(conn, false)
+
+	assert.Equal(t, []string{
+		`1: SET "application_name" TO 'alice'`,
+		"1: DISCARD ALL",
+		`1: SET "application_name" TO 'bob'`,
+		"1: DISCARD ALL",
+		`1: SET "application_name" TO 'alice'`,
+		"1: Parse s1",
+		"1: DISCARD ALL",
+		`1: SET "application_name" TO 'alice'`,
+		"1: BEGIN",
+		"1: COMMIT",
+		"1: DISCARD ALL",
+		`1: SET "application_name" TO 'bob'`,
+		"1: SELECT 2",
+		"1: DISCARD ALL",
+		`1: SET "application_name" TO 'alice'`,
+		"1: Parse s1",
+		"1: Bind s1",
+		"1: DISCARD ALL",
+	}, db.recorded())
+}
+
+func TestSessionPooledSessionSettings(t *testing.T) {
+	db := startTestPoolDB(t)
+	defer db.close()
+
+	poolConfig := &PoolConfig{Size: 1}
+
+	cfg := testConfigStore(t, &Config{
+		DatabaseURL: db.url(),
+		Auth:        &AuthConfig{Users: []*auth.User{{Name: "alice", Password: "pencil"}}},
+		Pool:        poolConfig,
+	})
+
+	pools := newBackendPools(nil)
+	defer pools.close()
+
+	conn, _, _, done := startPooledSession(t, cfg, pools)
+
+	for _, query := range []string{"SET search_path TO billing", "BEGIN", "SET statement_timeout = 0", "ROLLBACK", "SELECT 1"} {
+		require.NoError(t, conn.SendMessage(&pg.QueryMessage{Query: query}))
+
+		for {
+			msg, err := conn.RecvBackendMessage()
+			require.NoError(t, err)
+
+			if _, ok := msg.(*pg.ReadyForQueryMessage); ok {
+				break
+			}
+		}
+	}
+
+	require.NoError(t, conn.SendMessage(&pg.TerminateMessage{}))
+	require.NoError(t, <-done)
+
+	// Wait until the connection is reset
+	pool, err := pools.get("", 0, db.url(), poolConfig)
+	require.NoError(t, err)
+
+	backend, err := pool.acquire(nil)
+	require.NoError(t, err)
+
+	pool.release(backend, false)
+
+	// Parameters set by the client are set again on every leased connection unless the transaction is rolled back
+	assert.Equal(t, []string{
+		"1: DISCARD ALL",
+		"1: SET search_path TO billing",
+		"1: DISCARD ALL",
+		"1: SET search_path TO billing",
+		"1: BEGIN",
+		"1: SET statement_timeout = 0",
+		"1: ROLLBACK",
+		"1: DISCARD ALL",
+		"1: SET search_path TO billing",
+		"1: SELECT 1",
+		"1: DISCARD ALL",
+	}, db.recorded())
+}
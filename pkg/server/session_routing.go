@@ -0,0 +1,187 @@
+package server
+
+import (
+	"math/rand"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgsql"
+)
+
+// Values of the target_session_attrs startup parameter that request a read-only session
+var readOnlySessionAttrs = map[string]bool{
+	"read-only":      true,
+	"standby":        true,
+	"prefer-standby": true,
+}
+
+// routeReadOnly decides whether the session is routed to the replicas of its database: clients request
+// read-only sessions with the target_session_attrs startup parameter or by the configured application names.
+// It returns the startup message without target_session_attrs, which PostgreSQL doesn't accept.
+func (s *Session) routeReadOnly(startupMessage *pg.StartupMessage, config *Config) *pg.StartupMessage {
+	attrs := startupMessage.GetParameter("target_session_attrs")
+	startupMessage = withoutStartupParameter(startupMessage, "target_session_attrs")
+
+	if len(config.replicaURLs(s.database)) == 0 {
+		return startupMessage
+	}
+
+	routing := config.ReadRouting
+
+	if routing == nil {
+		routing = &ReadRoutingConfig{}
+	}
+
+	switch {
+	case readOnlySessionAttrs[attrs]:
+		s.readOnly = true
+
+	// NB: explicit read-write and primary attributes take precedence over the application name
+	case attrs == "" || attrs == "any":
+		s.readOnly = containsString(routing.ApplicationNames, startupMessage.GetParameter("application_name"))
+	}
+
+	s.routeSelects = routing.Selects
+
+	if s.readOnly {
+		log.Info("session: read-only session is routed to the replicas")
+	}
+
+	return startupMessage
+}
+
+// connectToReplica connects to one of the replicas of the session's database, trying them in random order.
+// It returns false if none of them is available; the session stays connected to the primary then.
+func (s *Session) connectToReplica(startupMessage *pg.StartupMessage, asDBUser bool) bool {
+	config, err := s.cfg.Get()
+
+	if err != nil {
+		log.Errorf("session: can't read the configuration: %v", err)
+		return false
+	}
+
+	primary, err := s.getDBConnectionParams()
+
+	if err != nil {
+		log.Errorf("session: can't read the configuration: %v", err)
+		return false
+	}
+
+	urls := config.replicaURLs(s.database)
+
+	for _, i := range rand.Perm(len(urls)) {
+		params, err := pg.ParseConnString(urls[i])
+
+		if err != nil {
+			log.Errorf("session: invalid replica URL: %v", err)
+			continue
+		}
+
+		params = preferStandby(params)
+
+		s.setDBConnectionParams(params)
+
+		msg := startupMessage
+
+		if asDBUser {
+			msg = withStartupParameter(startupMessage, "user", params["user"])
+		}
+
+		err = s.establishDBConnection(msg)
+
+		if err == nil {
+			log.Infof("session: connected to replica %s", params["host"])
+			return true
+		}
+
+		log.Warnf("session: replica %s is not available: %v", params["host"], err)
+	}
+
+	log.Warn("session: no replica is available; falling back to the primary")
+
+	s.setDBConnectionParams(primary)
+
+	return false
+}
+
+// setDBConnectionParams changes the database connection parameters of the session.
+func (s *Session) setDBConnectionParams(params pg.ConnectionParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dbConnectionParams = params
+}
+
+// bufferPipeline appends the client's message to the pipeline of requests waiting for a connection. It returns
+// true if the connection cannot be chosen yet: extended query messages of pooled sessions that may be routed
+// to the replicas are buffered until Sync (or Flush, which needs the responses right away).
+func (s *Session) bufferPipeline(msg pg.Message) bool {
+	s.pipeline = append(s.pipeline, msg)
+
+	if s.readOnly || !s.routeSelects || len(s.replicaPools) == 0 {
+		return false
+	}
+
+	switch msg.(type) {
+	case *pg.ParseMessage, *pg.BindMessage, *pg.DescribeMessage, *pg.ExecuteMessage, *pg.CloseMessage:
+		return true
+	}
+
+	return false
+}
+
+// readOnlyRequest returns true if the request starting a transaction of a pooled session can be routed to
+// the replicas: a simple query consisting of a single pure SELECT, or extended query messages up to Sync
+// that only execute pure SELECT statements.
+func (s *Session) readOnlyRequest(requests []pg.Message) bool {
+	if !s.routeSelects || len(s.replicaPools) == 0 || len(requests) == 0 {
+		return false
+	}
+
+	if query, ok := requests[0].(*pg.QueryMessage); ok {
+		return len(requests) == 1 && isReadOnlyQuery(query.Query)
+	}
+
+	if _, ok := requests[len(requests)-1].(*pg.SyncMessage); !ok {
+		return false
+	}
+
+	// Queries of the statements prepared by the request
+	prepared := make(map[string]string)
+
+	for _, request := range requests {
+		switch v := request.(type) {
+		case *pg.ParseMessage:
+			if !isReadOnlyQuery(v.Query) {
+				return false
+			}
+
+			prepared[v.Name] = v.Query
+
+		case *pg.BindMessage:
+			if _, ok := prepared[v.Statement]; ok {
+				continue
+			}
+
+			statement, ok := s.queries.statements[v.Statement]
+
+			if !ok || !isReadOnlyQuery(statement.query) {
+				return false
+			}
+
+		case *pg.DescribeMessage, *pg.ExecuteMessage, *pg.CloseMessage, *pg.SyncMessage:
+
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// isReadOnlyQuery returns true if the query consists of a single pure SELECT statement.
+func isReadOnlyQuery(query string) bool {
+	statements := pgsql.SplitStatements(query)
+	return len(statements) == 1 && statements[0].IsReadOnlySelect()
+}
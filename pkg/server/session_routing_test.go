@@ -0,0 +1,272 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/auth"
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// closedDatabaseURL returns URL of a database that refuses connections.
+func closedDatabaseURL(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	return fmt.Sprintf("postgres://gevulot@%s/hired_dev?sslmode=disable", l.Addr())
+}
+
+func TestSessionRouteReadOnly(t *testing.T) {
+	config := &Config{
+		DatabaseURL: "postgres://gevulot@localhost/hired_dev",
+		ReplicaURLs: []string{"postgres://gevulot@replica/hired_dev"},
+		ReadRouting: &ReadRoutingConfig{ApplicationNames: []string{"metabase"}, Selects: true},
+		Databases: map[string]*DatabaseConfig{
+			"analytics": {URL: "postgres://gevulot@analytics/analytics_production"},
+		},
+	}
+
+	cfg := testConfigStore(t, config)
+
+	tests := []struct {
+		name            string
+		database        string
+		attrs           string
+		applicationName string
+		readOnly        bool
+	}{
+		{name: "read-write session"},
+		{name: "read-only attrs", attrs: "read-only", readOnly: true},
+		{name: "standby attrs", attrs: "prefer-standby", readOnly: true},
+		{name: "application name", applicationName: "metabase", readOnly: true},
+		{name: "application name with any attrs", attrs: "any", applicationName: "metabase", readOnly: true},
+		{name: "application name with read-write attrs", attrs: "read-write", applicationName: "metabase"},
+		{name: "database without replicas", database: "analytics", attrs: "read-only"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := NewSession(nil, cfg, nil, nil)
+			session.database = tt.database
+
+			params := []*pg.StartupMessageParameter{{Name: "user", Value: "alice"}}
+
+			if tt.attrs != "" {
+				params = append(params, &pg.StartupMessageParameter{Name: "target_session_attrs", Value: tt.attrs})
+			}
+
+			if tt.applicationName != "" {
+				params = append(params, &pg.StartupMessageParameter{Name: "application_name", Value: tt.applicationName})
+			}
+
+			msg := session.routeReadOnly(&pg.StartupMessage{ProtocolVersion: pg.DefaultProtocolVersion, Parameters: params}, config)
+
+			assert.Equal(t, tt.readOnly, session.readOnly)
+			assert.Equal(t, tt.database == "", session.routeSelects)
+
+			// PostgreSQL doesn't accept target_session_attrs
+			assert.Equal(t, "", msg.GetParameter("target_session_attrs"))
+			assert.Equal(t, "alice", msg.GetParameter("user"))
+		})
+	}
+}
+
+func TestSessionConnectToReplica(t *testing.T) {
+	startup := &pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters:      []*pg.StartupMessageParameter{{Name: "user", Value: "alice"}},
+	}
+
+	t.Run("available replica", func(t *testing.T) {
+		primary, primaryStartup := startTestDB(t, 'N', nil)
+		defer primary.Close()
+
+		replica, replicaStartup := startTestDB(t, 'N', nil)
+		defer replica.Close()
+
+		replicaURL := fmt.Sprintf("postgres://replicator@%s/hired_dev?sslmode=disable", replica.Addr())
+
+		session := NewSession(nil, testConfigStore(t, &Config{
+			DatabaseURL: fmt.Sprintf("postgres://gevulot@%s/hired_dev?sslmode=disable", primary.Addr()),
+			ReplicaURLs: []string{closedDatabaseURL(t), replicaURL},
+		}), nil, nil)
+
+		session.readOnly = true
+
+		require.NoError(t, session.connectToDB(startup, true))
+		defer session.dbConn.Close()
+
+		// The user of the replica URL is used
+		assert.Equal(t, "replicator", (<-replicaStartup).GetParameter("user"))
+		assert.Equal(t, replica.Addr().String(), session.dbConnectionParams["host"]+":"+session.dbConnectionParams["port"])
+
+		select {
+		case <-primaryStartup:
+			assert.Fail(t, "the primary is connected")
+		default:
+		}
+	})
+
+	t.Run("falls back to the primary", func(t *testing.T) {
+		primary, primaryStartup := startTestDB(t, 'N', nil)
+		defer primary.Close()
+
+		session := NewSession(nil, testConfigStore(t, &Config{
+			DatabaseURL: fmt.Sprintf("postgres://gevulot@%s/hired_dev?sslmode=disable", primary.Addr()),
+			ReplicaURLs: []string{closedDatabaseURL(t)},
+		}), nil, nil)
+
+		session.readOnly = true
+
+		require.NoError(t, session.connectToDB(startup, false))
+		defer session.dbConn.Close()
+
+		assert.Equal(t, "alice", (<-primaryStartup).GetParameter("user"))
+		assert.Equal(t, primary.Addr().String(), session.dbConnectionParams["host"]+":"+session.dbConnectionParams["port"])
+	})
+}
+
+func TestSessionPooledReadRouting(t *testing.T) {
+	primary := startTestPoolDB(t)
+	defer primary.close()
+
+	replica := startTestPoolDB(t)
+	defer replica.close()
+
+	// run runs the requests in a pooled session and returns the requests received by the primary and the replica
+	run := func(replicaURL string, requests ...[]pg.Message) ([]string, []string) {
+		poolConfig := &PoolConfig{Size: 1}
+
+		cfg := testConfigStore(t, &Config{
+			DatabaseURL: primary.url(),
+			ReplicaURLs: []string{replicaURL},
+			ReadRouting: &ReadRoutingConfig{Selects: true},
+			Auth:        &AuthConfig{Users: []*auth.User{{Name: "alice", Password: "pencil"}}},
+			Pool:        poolConfig,
+		})
+
+		pools := newBackendPools(nil)
+		defer pools.close()
+
+		conn, _, _, done := startPooledSession(t, cfg, pools)
+
+		for _, request := range requests {
+			for _, msg := range request {
+				require.NoError(t, conn.SendMessage(msg))
+			}
+
+			for {
+				msg, err := conn.RecvBackendMessage()
+				require.NoError(t, err)
+				require.NotEqual(t, "*pg.ErrorResponseMessage", fmt.Sprintf("%T", msg))
+
+				if _, ok := msg.(*pg.ReadyForQueryMessage); ok {
+					break
+				}
+			}
+		}
+
+		require.NoError(t, conn.SendMessage(&pg.TerminateMessage{}))
+		require.NoError(t, <-done)
+
+		// Wait until the connections are reset
+		for node, url := range []string{primary.url(), replicaURL} {
+			pool, err := pools.get("", node, url, poolConfig)
+			require.NoError(t, err)
+
+			if backend, err := pool.acquire(nil); err == nil {
+				pool.release(backend, false)
+			}
+		}
+
+		return primary.recorded(), replica.recorded()
+	}
+
+	// queries returns simple query requests
+	queries := func(queries ...string) [][]pg.Message {
+		requests := make([][]pg.Message, len(queries))
+
+		for i, query := range queries {
+			requests[i] = []pg.Message{&pg.QueryMessage{Query: query}}
+		}
+
+		return requests
+	}
+
+	t.Run("routes selects outside transactions", func(t *testing.T) {
+		primaryRequests, replicaRequests := run(replica.url(), queries(
+			"SELECT 1", "INSERT INTO users VALUES (1)", "BEGIN", "SELECT 2", "COMMIT", "SELECT nextval('users_id_seq')")...)
+
+		assert.Equal(t, []string{
+			"1: DISCARD ALL",
+			"1: INSERT INTO users VALUES (1)",
+			"1: DISCARD ALL",
+			"1: BEGIN",
+			"1: SELECT 2",
+			"1: COMMIT",
+			"1: DISCARD ALL",
+			"1: SELECT nextval('users_id_seq')",
+			"1: DISCARD ALL",
+		}, primaryRequests)
+
+		assert.Equal(t, []string{"1: SELECT 1", "1: DISCARD ALL"}, replicaRequests)
+	})
+
+	t.Run("falls back to the primary", func(t *testing.T) {
+		before := len(primary.recorded())
+
+		primaryRequests, _ := run(closedDatabaseURL(t), queries("SELECT 3")...)
+
+		assert.Equal(t, []string{"2: DISCARD ALL", "2: SELECT 3", "2: DISCARD ALL"}, primaryRequests[before:])
+	})
+
+	t.Run("routes pipelines up to Sync", func(t *testing.T) {
+		primaryBefore, replicaBefore := len(primary.recorded()), len(replica.recorded())
+
+		primaryRequests, replicaRequests := run(replica.url(),
+			[]pg.Message{
+				&pg.ParseMessage{Name: "select", Query: "SELECT 1"},
+				&pg.BindMessage{Statement: "select"},
+				&pg.ExecuteMessage{},
+				&pg.SyncMessage{},
+			},
+			[]pg.Message{
+				&pg.ParseMessage{Name: "select", Query: "SELECT 1"},
+				&pg.BindMessage{Statement: "select"},
+				&pg.ExecuteMessage{},
+				&pg.ParseMessage{Name: "insert", Query: "INSERT INTO users VALUES (1)"},
+				&pg.BindMessage{Statement: "insert"},
+				&pg.ExecuteMessage{},
+				&pg.SyncMessage{},
+			},
+			[]pg.Message{
+				&pg.BindMessage{Statement: "select"},
+				&pg.ExecuteMessage{},
+				&pg.FlushMessage{},
+				&pg.SyncMessage{},
+			},
+		)
+
+		// The pipeline with a write and the flushed one go to the primary
+		assert.Equal(t, []string{
+			"3: DISCARD ALL",
+			"3: Parse select",
+			"3: Bind select",
+			"3: Parse insert",
+			"3: Bind insert",
+			"3: DISCARD ALL",
+			"3: Parse select",
+			"3: Bind select",
+			"3: DISCARD ALL",
+		}, primaryRequests[primaryBefore:])
+
+		assert.Equal(t, []string{"2: Parse select", "2: Bind select", "2: DISCARD ALL"}, replicaRequests[replicaBefore:])
+	})
+}
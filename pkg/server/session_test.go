@@ -0,0 +1,197 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/masking"
+	"github.com/hired/gevulot/pkg/pg"
+	"github.com/hired/gevulot/pkg/pgmeta"
+)
+
+// Fake events table OID
+const eventsTableOID = 787970
+
+// testConfigStore returns ConfigStore serving the given config.
+func testConfigStore(t *testing.T, config *Config) ConfigStore {
+	t.Helper()
+
+	src := make(chan *Config, 1)
+	src <- config
+
+	cfg := NewConfigDistributor(src)
+
+	_, err := cfg.Get()
+	require.NoError(t, err)
+
+	return cfg
+}
+
+func TestSessionRouteDatabase(t *testing.T) {
+	config := &Config{
+		DatabaseURL: "postgres://gevulot@localhost/hired_dev",
+		Mask:        []*masking.Rule{{Table: "users", Column: "email", Strategy: "redact"}},
+		Databases: map[string]*DatabaseConfig{
+			"analytics": {
+				URL:  "postgres://gevulot@analytics.example.com/analytics_production",
+				Mask: []*masking.Rule{{Table: "events", Column: "ip", Strategy: "redact"}},
+			},
+			"reports": {URL: "host=reports.example.com port=5433 dbname='reports production' user=gevulot"},
+		},
+	}
+
+	cfg := testConfigStore(t, config)
+
+	// route runs routeDatabase and returns the message sent to the client if any
+	route := func(params ...*pg.StartupMessageParameter) (*Session, *pg.StartupMessage, pg.Message, error) {
+		client, server := net.Pipe()
+		defer client.Close()
+
+		session := NewSession(server, cfg, nil, nil)
+		startup := &pg.StartupMessage{ProtocolVersion: pg.DefaultProtocolVersion, Parameters: params}
+
+		done := make(chan error, 1)
+
+		var msg *pg.StartupMessage
+
+		go func() {
+			var err error
+
+			msg, err = session.routeDatabase(startup)
+			server.Close()
+
+			done <- err
+		}()
+
+		response, _ := pg.NewConn(client).RecvBackendMessage()
+
+		return session, msg, response, <-done
+	}
+
+	user := &pg.StartupMessageParameter{Name: "user", Value: "alice"}
+
+	t.Run("default database", func(t *testing.T) {
+		session, msg, response, err := route(user, &pg.StartupMessageParameter{Name: "database", Value: "hired_dev"})
+		require.NoError(t, err)
+
+		assert.Nil(t, response)
+		assert.Equal(t, "", session.database)
+		assert.Equal(t, "hired_dev", msg.GetParameter("database"))
+		assert.Equal(t, "localhost", session.dbConnectionParams["host"])
+	})
+
+	t.Run("configured database", func(t *testing.T) {
+		session, msg, response, err := route(user, &pg.StartupMessageParameter{Name: "database", Value: "analytics"})
+		require.NoError(t, err)
+
+		assert.Nil(t, response)
+		assert.Equal(t, "analytics", session.database)
+		assert.Equal(t, "alice", msg.GetParameter("user"))
+		assert.Equal(t, "analytics_production", msg.GetParameter("database"))
+		assert.Equal(t, "analytics.example.com", session.dbConnectionParams["host"])
+
+		// The database has its own masking rules and columns
+		events := pgmeta.Table{Schema: "public", Name: "events"}
+		resolver := testColumnResolver{pgmeta.NewColumnCatalog(map[oid.Oid][]*pgmeta.Column{
+			eventsTableOID: {{Table: events, Name: "ip", Index: 1, TypeOID: oid.T_text, TypeName: "text"}},
+		})}
+
+		session.identity = &clientIdentity{}
+		session.columns = func(database string) masking.ColumnResolver {
+			assert.Equal(t, "analytics", database)
+			return resolver
+		}
+
+		require.NoError(t, session.initMasking())
+
+		desc := &pg.RowDescriptionMessage{Fields: []*pg.FieldDescriptor{{Name: "ip", TableOID: eventsTableOID, ColumnIndex: 1}}}
+		assert.NotNil(t, session.currentMasking().RowMasker(desc))
+	})
+
+	t.Run("keyword/value connection string", func(t *testing.T) {
+		session, msg, response, err := route(user, &pg.StartupMessageParameter{Name: "database", Value: "reports"})
+		require.NoError(t, err)
+
+		assert.Nil(t, response)
+		assert.Equal(t, "reports production", msg.GetParameter("database"))
+		assert.Equal(t, "reports.example.com", session.dbConnectionParams["host"])
+		assert.Equal(t, "5433", session.dbConnectionParams["port"])
+	})
+
+	t.Run("unknown database", func(t *testing.T) {
+		_, _, response, err := route(user, &pg.StartupMessageParameter{Name: "database", Value: "billing"})
+		assert.Error(t, err)
+
+		if assert.IsType(t, &pg.ErrorResponseMessage{}, response) {
+			assert.Equal(t, `database "billing" does not exist`, errorMessageText(response.(*pg.ErrorResponseMessage)))
+		}
+
+		// Database name defaults to the user name
+		_, _, response, err = route(user)
+		assert.Error(t, err)
+
+		if assert.IsType(t, &pg.ErrorResponseMessage{}, response) {
+			assert.Equal(t, `database "alice" does not exist`, errorMessageText(response.(*pg.ErrorResponseMessage)))
+		}
+	})
+}
+
+func TestWithStartupParameter(t *testing.T) {
+	startup := &pg.StartupMessage{
+		ProtocolVersion: pg.DefaultProtocolVersion,
+		Parameters:      []*pg.StartupMessageParameter{{Name: "user", Value: "alice"}},
+	}
+
+	msg := withStartupParameter(startup, "user", "gevulot")
+	assert.Equal(t, "gevulot", msg.GetParameter("user"))
+	assert.Equal(t, "alice", startup.GetParameter("user"))
+
+	msg = withStartupParameter(startup, "database", "hired_dev")
+	assert.Equal(t, "alice", msg.GetParameter("user"))
+	assert.Equal(t, "hired_dev", msg.GetParameter("database"))
+	assert.Len(t, startup.Parameters, 1)
+}
+
+func TestSessionProcessingOrder(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	session := NewSession(server, testConfigStore(t, &Config{}), nil, nil)
+	session.queries = newQueryTracker(nil)
+
+	done := make(chan error, 1)
+	go func() { done <- session.startProcessing() }()
+
+	session.dbIn <- &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle}
+	assert.Equal(t, &pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle}, <-session.clientOut)
+
+	session.clientIn <- &pg.QueryMessage{Query: "SET client_encoding = 'LATIN1'; SELECT 1"}
+	assert.Equal(t, &pg.QueryMessage{Query: "SET client_encoding = 'LATIN1'; SELECT 1"}, <-session.dbOut)
+
+	// Asynchronous messages keep their place in the response
+	responses := []pg.Message{
+		&pg.NoticeResponseMessage{Fields: []*pg.MessageField{{Type: pg.MessageFieldMessage, Value: "hello"}}},
+		&pg.ParameterStatusMessage{Name: "client_encoding", Value: "LATIN1"},
+		&pg.CommandCompleteMessage{Tag: "SET"},
+		&pg.RowDescriptionMessage{Fields: []*pg.FieldDescriptor{{Name: "?column?", DataTypeOID: oid.T_int4}}},
+		&pg.DataRowMessage{Values: [][]byte{[]byte("1")}},
+		&pg.CommandCompleteMessage{Tag: "SELECT 1"},
+		&pg.NotificationResponseMessage{ProcessID: 42, Channel: "events", Payload: "created"},
+		&pg.ReadyForQueryMessage{TxStatus: pg.TxStatusIdle},
+	}
+
+	for _, msg := range responses {
+		session.dbIn <- msg
+	}
+
+	for _, msg := range responses {
+		assert.Equal(t, msg, <-session.clientOut)
+	}
+
+	close(session.dbIn)
+	assert.NoError(t, <-done)
+}
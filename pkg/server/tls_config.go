@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Client certificate verification modes.
+const (
+	// ClientAuthRequire requires clients to present a certificate signed by the client CA.
+	ClientAuthRequire = "require"
+
+	// ClientAuthVerifyIfGiven verifies client certificates if clients present them.
+	ClientAuthVerifyIfGiven = "verify-if-given"
+)
+
+// TLSConfig configures TLS termination of client connections. Clients that send SSLRequest are refused
+// if TLS is not configured.
+type TLSConfig struct {
+	// Path to the PEM encoded server certificate (optionally followed by the intermediate certificates).
+	Cert string
+
+	// Path to the PEM encoded private key of the certificate.
+	Key string
+
+	// Path to the PEM encoded CA bundle used to verify client certificates (optional).
+	ClientCA string `toml:"client-ca"`
+
+	// Client certificate verification mode: "require" (default) or "verify-if-given".
+	// Only used if the client CA is set.
+	ClientAuth string `toml:"client-auth"`
+
+	// Contents of the files read by Load
+	certPEM     []byte
+	keyPEM      []byte
+	clientCAPEM []byte
+}
+
+// Load resolves paths relative to the given directory (i.e., the directory of the config file) and reads
+// the certificate, key and CA files. Loaded contents make changes of the files visible to the config
+// subscribers.
+func (c *TLSConfig) Load(dir string) error {
+	if c == nil {
+		return nil
+	}
+
+	var err error
+
+	for _, file := range []struct {
+		path     *string
+		contents *[]byte
+	}{
+		{&c.Cert, &c.certPEM},
+		{&c.Key, &c.keyPEM},
+		{&c.ClientCA, &c.clientCAPEM},
+	} {
+		if *file.path == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(*file.path) {
+			*file.path = filepath.Join(dir, *file.path)
+		}
+
+		*file.contents, err = ioutil.ReadFile(*file.path)
+
+		if err != nil {
+			return fmt.Errorf("server: can't read TLS file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Files returns paths of the certificate, key and CA files.
+func (c *TLSConfig) Files() []string {
+	if c == nil {
+		return nil
+	}
+
+	var files []string
+
+	for _, path := range []string{c.Cert, c.Key, c.ClientCA} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
+
+// Equal returns true if both configs refer to the same files with the same contents.
+func (c *TLSConfig) Equal(other *TLSConfig) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+
+	return c.Cert == other.Cert && c.Key == other.Key && c.ClientCA == other.ClientCA && c.ClientAuth == other.ClientAuth &&
+		bytes.Equal(c.certPEM, other.certPEM) && bytes.Equal(c.keyPEM, other.keyPEM) && bytes.Equal(c.clientCAPEM, other.clientCAPEM)
+}
+
+// serverConfig builds TLS configuration of client connections. It returns nil if TLS is not configured.
+// Files that have not been loaded with Load are read from the disk.
+func (c *TLSConfig) serverConfig() (*tls.Config, error) {
+	if c == nil || (c.Cert == "" && c.Key == "") {
+		return nil, nil
+	}
+
+	if c.Cert == "" || c.Key == "" {
+		return nil, fmt.Errorf("server: both TLS certificate and key must be set")
+	}
+
+	certPEM, err := readPEM(c.Cert, c.certPEM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := readPEM(c.Key, c.keyPEM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+
+	if err != nil {
+		return nil, fmt.Errorf("server: invalid TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCA == "" {
+		return config, nil
+	}
+
+	caPEM, err := readPEM(c.ClientCA, c.clientCAPEM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+
+	if !config.ClientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("server: no certificates found in the client CA bundle %s", c.ClientCA)
+	}
+
+	switch c.ClientAuth {
+	case "", ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	case ClientAuthVerifyIfGiven:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+
+	default:
+		return nil, fmt.Errorf("server: unknown client-auth mode %q", c.ClientAuth)
+	}
+
+	return config, nil
+}
+
+// readPEM returns the loaded contents of the file or reads it from the disk.
+func readPEM(path string, loaded []byte) ([]byte, error) {
+	if loaded != nil {
+		return loaded, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("server: can't read TLS file: %w", err)
+	}
+
+	return contents, nil
+}
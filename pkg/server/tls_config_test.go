@@ -0,0 +1,239 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hired/gevulot/pkg/pg"
+)
+
+// writeTestCertificate generates a self-signed certificate and writes it with its key into the given directory.
+func writeTestCertificate(t *testing.T, dir, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600))
+}
+
+func testTLSDir(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+
+	writeTestCertificate(t, dir, "server")
+	writeTestCertificate(t, dir, "client")
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestTLSConfigLoad(t *testing.T) {
+	dir, cleanup := testTLSDir(t)
+	defer cleanup()
+
+	config := &TLSConfig{Cert: "server.crt", Key: filepath.Join(dir, "server.key"), ClientCA: "client.crt"}
+	require.NoError(t, config.Load(dir))
+
+	// Relative paths are resolved against the directory
+	assert.Equal(t, []string{
+		filepath.Join(dir, "server.crt"),
+		filepath.Join(dir, "server.key"),
+		filepath.Join(dir, "client.crt"),
+	}, config.Files())
+
+	assert.NotEmpty(t, config.certPEM)
+	assert.NotEmpty(t, config.keyPEM)
+	assert.NotEmpty(t, config.clientCAPEM)
+
+	// Missing file
+	assert.Error(t, (&TLSConfig{Cert: "missing.crt"}).Load(dir))
+
+	// Not configured
+	var nilConfig *TLSConfig
+
+	assert.NoError(t, nilConfig.Load(dir))
+	assert.Empty(t, nilConfig.Files())
+}
+
+func TestTLSConfigEqual(t *testing.T) {
+	dir, cleanup := testTLSDir(t)
+	defer cleanup()
+
+	load := func() *TLSConfig {
+		config := &TLSConfig{Cert: "server.crt", Key: "server.key"}
+		require.NoError(t, config.Load(dir))
+
+		return config
+	}
+
+	var nilConfig *TLSConfig
+
+	assert.True(t, nilConfig.Equal(nil))
+	assert.False(t, nilConfig.Equal(load()))
+	assert.False(t, load().Equal(nil))
+	assert.True(t, load().Equal(load()))
+
+	// Different settings
+	changed := load()
+	changed.ClientAuth = ClientAuthVerifyIfGiven
+	assert.False(t, load().Equal(changed))
+
+	// Contents of the files have changed
+	old := load()
+	writeTestCertificate(t, dir, "server")
+	assert.False(t, old.Equal(load()))
+}
+
+func TestTLSConfigServerConfig(t *testing.T) {
+	dir, cleanup := testTLSDir(t)
+	defer cleanup()
+
+	serverConfig := func(config *TLSConfig) (*tls.Config, error) {
+		require.NoError(t, config.Load(dir))
+		return config.serverConfig()
+	}
+
+	// Not configured
+	config, err := serverConfig(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	// Without client certificates
+	config, err = serverConfig(&TLSConfig{Cert: "server.crt", Key: "server.key"})
+	require.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+
+	// Client certificates are required by default
+	config, err = serverConfig(&TLSConfig{Cert: "server.crt", Key: "server.key", ClientCA: "client.crt"})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	assert.NotNil(t, config.ClientCAs)
+
+	config, err = serverConfig(&TLSConfig{Cert: "server.crt", Key: "server.key", ClientCA: "client.crt", ClientAuth: "verify-if-given"})
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth)
+
+	// Invalid configs
+	_, err = serverConfig(&TLSConfig{Cert: "server.crt"})
+	assert.Error(t, err)
+
+	_, err = serverConfig(&TLSConfig{Cert: "server.crt", Key: "client.key"})
+	assert.Error(t, err)
+
+	_, err = serverConfig(&TLSConfig{Cert: "server.crt", Key: "server.key", ClientCA: "server.key"})
+	assert.Error(t, err)
+
+	_, err = serverConfig(&TLSConfig{Cert: "server.crt", Key: "server.key", ClientCA: "client.crt", ClientAuth: "maybe"})
+	assert.Error(t, err)
+}
+
+func TestSessionNegotiateTLS(t *testing.T) {
+	dir, cleanup := testTLSDir(t)
+	defer cleanup()
+
+	tlsConfig, err := (&TLSConfig{Cert: filepath.Join(dir, "server.crt"), Key: filepath.Join(dir, "server.key")}).serverConfig()
+	require.NoError(t, err)
+
+	serverCert, err := ioutil.ReadFile(filepath.Join(dir, "server.crt"))
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(serverCert))
+
+	t.Run("TLS handshake", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		session := NewSession(server, nil, nil, tlsConfig)
+		negotiated := make(chan error, 1)
+
+		go func() {
+			negotiated <- session.negotiateTLS()
+		}()
+
+		response := make([]byte, 1)
+		_, err := client.Read(response)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("S"), response)
+
+		tlsClient := tls.Client(client, &tls.Config{ServerName: "localhost", RootCAs: roots, MinVersion: tls.VersionTLS12})
+		require.NoError(t, tlsClient.Handshake())
+		require.NoError(t, <-negotiated)
+
+		// Session continues over TLS
+		_, ok := session.clientConn.Unwrap().(*tls.Conn)
+		assert.True(t, ok)
+
+		// SSLRequest is allowed only once
+		go func() {
+			negotiated <- session.negotiateTLS()
+		}()
+
+		msg, err := pg.NewConn(tlsClient).RecvBackendMessage()
+		require.NoError(t, err)
+		assert.IsType(t, &pg.ErrorResponseMessage{}, msg)
+		assert.Error(t, <-negotiated)
+	})
+
+	t.Run("TLS is disabled", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		session := NewSession(server, nil, nil, nil)
+		negotiated := make(chan error, 1)
+
+		go func() {
+			negotiated <- session.negotiateTLS()
+		}()
+
+		response := make([]byte, 1)
+		_, err := client.Read(response)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("N"), response)
+		require.NoError(t, <-negotiated)
+
+		assert.Equal(t, server, session.clientConn.Unwrap())
+	})
+}
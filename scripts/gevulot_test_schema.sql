@@ -1,5 +1,6 @@
 -- This SQL script (re-)creates test database.
 
+DROP TABLE IF EXISTS events;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS companies;
 
@@ -11,6 +12,19 @@ CREATE TABLE companies (
 CREATE TABLE users (
   id         SERIAL       PRIMARY KEY,
   company_id INTEGER      NOT NULL REFERENCES companies(id),
+  old_id     INTEGER,
   name       VARCHAR(255) NOT NULL,
   email      VARCHAR(255) NOT NULL
 );
+
+-- Dropped columns stay in the catalog; we use this one to check that the inspector handles them.
+ALTER TABLE users DROP COLUMN old_id;
+
+-- Partitioned tables are queried through the parent, so the inspector must return it along with the partitions.
+CREATE TABLE events (
+  id         INTEGER NOT NULL,
+  created_at DATE    NOT NULL,
+  payload    TEXT
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE events_2020 PARTITION OF events FOR VALUES FROM ('2020-01-01') TO ('2021-01-01');